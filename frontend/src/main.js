@@ -6,7 +6,9 @@ import {
   SelectInputZip,
   SelectOutputDir,
   RunEncrypt,
+  ValidateEncrypt,
   RunRecover,
+  CancelRecover,
 } from "./wailsjs/go/gui/App";
 
 const themeMedia = window.matchMedia("(prefers-color-scheme: light)");
@@ -62,6 +64,7 @@ const rec = {
   progress: document.getElementById("recProgress"),
   status: document.getElementById("recStatus"),
   start: document.getElementById("recStart"),
+  cancel: document.getElementById("recCancel"),
 };
 
 const modeEncrypt = document.getElementById("modeEncrypt");
@@ -204,10 +207,16 @@ EventsOn("recover:progress", (payload) => {
   const done = payload.done ?? 0;
   const total = payload.total ?? 0;
   const name = payload.name ?? "";
+  setStatus(rec.status, `${done}/${total}: ${name}`);
+});
+
+EventsOn("recover:bytes", (payload) => {
+  if (!payload) return;
+  const done = payload.done ?? 0;
+  const total = payload.total ?? 0;
   if (total > 0) {
     rec.progress.value = done / total;
   }
-  setStatus(rec.status, `${done}/${total}: ${name}`);
 });
 
 enc.start.addEventListener("click", async () => {
@@ -224,27 +233,6 @@ enc.start.addEventListener("click", async () => {
   const level = parseNumber(enc.level.value, 6);
   const workers = parseNumber(enc.workers.value, cpuCount);
 
-  if (commentSize < 0 || commentSize > 65535) {
-    setStatus(enc.status, "ZIP comment junk must be 0..65535.");
-    return;
-  }
-  if (noiseFiles < 0 || noiseSize < 0) {
-    setStatus(enc.status, "Noise files and size must be >= 0.");
-    return;
-  }
-  if (level < 0 || level > 9) {
-    setStatus(enc.status, "Compression level must be 0..9.");
-    return;
-  }
-  if (workers < 1) {
-    setStatus(enc.status, "Workers must be >= 1.");
-    return;
-  }
-
-  enc.progress.value = 0;
-  setStatus(enc.status, "Starting...");
-  setRunning(encLockables, true);
-
   const cfg = {
     srcDir,
     outZip,
@@ -263,6 +251,16 @@ enc.start.addEventListener("click", async () => {
     includeHidden: enc.includeHidden.checked,
   };
 
+  const problems = await ValidateEncrypt(cfg);
+  if (problems && problems.length > 0) {
+    setStatus(enc.status, problems.join("; "));
+    return;
+  }
+
+  enc.progress.value = 0;
+  setStatus(enc.status, "Starting...");
+  setRunning(encLockables, true);
+
   try {
     const result = await RunEncrypt(cfg);
     setStatus(enc.status, `Done. Files: ${result.total}`);
@@ -298,6 +296,7 @@ rec.start.addEventListener("click", async () => {
   rec.progress.value = 0;
   setStatus(rec.status, "Starting...");
   setRunning(recLockables, true);
+  rec.cancel.disabled = false;
 
   const cfg = {
     inZip,
@@ -324,5 +323,12 @@ rec.start.addEventListener("click", async () => {
     setStatus(rec.status, `Error: ${message}`);
   } finally {
     setRunning(recLockables, false);
+    rec.cancel.disabled = true;
   }
 });
+
+rec.cancel.addEventListener("click", async () => {
+  rec.cancel.disabled = true;
+  setStatus(rec.status, "Cancelling...");
+  await CancelRecover();
+});
@@ -2,6 +2,8 @@
 // This file is automatically generated. DO NOT EDIT
 import {gui} from '../models';
 
+export function CancelRecover():Promise<void>;
+
 export function RunEncrypt(arg1:gui.EncryptConfig):Promise<gui.EncryptResult>;
 
 export function RunRecover(arg1:gui.RecoverConfig):Promise<gui.RecoverResult>;
@@ -13,3 +15,5 @@ export function SelectOutputDir():Promise<string>;
 export function SelectOutputZip():Promise<string>;
 
 export function SelectSourceDir():Promise<string>;
+
+export function ValidateEncrypt(arg1:gui.EncryptConfig):Promise<Array<string>>;
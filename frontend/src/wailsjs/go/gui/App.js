@@ -2,6 +2,10 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function CancelRecover() {
+  return window['go']['gui']['App']['CancelRecover']();
+}
+
 export function RunEncrypt(arg1) {
   return window['go']['gui']['App']['RunEncrypt'](arg1);
 }
@@ -25,3 +29,7 @@ export function SelectOutputZip() {
 export function SelectSourceDir() {
   return window['go']['gui']['App']['SelectSourceDir']();
 }
+
+export function ValidateEncrypt(arg1) {
+  return window['go']['gui']['App']['ValidateEncrypt'](arg1);
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type batchOptions struct {
+	help      bool
+	jobsPath  string
+	parallel  int
+	asJSON    bool
+	verbosity verbosityFlags
+}
+
+func newBatchFlagSet(output io.Writer) (*flag.FlagSet, *batchOptions) {
+	opts := &batchOptions{parallel: 1}
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.jobsPath, "jobs", "", "Job manifest (JSON)")
+	fs.IntVar(&opts.parallel, "parallel", opts.parallel, "Number of jobs run at the same time")
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the result of every job as JSON on stdout (logs stay on stderr)")
+	addVerbosityFlags(fs, &opts.verbosity)
+	return fs, opts
+}
+
+func printBatchHelp(w io.Writer) {
+	fs, _ := newBatchFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip batch -jobs <manifest.json> [-parallel N] [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Runs the noise mode once per job of the manifest:")
+	fmt.Fprintln(w, `  {"defaults": {"noise-files": 20}, "jobs": [{"src": "a", "out": "a.zip"}, {"src": "b", "out": "b.zip", "seed": 7}]}`)
+	fmt.Fprintln(w, "Jobs take the keys of a config file; their keys override the defaults.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+// batchManifest is the file passed to -jobs. Every job is a config file of
+// its own, laid over defaults.
+type batchManifest struct {
+	Version  *int         `json:"version"`
+	Defaults *fileConfig  `json:"defaults"`
+	Jobs     []fileConfig `json:"jobs"`
+}
+
+// batchJobResult is the outcome of one job, in manifest order.
+type batchJobResult struct {
+	Src   string `json:"src"`
+	Out   string `json:"out"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Files int    `json:"files,omitempty"`
+}
+
+func readBatchManifest(path string) (*batchManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	data, warnings, err := upgradeConfig(stripJSONComments(data), true)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, w := range warnings {
+		printWarning("config: %s: %s", path, w)
+	}
+	var m batchManifest
+	if err := decodeStrict(data, &m, append(slices.Clone(configKeys), "defaults", "jobs")); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(m.Jobs) == 0 {
+		return nil, fmt.Errorf("%s lists no jobs", path)
+	}
+	if m.Defaults != nil {
+		if err := m.Defaults.validate(); err != nil {
+			return nil, fmt.Errorf("%s: defaults: %w", path, err)
+		}
+	}
+	for i := range m.Jobs {
+		if err := m.Jobs[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: job %d: %w", path, i+1, err)
+		}
+	}
+	return &m, nil
+}
+
+func runBatch(args []string) int {
+	fs, opts := newBatchFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printBatchHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printBatchHelp(os.Stdout)
+		return 0
+	}
+	if strings.TrimSpace(opts.jobsPath) == "" {
+		printError("-jobs is required")
+		printBatchHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.parallel < 1 {
+		printError("-parallel must be at least 1")
+		return ExitUsage
+	}
+	manifest, err := readBatchManifest(opts.jobsPath)
+	if err != nil {
+		printError("jobs: %v", err)
+		return ExitUsage
+	}
+
+	if err := opts.verbosity.openLogs("batch", opts.asJSON); err != nil {
+		return openLogsFailed(err)
+	}
+	level := opts.verbosity.level()
+	ctx, stop := interruptContext()
+	defer stop()
+	results := make([]batchJobResult, len(manifest.Jobs))
+	sem := make(chan struct{}, opts.parallel)
+	var wg sync.WaitGroup
+	for i := range manifest.Jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = runBatchJob(ctx, manifest.Defaults, &manifest.Jobs[i], i, len(manifest.Jobs), level)
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if !r.OK {
+			failed++
+		}
+	}
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "batch", OK: failed == 0, Report: results})
+	} else {
+		out := level.stdout()
+		for _, r := range results {
+			if r.OK {
+				fmt.Fprintf(out, "OK    %s (%d files)\n", r.Out, r.Files)
+			} else {
+				fmt.Fprintf(out, "FAIL  %s: %s\n", r.Out, r.Error)
+			}
+		}
+		fmt.Fprintf(out, "Jobs: %d, done: %d, failed: %d\n", len(results), len(results)-failed, failed)
+	}
+	switch {
+	case ctx.Err() != nil:
+		return ExitInterrupted
+	case failed == len(results):
+		return ExitFailure
+	case failed > 0:
+		return ExitPartial
+	}
+	return 0
+}
+
+// runBatchJob runs job n of total: the flag defaults, then the manifest
+// defaults, then the job's own keys.
+func runBatchJob(ctx context.Context, defaults, job *fileConfig, n, total int, level verbosity) batchJobResult {
+	_, opts := newEncryptFlagSet(io.Discard)
+	applyEncryptConfig(opts, defaults, nil)
+	applyEncryptConfig(opts, job, nil)
+	res := batchJobResult{Src: opts.srcDir, Out: opts.outZip}
+	if res.Out == "" {
+		res.Out = fmt.Sprintf("job %d", n+1)
+	}
+	cfg, err := opts.config()
+	if err != nil {
+		res.Error = err.Error()
+		printError("%s: %v", res.Out, err)
+		return res
+	}
+	res.Out = cfg.OutZip
+	progress, logCb := level.sizedCallbacks(fmt.Sprintf("[%d/%d %s]", n+1, total, cfg.OutZip), sourceSizes(cfg.SrcDir))
+	files, err := core.RunEncryptContext(ctx, cfg, progress, logCb)
+	if errors.Is(err, context.Canceled) {
+		res.Error = "interrupted"
+		return res
+	}
+	if err != nil {
+		res.Error = err.Error()
+		printError("%s: %v", res.Out, err)
+		return res
+	}
+	res.OK = true
+	res.Files = files
+	return res
+}
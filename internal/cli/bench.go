@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type benchOptions struct {
+	help          bool
+	srcDir        string
+	corpus        string
+	recover       bool
+	levels        string
+	workers       string
+	sample        string
+	includeHidden bool
+	tmpDir        string
+	asJSON        bool
+	verbosity     verbosityFlags
+}
+
+func newBenchFlagSet(output io.Writer) (*flag.FlagSet, *benchOptions) {
+	opts := &benchOptions{
+		levels:  "1,6,9",
+		workers: "1," + strconv.Itoa(runtime.NumCPU()),
+		sample:  "64m",
+	}
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.srcDir, "src", "", "Directory to take the sample from")
+	fs.StringVar(&opts.corpus, "corpus", "", trf("Bench a synthetic corpus of -sample bytes instead of -src: %s", strings.Join(core.BenchCorpora(), ", ")))
+	fs.BoolVar(&opts.recover, "recover", false, "Also recover every archive and report the time it took")
+	fs.StringVar(&opts.levels, "levels", opts.levels, "Comma-separated deflate levels to try (store is always tried)")
+	fs.StringVar(&opts.workers, "workers", opts.workers, "Comma-separated worker counts to try (auto and percentages such as 50% work too)")
+	fs.StringVar(&opts.sample, "sample", opts.sample, "Most source data to put in the sample (0 for all of it)")
+	fs.BoolVar(&opts.includeHidden, "include-hidden", false, "Include hidden files")
+	fs.StringVar(&opts.tmpDir, "tmp-dir", "", "Directory for the test archives and temp files (default: OS temp directory)")
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the results as JSON on stdout")
+	addVerbosityFlags(fs, &opts.verbosity)
+	addFlagAliases(fs)
+	return fs, opts
+}
+
+func printBenchHelp(w io.Writer) {
+	fs, _ := newBenchFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip bench -src <dir> | -corpus <name> [-levels 1,6,9] [-workers 1,4] [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Writes a sample of the files with store and each deflate level, at each worker")
+	fmt.Fprintln(w, "count, and reports the ratio and throughput of every setting. No noise is added.")
+	fmt.Fprintln(w, "With -corpus the sample is generated, so results compare across machines and")
+	fmt.Fprintln(w, "releases.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+// parseIntList parses a comma-separated list of integers.
+func parseIntList(val string) ([]int, error) {
+	var out []int
+	for _, item := range splitList(val) {
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", item)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func runBench(args []string) int {
+	fs, opts := newBenchFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printBenchHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printBenchHelp(os.Stdout)
+		return 0
+	}
+	src, corpus := strings.TrimSpace(opts.srcDir), strings.TrimSpace(opts.corpus)
+	if (src == "") == (corpus == "") {
+		printError("one of -src and -corpus is required")
+		printBenchHelp(os.Stderr)
+		return ExitUsage
+	}
+	levels, err := parseIntList(opts.levels)
+	if err != nil {
+		printError("levels: %v", err)
+		return ExitUsage
+	}
+	workers, err := parseWorkersList(opts.workers)
+	if err != nil {
+		printError("workers: %v", err)
+		return ExitUsage
+	}
+	sample, err := parseByteSize(opts.sample)
+	if err != nil {
+		printError("sample: %v", err)
+		return ExitUsage
+	}
+
+	if err := opts.verbosity.openLogs("bench", opts.asJSON); err != nil {
+		return openLogsFailed(err)
+	}
+	level := opts.verbosity.level()
+	progress, _ := level.callbacks()
+	ctx, stop := interruptContext()
+	defer stop()
+	results, err := core.RunBench(ctx, core.BenchConfig{
+		SrcDir:        src,
+		Corpus:        corpus,
+		IncludeHidden: opts.includeHidden,
+		SampleSize:    sample,
+		Levels:        levels,
+		Workers:       workers,
+		TmpDir:        strings.TrimSpace(opts.tmpDir),
+		Recover:       opts.recover,
+	}, progress)
+	if err != nil {
+		return failJSON(opts.asJSON, "bench", err)
+	}
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "bench", OK: true, Report: results})
+		return 0
+	}
+	printBench(level.stdout(), results)
+	return 0
+}
+
+func printBench(w io.Writer, results []core.BenchResult) {
+	if len(results) == 0 {
+		return
+	}
+	withRecover := results[0].RecoverDuration > 0
+	fmt.Fprintf(w, "Sample: %d files, %s\n\n", results[0].Files, core.FormatBytes(results[0].InBytes))
+	fmt.Fprintf(w, "%-8s %5s %7s %10s %8s %12s %9s", "Method", "Level", "Workers", "Size", "Ratio", "Throughput", "Time")
+	if withRecover {
+		fmt.Fprintf(w, " %9s", "Recover")
+	}
+	fmt.Fprintln(w)
+	smallest, fastest := results[0], results[0]
+	for _, r := range results {
+		lvl := "-"
+		if r.Compression == "deflate" {
+			lvl = strconv.Itoa(r.Level)
+		}
+		fmt.Fprintf(w, "%-8s %5s %7d %10s %7.1f%% %10s/s %9s",
+			r.Compression, lvl, r.Workers, core.FormatBytes(r.OutBytes), 100*r.Ratio(),
+			core.FormatBytes(int64(r.Throughput())), r.Duration.Round(time.Millisecond))
+		if withRecover {
+			fmt.Fprintf(w, " %9s", r.RecoverDuration.Round(time.Millisecond))
+		}
+		fmt.Fprintln(w)
+		if r.OutBytes < smallest.OutBytes || (r.OutBytes == smallest.OutBytes && r.Duration < smallest.Duration) {
+			smallest = r
+		}
+		if r.Duration < fastest.Duration {
+			fastest = r
+		}
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "Smallest: %s\n", benchSetting(smallest))
+	fmt.Fprintf(w, "Fastest:  %s\n", benchSetting(fastest))
+}
+
+// benchSetting is r as the noise mode flags that reproduce it.
+func benchSetting(r core.BenchResult) string {
+	if r.Compression == "store" {
+		return fmt.Sprintf("-compression store -workers %d", r.Workers)
+	}
+	return fmt.Sprintf("-compression deflate -level %d -workers %d", r.Level, r.Workers)
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type checksumOptions struct {
+	help       bool
+	configPath string
+	profile    string
+	inZip      stringListFlag
+	digest     string
+	entries    bool
+	encodings  string
+	forceScan  bool
+	junk       stringListFlag
+	junkPrefix string
+	password   passwordFlags
+	asJSON     bool
+}
+
+// checksumReport is the -json report of checksum.
+type checksumReport struct {
+	Algorithm string `json:"algorithm"`
+	Archive   string `json:"archive"`
+	Size      int64  `json:"size"`
+	Digest    string `json:"digest"`
+	// Entries are the recovered files, with -entries.
+	Entries []core.RecoveredEntry `json:"entries,omitempty"`
+}
+
+func newChecksumFlagSet(output io.Writer) (*flag.FlagSet, *checksumOptions) {
+	opts := &checksumOptions{digest: core.DigestSHA256, junkPrefix: ".junk"}
+	fs := flag.NewFlagSet("checksum", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.configPath, "config", "", "Path to JSON config file")
+	fs.StringVar(&opts.profile, "profile", "", "Named profile from the config file to apply over its top-level keys")
+	fs.Var(&opts.inZip, "in", "Input ZIP path, - for stdin (repeat for the parts of a split archive, in order)")
+	fs.StringVar(&opts.digest, "digest", opts.digest, trf("Digest algorithm (%s)", strings.Join(core.DigestAlgorithms, ", ")))
+	fs.BoolVar(&opts.entries, "entries", false, "Also hash the decoded data of every file recover would write")
+	fs.StringVar(&opts.encodings, "encodings", "", trf("Comma-separated charsets tried for non-UTF-8 names (%s)", strings.Join(core.NameDecoders(), ", ")))
+	fs.BoolVar(&opts.forceScan, "force-scan", false, "Ignore the central directory and scan for local headers")
+	fs.Var(&opts.junk, "junk", "Leave out entries matching this glob (repeatable)")
+	fs.StringVar(&opts.junkPrefix, "junk-prefix", opts.junkPrefix, "Directory noise entries were written under (empty disables)")
+	addPasswordFlags(fs, &opts.password, "password", "Password for ZipCrypto and WinZip AES entries")
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the result as JSON on stdout")
+	addFlagAliases(fs)
+	return fs, opts
+}
+
+func printChecksumHelp(w io.Writer) {
+	fs, _ := newChecksumFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip checksum -in <zip> [-digest sha256|blake3] [-entries] [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Prints the digest of the archive as sha256sum --tag or b3sum --tag would, so the")
+	fmt.Fprintln(w, "line can be checked later with those tools. -entries adds a line per recovered")
+	fmt.Fprintln(w, "file, named archive:path, hashing the decoded data without writing it.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+func runChecksum(args []string) int {
+	fs, opts := newChecksumFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printChecksumHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printChecksumHelp(os.Stdout)
+		return 0
+	}
+	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
+		cfg, err := readConfig(cfgPath, opts.profile)
+		if err != nil {
+			printError("config: %v", err)
+			return ExitUsage
+		}
+		applyChecksumConfig(opts, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+	if err := opts.password.resolve(false); err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+
+	inParts, err := splitParts(opts.inZip)
+	if err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+	if len(inParts) == 0 {
+		printError("-in is required")
+		printChecksumHelp(os.Stderr)
+		return ExitUsage
+	}
+	// Standard input cannot be read twice, once to hash and once to scan.
+	if opts.entries && slices.Contains(inParts, core.StdinPath) {
+		printError("-entries cannot read the archive from stdin")
+		return ExitUsage
+	}
+	algorithm := strings.ToLower(strings.TrimSpace(opts.digest))
+
+	ctx, stop := interruptContext()
+	defer stop()
+	digest, size, err := core.ChecksumFiles(ctx, inParts, algorithm)
+	if err != nil {
+		return failJSON(opts.asJSON, "checksum", err)
+	}
+	archive := strings.Join(inParts, "+")
+	rep := checksumReport{Algorithm: algorithm, Archive: archive, Size: size, Digest: digest}
+
+	code := ExitOK
+	if opts.entries {
+		recoverCfg := core.RecoverConfig{
+			InZip:     inParts[0],
+			ListOnly:  true,
+			ForceScan: opts.forceScan,
+			Encodings: splitList(opts.encodings),
+			Junk:      junkPatterns(opts.junkPrefix, opts.junk),
+			Password:  opts.password.value,
+			Digest:    algorithm,
+		}
+		if len(inParts) > 1 {
+			recoverCfg.InParts = inParts
+		}
+		recovered, err := core.RecoverZipContext(ctx, recoverCfg, nil, nil)
+		if err != nil {
+			return failJSON(opts.asJSON, "checksum", err)
+		}
+		for _, ent := range recovered.Entries {
+			switch ent.Status {
+			case core.EntryOK, core.EntryTruncated:
+				rep.Entries = append(rep.Entries, ent)
+			case core.EntryFailed, core.EntryInvalid:
+				fmt.Fprintf(os.Stderr, "Failed: %s: %s\n", ent.Name, ent.Error)
+				code = ExitPartial
+			}
+			if ent.Status == core.EntryTruncated {
+				code = ExitPartial
+			}
+		}
+	}
+
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "checksum", OK: code == ExitOK, Kind: exitKinds[code], Report: rep})
+		return code
+	}
+	tag := strings.ToUpper(algorithm)
+	fmt.Printf("%s (%s) = %s\n", tag, archive, digest)
+	for _, ent := range rep.Entries {
+		fmt.Printf("%s (%s:%s) = %s\n", tag, archive, ent.Path, ent.Digest)
+	}
+	return code
+}
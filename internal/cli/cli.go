@@ -1,56 +1,78 @@
 package cli
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
-	"noisyzip/internal/core"
+	"github.com/chekomaid/NoisyZip/internal/core"
 )
 
-func Main(args []string) int {
-	if len(args) == 0 {
-		printEncryptHelp(os.Stdout)
-		return 2
-	}
-	mode := strings.ToLower(strings.TrimSpace(args[0]))
-	if mode == "cli" {
+func Main(args []string) (code int) {
+	defer func() { closeLogs(code) }()
+	if len(args) > 0 && strings.EqualFold(strings.TrimSpace(args[0]), "cli") {
 		args = args[1:]
-		if len(args) == 0 {
-			printEncryptHelp(os.Stdout)
-			return 2
+	}
+	args, err := selectLanguage(args)
+	if err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+	args, pprofAddr, err := selectPprof(args)
+	if err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+	if pprofAddr != "" {
+		if err := startPprof(pprofAddr); err != nil {
+			printError("%v", err)
+			return ExitUsage
 		}
-		mode = strings.ToLower(strings.TrimSpace(args[0]))
 	}
+	if len(args) == 0 {
+		printHelp(os.Stdout)
+		return ExitUsage
+	}
+	name := strings.TrimSpace(args[0])
 
-	switch mode {
-	case "help", "-h", "--help":
+	// Alone, -v is the version; followed by flags it is -verbose.
+	if len(args) == 1 && (name == "-v" || name == "--version") {
+		return runVersion(nil)
+	}
+	switch name {
+	case "-h", "--help":
 		printHelp(os.Stdout)
 		return 0
-	case "version", "-v", "--version":
-		printVersion(os.Stdout)
-		return 0
-	case "recover":
-		return runRecover(args[1:])
-	default:
-		if strings.HasPrefix(mode, "-") {
-			return runEncrypt(args)
-		}
-		fmt.Fprintln(os.Stderr, "Error: unknown command", mode)
-		printHelp(os.Stderr)
-		return 2
 	}
+	if c, ok := lookupCommand(name); ok {
+		return c.run(args[1:])
+	}
+	// Flags without a command are the short form of encrypt.
+	if strings.HasPrefix(name, "-") {
+		return runEncrypt(args)
+	}
+	printError("unknown command %v", name)
+	printHelp(os.Stderr)
+	return ExitUsage
 }
 
 type encryptOptions struct {
 	help                bool
 	configPath          string
+	profile             string
 	srcDir              string
 	outZip              string
+	outTemplate         string
 	compression         string
 	encoding            string
 	overwriteCentralDir bool
@@ -58,11 +80,27 @@ type encryptOptions struct {
 	fixedTime           bool
 	noiseFiles          int
 	noiseSize           int
+	noiseGenerator      string
 	level               int
 	strategy            string
 	workers             int
 	seed                string
 	includeHidden       bool
+	filesFrom           string
+	maxDepth            int
+	tmpDir              string
+	memEntrySize        string
+	memTotal            string
+	bufferSize          string
+	resume              string
+	bait                bool
+	dedup               bool
+	padNames            bool
+	padBucket           string
+	reportPath          string
+	reportPassword      passwordFlags
+	asJSON              bool
+	verbosity           verbosityFlags
 }
 
 func newEncryptFlagSet(output io.Writer) (*flag.FlagSet, *encryptOptions) {
@@ -73,14 +111,19 @@ func newEncryptFlagSet(output io.Writer) (*flag.FlagSet, *encryptOptions) {
 		level:               6,
 		strategy:            "default",
 		workers:             runtime.NumCPU(),
+		memEntrySize:        "4m",
+		memTotal:            "64m",
+		bufferSize:          "1m",
 	}
 	fs := flag.NewFlagSet("encrypt", flag.ContinueOnError)
 	fs.SetOutput(output)
 	fs.BoolVar(&opts.help, "h", false, "Show help")
 	fs.BoolVar(&opts.help, "help", false, "Show help")
 	fs.StringVar(&opts.configPath, "config", "", "Path to JSON config file")
+	fs.StringVar(&opts.profile, "profile", "", "Named profile from the config file to apply over its top-level keys")
 	fs.StringVar(&opts.srcDir, "src", "", "Input directory")
 	fs.StringVar(&opts.outZip, "out", "", "Output ZIP path")
+	fs.StringVar(&opts.outTemplate, "out-template", "", "Output ZIP name with placeholders filled in per run: {date}, {time}, {seed}, {src}, {randN}; -out wins")
 	fs.StringVar(&opts.compression, "compression", opts.compression, "Compression method: deflate or store")
 	fs.StringVar(&opts.compression, "method", opts.compression, "Alias for -compression")
 	fs.StringVar(&opts.encoding, "encoding", opts.encoding, "Filename encoding: utf-8 or cp1251")
@@ -89,18 +132,36 @@ func newEncryptFlagSet(output io.Writer) (*flag.FlagSet, *encryptOptions) {
 	fs.BoolVar(&opts.fixedTime, "fixed-time", false, "Overwrite file timestamps")
 	fs.IntVar(&opts.noiseFiles, "noise-files", 0, "Number of noise files")
 	fs.IntVar(&opts.noiseSize, "noise-size", 0, "Size of each noise file (bytes)")
+	fs.StringVar(&opts.noiseGenerator, "noise-generator", core.DefaultNoiseGenerator, trf("Noise generator: %s", strings.Join(core.NoiseGenerators(), ", ")))
 	fs.IntVar(&opts.level, "level", opts.level, "Deflate level (0-9)")
 	fs.StringVar(&opts.strategy, "strategy", opts.strategy, "Deflate strategy: default, huffman")
-	fs.IntVar(&opts.workers, "workers", opts.workers, "Worker goroutines")
+	fs.Var(workersFlag{&opts.workers}, "workers", "Worker goroutines: a number, auto (one per CPU) or a percentage of the CPUs such as 50%")
 	fs.StringVar(&opts.seed, "seed", "", "Deterministic noise seed (integer)")
 	fs.BoolVar(&opts.includeHidden, "include-hidden", false, "Include hidden files")
+	fs.StringVar(&opts.tmpDir, "tmp-dir", "", "Directory for temp files (default: the OS temp directory)")
+	fs.StringVar(&opts.memEntrySize, "mem-entry-size", opts.memEntrySize, "Stage files up to this size in memory instead of temp files (0 = always use temp files)")
+	fs.StringVar(&opts.memTotal, "mem-total", opts.memTotal, "Memory all files staged in memory may take together")
+	fs.StringVar(&opts.bufferSize, "buffer-size", opts.bufferSize, "Size of the chunks files are read in and of the output write buffer")
+	fs.StringVar(&opts.resume, "resume", "", "Save progress to this file and carry on from it when it exists")
+	fs.StringVar(&opts.filesFrom, "files-from", "", "Archive the files listed in this file (one path per line, relative to -src; - for stdin) instead of walking -src")
+	fs.IntVar(&opts.maxDepth, "max-depth", 0, "Only take files this many directories deep; 1 is -src itself (0 = no limit)")
+	fs.BoolVar(&opts.bait, "bait", false, "Add decoy README.txt/passwords.txt files")
+	fs.BoolVar(&opts.dedup, "dedup", false, "Store the data of identical files once, the copies pointing at it")
+	fs.BoolVar(&opts.padNames, "pad-names", false, "Pad all entry names to a uniform length")
+	fs.StringVar(&opts.padBucket, "pad-bucket", "", "Pad deflated streams to a multiple of this size (e.g. 4k, 64k)")
+	fs.StringVar(&opts.reportPath, "report", "", "Write an obfuscation report (JSON) to this path")
+	addPasswordFlags(fs, &opts.reportPassword, "report-password", "Encrypt the report with this password")
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the result as JSON on stdout (logs stay on stderr)")
+	addVerbosityFlags(fs, &opts.verbosity)
+	addFlagAliases(fs)
 	return fs, opts
 }
 
 type recoverOptions struct {
 	help          bool
 	configPath    string
-	inZip         string
+	profile       string
+	inZip         stringListFlag
 	outZip        string
 	compression   string
 	encoding      string
@@ -109,6 +170,107 @@ type recoverOptions struct {
 	workers       int
 	seed          string
 	includeHidden bool
+	unpadNames    bool
+	noRezip       bool
+	list          bool
+	forceScan     bool
+	encodings     string
+	recurse       int
+	password      passwordFlags
+	only          stringListFlag
+	skip          stringListFlag
+	junk          stringListFlag
+	junkPrefix    string
+	maxMemory     string
+	maxEntrySize  string
+	maxTotalSize  string
+	maxRatio      float64
+	manifest      string
+	keepJunk      string
+	format        string
+	timeout       string
+	comments      string
+	strict        bool
+	order         string
+	pathPolicy    string
+	checkpoint    string
+	tmpDir        string
+	incremental   bool
+	keepMethods   bool
+	asJSON        bool
+	verbosity     verbosityFlags
+}
+
+// stringListFlag collects every occurrence of a repeatable flag.
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(val string) error {
+	*f = append(*f, val)
+	return nil
+}
+
+// flagAliases maps the short forms of common flags to their long names.
+var flagAliases = map[string]string{
+	"s": "src",
+	"o": "out",
+	"i": "in",
+	"w": "workers",
+}
+
+// addFlagAliases registers the short form of each flag of fs that has one.
+// Call it once every flag is defined.
+func addFlagAliases(fs *flag.FlagSet) {
+	for short, long := range flagAliases {
+		if f := fs.Lookup(long); f != nil && fs.Lookup(short) == nil {
+			fs.Var(f.Value, short, trf("Alias for -%s", long))
+		}
+	}
+}
+
+// junkPatterns combines -junk-prefix and -junk into the core pattern list.
+func junkPatterns(junkPrefix string, junk []string) []string {
+	patterns := []string{}
+	if prefix := strings.Trim(strings.TrimSpace(junkPrefix), "/"); prefix != "" {
+		patterns = append(patterns, prefix+"/**")
+	}
+	return append(patterns, junk...)
+}
+
+// splitParts trims the -in values and drops empty ones. Standard input can
+// only be read once, so "-" may appear a single time.
+func splitParts(vals []string) ([]string, error) {
+	var out []string
+	stdin := 0
+	for _, v := range vals {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+		if v == core.StdinPath {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return nil, fmt.Errorf("-in %s can only be given once", core.StdinPath)
+	}
+	return out, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(val string) []string {
+	var out []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
 
 type negatedBoolFlag struct {
@@ -150,76 +312,210 @@ func newRecoverFlagSet(output io.Writer) (*flag.FlagSet, *recoverOptions) {
 	fs.BoolVar(&opts.help, "h", false, "Show help")
 	fs.BoolVar(&opts.help, "help", false, "Show help")
 	fs.StringVar(&opts.configPath, "config", "", "Path to JSON config file")
-	fs.StringVar(&opts.inZip, "in", "", "Input ZIP path")
-	fs.StringVar(&opts.outZip, "out", "", "Output ZIP path")
+	fs.StringVar(&opts.profile, "profile", "", "Named profile from the config file to apply over its top-level keys")
+	fs.Var(&opts.inZip, "in", "Input ZIP path, - for stdin (repeat for the parts of a split archive, in order)")
+	fs.StringVar(&opts.outZip, "out", "", "Output ZIP path (output directory with -no-rezip)")
+	fs.BoolVar(&opts.noRezip, "no-rezip", false, "Write recovered files to the -out directory instead of rebuilding a ZIP")
+	fs.StringVar(&opts.format, "format", "zip", "Output format: zip or tar.gz (tar.gz streams files into the archive without a temp directory)")
+	fs.BoolVar(&opts.list, "list", false, "List recoverable entries without writing anything")
+	fs.Var(&opts.only, "only", "Recover only entries matching this glob (repeatable, ** matches directories)")
+	fs.Var(&opts.skip, "skip", "Skip entries matching this glob (repeatable)")
+	fs.StringVar(&opts.junkPrefix, "junk-prefix", ".junk", "Directory noise entries were written under (empty disables)")
+	fs.StringVar(&opts.maxMemory, "max-memory", "", "Largest decoded entry kept in memory (e.g. 256m); bigger ones stream to disk")
+	fs.StringVar(&opts.maxEntrySize, "max-entry-size", "", "Abandon entries that decode to more than this (e.g. 4g)")
+	fs.StringVar(&opts.maxTotalSize, "max-total-size", "", "Stop writing once the recovered data reaches this size")
+	fs.Float64Var(&opts.maxRatio, "max-ratio", 0, "Abandon entries that expand more than this many times their compressed size")
+	fs.StringVar(&opts.manifest, "manifest", "", "Write a JSON manifest (path, size, SHA-256, offset) of the recovered files")
+	fs.StringVar(&opts.order, "order", core.OrderOffset, "Order of recovered files and of the rebuilt ZIP: offset or name")
+	fs.StringVar(&opts.pathPolicy, "path-policy", core.PathStrip, "What to do with .. components and absolute names: strip, replace (with _) or reject")
+	fs.BoolVar(&opts.strict, "strict", false, "Only accept entries with a verified CRC and an unambiguous name")
+	fs.StringVar(&opts.comments, "comments", "", "Write the archive and entry comments (JSON) to this path")
+	fs.BoolVar(&opts.incremental, "incremental", false, "With -no-rezip, skip entries whose files are already in -out from an earlier -incremental run")
+	fs.StringVar(&opts.checkpoint, "checkpoint", "", "Save progress to this file and resume from it when it exists; the archive is still read whole")
+	fs.StringVar(&opts.tmpDir, "tmp-dir", "", "Directory for the recovered files before the ZIP is rebuilt (default: the OS temp directory)")
+	fs.StringVar(&opts.timeout, "timeout", "", "Give up recovery after this long (e.g. 30s, 10m)")
+	fs.StringVar(&opts.keepJunk, "keep-junk", "", "Write entries classified as junk to this directory for inspection")
+	fs.Var(&opts.junk, "junk", "Treat entries matching this glob as junk (repeatable)")
 	fs.StringVar(&opts.compression, "compression", opts.compression, "Compression method: deflate or store")
 	fs.StringVar(&opts.compression, "method", opts.compression, "Alias for -compression")
 	fs.StringVar(&opts.encoding, "encoding", opts.encoding, "Filename encoding: utf-8 or cp1251")
 	fs.IntVar(&opts.level, "level", opts.level, "Deflate level (0-9)")
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the result (counts, paths, errors, per-entry data) as JSON on stdout; logs stay on stderr")
+	addVerbosityFlags(fs, &opts.verbosity)
+	fs.BoolVar(&opts.keepMethods, "keep-methods", false, "Rebuild each file with its original method and deflate level hint instead of -compression/-level")
 	fs.StringVar(&opts.strategy, "strategy", opts.strategy, "Deflate strategy: default, filtered, huffman, rle, fixed")
-	fs.IntVar(&opts.workers, "workers", opts.workers, "Worker goroutines")
+	fs.Var(workersFlag{&opts.workers}, "workers", "Worker goroutines: a number, auto (one per CPU) or a percentage of the CPUs such as 50%")
 	fs.StringVar(&opts.seed, "seed", "", "Deterministic noise seed (integer)")
 	fs.BoolVar(&opts.includeHidden, "include-hidden", false, "Include hidden files")
+	fs.BoolVar(&opts.unpadNames, "unpad-names", false, "Strip name padding added by -pad-names")
+	fs.BoolVar(&opts.forceScan, "force-scan", false, "Ignore the central directory and scan for local headers")
+	addPasswordFlags(fs, &opts.password, "password", "Password for encrypted entries (ZipCrypto or AES)")
+	fs.IntVar(&opts.recurse, "recurse", 0, "Recover nested ZIPs found inside the archive, up to this depth")
+	fs.StringVar(&opts.encodings, "encodings", "", trf("Comma-separated charsets tried for non-UTF-8 names (%s)", strings.Join(core.NameDecoders(), ", ")))
+	addFlagAliases(fs)
 	return fs, opts
 }
 
-func printHelp(w io.Writer) {
-	fmt.Fprintln(w, "Usage:")
-	fmt.Fprintln(w, "  noisyzip -v")
-	fmt.Fprintln(w, "  noisyzip -src <dir> -out <zip> [options]")
-	fmt.Fprintln(w, "  noisyzip recover -in <zip> -out <zip> [options]")
-	fmt.Fprintln(w, "")
-	fmt.Fprintln(w, "Run noisyzip -h or noisyzip recover -h for options.")
-	fmt.Fprintln(w, "GitHub: https://github.com/chekomaid/NoisyZip/")
-}
-
 func printEncryptHelp(w io.Writer) {
 	fs, _ := newEncryptFlagSet(w)
-	fmt.Fprintln(w, "Usage: noisyzip -src <dir> -out <zip> [options]")
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip encrypt -src <dir> -out <zip> [options]")
+	fmt.Fprintln(w, "       noisyzip -src <dir> -out <zip> [options]")
 	fmt.Fprintln(w, "")
-	fmt.Fprintln(w, "Options:")
-	fs.PrintDefaults()
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
 }
 
 func printRecoverHelp(w io.Writer) {
 	fs, _ := newRecoverFlagSet(w)
-	fmt.Fprintln(w, "Usage: noisyzip recover -in <zip> -out <zip> [options]")
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip recover -in <zip> -out <zip> [options]")
+	fmt.Fprintln(w, "       noisyzip recover -in <file.z01> -in <file.z02> -in <file.zip> -out <zip> [options]")
 	fmt.Fprintln(w, "")
-	fmt.Fprintln(w, "Options:")
-	fs.PrintDefaults()
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
 }
 
 func runEncrypt(args []string) int {
 	fs, opts := newEncryptFlagSet(io.Discard)
 	if err := fs.Parse(args); err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
+		printError("%v", err)
 		printEncryptHelp(os.Stderr)
-		return 2
+		return ExitUsage
 	}
 	if opts.help {
 		printEncryptHelp(os.Stdout)
 		return 0
 	}
 	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
-		cfg, err := readConfig(cfgPath)
+		cfg, err := readConfig(cfgPath, opts.profile)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error: config:", err)
-			return 2
+			printError("config: %v", err)
+			return ExitUsage
 		}
 		applyEncryptConfig(opts, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+	if err := opts.reportPassword.resolve(true); err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+
+	cfg, err := opts.config()
+	if err != nil {
+		printError("%v", err)
+		if errors.Is(err, errSrcOutRequired) {
+			printEncryptHelp(os.Stderr)
+		}
+		if exitCode(err) == ExitIO {
+			return ExitIO
+		}
+		return ExitUsage
 	}
+	outZip := cfg.OutZip
 
+	if err := opts.verbosity.openLogs("encrypt", opts.asJSON); err != nil {
+		return openLogsFailed(err)
+	}
+	level := opts.verbosity.level()
+	progress, logCb := level.sizedCallbacks("", sourceSizes(cfg.SrcDir))
+	ctx, stop := interruptContext()
+	defer stop()
+	stats, err := core.RunEncryptStats(ctx, cfg, progress, logCb)
+	if err != nil {
+		return failJSON(opts.asJSON, "encrypt", err)
+	}
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "encrypt", OK: true, Output: outZip, Files: stats.Entries, Report: stats})
+		return 0
+	}
+	out := level.stdout()
+	fmt.Fprintf(out, "Done. Files: %d\nOutput: %s\n", stats.Entries, outZip)
+	printEncryptStats(out, stats, level >= verbosityVerbose)
+	return 0
+}
+
+// printEncryptStats prints the totals of a run after the Done line. The
+// worker average counts only workers that got a file; perWorker adds a line
+// per worker.
+func printEncryptStats(w io.Writer, s core.EncryptStats, perWorker bool) {
+	fmt.Fprintf(w, "Source:  %d files, %s\n", s.Files, core.FormatBytes(s.InputBytes))
+	fmt.Fprintf(w, "Data:    %s (%.1f%% of the source)\n", core.FormatBytes(s.DataBytes), 100*s.Ratio)
+	if s.Duplicates > 0 {
+		fmt.Fprintf(w, "Dedup:   %d duplicate files, %s saved\n", s.Duplicates, core.FormatBytes(s.DuplicateBytes))
+	}
+	if s.NoiseEntries > 0 {
+		fmt.Fprintf(w, "Noise:   %d entries, %s\n", s.NoiseEntries, core.FormatBytes(s.NoiseBytes))
+	}
+	if s.BaitEntries > 0 {
+		fmt.Fprintf(w, "Bait:    %d entries, %s\n", s.BaitEntries, core.FormatBytes(s.BaitBytes))
+	}
+	fmt.Fprintf(w, "Written: %s\n", core.FormatBytes(s.OutputBytes))
+	var rate float64
+	if s.Duration > 0 {
+		rate = float64(s.InputBytes) / s.Duration.Seconds()
+	}
+	fmt.Fprintf(w, "Time:    %s, %s/s\n", s.Duration.Round(time.Millisecond), core.FormatBytes(int64(rate)))
+	var sum float64
+	busy := 0
+	for _, ws := range s.Workers {
+		if ws.Busy > 0 {
+			sum += ws.Throughput
+			busy++
+		}
+	}
+	if busy > 0 {
+		fmt.Fprintf(w, "Workers: %d, %s/s each on average\n", len(s.Workers), core.FormatBytes(int64(sum/float64(busy))))
+	}
+	if !perWorker {
+		return
+	}
+	for i, ws := range s.Workers {
+		fmt.Fprintf(w, "Worker %d: %d files, %s in %s, %s/s\n", i+1, ws.Files, core.FormatBytes(ws.Bytes), ws.Busy.Round(time.Millisecond), core.FormatBytes(int64(ws.Throughput)))
+	}
+}
+
+var errSrcOutRequired = errors.New("-src and -out are required")
+
+// config checks the noise options and turns them into a core.Config.
+func (opts *encryptOptions) config() (core.Config, error) {
 	src := strings.TrimSpace(opts.srcDir)
 	outZip := strings.TrimSpace(opts.outZip)
+	if tmpl := strings.TrimSpace(opts.outTemplate); outZip == "" && tmpl != "" {
+		expanded, err := expandOutTemplate(tmpl, time.Now(), strings.TrimSpace(opts.seed), src)
+		if err != nil {
+			return core.Config{}, fmt.Errorf("out-template: %w", err)
+		}
+		outZip = expanded
+	}
 	if src == "" || outZip == "" {
-		fmt.Fprintln(os.Stderr, "Error: -src and -out are required")
-		printEncryptHelp(os.Stderr)
-		return 2
+		return core.Config{}, errSrcOutRequired
 	}
 	if !strings.HasSuffix(strings.ToLower(outZip), ".zip") {
 		outZip += ".zip"
 	}
 
+	var padBucket int64
+	if text := strings.TrimSpace(opts.padBucket); text != "" {
+		val, err := parseByteSize(text)
+		if err != nil {
+			return core.Config{}, fmt.Errorf("pad-bucket: %w", err)
+		}
+		padBucket = val
+	}
+	memEntrySize, err := parseByteSize(strings.TrimSpace(opts.memEntrySize))
+	if err != nil {
+		return core.Config{}, fmt.Errorf("mem-entry-size: %w", err)
+	}
+	memTotal, err := parseByteSize(strings.TrimSpace(opts.memTotal))
+	if err != nil {
+		return core.Config{}, fmt.Errorf("mem-total: %w", err)
+	}
+	bufferSize, err := parseByteSize(strings.TrimSpace(opts.bufferSize))
+	if err != nil {
+		return core.Config{}, fmt.Errorf("buffer-size: %w", err)
+	}
+
 	cfg := core.Config{
 		SrcDir:              src,
 		OutZip:              outZip,
@@ -230,95 +526,301 @@ func runEncrypt(args []string) int {
 		FixedTime:           opts.fixedTime,
 		NoiseFiles:          opts.noiseFiles,
 		NoiseSize:           opts.noiseSize,
+		NoiseGenerator:      opts.noiseGenerator,
 		Level:               opts.level,
 		Strategy:            opts.strategy,
 		DictSize:            32768,
 		Workers:             opts.workers,
 		IncludeHidden:       opts.includeHidden,
+		MaxDepth:            opts.maxDepth,
+		Bait:                opts.bait,
+		Dedup:               opts.dedup,
+		PadNames:            opts.padNames,
+		PadBucket:           padBucket,
+		ReportPath:          strings.TrimSpace(opts.reportPath),
+		ReportPassword:      opts.reportPassword.value,
+		TmpDir:              strings.TrimSpace(opts.tmpDir),
+		MemEntrySize:        memEntrySize,
+		MemTotal:            memTotal,
+		BufferSize:          int(bufferSize),
+		Resume:              strings.TrimSpace(opts.resume),
 	}
 
 	seedText := strings.TrimSpace(opts.seed)
 	if seedText != "" {
 		seedVal, err := strconv.ParseInt(seedText, 10, 64)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error: seed must be an integer")
-			return 2
+			return core.Config{}, errors.New("seed must be an integer")
 		}
 		cfg.Seed = seedVal
 		cfg.HasSeed = true
 	}
-
-	progress := func(done, total int, name string) {
-		fmt.Fprintf(os.Stderr, "%d/%d: %s\n", done, total, name)
-	}
-	logCb := func(msg string) {
-		if strings.TrimSpace(msg) == "" {
-			return
+	if from := strings.TrimSpace(opts.filesFrom); from != "" {
+		files, err := readFileList(from)
+		if err != nil {
+			return core.Config{}, fmt.Errorf("files-from: %w", err)
 		}
-		fmt.Fprintln(os.Stderr, msg)
+		cfg.Files = files
 	}
+	return cfg, nil
+}
 
-	total, err := core.RunEncrypt(cfg, progress, logCb)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		return 1
+// readFileList reads the paths of -files-from, one per line, from path or
+// from stdin for "-". Blank lines are skipped; other whitespace is part of
+// the name.
+func readFileList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
 	}
-	fmt.Fprintf(os.Stdout, "Done. Files: %d\nOutput: %s\n", total, outZip)
-	return 0
+	files := []string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if line := strings.TrimRight(sc.Text(), "\r"); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, sc.Err()
 }
 
 func runRecover(args []string) int {
 	fs, opts := newRecoverFlagSet(io.Discard)
 	if err := fs.Parse(args); err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
+		printError("%v", err)
 		printRecoverHelp(os.Stderr)
-		return 2
+		return ExitUsage
 	}
 	if opts.help {
 		printRecoverHelp(os.Stdout)
 		return 0
 	}
 	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
-		cfg, err := readConfig(cfgPath)
+		cfg, err := readConfig(cfgPath, opts.profile)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error: config:", err)
-			return 2
+			printError("config: %v", err)
+			return ExitUsage
 		}
 		applyRecoverConfig(opts, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+	if err := opts.password.resolve(false); err != nil {
+		printError("%v", err)
+		return ExitUsage
 	}
 
-	inZip := strings.TrimSpace(opts.inZip)
+	var limits [3]int64
+	for i, opt := range []struct{ name, text string }{
+		{"max-memory", opts.maxMemory},
+		{"max-entry-size", opts.maxEntrySize},
+		{"max-total-size", opts.maxTotalSize},
+	} {
+		if text := strings.TrimSpace(opt.text); text != "" {
+			val, err := parseByteSize(text)
+			if err != nil {
+				printError("%s: %v", opt.name, err)
+				return ExitUsage
+			}
+			limits[i] = val
+		}
+	}
+	maxMemory, maxEntrySize, maxTotalSize := limits[0], limits[1], limits[2]
+	if opts.maxRatio < 0 {
+		printError("max-ratio must be >= 0")
+		return ExitUsage
+	}
+	var timeout time.Duration
+	if text := strings.TrimSpace(opts.timeout); text != "" {
+		val, err := time.ParseDuration(text)
+		if err != nil || val < 0 {
+			printError("timeout must be a duration such as 30s or 10m")
+			return ExitUsage
+		}
+		timeout = val
+	}
+
+	command := "recover"
+	if opts.list {
+		command = "list"
+	}
+
+	// Ctrl-C stops the recovery cleanly instead of leaving partial output.
+	ctx, stop := interruptContext()
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	inParts, err := splitParts(opts.inZip)
+	if err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+	inZip := ""
+	if len(inParts) > 0 {
+		inZip = inParts[0]
+	}
+	if len(inParts) < 2 {
+		inParts = nil
+	}
 	outZip := strings.TrimSpace(opts.outZip)
+	format := strings.ToLower(strings.TrimSpace(opts.format))
+	switch format {
+	case "", "zip":
+		format = "zip"
+	case "tar.gz", "tgz":
+		format = "tar.gz"
+	default:
+		printError("format must be zip or tar.gz")
+		return ExitUsage
+	}
+	if opts.noRezip && format != "zip" {
+		printError("-format cannot be combined with -no-rezip")
+		return ExitUsage
+	}
+	if opts.checkpoint != "" && (format != "zip" || opts.list) {
+		printError("-checkpoint cannot be combined with -format tar.gz or -list")
+		return ExitUsage
+	}
+	if opts.incremental && (!opts.noRezip || opts.checkpoint != "") {
+		printError("-incremental needs -no-rezip and cannot be combined with -checkpoint")
+		return ExitUsage
+	}
+	recoverCfg := core.RecoverConfig{
+		InZip:        inZip,
+		InParts:      inParts,
+		UnpadNames:   opts.unpadNames,
+		ForceScan:    opts.forceScan,
+		Encodings:    splitList(opts.encodings),
+		Recurse:      opts.recurse,
+		Password:     opts.password.value,
+		Only:         opts.only,
+		Skip:         opts.skip,
+		Junk:         junkPatterns(opts.junkPrefix, opts.junk),
+		MaxMemory:    maxMemory,
+		MaxEntrySize: maxEntrySize,
+		MaxTotalSize: maxTotalSize,
+		MaxRatio:     opts.maxRatio,
+		Manifest:     opts.manifest,
+		Comments:     opts.comments,
+		KeepJunk:     opts.keepJunk,
+		Strict:       opts.strict,
+		Order:        strings.ToLower(strings.TrimSpace(opts.order)),
+		PathPolicy:   strings.ToLower(strings.TrimSpace(opts.pathPolicy)),
+		Checkpoint:   opts.checkpoint,
+		Incremental:  opts.incremental,
+	}
+	if opts.list {
+		if inZip == "" {
+			printError("-in is required")
+			printRecoverHelp(os.Stderr)
+			return ExitUsage
+		}
+		recoverCfg.ListOnly = true
+		rep, err := core.RecoverZipContext(ctx, recoverCfg, nil, nil)
+		if err != nil {
+			return failJSON(opts.asJSON, command, err)
+		}
+		if opts.asJSON {
+			writeJSON(os.Stdout, jsonResult{Command: command, OK: true, Report: rep})
+			return 0
+		}
+		printRecoverList(os.Stdout, rep)
+		return 0
+	}
 	if inZip == "" || outZip == "" {
-		fmt.Fprintln(os.Stderr, "Error: -in and -out are required")
+		printError("-in and -out are required")
 		printRecoverHelp(os.Stderr)
-		return 2
+		return ExitUsage
 	}
-	if !strings.HasSuffix(strings.ToLower(outZip), ".zip") {
+	switch {
+	case opts.noRezip:
+	case format == "tar.gz":
+		lower := strings.ToLower(outZip)
+		if !strings.HasSuffix(lower, ".tar.gz") && !strings.HasSuffix(lower, ".tgz") {
+			outZip += ".tar.gz"
+		}
+	case !strings.HasSuffix(strings.ToLower(outZip), ".zip"):
 		outZip += ".zip"
 	}
 
-	logCb := func(msg string) {
-		if strings.TrimSpace(msg) == "" {
-			return
+	if err := opts.verbosity.openLogs(command, opts.asJSON); err != nil {
+		return openLogsFailed(err)
+	}
+	level := opts.verbosity.level()
+	progress, logCb := level.callbacks()
+
+	if opts.noRezip {
+		recoverCfg.OutDir = outZip
+		rep, err := core.RecoverZipContext(ctx, recoverCfg, progress, logCb)
+		if err != nil {
+			return failJSON(opts.asJSON, command, err)
+		}
+		code := recoverExitCode(rep)
+		if opts.asJSON {
+			writeJSON(os.Stdout, jsonResult{Command: command, OK: code == ExitOK, Kind: exitKinds[code], Output: outZip, Report: rep})
+			return code
 		}
-		fmt.Fprintln(os.Stderr, msg)
+		fmt.Fprintf(level.stdout(), "Recovered: %d\nTruncated: %d\nCRC mismatches: %d\nOutput: %s\n", rep.Recovered, rep.Truncated, rep.BadCRC, outZip)
+		return code
 	}
-	progress := func(done, total int, name string) {
-		fmt.Fprintf(os.Stderr, "%d/%d: %s\n", done, total, name)
+
+	if format == "tar.gz" {
+		if err := os.MkdirAll(filepath.Dir(outZip), 0o755); err != nil {
+			return failJSON(opts.asJSON, command, err)
+		}
+		f, err := os.Create(outZip)
+		if err != nil {
+			return failJSON(opts.asJSON, command, err)
+		}
+		recoverCfg.TarOut = f
+		rep, err := core.RecoverZipContext(ctx, recoverCfg, progress, logCb)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(outZip)
+			return failJSON(opts.asJSON, command, err)
+		}
+		code := recoverExitCode(rep)
+		if opts.asJSON {
+			writeJSON(os.Stdout, jsonResult{Command: command, OK: code == ExitOK, Kind: exitKinds[code], Output: outZip, Report: rep})
+			return code
+		}
+		fmt.Fprintf(level.stdout(), "Recovered: %d\nTruncated: %d\nCRC mismatches: %d\nOutput: %s\n", rep.Recovered, rep.Truncated, rep.BadCRC, outZip)
+		return code
 	}
 
-	tmpDir, err := os.MkdirTemp("", "zip-recover-*")
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		return 1
+	// A resumable run needs its files to outlive the process, so they go
+	// next to the checkpoint instead of into a temp dir.
+	var tmpDir string
+	if opts.checkpoint != "" {
+		tmpDir = opts.checkpoint + ".files"
+	} else {
+		dir, err := os.MkdirTemp(strings.TrimSpace(opts.tmpDir), "zip-recover-*")
+		if err != nil {
+			return failJSON(opts.asJSON, command, err)
+		}
+		defer os.RemoveAll(dir)
+		tmpDir = dir
 	}
-	defer os.RemoveAll(tmpDir)
 
-	recovered, err := core.RecoverZip(inZip, tmpDir, progress, logCb)
+	level.debugf("recovering into %s", tmpDir)
+	recoverCfg.OutDir = tmpDir
+	rep, err := core.RecoverZipContext(ctx, recoverCfg, progress, logCb)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		return 1
+		return failJSON(opts.asJSON, command, err)
+	}
+	if opts.checkpoint != "" {
+		defer os.RemoveAll(tmpDir)
 	}
 
 	cfg := core.Config{
@@ -336,25 +838,75 @@ func runRecover(args []string) int {
 		DictSize:            32768,
 		Workers:             opts.workers,
 		IncludeHidden:       opts.includeHidden,
+		TmpDir:              strings.TrimSpace(opts.tmpDir),
 	}
 
 	seedText := strings.TrimSpace(opts.seed)
 	if seedText != "" {
 		seedVal, err := strconv.ParseInt(seedText, 10, 64)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error: seed must be an integer")
-			return 2
+			printError("seed must be an integer")
+			return ExitUsage
 		}
 		cfg.Seed = seedVal
 		cfg.HasSeed = true
 	}
 
-	rebuilt, err := core.RunEncrypt(cfg, nil, nil)
+	cfg.FileOrder = rep.RecoveredPaths()
+	if opts.keepMethods {
+		cfg.FileMethods = rep.FileMethods()
+	}
+	level.debugf("rebuilding %d files into %s", len(cfg.FileOrder), outZip)
+	rebuilt, err := core.RunEncryptContext(ctx, cfg, nil, nil)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		return 1
+		return failJSON(opts.asJSON, command, err)
 	}
 
-	fmt.Fprintf(os.Stdout, "Recovered: %d\nZIP files: %d\nOutput: %s\n", recovered, rebuilt, outZip)
-	return 0
+	code := recoverExitCode(rep)
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: command, OK: code == ExitOK, Kind: exitKinds[code], Output: outZip, Files: rebuilt, Report: rep})
+		return code
+	}
+	fmt.Fprintf(level.stdout(), "Recovered: %d\nTruncated: %d\nCRC mismatches: %d\nZIP files: %d\nOutput: %s\n", rep.Recovered, rep.Truncated, rep.BadCRC, rebuilt, outZip)
+	return code
+}
+
+func printRecoverList(w io.Writer, rep core.RecoverReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OFFSET\tSIZE\tCRC\tCONF\tSTATUS\tNAME")
+	for _, ent := range rep.Entries {
+		if ent.Status == core.EntryInvalid {
+			continue
+		}
+		size := strconv.FormatInt(ent.Size, 10)
+		crc := ent.CRCCheck
+		conf := strconv.Itoa(ent.Confidence)
+		if ent.Status != core.EntryOK && ent.Status != core.EntryTruncated {
+			size = "-"
+			crc = "-"
+			conf = "-"
+		}
+		name := ent.Name
+		if ent.Path != "" {
+			name = ent.Path
+		}
+		if ent.Error != "" {
+			name += " (" + ent.Error + ")"
+		}
+		if ent.Comment != "" {
+			name += " [comment: " + strconv.Quote(ent.Comment) + "]"
+		}
+		if len(ent.ConfidenceNotes) > 0 && conf != "-" {
+			name += " {" + strings.Join(ent.ConfidenceNotes, "; ") + "}"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", ent.Offset, size, crc, conf, ent.Status, name)
+	}
+	tw.Flush()
+	if rep.Comment != "" {
+		fmt.Fprintf(w, "Comment: %s\n", strconv.Quote(rep.Comment))
+	}
+	for _, p := range rep.Poison {
+		fmt.Fprintf(w, "Poison: %s at %d (%d bytes): %s\n", p.Kind, p.Offset, p.Size, p.Detail)
+	}
+	fmt.Fprintf(w, "Source: %s, headers: %d, recoverable: %d, truncated: %d, nested: %d, junk: %d, skipped: %d, failed: %d, CRC mismatches: %d\n", rep.Source, rep.Headers, rep.Recovered, rep.Truncated, rep.Nested, rep.Junk, rep.Skipped, rep.Failed, rep.BadCRC)
 }
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is one subcommand of noisyzip.
+type command struct {
+	name    string
+	aliases []string
+	summary string
+	run     func(args []string) int
+}
+
+// commands is the command tree, in the order help lists it. Main routes
+// args[0] through it.
+var commands = []command{
+	{name: "encrypt", aliases: []string{"noise"}, summary: "Write a directory as a ZIP with noise, junk and a misleading layout", run: runEncrypt},
+	{name: "batch", summary: "Run encrypt once per job of a manifest", run: runBatch},
+	{name: "decoy", summary: "Write an archive made only of plausible-looking noise", run: runDecoy},
+	{name: "estimate", summary: "Predict the size encrypt would write", run: runEstimate},
+	{name: "bench", summary: "Compare compression levels and worker counts on a sample", run: runBench},
+	{name: "recover", summary: "Strip the noise and rebuild a clean ZIP, directory or tar.gz", run: runRecover},
+	{name: "extract", summary: "Recover just the files, into a directory", run: runExtract},
+	{name: "repair", summary: "Rewrite a standard ZIP around the original data, without recompressing", run: runRepair},
+	{name: "inspect", summary: "Report what was done to an archive", run: runInspect},
+	{name: "checksum", summary: "Print the digest of an archive and, optionally, of each file in it", run: runChecksum},
+	{name: "diff", summary: "Compare the real files of two archives", run: runDiff},
+	{name: "report", summary: "Read a report written by encrypt -report", run: runReport},
+	{name: "encodings", summary: "List filename encodings, or test how a name is stored and read back", run: runEncodings},
+	{name: "init-config", summary: "Write a config file listing every key", run: runInitConfig},
+	{name: "version", summary: "Print the version and build details", run: runVersion},
+}
+
+// help looks commands up itself, so it joins the table in init.
+func init() {
+	commands = append(commands, command{name: "help", summary: "Show this help, or the options of a command", run: runHelp})
+}
+
+// lookupCommand finds a command by name or alias, ignoring case.
+func lookupCommand(name string) (*command, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i := range commands {
+		c := &commands[i]
+		if c.name == name {
+			return c, true
+		}
+		for _, alias := range c.aliases {
+			if alias == name {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip <command> [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Commands:"))
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, tr(c.summary))
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Run noisyzip help <command> or noisyzip <command> -h for its options."))
+	fmt.Fprintln(w, tr("noisyzip -src <dir> -out <zip> [options] still works as a short form of encrypt."))
+	fmt.Fprintln(w, tr("-lang en|ru, before or after the command, sets the language of messages; the default follows LANG."))
+	fmt.Fprintln(w, "GitHub: https://github.com/chekomaid/NoisyZip/")
+}
+
+// runHelp prints the general help, or with a command name that command's.
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printHelp(os.Stdout)
+		return 0
+	}
+	c, ok := lookupCommand(args[0])
+	if !ok || c.name == "help" {
+		printError("unknown command %v", args[0])
+		printHelp(os.Stderr)
+		return ExitUsage
+	}
+	return c.run([]string{"-h"})
+}
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type configSeed struct {
@@ -44,41 +47,264 @@ func (s *configSeed) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// configSize holds a byte size given either as a JSON number or as a string
+// with a unit suffix ("64k", "100m").
+// configStrings accepts a single string or an array of strings.
+type configStrings struct {
+	Values []string
+	Set    bool
+}
+
+func (s *configStrings) UnmarshalJSON(data []byte) error {
+	if s == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(data)) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	var asString string
+	if err := json.Unmarshal(data, &asString); err == nil {
+		s.Values = []string{asString}
+		s.Set = true
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("value must be a string or an array of strings")
+	}
+	s.Values = list
+	s.Set = true
+	return nil
+}
+
+// configWorkers holds a worker count given either as a JSON number or as a
+// string such as "auto" or "50%".
+type configWorkers struct {
+	Value string
+	Set   bool
+}
+
+func (w *configWorkers) UnmarshalJSON(data []byte) error {
+	if w == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(data)) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	var asString string
+	if err := json.Unmarshal(data, &asString); err == nil {
+		w.Value = asString
+		w.Set = true
+		return nil
+	}
+	var num int64
+	if err := json.Unmarshal(data, &num); err != nil {
+		return fmt.Errorf("workers must be a number or a string such as \"auto\"")
+	}
+	w.Value = strconv.FormatInt(num, 10)
+	w.Set = true
+	return nil
+}
+
+type configSize struct {
+	Value string
+	Set   bool
+}
+
+func (s *configSize) UnmarshalJSON(data []byte) error {
+	if s == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(data)) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	var asString string
+	if err := json.Unmarshal(data, &asString); err == nil {
+		s.Value = asString
+		s.Set = true
+		return nil
+	}
+	var num int64
+	if err := json.Unmarshal(data, &num); err != nil {
+		return fmt.Errorf("size must be a string or integer")
+	}
+	s.Value = strconv.FormatInt(num, 10)
+	s.Set = true
+	return nil
+}
+
 type fileConfig struct {
-	SrcDir                *string    `json:"src"`
-	OutZip                *string    `json:"out"`
-	InZip                 *string    `json:"in"`
-	Compression           *string    `json:"compression"`
-	Method                *string    `json:"method"`
-	Encoding              *string    `json:"encoding"`
-	NoOverwriteCentralDir *bool      `json:"no-overwrite-cdir"`
-	CommentSize           *int       `json:"comment-size"`
-	FixedTime             *bool      `json:"fixed-time"`
-	NoiseFiles            *int       `json:"noise-files"`
-	NoiseSize             *int       `json:"noise-size"`
-	Level                 *int       `json:"level"`
-	Strategy              *string    `json:"strategy"`
-	Workers               *int       `json:"workers"`
-	Seed                  configSeed `json:"seed"`
-	IncludeHidden         *bool      `json:"include-hidden"`
-}
-
-func readConfig(path string) (*fileConfig, error) {
+	// Version is the config format the file was written for; readConfig
+	// has upgraded it to configVersion.
+	Version               *int          `json:"version"`
+	SrcDir                *string       `json:"src"`
+	OutZip                *string       `json:"out"`
+	OutTemplate           *string       `json:"out-template"`
+	InZip                 configStrings `json:"in"`
+	Compression           *string       `json:"compression"`
+	Encoding              *string       `json:"encoding"`
+	NoOverwriteCentralDir *bool         `json:"no-overwrite-cdir"`
+	CommentSize           *int          `json:"comment-size"`
+	FixedTime             *bool         `json:"fixed-time"`
+	NoiseFiles            *int          `json:"noise-files"`
+	NoiseSize             *int          `json:"noise-size"`
+	NoiseGenerator        *string       `json:"noise-generator"`
+	Level                 *int          `json:"level"`
+	Strategy              *string       `json:"strategy"`
+	Workers               configWorkers `json:"workers"`
+	Seed                  configSeed    `json:"seed"`
+	IncludeHidden         *bool         `json:"include-hidden"`
+	FilesFrom             *string       `json:"files-from"`
+	MaxDepth              *int          `json:"max-depth"`
+	TmpDir                *string       `json:"tmp-dir"`
+	MemEntrySize          configSize    `json:"mem-entry-size"`
+	MemTotal              configSize    `json:"mem-total"`
+	BufferSize            configSize    `json:"buffer-size"`
+	Resume                *string       `json:"resume"`
+	LogFile               *string       `json:"log-file"`
+	LogMaxSize            configSize    `json:"log-max-size"`
+	Bait                  *bool         `json:"bait"`
+	Dedup                 *bool         `json:"dedup"`
+	PadNames              *bool         `json:"pad-names"`
+	Report                *string       `json:"report"`
+	Entries               *int          `json:"entries"`
+	TotalSize             configSize    `json:"total-size"`
+	PadBucket             configSize    `json:"pad-bucket"`
+	UnpadNames            *bool         `json:"unpad-names"`
+	NoRezip               *bool         `json:"no-rezip"`
+	Format                *string       `json:"format"`
+	Timeout               *string       `json:"timeout"`
+	Comments              *string       `json:"comments"`
+	Strict                *bool         `json:"strict"`
+	Order                 *string       `json:"order"`
+	PathPolicy            *string       `json:"path-policy"`
+	Checkpoint            *string       `json:"checkpoint"`
+	Incremental           *bool         `json:"incremental"`
+	KeepMethods           *bool         `json:"keep-methods"`
+	List                  *bool         `json:"list"`
+	ForceScan             *bool         `json:"force-scan"`
+	Encodings             []string      `json:"encodings"`
+	Recurse               *int          `json:"recurse"`
+	Only                  []string      `json:"only"`
+	Skip                  []string      `json:"skip"`
+	Junk                  []string      `json:"junk"`
+	JunkPrefix            *string       `json:"junk-prefix"`
+	MaxMemory             configSize    `json:"max-memory"`
+	MaxEntrySize          configSize    `json:"max-entry-size"`
+	MaxTotalSize          configSize    `json:"max-total-size"`
+	MaxRatio              *float64      `json:"max-ratio"`
+	Manifest              *string       `json:"manifest"`
+	KeepJunk              *string       `json:"keep-junk"`
+	Digest                *string       `json:"digest"`
+	// Profiles holds named sets of keys that -profile lays over the
+	// top-level ones.
+	Profiles map[string]json.RawMessage `json:"profiles"`
+}
+
+// readConfig reads a config file, upgrading it from an older version with a
+// warning for each change, and when profile is set, applies that profile's
+// keys over the top-level ones. Flags still win over both.
+func readConfig(path, profile string) (*fileConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read %s: %w", path, err)
 	}
+	data, warnings, err := upgradeConfig(stripJSONComments(data), false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, w := range warnings {
+		printWarning("config: %s: %s", path, w)
+	}
 	var cfg fileConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	if err := decodeStrict(data, &cfg, configKeys); err != nil {
 		return nil, fmt.Errorf("parse %s: %w", path, err)
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// Every profile is checked, not just the one picked, so a typo shows
+	// up before the day that profile is needed.
+	for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		var p fileConfig
+		if err := decodeStrict(cfg.Profiles[name], &p, configKeys); err != nil {
+			return nil, fmt.Errorf("parse %s: profile %q: %w", path, name, err)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %w", path, name, err)
+		}
+	}
+	if profile == "" {
+		return &cfg, nil
+	}
+	raw, ok := cfg.Profiles[profile]
+	if !ok {
+		names := slices.Sorted(maps.Keys(cfg.Profiles))
+		if len(names) == 0 {
+			return nil, fmt.Errorf("%s defines no profiles", path)
+		}
+		return nil, fmt.Errorf("%s has no profile %q (have %s)", path, profile, strings.Join(names, ", "))
+	}
+	// Decoding over the same struct replaces exactly the keys the profile
+	// sets.
+	profiles := cfg.Profiles
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: profile %q: %w", path, profile, err)
+	}
+	cfg.Profiles = profiles
 	return &cfg, nil
 }
 
+// stripJSONComments blanks out // comments and drops commas that directly
+// precede } or ], so config files written by init-config, with keys
+// commented in and out, stay valid JSON. Byte offsets in errors are kept.
+func stripJSONComments(data []byte) []byte {
+	out := bytes.Clone(data)
+	inString, escaped := false, false
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			lastComma = -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			lastComma = -1
+		}
+	}
+	return out
+}
+
 func collectVisitedFlags(fs *flag.FlagSet) map[string]bool {
 	visited := make(map[string]bool)
 	fs.Visit(func(f *flag.Flag) {
 		visited[f.Name] = true
+		// Config keys go by the long name.
+		if long, ok := flagAliases[f.Name]; ok {
+			visited[long] = true
+		}
 	})
 	return visited
 }
@@ -99,15 +325,15 @@ func applyEncryptConfig(opts *encryptOptions, cfg *fileConfig, visited map[strin
 	if !flagWasSet(visited, "src") && cfg.SrcDir != nil {
 		opts.srcDir = *cfg.SrcDir
 	}
-	if !flagWasSet(visited, "out") && cfg.OutZip != nil {
+	// -out-template on the command line beats an out from the file.
+	if !flagWasSet(visited, "out", "out-template") && cfg.OutZip != nil {
 		opts.outZip = *cfg.OutZip
 	}
-	if !flagWasSet(visited, "compression", "method") {
-		if cfg.Compression != nil {
-			opts.compression = *cfg.Compression
-		} else if cfg.Method != nil {
-			opts.compression = *cfg.Method
-		}
+	if !flagWasSet(visited, "out-template") && cfg.OutTemplate != nil {
+		opts.outTemplate = *cfg.OutTemplate
+	}
+	if !flagWasSet(visited, "compression", "method") && cfg.Compression != nil {
+		opts.compression = *cfg.Compression
 	}
 	if !flagWasSet(visited, "encoding") && cfg.Encoding != nil {
 		opts.encoding = *cfg.Encoding
@@ -127,14 +353,18 @@ func applyEncryptConfig(opts *encryptOptions, cfg *fileConfig, visited map[strin
 	if !flagWasSet(visited, "noise-size") && cfg.NoiseSize != nil {
 		opts.noiseSize = *cfg.NoiseSize
 	}
+	if !flagWasSet(visited, "noise-generator") && cfg.NoiseGenerator != nil {
+		opts.noiseGenerator = *cfg.NoiseGenerator
+	}
 	if !flagWasSet(visited, "level") && cfg.Level != nil {
 		opts.level = *cfg.Level
 	}
 	if !flagWasSet(visited, "strategy") && cfg.Strategy != nil {
 		opts.strategy = *cfg.Strategy
 	}
-	if !flagWasSet(visited, "workers") && cfg.Workers != nil {
-		opts.workers = *cfg.Workers
+	if !flagWasSet(visited, "workers") && cfg.Workers.Set {
+		// readConfig has validated it.
+		opts.workers, _ = parseWorkers(cfg.Workers.Value)
 	}
 	if !flagWasSet(visited, "seed") && cfg.Seed.Set {
 		opts.seed = cfg.Seed.Value
@@ -142,24 +372,57 @@ func applyEncryptConfig(opts *encryptOptions, cfg *fileConfig, visited map[strin
 	if !flagWasSet(visited, "include-hidden") && cfg.IncludeHidden != nil {
 		opts.includeHidden = *cfg.IncludeHidden
 	}
+	if !flagWasSet(visited, "files-from") && cfg.FilesFrom != nil {
+		opts.filesFrom = *cfg.FilesFrom
+	}
+	if !flagWasSet(visited, "max-depth") && cfg.MaxDepth != nil {
+		opts.maxDepth = *cfg.MaxDepth
+	}
+	if !flagWasSet(visited, "tmp-dir") && cfg.TmpDir != nil {
+		opts.tmpDir = *cfg.TmpDir
+	}
+	if !flagWasSet(visited, "mem-entry-size") && cfg.MemEntrySize.Set {
+		opts.memEntrySize = cfg.MemEntrySize.Value
+	}
+	if !flagWasSet(visited, "mem-total") && cfg.MemTotal.Set {
+		opts.memTotal = cfg.MemTotal.Value
+	}
+	if !flagWasSet(visited, "buffer-size") && cfg.BufferSize.Set {
+		opts.bufferSize = cfg.BufferSize.Value
+	}
+	if !flagWasSet(visited, "resume") && cfg.Resume != nil {
+		opts.resume = *cfg.Resume
+	}
+	if !flagWasSet(visited, "bait") && cfg.Bait != nil {
+		opts.bait = *cfg.Bait
+	}
+	if !flagWasSet(visited, "dedup") && cfg.Dedup != nil {
+		opts.dedup = *cfg.Dedup
+	}
+	if !flagWasSet(visited, "pad-names") && cfg.PadNames != nil {
+		opts.padNames = *cfg.PadNames
+	}
+	if !flagWasSet(visited, "pad-bucket") && cfg.PadBucket.Set {
+		opts.padBucket = cfg.PadBucket.Value
+	}
+	if !flagWasSet(visited, "report") && cfg.Report != nil {
+		opts.reportPath = *cfg.Report
+	}
+	applyLogConfig(&opts.verbosity, cfg, visited)
 }
 
 func applyRecoverConfig(opts *recoverOptions, cfg *fileConfig, visited map[string]bool) {
 	if cfg == nil || opts == nil {
 		return
 	}
-	if !flagWasSet(visited, "in") && cfg.InZip != nil {
-		opts.inZip = *cfg.InZip
+	if !flagWasSet(visited, "in") && cfg.InZip.Set {
+		opts.inZip = cfg.InZip.Values
 	}
 	if !flagWasSet(visited, "out") && cfg.OutZip != nil {
 		opts.outZip = *cfg.OutZip
 	}
-	if !flagWasSet(visited, "compression", "method") {
-		if cfg.Compression != nil {
-			opts.compression = *cfg.Compression
-		} else if cfg.Method != nil {
-			opts.compression = *cfg.Method
-		}
+	if !flagWasSet(visited, "compression", "method") && cfg.Compression != nil {
+		opts.compression = *cfg.Compression
 	}
 	if !flagWasSet(visited, "encoding") && cfg.Encoding != nil {
 		opts.encoding = *cfg.Encoding
@@ -170,8 +433,9 @@ func applyRecoverConfig(opts *recoverOptions, cfg *fileConfig, visited map[strin
 	if !flagWasSet(visited, "strategy") && cfg.Strategy != nil {
 		opts.strategy = *cfg.Strategy
 	}
-	if !flagWasSet(visited, "workers") && cfg.Workers != nil {
-		opts.workers = *cfg.Workers
+	if !flagWasSet(visited, "workers") && cfg.Workers.Set {
+		// readConfig has validated it.
+		opts.workers, _ = parseWorkers(cfg.Workers.Value)
 	}
 	if !flagWasSet(visited, "seed") && cfg.Seed.Set {
 		opts.seed = cfg.Seed.Value
@@ -179,4 +443,275 @@ func applyRecoverConfig(opts *recoverOptions, cfg *fileConfig, visited map[strin
 	if !flagWasSet(visited, "include-hidden") && cfg.IncludeHidden != nil {
 		opts.includeHidden = *cfg.IncludeHidden
 	}
+	if !flagWasSet(visited, "unpad-names") && cfg.UnpadNames != nil {
+		opts.unpadNames = *cfg.UnpadNames
+	}
+	if !flagWasSet(visited, "no-rezip") && cfg.NoRezip != nil {
+		opts.noRezip = *cfg.NoRezip
+	}
+	if !flagWasSet(visited, "format") && cfg.Format != nil {
+		opts.format = *cfg.Format
+	}
+	if !flagWasSet(visited, "timeout") && cfg.Timeout != nil {
+		opts.timeout = *cfg.Timeout
+	}
+	if !flagWasSet(visited, "comments") && cfg.Comments != nil {
+		opts.comments = *cfg.Comments
+	}
+	if !flagWasSet(visited, "strict") && cfg.Strict != nil {
+		opts.strict = *cfg.Strict
+	}
+	if !flagWasSet(visited, "order") && cfg.Order != nil {
+		opts.order = *cfg.Order
+	}
+	if !flagWasSet(visited, "path-policy") && cfg.PathPolicy != nil {
+		opts.pathPolicy = *cfg.PathPolicy
+	}
+	if !flagWasSet(visited, "checkpoint") && cfg.Checkpoint != nil {
+		opts.checkpoint = *cfg.Checkpoint
+	}
+	if !flagWasSet(visited, "tmp-dir") && cfg.TmpDir != nil {
+		opts.tmpDir = *cfg.TmpDir
+	}
+	if !flagWasSet(visited, "incremental") && cfg.Incremental != nil {
+		opts.incremental = *cfg.Incremental
+	}
+	if !flagWasSet(visited, "keep-methods") && cfg.KeepMethods != nil {
+		opts.keepMethods = *cfg.KeepMethods
+	}
+	if !flagWasSet(visited, "list") && cfg.List != nil {
+		opts.list = *cfg.List
+	}
+	if !flagWasSet(visited, "force-scan") && cfg.ForceScan != nil {
+		opts.forceScan = *cfg.ForceScan
+	}
+	if !flagWasSet(visited, "recurse") && cfg.Recurse != nil {
+		opts.recurse = *cfg.Recurse
+	}
+	if !flagWasSet(visited, "encodings") && cfg.Encodings != nil {
+		opts.encodings = strings.Join(cfg.Encodings, ",")
+	}
+	if !flagWasSet(visited, "only") && cfg.Only != nil {
+		opts.only = cfg.Only
+	}
+	if !flagWasSet(visited, "skip") && cfg.Skip != nil {
+		opts.skip = cfg.Skip
+	}
+	if !flagWasSet(visited, "junk") && cfg.Junk != nil {
+		opts.junk = cfg.Junk
+	}
+	if !flagWasSet(visited, "junk-prefix") && cfg.JunkPrefix != nil {
+		opts.junkPrefix = *cfg.JunkPrefix
+	}
+	if !flagWasSet(visited, "max-memory") && cfg.MaxMemory.Set {
+		opts.maxMemory = cfg.MaxMemory.Value
+	}
+	if !flagWasSet(visited, "max-entry-size") && cfg.MaxEntrySize.Set {
+		opts.maxEntrySize = cfg.MaxEntrySize.Value
+	}
+	if !flagWasSet(visited, "max-total-size") && cfg.MaxTotalSize.Set {
+		opts.maxTotalSize = cfg.MaxTotalSize.Value
+	}
+	if !flagWasSet(visited, "max-ratio") && cfg.MaxRatio != nil {
+		opts.maxRatio = *cfg.MaxRatio
+	}
+	if !flagWasSet(visited, "manifest") && cfg.Manifest != nil {
+		opts.manifest = *cfg.Manifest
+	}
+	if !flagWasSet(visited, "keep-junk") && cfg.KeepJunk != nil {
+		opts.keepJunk = *cfg.KeepJunk
+	}
+	applyLogConfig(&opts.verbosity, cfg, visited)
+}
+
+func applyRepairConfig(opts *repairOptions, cfg *fileConfig, visited map[string]bool) {
+	if cfg == nil || opts == nil {
+		return
+	}
+	if !flagWasSet(visited, "in") && cfg.InZip.Set {
+		opts.inZip = cfg.InZip.Values
+	}
+	if !flagWasSet(visited, "out") && cfg.OutZip != nil {
+		opts.outZip = *cfg.OutZip
+	}
+	if !flagWasSet(visited, "force-scan") && cfg.ForceScan != nil {
+		opts.forceScan = *cfg.ForceScan
+	}
+	if !flagWasSet(visited, "encodings") && cfg.Encodings != nil {
+		opts.encodings = strings.Join(cfg.Encodings, ",")
+	}
+	if !flagWasSet(visited, "junk") && cfg.Junk != nil {
+		opts.junk = cfg.Junk
+	}
+	if !flagWasSet(visited, "junk-prefix") && cfg.JunkPrefix != nil {
+		opts.junkPrefix = *cfg.JunkPrefix
+	}
+	applyLogConfig(&opts.verbosity, cfg, visited)
+}
+
+func applyExtractConfig(opts *extractOptions, cfg *fileConfig, visited map[string]bool) {
+	if cfg == nil || opts == nil {
+		return
+	}
+	if !flagWasSet(visited, "in") && cfg.InZip.Set {
+		opts.inZip = cfg.InZip.Values
+	}
+	if !flagWasSet(visited, "out") && cfg.OutZip != nil {
+		opts.outDir = *cfg.OutZip
+	}
+	if !flagWasSet(visited, "only") && cfg.Only != nil {
+		opts.only = cfg.Only
+	}
+	if !flagWasSet(visited, "skip") && cfg.Skip != nil {
+		opts.skip = cfg.Skip
+	}
+	if !flagWasSet(visited, "junk") && cfg.Junk != nil {
+		opts.junk = cfg.Junk
+	}
+	if !flagWasSet(visited, "junk-prefix") && cfg.JunkPrefix != nil {
+		opts.junkPrefix = *cfg.JunkPrefix
+	}
+	if !flagWasSet(visited, "keep-junk") && cfg.KeepJunk != nil {
+		opts.keepJunk = *cfg.KeepJunk
+	}
+	if !flagWasSet(visited, "unpad-names") && cfg.UnpadNames != nil {
+		opts.unpadNames = *cfg.UnpadNames
+	}
+	if !flagWasSet(visited, "force-scan") && cfg.ForceScan != nil {
+		opts.forceScan = *cfg.ForceScan
+	}
+	if !flagWasSet(visited, "encodings") && cfg.Encodings != nil {
+		opts.encodings = strings.Join(cfg.Encodings, ",")
+	}
+	applyLogConfig(&opts.verbosity, cfg, visited)
+}
+
+func applyDiffConfig(opts *diffOptions, cfg *fileConfig, visited map[string]bool) {
+	if cfg == nil || opts == nil {
+		return
+	}
+	if !flagWasSet(visited, "only") && cfg.Only != nil {
+		opts.only = cfg.Only
+	}
+	if !flagWasSet(visited, "skip") && cfg.Skip != nil {
+		opts.skip = cfg.Skip
+	}
+	if !flagWasSet(visited, "junk") && cfg.Junk != nil {
+		opts.junk = cfg.Junk
+	}
+	if !flagWasSet(visited, "junk-prefix") && cfg.JunkPrefix != nil {
+		opts.junkPrefix = *cfg.JunkPrefix
+	}
+	if !flagWasSet(visited, "unpad-names") && cfg.UnpadNames != nil {
+		opts.unpadNames = *cfg.UnpadNames
+	}
+	if !flagWasSet(visited, "force-scan") && cfg.ForceScan != nil {
+		opts.forceScan = *cfg.ForceScan
+	}
+	if !flagWasSet(visited, "encodings") && cfg.Encodings != nil {
+		opts.encodings = strings.Join(cfg.Encodings, ",")
+	}
+}
+
+func applyInspectConfig(opts *inspectOptions, cfg *fileConfig, visited map[string]bool) {
+	if cfg == nil || opts == nil {
+		return
+	}
+	if !flagWasSet(visited, "in") && cfg.InZip.Set {
+		opts.inZip = cfg.InZip.Values
+	}
+	if !flagWasSet(visited, "encodings") && cfg.Encodings != nil {
+		opts.encodings = strings.Join(cfg.Encodings, ",")
+	}
+	if !flagWasSet(visited, "junk") && cfg.Junk != nil {
+		opts.junk = cfg.Junk
+	}
+	if !flagWasSet(visited, "junk-prefix") && cfg.JunkPrefix != nil {
+		opts.junkPrefix = *cfg.JunkPrefix
+	}
+}
+
+func applyChecksumConfig(opts *checksumOptions, cfg *fileConfig, visited map[string]bool) {
+	if cfg == nil || opts == nil {
+		return
+	}
+	if !flagWasSet(visited, "in") && cfg.InZip.Set {
+		opts.inZip = cfg.InZip.Values
+	}
+	if !flagWasSet(visited, "digest") && cfg.Digest != nil {
+		opts.digest = *cfg.Digest
+	}
+	if !flagWasSet(visited, "encodings") && cfg.Encodings != nil {
+		opts.encodings = strings.Join(cfg.Encodings, ",")
+	}
+	if !flagWasSet(visited, "force-scan") && cfg.ForceScan != nil {
+		opts.forceScan = *cfg.ForceScan
+	}
+	if !flagWasSet(visited, "junk") && cfg.Junk != nil {
+		opts.junk = cfg.Junk
+	}
+	if !flagWasSet(visited, "junk-prefix") && cfg.JunkPrefix != nil {
+		opts.junkPrefix = *cfg.JunkPrefix
+	}
+}
+
+func applyDecoyConfig(opts *decoyOptions, cfg *fileConfig, visited map[string]bool) {
+	if cfg == nil || opts == nil {
+		return
+	}
+	if !flagWasSet(visited, "out") && cfg.OutZip != nil {
+		opts.outZip = *cfg.OutZip
+	}
+	if !flagWasSet(visited, "entries") && cfg.Entries != nil {
+		opts.entries = *cfg.Entries
+	}
+	if !flagWasSet(visited, "total-size") && cfg.TotalSize.Set {
+		opts.totalSize = cfg.TotalSize.Value
+	}
+	if !flagWasSet(visited, "compression", "method") && cfg.Compression != nil {
+		opts.compression = *cfg.Compression
+	}
+	if !flagWasSet(visited, "encoding") && cfg.Encoding != nil {
+		opts.encoding = *cfg.Encoding
+	}
+	if !flagWasSet(visited, "no-overwrite-cdir") && cfg.NoOverwriteCentralDir != nil {
+		opts.overwriteCentralDir = !*cfg.NoOverwriteCentralDir
+	}
+	if !flagWasSet(visited, "comment-size") && cfg.CommentSize != nil {
+		opts.commentSize = *cfg.CommentSize
+	}
+	if !flagWasSet(visited, "fixed-time") && cfg.FixedTime != nil {
+		opts.fixedTime = *cfg.FixedTime
+	}
+	if !flagWasSet(visited, "level") && cfg.Level != nil {
+		opts.level = *cfg.Level
+	}
+	if !flagWasSet(visited, "strategy") && cfg.Strategy != nil {
+		opts.strategy = *cfg.Strategy
+	}
+	if !flagWasSet(visited, "seed") && cfg.Seed.Set {
+		opts.seed = cfg.Seed.Value
+	}
+	if !flagWasSet(visited, "pad-names") && cfg.PadNames != nil {
+		opts.padNames = *cfg.PadNames
+	}
+	if !flagWasSet(visited, "pad-bucket") && cfg.PadBucket.Set {
+		opts.padBucket = cfg.PadBucket.Value
+	}
+	if !flagWasSet(visited, "report") && cfg.Report != nil {
+		opts.reportPath = *cfg.Report
+	}
+	if !flagWasSet(visited, "tmp-dir") && cfg.TmpDir != nil {
+		opts.tmpDir = *cfg.TmpDir
+	}
+	applyLogConfig(&opts.verbosity, cfg, visited)
+}
+
+func applyLogConfig(v *verbosityFlags, cfg *fileConfig, visited map[string]bool) {
+	if !flagWasSet(visited, "log-file") && cfg.LogFile != nil {
+		v.logFile = *cfg.LogFile
+	}
+	if !flagWasSet(visited, "log-max-size") && cfg.LogMaxSize.Set {
+		v.logMaxSize = cfg.LogMaxSize.Value
+	}
 }
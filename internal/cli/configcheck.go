@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+// configKeys are the JSON keys of fileConfig, in declaration order.
+var configKeys = func() []string {
+	t := reflect.TypeFor[fileConfig]()
+	keys := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		if key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); key != "" && key != "-" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}()
+
+// decodeStrict decodes a config document into v, refusing keys v does not
+// have. Errors name the key and, for a typo, the key probably meant, which
+// is picked from keys.
+func decodeStrict(data []byte, v any, keys []string) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, col := lineCol(data, syntaxErr.Offset)
+		return fmt.Errorf("line %d, column %d: %s", line, col, syntaxErr)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Errorf("expected %s, got a JSON %s", jsonKind(typeErr.Type), typeErr.Value)
+		}
+		return fmt.Errorf("key %q: expected %s, got a JSON %s", typeErr.Field, jsonKind(typeErr.Type), typeErr.Value)
+	}
+	if key, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		key = strings.Trim(key, `"`)
+		if r, ok := renamedConfigKeys[key]; ok {
+			return fmt.Errorf("unknown key %q (renamed %q in config version %d)", key, r.to, r.version)
+		}
+		if near := closestKey(key, keys); near != "" {
+			return fmt.Errorf("unknown key %q (did you mean %q?)", key, near)
+		}
+		return fmt.Errorf("unknown key %q (run noisyzip init-config for the list of keys)", key)
+	}
+	return err
+}
+
+// lineCol turns a byte offset of data into a 1-based line and column.
+func lineCol(data []byte, offset int64) (int, int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// jsonKind describes the JSON value a Go type is decoded from.
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "an array of " + strings.TrimPrefix(strings.TrimPrefix(jsonKind(t.Elem()), "a "), "an ") + "s"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
+
+// closestKey is the key of keys within two edits of key, or one key starts
+// with the other; "" when none is that close.
+func closestKey(key string, keys []string) string {
+	best, bestDist := "", 3
+	for _, k := range keys {
+		d := editDistance(strings.ToLower(key), k)
+		if d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	if best == "" {
+		for _, k := range keys {
+			if len(key) >= 3 && (strings.HasPrefix(k, key) || strings.HasPrefix(key, k)) {
+				return k
+			}
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// validate checks the values of the keys that are set, so a bad value is
+// reported against its key before any command runs. Every problem is
+// reported, one per line.
+func (cfg *fileConfig) validate() error {
+	var errs []error
+	bad := func(key string, val any, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("key %q: %v %s", key, val, fmt.Sprintf(format, args...)))
+	}
+	oneOf := func(key string, val *string, allowed ...string) {
+		if val != nil && !slices.Contains(allowed, strings.ToLower(strings.TrimSpace(*val))) {
+			bad(key, fmt.Sprintf("%q", *val), "is not one of %s", strings.Join(allowed, ", "))
+		}
+	}
+	inRange := func(key string, val *int, lo, hi int) {
+		switch {
+		case val == nil:
+		case hi < lo && *val < lo:
+			bad(key, *val, "must be >= %d", lo)
+		case hi >= lo && (*val < lo || *val > hi):
+			bad(key, *val, "is out of range %d..%d", lo, hi)
+		}
+	}
+	size := func(key string, val configSize) {
+		if !val.Set {
+			return
+		}
+		if _, err := parseByteSize(val.Value); err != nil {
+			bad(key, fmt.Sprintf("%q", val.Value), "is not a size (%v); use bytes or a suffix such as 64k, 100m, 2g", err)
+		}
+	}
+
+	oneOf("compression", cfg.Compression, "deflate", "store")
+	oneOf("encoding", cfg.Encoding, append(core.NameEncodings(), "utf8")...)
+	oneOf("strategy", cfg.Strategy, "default", "filtered", "huffman", "rle", "fixed")
+	oneOf("noise-generator", cfg.NoiseGenerator, core.NoiseGenerators()...)
+	oneOf("format", cfg.Format, "zip", "tar.gz", "tgz")
+	oneOf("order", cfg.Order, core.OrderOffset, core.OrderName)
+	oneOf("path-policy", cfg.PathPolicy, core.PathStrip, core.PathReplace, core.PathReject)
+	oneOf("digest", cfg.Digest, core.DigestAlgorithms...)
+
+	inRange("comment-size", cfg.CommentSize, 0, 0xffff)
+	inRange("level", cfg.Level, 0, 9)
+	inRange("noise-files", cfg.NoiseFiles, 0, -1)
+	inRange("noise-size", cfg.NoiseSize, 0, -1)
+	inRange("max-depth", cfg.MaxDepth, 0, -1)
+	inRange("entries", cfg.Entries, 1, -1)
+	inRange("recurse", cfg.Recurse, 0, -1)
+	if cfg.MaxRatio != nil && *cfg.MaxRatio < 0 {
+		bad("max-ratio", *cfg.MaxRatio, "must be >= 0")
+	}
+
+	if cfg.Workers.Set {
+		if _, err := parseWorkers(cfg.Workers.Value); err != nil {
+			errs = append(errs, fmt.Errorf("key %q: %v", "workers", err))
+		}
+	}
+
+	if cfg.OutTemplate != nil && strings.TrimSpace(*cfg.OutTemplate) != "" {
+		if _, err := expandOutTemplate(*cfg.OutTemplate, time.Now(), "", "src"); err != nil {
+			errs = append(errs, fmt.Errorf("key %q: %v", "out-template", err))
+		}
+	}
+
+	size("log-max-size", cfg.LogMaxSize)
+	size("total-size", cfg.TotalSize)
+	size("pad-bucket", cfg.PadBucket)
+	size("mem-entry-size", cfg.MemEntrySize)
+	size("mem-total", cfg.MemTotal)
+	size("buffer-size", cfg.BufferSize)
+	size("max-memory", cfg.MaxMemory)
+	size("max-entry-size", cfg.MaxEntrySize)
+	size("max-total-size", cfg.MaxTotalSize)
+
+	if cfg.Timeout != nil && strings.TrimSpace(*cfg.Timeout) != "" {
+		if _, err := time.ParseDuration(strings.TrimSpace(*cfg.Timeout)); err != nil {
+			bad("timeout", fmt.Sprintf("%q", *cfg.Timeout), "is not a duration such as 30s or 10m")
+		}
+	}
+	decoders := core.NameDecoders()
+	for _, name := range cfg.Encodings {
+		if !slices.Contains(decoders, strings.ToLower(strings.TrimSpace(name))) {
+			bad("encodings", fmt.Sprintf("%q", name), "is not one of %s", strings.Join(decoders, ", "))
+		}
+	}
+	return errors.Join(errs...)
+}
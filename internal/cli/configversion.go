@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// configVersion is the config file format this noisyzip reads and
+// init-config writes. A file without a "version" key is version 1; older
+// versions are upgraded as they are read, with a warning for every change
+// that affects them.
+const configVersion = 2
+
+// configMigrations upgrade one config object, from the version of its index
+// plus one to the next, and return a warning for each change made.
+var configMigrations = []func(obj map[string]json.RawMessage) []string{
+	migrateConfigV1,
+}
+
+// renamedConfigKeys are keys that later versions dropped, with the key that
+// replaces them and the version that renamed them.
+var renamedConfigKeys = map[string]struct {
+	to      string
+	version int
+}{
+	"method": {"compression", 2},
+}
+
+// migrateConfigV1 drops "method", an alias of "compression", and flags a
+// seed: seeded noise comes from a new stream since version 2.
+func migrateConfigV1(obj map[string]json.RawMessage) []string {
+	var warnings []string
+	if raw, ok := obj["method"]; ok {
+		delete(obj, "method")
+		if _, ok := obj["compression"]; ok {
+			warnings = append(warnings, trf("key %q is dropped, as %q is also set", "method", "compression"))
+		} else {
+			obj["compression"] = raw
+			warnings = append(warnings, trf("key %q is now %q", "method", "compression"))
+		}
+	}
+	if _, ok := obj["seed"]; ok {
+		warnings = append(warnings, trf("key %q: seeded noise now comes from a ChaCha20 stream, so archives differ from those of earlier releases", "seed"))
+	}
+	return warnings
+}
+
+// upgradeConfig returns the config document data at configVersion and the
+// warnings of the migrations applied. The objects upgraded are the top level
+// and its profiles, or for a batch manifest its defaults and jobs. Only the
+// top level may carry "version". A document that is not valid JSON is
+// returned as is, for decodeStrict to report with its position.
+func upgradeConfig(data []byte, batch bool) ([]byte, []string, error) {
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(data, &doc) != nil {
+		return data, nil, nil
+	}
+	version := 1
+	if raw, ok := doc["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil || version < 1 {
+			return nil, nil, fmt.Errorf("key %q: expected a positive integer, got %s", "version", raw)
+		}
+	}
+	if version > configVersion {
+		return nil, nil, fmt.Errorf("config version %d is newer than this noisyzip reads (%d); upgrade noisyzip", version, configVersion)
+	}
+
+	var warnings []string
+	migrate := func(where string, obj map[string]json.RawMessage) error {
+		if _, ok := obj["version"]; ok {
+			return fmt.Errorf("%skey %q belongs at the top level", where, "version")
+		}
+		for _, m := range configMigrations[version-1:] {
+			for _, w := range m(obj) {
+				warnings = append(warnings, where+w)
+			}
+		}
+		return nil
+	}
+	upgrade := func(where string, raw json.RawMessage) (json.RawMessage, error) {
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(raw, &obj) != nil {
+			return raw, nil
+		}
+		if err := migrate(where, obj); err != nil {
+			return nil, err
+		}
+		return json.Marshal(obj)
+	}
+	var err error
+	if batch {
+		if raw, ok := doc["defaults"]; ok {
+			if doc["defaults"], err = upgrade("defaults: ", raw); err != nil {
+				return nil, nil, err
+			}
+		}
+		var jobs []json.RawMessage
+		if json.Unmarshal(doc["jobs"], &jobs) == nil {
+			for i, raw := range jobs {
+				if jobs[i], err = upgrade(fmt.Sprintf("job %d: ", i+1), raw); err != nil {
+					return nil, nil, err
+				}
+			}
+			if doc["jobs"], err = json.Marshal(jobs); err != nil {
+				return nil, nil, err
+			}
+		}
+	} else {
+		profiles := doc["profiles"]
+		delete(doc, "profiles")
+		delete(doc, "version")
+		if err := migrate("", doc); err != nil {
+			return nil, nil, err
+		}
+		var byName map[string]json.RawMessage
+		if json.Unmarshal(profiles, &byName) == nil {
+			for _, name := range slices.Sorted(maps.Keys(byName)) {
+				if byName[name], err = upgrade(fmt.Sprintf("profile %q: ", name), byName[name]); err != nil {
+					return nil, nil, err
+				}
+			}
+			if profiles, err = json.Marshal(byName); err != nil {
+				return nil, nil, err
+			}
+		}
+		if profiles != nil {
+			doc["profiles"] = profiles
+		}
+	}
+	if version == configVersion {
+		return data, nil, nil
+	}
+	if len(warnings) > 0 {
+		warnings = append(warnings, trf("written for config version %d; update it and set \"version\": %d", version, configVersion))
+	}
+	doc["version"], _ = json.Marshal(configVersion)
+	out, err := json.Marshal(doc)
+	return out, warnings, err
+}
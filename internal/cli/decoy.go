@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type decoyOptions struct {
+	help                bool
+	configPath          string
+	profile             string
+	outZip              string
+	entries             int
+	totalSize           string
+	compression         string
+	encoding            string
+	overwriteCentralDir bool
+	commentSize         int
+	fixedTime           bool
+	level               int
+	strategy            string
+	seed                string
+	padNames            bool
+	padBucket           string
+	reportPath          string
+	reportPassword      passwordFlags
+	tmpDir              string
+	verbosity           verbosityFlags
+}
+
+func newDecoyFlagSet(output io.Writer) (*flag.FlagSet, *decoyOptions) {
+	opts := &decoyOptions{
+		entries:             50,
+		totalSize:           "10m",
+		compression:         "deflate",
+		encoding:            "utf-8",
+		overwriteCentralDir: true,
+		level:               6,
+		strategy:            "default",
+	}
+	fs := flag.NewFlagSet("decoy", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.configPath, "config", "", "Path to JSON config file")
+	fs.StringVar(&opts.profile, "profile", "", "Named profile from the config file to apply over its top-level keys")
+	fs.StringVar(&opts.outZip, "out", "", "Output ZIP path")
+	fs.IntVar(&opts.entries, "entries", opts.entries, "Number of decoy entries")
+	fs.StringVar(&opts.totalSize, "total-size", opts.totalSize, "Total payload size (e.g. 512k, 100m, 2g)")
+	fs.StringVar(&opts.compression, "compression", opts.compression, "Compression method: deflate or store")
+	fs.StringVar(&opts.compression, "method", opts.compression, "Alias for -compression")
+	fs.StringVar(&opts.encoding, "encoding", opts.encoding, "Filename encoding: utf-8 or cp1251")
+	fs.Var(&negatedBoolFlag{target: &opts.overwriteCentralDir}, "no-overwrite-cdir", "Do not overwrite central directory")
+	fs.IntVar(&opts.commentSize, "comment-size", 0, "ZIP comment junk size (bytes)")
+	fs.BoolVar(&opts.fixedTime, "fixed-time", false, "Overwrite file timestamps")
+	fs.IntVar(&opts.level, "level", opts.level, "Deflate level (0-9)")
+	fs.StringVar(&opts.strategy, "strategy", opts.strategy, "Deflate strategy: default, huffman")
+	fs.StringVar(&opts.seed, "seed", "", "Deterministic noise seed (integer)")
+	fs.BoolVar(&opts.padNames, "pad-names", false, "Pad all entry names to a uniform length")
+	fs.StringVar(&opts.padBucket, "pad-bucket", "", "Pad deflated streams to a multiple of this size (e.g. 4k, 64k)")
+	fs.StringVar(&opts.reportPath, "report", "", "Write an obfuscation report (JSON) to this path")
+	addPasswordFlags(fs, &opts.reportPassword, "report-password", "Encrypt the report with this password")
+	fs.StringVar(&opts.tmpDir, "tmp-dir", "", "Directory for temp files (default: the OS temp directory)")
+	addVerbosityFlags(fs, &opts.verbosity)
+	addFlagAliases(fs)
+	return fs, opts
+}
+
+func printDecoyHelp(w io.Writer) {
+	fs, _ := newDecoyFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip decoy -out <zip> [-entries N] [-total-size SIZE] [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+func runDecoy(args []string) int {
+	fs, opts := newDecoyFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printDecoyHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printDecoyHelp(os.Stdout)
+		return 0
+	}
+	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
+		cfg, err := readConfig(cfgPath, opts.profile)
+		if err != nil {
+			printError("config: %v", err)
+			return ExitUsage
+		}
+		applyDecoyConfig(opts, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+	if err := opts.reportPassword.resolve(true); err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+
+	outZip := strings.TrimSpace(opts.outZip)
+	if outZip == "" {
+		printError("-out is required")
+		printDecoyHelp(os.Stderr)
+		return ExitUsage
+	}
+	if !strings.HasSuffix(strings.ToLower(outZip), ".zip") {
+		outZip += ".zip"
+	}
+	totalSize, err := parseByteSize(opts.totalSize)
+	if err != nil {
+		printError("total-size: %v", err)
+		return ExitUsage
+	}
+
+	var padBucket int64
+	if text := strings.TrimSpace(opts.padBucket); text != "" {
+		val, err := parseByteSize(text)
+		if err != nil {
+			printError("pad-bucket: %v", err)
+			return ExitUsage
+		}
+		padBucket = val
+	}
+
+	cfg := core.Config{
+		OutZip:              outZip,
+		Compression:         opts.compression,
+		Encoding:            opts.encoding,
+		OverwriteCentralDir: opts.overwriteCentralDir,
+		CommentSize:         opts.commentSize,
+		FixedTime:           opts.fixedTime,
+		Level:               opts.level,
+		Strategy:            opts.strategy,
+		DictSize:            32768,
+		PadNames:            opts.padNames,
+		PadBucket:           padBucket,
+		ReportPath:          strings.TrimSpace(opts.reportPath),
+		ReportPassword:      opts.reportPassword.value,
+		TmpDir:              strings.TrimSpace(opts.tmpDir),
+	}
+
+	seedText := strings.TrimSpace(opts.seed)
+	if seedText != "" {
+		seedVal, err := strconv.ParseInt(seedText, 10, 64)
+		if err != nil {
+			printError("seed must be an integer")
+			return ExitUsage
+		}
+		cfg.Seed = seedVal
+		cfg.HasSeed = true
+	}
+
+	if err := opts.verbosity.openLogs("decoy", false); err != nil {
+		return openLogsFailed(err)
+	}
+	level := opts.verbosity.level()
+	progress, logCb := level.callbacks()
+
+	ctx, stop := interruptContext()
+	defer stop()
+	total, err := core.RunDecoyContext(ctx, cfg, opts.entries, totalSize, progress, logCb)
+	if err != nil {
+		return failJSON(false, "decoy", err)
+	}
+	fmt.Fprintf(level.stdout(), "Done. Decoy files: %d\nOutput: %s\n", total, outZip)
+	return 0
+}
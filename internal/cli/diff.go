@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type diffOptions struct {
+	help       bool
+	configPath string
+	profile    string
+	only       stringListFlag
+	skip       stringListFlag
+	junk       stringListFlag
+	junkPrefix string
+	password   passwordFlags
+	unpadNames bool
+	forceScan  bool
+	encodings  string
+	asJSON     bool
+}
+
+func newDiffFlagSet(output io.Writer) (*flag.FlagSet, *diffOptions) {
+	opts := &diffOptions{junkPrefix: ".junk"}
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.configPath, "config", "", "Path to JSON config file")
+	fs.StringVar(&opts.profile, "profile", "", "Named profile from the config file to apply over its top-level keys")
+	fs.Var(&opts.only, "only", "Compare only entries matching this glob (repeatable, ** matches directories)")
+	fs.Var(&opts.skip, "skip", "Leave out entries matching this glob (repeatable)")
+	fs.Var(&opts.junk, "junk", "Treat entries matching this glob as junk (repeatable)")
+	fs.StringVar(&opts.junkPrefix, "junk-prefix", opts.junkPrefix, "Directory noise entries were written under (empty disables)")
+	addPasswordFlags(fs, &opts.password, "password", "Password for ZipCrypto and WinZip AES entries, used for both archives")
+	fs.BoolVar(&opts.unpadNames, "unpad-names", false, "Strip padding added by -pad-names")
+	fs.BoolVar(&opts.forceScan, "force-scan", false, "Ignore the central directories and scan local headers")
+	fs.StringVar(&opts.encodings, "encodings", "", trf("Comma-separated charsets tried for non-UTF-8 names (%s)", strings.Join(core.NameDecoders(), ", ")))
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the differences as JSON on stdout")
+	return fs, opts
+}
+
+func printDiffHelp(w io.Writer) {
+	fs, _ := newDiffFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip diff [options] <old.zip> <new.zip>")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Compares the real files of two archives by path, size and CRC, ignoring noise.")
+	fmt.Fprintln(w, "Exits 0 when they hold the same files, 1 when they differ and 2 on errors.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+// runDiff exits as diff(1) does: 0 for the same files, 1 when they
+// differ, and ExitUsage, 2, for trouble of any kind.
+func runDiff(args []string) int {
+	fs, opts := newDiffFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printDiffHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printDiffHelp(os.Stdout)
+		return 0
+	}
+	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
+		cfg, err := readConfig(cfgPath, opts.profile)
+		if err != nil {
+			printError("config: %v", err)
+			return ExitUsage
+		}
+		applyDiffConfig(opts, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+	if err := opts.password.resolve(false); err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+	if fs.NArg() != 2 {
+		printError("diff needs two archives")
+		printDiffHelp(os.Stderr)
+		return ExitUsage
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	var reps [2]core.RecoverReport
+	for i, in := range fs.Args() {
+		rep, err := core.RecoverZipContext(ctx, core.RecoverConfig{
+			InZip:      in,
+			ListOnly:   true,
+			UnpadNames: opts.unpadNames,
+			ForceScan:  opts.forceScan,
+			Encodings:  splitList(opts.encodings),
+			Password:   opts.password.value,
+			Only:       opts.only,
+			Skip:       opts.skip,
+			Junk:       junkPatterns(opts.junkPrefix, opts.junk),
+		}, nil, nil)
+		if err != nil {
+			printError("%s: %v", in, err)
+			if opts.asJSON {
+				writeJSON(os.Stdout, jsonResult{Command: "diff", Error: fmt.Sprintf("%s: %v", in, err)})
+			}
+			return ExitUsage
+		}
+		// A file that cannot be read shows up as removed or added, so say
+		// why.
+		for _, ent := range rep.Entries {
+			if ent.Status == core.EntryFailed {
+				fmt.Fprintf(os.Stderr, "Failed: %s: %s: %s\n", in, ent.Path, ent.Error)
+			}
+		}
+		reps[i] = rep
+	}
+
+	d := core.DiffReports(reps[0], reps[1])
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "diff", OK: true, Report: d})
+	} else {
+		printDiff(os.Stdout, d)
+	}
+	if !d.Empty() {
+		return 1
+	}
+	return 0
+}
+
+func printDiff(w io.Writer, d core.ArchiveDiff) {
+	for _, e := range d.Added {
+		fmt.Fprintf(w, "A  %s (%d bytes)\n", e.Path, e.NewSize)
+	}
+	for _, e := range d.Removed {
+		fmt.Fprintf(w, "D  %s (%d bytes)\n", e.Path, e.OldSize)
+	}
+	for _, e := range d.Changed {
+		fmt.Fprintf(w, "M  %s (%d -> %d bytes, crc %08x -> %08x)\n", e.Path, e.OldSize, e.NewSize, e.OldCRC, e.NewCRC)
+	}
+	fmt.Fprintf(w, "Added: %d, removed: %d, changed: %d, unchanged: %d\n", len(d.Added), len(d.Removed), len(d.Changed), d.Unchanged)
+}
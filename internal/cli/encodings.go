@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+// encodingList is the -json output of encodings.
+type encodingList struct {
+	// Write are the values of -encoding, Read those of -encodings.
+	Write []string `json:"write"`
+	Read  []string `json:"read"`
+	// Default is the -encodings list used when none is given, in order.
+	Default []string `json:"default"`
+}
+
+// nameTest is the -json output of encodings test.
+type nameTest struct {
+	Name     string `json:"name"`
+	Encoding string `json:"encoding"`
+	// Bytes is the stored name in hex; empty when it cannot be encoded.
+	Bytes    string `json:"bytes,omitempty"`
+	UTF8Flag bool   `json:"utf8Flag"`
+	Error    string `json:"error,omitempty"`
+	// RoundTrip is the name read back with the same encoding.
+	RoundTrip   string `json:"roundTrip,omitempty"`
+	RoundTripOK bool   `json:"roundTripOk"`
+	// Recovered is the name recover settles on with Encodings.
+	Recovered   string   `json:"recovered,omitempty"`
+	RecoveredOK bool     `json:"recoveredOk"`
+	Encodings   []string `json:"encodings"`
+}
+
+func printEncodingsHelp(w io.Writer) {
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip encodings [-json]")
+	fmt.Fprintln(w, "       noisyzip encodings test [-encoding <name>] -name <filename> [-encodings <list>] [-json]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Lists the filename encodings noise mode can write (-encoding) and recover can")
+	fmt.Fprintln(w, "read (-encodings). test shows the bytes a name is stored as, whether it reads")
+	fmt.Fprintln(w, "back the same, and the name recover would guess for those bytes.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("test options:"))
+	fs, _ := newEncodingsTestFlagSet(w)
+	printDefaults(fs)
+}
+
+type encodingsTestOptions struct {
+	help      bool
+	encoding  string
+	name      string
+	encodings string
+	asJSON    bool
+}
+
+func newEncodingsTestFlagSet(output io.Writer) (*flag.FlagSet, *encodingsTestOptions) {
+	opts := &encodingsTestOptions{}
+	fs := flag.NewFlagSet("encodings test", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.encoding, "encoding", "utf-8", "Encoding to store the name with, as for noise mode")
+	fs.StringVar(&opts.name, "name", "", "Filename to try")
+	fs.StringVar(&opts.encodings, "encodings", "", trf("Comma-separated encodings recover tries (default: %s)", strings.Join(core.DefaultNameDecoders, ",")))
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the result as JSON")
+	return fs, opts
+}
+
+func runEncodings(args []string) int {
+	if len(args) > 0 && args[0] == "test" {
+		return runEncodingsTest(args[1:])
+	}
+	fs := flag.NewFlagSet("encodings", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "Print the lists as JSON")
+	help := fs.Bool("h", false, "Show help")
+	fs.BoolVar(help, "help", false, "Show help")
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printEncodingsHelp(os.Stderr)
+		return ExitUsage
+	}
+	if *help {
+		printEncodingsHelp(os.Stdout)
+		return 0
+	}
+	if fs.NArg() > 0 {
+		printError("unknown argument %v", fs.Arg(0))
+		printEncodingsHelp(os.Stderr)
+		return ExitUsage
+	}
+
+	list := encodingList{
+		Write:   core.NameEncodings(),
+		Read:    core.NameDecoders(),
+		Default: core.DefaultNameDecoders,
+	}
+	if *asJSON {
+		writeJSON(os.Stdout, list)
+		return 0
+	}
+	fmt.Println("Writing names (-encoding):")
+	for _, name := range list.Write {
+		note := "no UTF-8 flag; read back only by tools that guess or are told the charset"
+		if _, utf8Flag, _ := core.EncodeName(name, ""); utf8Flag {
+			note = "UTF-8 flag set; read correctly by every modern unzip"
+		}
+		fmt.Printf("  %-11s %s\n", name, note)
+	}
+	fmt.Println("")
+	fmt.Println("Reading names (-encodings of recover, extract, repair, inspect and diff):")
+	for _, name := range list.Read {
+		if i := slices.Index(list.Default, name); i >= 0 {
+			fmt.Printf("  %-11s default, tried %d of %d\n", name, i+1, len(list.Default))
+			continue
+		}
+		fmt.Printf("  %s\n", name)
+	}
+	return 0
+}
+
+func runEncodingsTest(args []string) int {
+	fs, opts := newEncodingsTestFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printEncodingsHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printEncodingsHelp(os.Stdout)
+		return 0
+	}
+	if opts.name == "" {
+		printError("-name is required")
+		printEncodingsHelp(os.Stderr)
+		return ExitUsage
+	}
+	if !slices.Contains(core.NameEncodings(), strings.ToLower(strings.TrimSpace(opts.encoding))) {
+		printError("unsupported encoding %q (available: %s)", opts.encoding, strings.Join(core.NameEncodings(), ", "))
+		return ExitUsage
+	}
+
+	res := nameTest{
+		Name:      opts.name,
+		Encoding:  strings.ToLower(strings.TrimSpace(opts.encoding)),
+		Encodings: splitList(opts.encodings),
+	}
+	if len(res.Encodings) == 0 {
+		res.Encodings = core.DefaultNameDecoders
+	}
+	raw, utf8Flag, err := core.EncodeName(res.Encoding, res.Name)
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Bytes = fmt.Sprintf("% x", raw)
+		res.UTF8Flag = utf8Flag
+		if back, ok, err := core.DecodeName(res.Encoding, raw); err == nil && ok {
+			res.RoundTrip, res.RoundTripOK = back, back == res.Name
+		}
+		guessed, ok, err := core.GuessName(raw, utf8Flag, res.Encodings)
+		if err != nil {
+			printError("encodings: %v", err)
+			return ExitUsage
+		}
+		res.Recovered, res.RecoveredOK = guessed, ok && guessed == res.Name
+	}
+
+	code := ExitOK
+	if !res.RoundTripOK || !res.RecoveredOK {
+		code = ExitFailure
+	}
+	if opts.asJSON {
+		writeJSON(os.Stdout, res)
+		return code
+	}
+	fmt.Printf("Name:       %s\n", res.Name)
+	flagNote := "no UTF-8 flag"
+	if res.UTF8Flag {
+		flagNote = "UTF-8 flag"
+	}
+	fmt.Printf("Encoding:   %s (%s)\n", res.Encoding, flagNote)
+	if res.Error != "" {
+		fmt.Printf("Bytes:      cannot be encoded: %s\n", res.Error)
+		return code
+	}
+	fmt.Printf("Bytes:      %s (%d)\n", res.Bytes, len(raw))
+	fmt.Printf("Round trip: %s\n", testVerdict(res.RoundTrip, res.RoundTripOK))
+	fmt.Printf("Recover:    %s, trying %s\n", testVerdict(res.Recovered, res.RecoveredOK), strings.Join(res.Encodings, ","))
+	return code
+}
+
+// testVerdict is a name read back and whether it matches the original.
+func testVerdict(name string, ok bool) string {
+	if ok {
+		return name + " (ok)"
+	}
+	if name == "" {
+		return "not readable"
+	}
+	return name + " (differs)"
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+// estimateOptions are the noise mode options plus how to measure.
+type estimateOptions struct {
+	*encryptOptions
+	sample string
+	full   bool
+}
+
+func newEstimateFlagSet(output io.Writer) (*flag.FlagSet, *estimateOptions) {
+	fs, enc := newEncryptFlagSet(output)
+	fs.Init("estimate", flag.ContinueOnError)
+	opts := &estimateOptions{encryptOptions: enc, sample: "64m"}
+	fs.StringVar(&opts.sample, "sample", opts.sample, "Source data compressed to measure the ratio (0 for all of it)")
+	fs.BoolVar(&opts.full, "full", false, "Compress every file with a fast level-1 pass instead of sampling")
+	return fs, opts
+}
+
+func printEstimateHelp(w io.Writer) {
+	fs, _ := newEstimateFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip estimate -src <dir> [-out <zip>] [noise options] [-sample SIZE | -full]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Predicts the size of the archive the same options would write, including noise,")
+	fmt.Fprintln(w, "bait, padding and the comment, without writing it. With -out, also checks that")
+	fmt.Fprintln(w, "it fits in the free space there (exit code 4 if not).")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+func runEstimate(args []string) int {
+	fs, opts := newEstimateFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printEstimateHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printEstimateHelp(os.Stdout)
+		return 0
+	}
+	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
+		cfg, err := readConfig(cfgPath, opts.profile)
+		if err != nil {
+			printError("config: %v", err)
+			return ExitUsage
+		}
+		applyEncryptConfig(opts.encryptOptions, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+	sample, err := parseByteSize(opts.sample)
+	if err != nil {
+		printError("sample: %v", err)
+		return ExitUsage
+	}
+
+	if strings.TrimSpace(opts.srcDir) == "" {
+		printError("-src is required")
+		printEstimateHelp(os.Stderr)
+		return ExitUsage
+	}
+	// -out only says where the archive would go; without it there is no
+	// free space to compare with.
+	checkFit := strings.TrimSpace(opts.outZip) != ""
+	if !checkFit {
+		opts.outZip = "estimate.zip"
+	}
+	cfg, err := opts.config()
+	if err != nil {
+		printError("%v", err)
+		if exitCode(err) == ExitIO {
+			return ExitIO
+		}
+		return ExitUsage
+	}
+
+	if err := opts.verbosity.openLogs("estimate", opts.asJSON); err != nil {
+		return openLogsFailed(err)
+	}
+	level := opts.verbosity.level()
+	progress, _ := level.callbacks()
+	ctx, stop := interruptContext()
+	defer stop()
+	est, err := core.EstimateEncrypt(ctx, cfg, sample, opts.full, progress)
+	if err != nil {
+		return failJSON(opts.asJSON, "estimate", err)
+	}
+
+	code := ExitOK
+	var free uint64
+	var freeKnown bool
+	if checkFit {
+		free, freeKnown = core.FreeSpace(filepath.Dir(cfg.OutZip))
+		if freeKnown && uint64(est.Total) > free {
+			code = ExitIO
+			printError("the archive does not fit in %s: about %s needed, %s free", filepath.Dir(cfg.OutZip), core.FormatBytes(est.Total), core.FormatBytes(int64(free)))
+		}
+	}
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "estimate", OK: code == ExitOK, Kind: exitKinds[code], Report: est})
+		return code
+	}
+
+	out := level.stdout()
+	fmt.Fprintf(out, "Files:     %d (%s)\n", est.Files, core.FormatBytes(est.SourceBytes))
+	switch {
+	case est.Full:
+		fmt.Fprintf(out, "Measured:  all files at level 1 or lower, ratio %.1f%%\n", 100*est.Ratio)
+	case est.SampledFiles > 0:
+		fmt.Fprintf(out, "Measured:  %d files (%s), ratio %.1f%%\n", est.SampledFiles, core.FormatBytes(est.SampledBytes), 100*est.Ratio)
+	}
+	fmt.Fprintf(out, "Data:      %s\n", core.FormatBytes(est.Data))
+	if est.Padding > 0 {
+		fmt.Fprintf(out, "Padding:   %s\n", core.FormatBytes(est.Padding))
+	}
+	if est.Noise > 0 {
+		fmt.Fprintf(out, "Noise:     %s\n", core.FormatBytes(est.Noise))
+	}
+	if est.Bait > 0 {
+		fmt.Fprintf(out, "Bait:      %s\n", core.FormatBytes(est.Bait))
+	}
+	fmt.Fprintf(out, "Headers:   %s\n", core.FormatBytes(est.Headers+est.Trailer))
+	fmt.Fprintf(out, "Estimated: %s (%d bytes)\n", core.FormatBytes(est.Total), est.Total)
+	if checkFit {
+		switch {
+		case !freeKnown:
+			fmt.Fprintf(out, "Free space in %s is unknown\n", filepath.Dir(cfg.OutZip))
+		case code == ExitOK:
+			fmt.Fprintf(out, "Fits: %s free in %s\n", core.FormatBytes(int64(free)), filepath.Dir(cfg.OutZip))
+		}
+	}
+	return code
+}
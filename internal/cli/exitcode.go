@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+// Exit codes returned by Main. Wrappers can branch on these instead of
+// reading stderr. diff keeps the diff(1) convention instead: 1 when the
+// archives differ, 2 on trouble.
+const (
+	ExitOK = 0
+	// ExitFailure is any failure without a more specific code.
+	ExitFailure = 1
+	// ExitUsage is a bad flag, a missing argument or an invalid config
+	// value; nothing was done.
+	ExitUsage = 2
+	// ExitSourceEmpty means there was nothing to work on: a source
+	// directory without files, or an archive without a single entry.
+	ExitSourceEmpty = 3
+	// ExitIO is a file that could not be read or written, or too little
+	// disk space to start.
+	ExitIO = 4
+	// ExitPartial means the run finished but some entries (or batch jobs)
+	// failed or were truncated.
+	ExitPartial = 5
+	// ExitVerification means data was written or read that failed its
+	// CRC or authentication check.
+	ExitVerification = 6
+	// ExitInterrupted means Ctrl-C or SIGTERM stopped the run; temp files
+	// and partial output were removed. 130 is what shells report for a
+	// process killed by SIGINT.
+	ExitInterrupted = 130
+)
+
+// Error kinds named in -json output, one per exit code.
+var exitKinds = map[int]string{
+	ExitFailure:      "failure",
+	ExitUsage:        "usage",
+	ExitSourceEmpty:  "source-empty",
+	ExitIO:           "io",
+	ExitPartial:      "partial",
+	ExitVerification: "verification",
+	ExitInterrupted:  "interrupted",
+}
+
+// exitCode classifies err from core into an exit code.
+func exitCode(err error) int {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, core.ErrInvalidConfig):
+		return ExitUsage
+	case errors.Is(err, core.ErrNoFiles):
+		return ExitSourceEmpty
+	case errors.Is(err, core.ErrVerification):
+		return ExitVerification
+	case errors.Is(err, core.ErrNoSpace):
+		return ExitIO
+	case errors.As(err, &pathErr), errors.As(err, &linkErr):
+		return ExitIO
+	}
+	return ExitFailure
+}
+
+// recoverExitCode is the exit code of a recovery that returned rep: data
+// that failed its CRC outranks missing entries.
+func recoverExitCode(rep core.RecoverReport) int {
+	switch {
+	case rep.BadCRC > 0:
+		return ExitVerification
+	case rep.Failed > 0 || rep.Truncated > 0:
+		return ExitPartial
+	case rep.Headers == 0:
+		return ExitSourceEmpty
+	}
+	return ExitOK
+}
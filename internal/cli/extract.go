@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type extractOptions struct {
+	help       bool
+	configPath string
+	profile    string
+	inZip      stringListFlag
+	outDir     string
+	only       stringListFlag
+	skip       stringListFlag
+	junk       stringListFlag
+	junkPrefix string
+	keepJunk   string
+	password   passwordFlags
+	unpadNames bool
+	forceScan  bool
+	encodings  string
+	asJSON     bool
+	verbosity  verbosityFlags
+}
+
+func newExtractFlagSet(output io.Writer) (*flag.FlagSet, *extractOptions) {
+	opts := &extractOptions{outDir: ".", junkPrefix: ".junk"}
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.configPath, "config", "", "Path to JSON config file")
+	fs.StringVar(&opts.profile, "profile", "", "Named profile from the config file to apply over its top-level keys")
+	fs.Var(&opts.inZip, "in", "Input ZIP path, - for stdin (repeat for the parts of a split archive, in order)")
+	fs.StringVar(&opts.outDir, "out", opts.outDir, "Directory to extract into")
+	fs.Var(&opts.only, "only", "Extract only entries matching this glob (repeatable, ** matches directories)")
+	fs.Var(&opts.skip, "skip", "Skip entries matching this glob (repeatable)")
+	fs.StringVar(&opts.keepJunk, "keep-junk", "", "Write entries classified as junk to this directory instead of dropping them")
+	fs.Var(&opts.junk, "junk", "Treat entries matching this glob as junk (repeatable)")
+	fs.StringVar(&opts.junkPrefix, "junk-prefix", opts.junkPrefix, "Directory noise entries were written under (empty disables)")
+	addPasswordFlags(fs, &opts.password, "password", "Password for ZipCrypto and WinZip AES entries")
+	fs.BoolVar(&opts.unpadNames, "unpad-names", false, "Strip padding added by -pad-names")
+	fs.BoolVar(&opts.forceScan, "force-scan", false, "Ignore the central directory and scan local headers")
+	fs.StringVar(&opts.encodings, "encodings", "", trf("Comma-separated charsets tried for non-UTF-8 names (%s)", strings.Join(core.NameDecoders(), ", ")))
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the result as JSON on stdout (logs stay on stderr)")
+	addVerbosityFlags(fs, &opts.verbosity)
+	addFlagAliases(fs)
+	return fs, opts
+}
+
+func printExtractHelp(w io.Writer) {
+	fs, _ := newExtractFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip extract -in <zip> [-out <dir>] [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Extracts the real files of an archive, noisy or not, into a directory.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+func runExtract(args []string) int {
+	fs, opts := newExtractFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printExtractHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printExtractHelp(os.Stdout)
+		return 0
+	}
+	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
+		cfg, err := readConfig(cfgPath, opts.profile)
+		if err != nil {
+			printError("config: %v", err)
+			return ExitUsage
+		}
+		applyExtractConfig(opts, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+	if err := opts.password.resolve(false); err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+
+	inParts, err := splitParts(opts.inZip)
+	if err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+	inZip := ""
+	if len(inParts) > 0 {
+		inZip = inParts[0]
+	}
+	if len(inParts) < 2 {
+		inParts = nil
+	}
+	outDir := strings.TrimSpace(opts.outDir)
+	if inZip == "" || outDir == "" {
+		printError("-in and -out are required")
+		printExtractHelp(os.Stderr)
+		return ExitUsage
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := opts.verbosity.openLogs("extract", opts.asJSON); err != nil {
+		return openLogsFailed(err)
+	}
+	level := opts.verbosity.level()
+	progress, logCb := level.callbacks()
+	rep, err := core.RecoverZipContext(ctx, core.RecoverConfig{
+		InZip:      inZip,
+		InParts:    inParts,
+		OutDir:     outDir,
+		UnpadNames: opts.unpadNames,
+		ForceScan:  opts.forceScan,
+		Encodings:  splitList(opts.encodings),
+		Password:   opts.password.value,
+		Only:       opts.only,
+		Skip:       opts.skip,
+		Junk:       junkPatterns(opts.junkPrefix, opts.junk),
+		KeepJunk:   opts.keepJunk,
+	}, progress, logCb)
+	if err != nil {
+		return failJSON(opts.asJSON, "extract", err)
+	}
+	for _, ent := range rep.Entries {
+		if ent.Status == core.EntryFailed {
+			fmt.Fprintf(os.Stderr, "Failed: %s: %s\n", ent.Path, ent.Error)
+			logAudit(fmt.Sprintf("Failed: %s: %s", ent.Path, ent.Error))
+		}
+	}
+	// Like unzip, a run that lost files exits non-zero.
+	code := recoverExitCode(rep)
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "extract", OK: code == ExitOK, Kind: exitKinds[code], Output: outDir, Report: rep})
+	} else {
+		fmt.Fprintf(level.stdout(), "Extracted: %d\nTruncated: %d\nFailed: %d\nOutput: %s\n", rep.Recovered, rep.Truncated, rep.Failed, outDir)
+	}
+	return code
+}
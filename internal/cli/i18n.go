@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// languages are the languages of the CLI messages. English is the source
+// text and needs no catalog.
+var languages = []string{"en", "ru"}
+
+// catalogs map an English message, or format, to its translation.
+var catalogs = map[string]map[string]string{
+	"ru": ruMessages,
+}
+
+// catalog holds the translations in use; nil prints English.
+var catalog map[string]string
+
+// tr translates msg into the language in use. A message without a
+// translation stays in English.
+func tr(msg string) string {
+	if s, ok := catalog[msg]; ok {
+		return s
+	}
+	return msg
+}
+
+// trf formats the translation of format with args.
+func trf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// printError prints the translation of an error message on stderr.
+func printError(format string, args ...any) {
+	fmt.Fprintln(os.Stderr, tr("Error:"), trf(format, args...))
+}
+
+// printWarning prints the translation of a warning on stderr.
+func printWarning(format string, args ...any) {
+	fmt.Fprintln(os.Stderr, tr("Warning:"), trf(format, args...))
+}
+
+// printDefaults is fs.PrintDefaults with the flag descriptions translated.
+func printDefaults(fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) { f.Usage = tr(f.Usage) })
+	fs.PrintDefaults()
+}
+
+// selectLanguage picks the message language from -lang, which may appear
+// anywhere before a "--", or else from the locale in LC_ALL, LC_MESSAGES
+// or LANG. It returns args without -lang.
+func selectLanguage(args []string) ([]string, error) {
+	lang := localeLanguage()
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			out = append(out, args[i:]...)
+			break
+		}
+		name, val, hasVal := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "lang" {
+			out = append(out, arg)
+			continue
+		}
+		if !hasVal {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("flag needs an argument: -lang")
+			}
+			i++
+			val = args[i]
+		}
+		lang = strings.ToLower(strings.TrimSpace(val))
+		if !slices.Contains(languages, lang) {
+			return nil, fmt.Errorf("unsupported language %q (available: %s)", val, strings.Join(languages, ", "))
+		}
+	}
+	catalog = catalogs[lang]
+	return out, nil
+}
+
+// localeLanguage is the language of the first locale variable set, such as
+// "ru" for ru_RU.UTF-8; "en" when it has no catalog.
+func localeLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(name)
+		if locale == "" {
+			continue
+		}
+		lang, _, _ := strings.Cut(locale, "_")
+		lang, _, _ = strings.Cut(lang, ".")
+		if lang = strings.ToLower(lang); slices.Contains(languages, lang) {
+			return lang
+		}
+		return "en"
+	}
+	return "en"
+}
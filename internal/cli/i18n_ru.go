@@ -0,0 +1,199 @@
+package cli
+
+// ruMessages is the Russian catalog. Keys are the English messages exactly
+// as the code writes them, format verbs included.
+var ruMessages = map[string]string{
+	// General help and errors
+	"Error:":      "Ошибка:",
+	"Warning:":    "Предупреждение:",
+	"Interrupted": "Прервано",
+	"Usage:":      "Использование:",
+	"Options:":    "Параметры:",
+	"Commands:":   "Команды:",
+	"Run noisyzip help <command> or noisyzip <command> -h for its options.":                              "Параметры команды: noisyzip help <команда> или noisyzip <команда> -h.",
+	"noisyzip -src <dir> -out <zip> [options] still works as a short form of encrypt.":                   "noisyzip -src <папка> -out <zip> [параметры] по-прежнему работает как краткая форма encrypt.",
+	"-lang en|ru, before or after the command, sets the language of messages; the default follows LANG.": "-lang en|ru до или после команды задаёт язык сообщений; по умолчанию он берётся из LANG.",
+	"unknown command %v":                            "неизвестная команда %v",
+	"unknown argument %v":                           "лишний аргумент %v",
+	"config: %v":                                    "конфигурация: %v",
+	"config: %s: %s":                                "конфигурация: %s: %s",
+	"key %q is now %q":                              "ключ %q теперь называется %q",
+	"key %q is dropped, as %q is also set":          "ключ %q отброшен, так как задан и %q",
+	"-profile needs -config":                        "для -profile нужен -config",
+	"-in is required":                               "нужен -in",
+	"-out is required":                              "нужен -out",
+	"-src is required":                              "нужен -src",
+	"-in and -out are required":                     "нужны -in и -out",
+	"-jobs is required":                             "нужен -jobs",
+	"-name is required":                             "нужен -name",
+	"-parallel must be at least 1":                  "-parallel должен быть не меньше 1",
+	"seed must be an integer":                       "seed должен быть целым числом",
+	"max-ratio must be >= 0":                        "max-ratio должен быть >= 0",
+	"timeout must be a duration such as 30s or 10m": "timeout должен быть длительностью, например 30s или 10m",
+	"format must be zip or tar.gz":                  "format должен быть zip или tar.gz",
+	"-format cannot be combined with -no-rezip":     "-format нельзя сочетать с -no-rezip",
+	"-checkpoint cannot be combined with -format tar.gz or -list":          "-checkpoint нельзя сочетать с -format tar.gz или -list",
+	"-incremental needs -no-rezip and cannot be combined with -checkpoint": "для -incremental нужен -no-rezip, и его нельзя сочетать с -checkpoint",
+	"-entries cannot read the archive from stdin":                          "-entries не может читать архив со стандартного ввода",
+	"diff needs two archives":                                              "diff нужны два архива",
+	"unsupported encoding %q (available: %s)":                              "неподдерживаемая кодировка %q (доступны: %s)",
+	"the archive does not fit in %s: about %s needed, %s free":             "архив не поместится в %s: нужно около %s, свободно %s",
+	"%s already exists (use -force to overwrite)":                          "%s уже существует (перезапись: -force)",
+
+	// Config upgrades
+	"key %q: seeded noise now comes from a ChaCha20 stream, so archives differ from those of earlier releases": "ключ %q: шум с seed теперь берётся из потока ChaCha20, поэтому архивы отличаются от созданных прежними версиями",
+	"written for config version %d; update it and set \"version\": %d":                                         "файл написан для версии конфигурации %d; обновите его и укажите \"version\": %d",
+
+	// Command summaries
+	"Write a directory as a ZIP with noise, junk and a misleading layout":    "Записать папку в ZIP с шумом, мусором и запутанной структурой",
+	"Run encrypt once per job of a manifest":                                 "Выполнить encrypt для каждого задания из манифеста",
+	"Write an archive made only of plausible-looking noise":                  "Записать архив только из правдоподобного шума",
+	"Predict the size encrypt would write":                                   "Оценить размер архива, который запишет encrypt",
+	"Compare compression levels and worker counts on a sample":               "Сравнить уровни сжатия и число потоков на выборке",
+	"Strip the noise and rebuild a clean ZIP, directory or tar.gz":           "Убрать шум и собрать чистый ZIP, папку или tar.gz",
+	"Recover just the files, into a directory":                               "Восстановить только файлы в папку",
+	"Rewrite a standard ZIP around the original data, without recompressing": "Переписать стандартный ZIP вокруг исходных данных без повторного сжатия",
+	"Report what was done to an archive":                                     "Показать, что было сделано с архивом",
+	"Print the digest of an archive and, optionally, of each file in it":     "Вывести контрольную сумму архива и, по желанию, каждого файла в нём",
+	"Compare the real files of two archives":                                 "Сравнить настоящие файлы двух архивов",
+	"Read a report written by encrypt -report":                               "Прочитать отчёт, записанный encrypt -report",
+	"List filename encodings, or test how a name is stored and read back":    "Перечислить кодировки имён или проверить, как имя записывается и читается",
+	"Write a config file listing every key":                                  "Записать файл конфигурации со всеми ключами",
+	"Print the version and build details":                                    "Вывести версию и сведения о сборке",
+	"Show this help, or the options of a command":                            "Показать эту справку или параметры команды",
+
+	// Flags shared by several commands
+	"Show help":                "Показать справку",
+	"Path to JSON config file": "Путь к файлу конфигурации JSON",
+	"Named profile from the config file to apply over its top-level keys": "Профиль из файла конфигурации, применяемый поверх его ключей верхнего уровня",
+	"Input directory": "Исходная папка",
+	"Output ZIP path": "Путь к выходному ZIP",
+	"Input ZIP path, - for stdin (repeat for the parts of a split archive, in order)": "Путь к входному ZIP, - для стандартного ввода (повторите для частей разбитого архива по порядку)",
+	"Include hidden files":                                     "Включать скрытые файлы",
+	"Alias for -%s":                                            "То же, что -%s",
+	"Alias for -compression":                                   "То же, что -compression",
+	"Compression method: deflate or store":                     "Метод сжатия: deflate или store",
+	"Filename encoding: utf-8 or cp1251":                       "Кодировка имён файлов: utf-8 или cp1251",
+	"Deflate level (0-9)":                                      "Уровень deflate (0-9)",
+	"Deflate strategy: default, huffman":                       "Стратегия deflate: default, huffman",
+	"Deflate strategy: default, filtered, huffman, rle, fixed": "Стратегия deflate: default, filtered, huffman, rle, fixed",
+	"Worker goroutines: a number, auto (one per CPU) or a percentage of the CPUs such as 50%": "Число рабочих потоков: число, auto (по одному на процессор) или доля процессоров, например 50%",
+	"Save progress to this file and carry on from it when it exists":                          "Сохранять ход работы в этот файл и продолжать с него, если он есть",
+	"Directory for temp files (default: the OS temp directory)":                               "Папка для временных файлов (по умолчанию временная папка ОС)",
+	"Stage files up to this size in memory instead of temp files (0 = always use temp files)": "Держать файлы до этого размера в памяти вместо временных файлов (0 = всегда временные файлы)",
+	"Memory all files staged in memory may take together":                                     "Сколько памяти могут занимать все файлы, которые держатся в памяти",
+	"Size of the chunks files are read in and of the output write buffer":                     "Размер блоков, которыми читаются файлы, и буфера записи архива",
+	"Comma-separated charsets tried for non-UTF-8 names (%s)":                                 "Кодировки через запятую, которые пробуются для имён не в UTF-8 (%s)",
+	"Comma-separated encodings recover tries (default: %s)":                                   "Кодировки через запятую, которые пробует recover (по умолчанию: %s)",
+	"Ignore the central directory and scan for local headers":                                 "Игнорировать центральный каталог и искать локальные заголовки",
+	"Ignore the central directory and scan local headers":                                     "Игнорировать центральный каталог и искать локальные заголовки",
+	"Ignore the central directories and scan local headers":                                   "Игнорировать центральные каталоги и искать локальные заголовки",
+	"Directory noise entries were written under (empty disables)":                             "Папка, в которую записаны шумовые записи (пусто — отключить)",
+	"Strip padding added by -pad-names":                                                       "Убрать дополнение имён, добавленное -pad-names",
+	"Strip name padding added by -pad-names":                                                  "Убрать дополнение имён, добавленное -pad-names",
+	"Print the result as JSON":                                                                "Вывести результат в формате JSON",
+	"Print the result as JSON on stdout":                                                      "Вывести результат в формате JSON на stdout",
+	"Print the result as JSON on stdout (logs stay on stderr)":                                "Вывести результат в формате JSON на stdout (журнал остаётся в stderr)",
+	"Print errors only": "Выводить только ошибки",
+	"Print a line per file; repeat (-v -v) for debug output": "Выводить строку на каждый файл; повторите (-v -v) для отладочного вывода",
+	"Same as -v": "То же, что -v",
+	"Also append every log and progress line, with a timestamp, to this file":                        "Также дописывать каждую строку журнала и хода работы с отметкой времени в этот файл",
+	"Rotate -log-file once it reaches this size, keeping %d old files":                               "Ротировать -log-file по достижении этого размера, храня %d старых файла",
+	"Write progress as NDJSON events to this file descriptor (e.g. 3; 1 for stdout, not with -json)": "Писать ход работы событиями NDJSON в этот файловый дескриптор (например 3; 1 — stdout, но не вместе с -json)",
+
+	// Passwords
+	"password":        "пароль",
+	"report password": "пароль отчёта",
+	"Password for ZipCrypto and WinZip AES entries":                                 "Пароль для записей ZipCrypto и WinZip AES",
+	"Password for ZipCrypto and WinZip AES entries, used for both archives":         "Пароль для записей ZipCrypto и WinZip AES, общий для обоих архивов",
+	"Password for encrypted entries (ZipCrypto or AES)":                             "Пароль для зашифрованных записей (ZipCrypto или AES)",
+	"Password used with -report-password":                                           "Пароль, заданный в -report-password",
+	"Encrypt the report with this password":                                         "Зашифровать отчёт этим паролем",
+	"%s (shows up in shell history and process listings; prefer the -%s-* options)": "%s (попадает в историю оболочки и список процессов; лучше параметры -%s-*)",
+	"Ask for the %s on the terminal":                                                "Запросить %s в терминале",
+	"Read the %s from this environment variable":                                    "Прочитать %s из этой переменной окружения",
+	"Read the %s from the first line of this file (- for stdin)":                    "Прочитать %s из первой строки этого файла (- для стандартного ввода)",
+	"Enter %s: ":  "Введите %s: ",
+	"Repeat %s: ": "Повторите %s: ",
+
+	// encrypt, decoy and estimate
+	"Do not overwrite central directory": "Не перезаписывать центральный каталог",
+	"ZIP comment junk size (bytes)":      "Размер мусорного комментария ZIP (байт)",
+	"Overwrite file timestamps":          "Перезаписать время изменения файлов",
+	"Output ZIP name with placeholders filled in per run: {date}, {time}, {seed}, {src}, {randN}; -out wins": "Имя выходного ZIP с подстановками при каждом запуске: {date}, {time}, {seed}, {src}, {randN}; -out важнее",
+	"Number of noise files":              "Число шумовых файлов",
+	"Size of each noise file (bytes)":    "Размер каждого шумового файла (байт)",
+	"Noise generator: %s":                "Генератор шума: %s",
+	"Deterministic noise seed (integer)": "Начальное значение для воспроизводимого шума (целое число)",
+	"Archive the files listed in this file (one path per line, relative to -src; - for stdin) instead of walking -src": "Архивировать файлы из этого списка (по пути на строку относительно -src; - для стандартного ввода) вместо обхода -src",
+	"Only take files this many directories deep; 1 is -src itself (0 = no limit)":                                      "Брать файлы не глубже этого числа уровней; 1 — сама -src (0 — без ограничения)",
+	"Add decoy README.txt/passwords.txt files":                                                                         "Добавить файлы-приманки README.txt/passwords.txt",
+	"Store the data of identical files once, the copies pointing at it":                                                "Хранить данные одинаковых файлов один раз, копии ссылаются на них",
+	"Pad all entry names to a uniform length":                                                                          "Дополнить все имена записей до одной длины",
+	"Pad deflated streams to a multiple of this size (e.g. 4k, 64k)":                                                   "Дополнить сжатые потоки до кратного этому размеру (например 4k, 64k)",
+	"Write an obfuscation report (JSON) to this path":                                                                  "Записать отчёт о запутывании (JSON) по этому пути",
+	"Number of decoy entries":                                                                                          "Число записей-приманок",
+	"Total payload size (e.g. 512k, 100m, 2g)":                                                                         "Общий объём данных (например 512k, 100m, 2g)",
+	"Source data compressed to measure the ratio (0 for all of it)":                                                    "Объём исходных данных, сжимаемых для оценки степени сжатия (0 — все)",
+	"Compress every file with a fast level-1 pass instead of sampling":                                                 "Сжать каждый файл быстрым проходом уровня 1 вместо выборки",
+
+	// recover and extract
+	"Output ZIP path (output directory with -no-rezip)":                                                "Путь к выходному ZIP (выходная папка при -no-rezip)",
+	"Write recovered files to the -out directory instead of rebuilding a ZIP":                          "Записать восстановленные файлы в папку -out вместо сборки ZIP",
+	"Output format: zip or tar.gz (tar.gz streams files into the archive without a temp directory)":    "Формат вывода: zip или tar.gz (tar.gz пишет файлы в архив потоком, без временной папки)",
+	"List recoverable entries without writing anything":                                                "Перечислить восстановимые записи, ничего не записывая",
+	"Recover only entries matching this glob (repeatable, ** matches directories)":                     "Восстановить только записи по этому шаблону (можно повторять, ** совпадает с папками)",
+	"Extract only entries matching this glob (repeatable, ** matches directories)":                     "Извлечь только записи по этому шаблону (можно повторять, ** совпадает с папками)",
+	"Compare only entries matching this glob (repeatable, ** matches directories)":                     "Сравнить только записи по этому шаблону (можно повторять, ** совпадает с папками)",
+	"Skip entries matching this glob (repeatable)":                                                     "Пропустить записи по этому шаблону (можно повторять)",
+	"Leave out entries matching this glob (repeatable)":                                                "Не учитывать записи по этому шаблону (можно повторять)",
+	"Largest decoded entry kept in memory (e.g. 256m); bigger ones stream to disk":                     "Наибольшая распакованная запись, хранимая в памяти (например 256m); большие пишутся на диск",
+	"Abandon entries that decode to more than this (e.g. 4g)":                                          "Бросить записи, распаковывающиеся больше чем в этот размер (например 4g)",
+	"Stop writing once the recovered data reaches this size":                                           "Остановить запись, когда восстановленные данные достигнут этого размера",
+	"Abandon entries that expand more than this many times their compressed size":                      "Бросить записи, которые распаковываются больше чем во столько раз их сжатого размера",
+	"Write a JSON manifest (path, size, SHA-256, offset) of the recovered files":                       "Записать манифест JSON (путь, размер, SHA-256, смещение) восстановленных файлов",
+	"Order of recovered files and of the rebuilt ZIP: offset or name":                                  "Порядок восстановленных файлов и собранного ZIP: offset или name",
+	"What to do with .. components and absolute names: strip, replace (with _) or reject":              "Что делать с компонентами .. и абсолютными именами: strip, replace (на _) или reject",
+	"Only accept entries with a verified CRC and an unambiguous name":                                  "Принимать только записи с проверенным CRC и однозначным именем",
+	"Write the archive and entry comments (JSON) to this path":                                         "Записать комментарии архива и записей (JSON) по этому пути",
+	"With -no-rezip, skip entries whose files are already in -out from an earlier -incremental run":    "При -no-rezip пропускать записи, файлы которых уже есть в -out после прошлого запуска с -incremental",
+	"Save progress to this file and resume from it when it exists; the archive is still read whole":    "Сохранять ход работы в этот файл и продолжать с него, если он есть; архив всё равно читается целиком",
+	"Directory for the recovered files before the ZIP is rebuilt (default: the OS temp directory)":     "Папка для восстановленных файлов до сборки ZIP (по умолчанию временная папка ОС)",
+	"Give up recovery after this long (e.g. 30s, 10m)":                                                 "Прекратить восстановление через это время (например 30s, 10m)",
+	"Write entries classified as junk to this directory for inspection":                                "Записать записи, признанные мусором, в эту папку для изучения",
+	"Write entries classified as junk to this directory instead of dropping them":                      "Записать записи, признанные мусором, в эту папку вместо отбрасывания",
+	"Treat entries matching this glob as junk (repeatable)":                                            "Считать мусором записи по этому шаблону (можно повторять)",
+	"Count entries matching this glob as junk (repeatable)":                                            "Считать мусором записи по этому шаблону (можно повторять)",
+	"Drop entries matching this glob as junk (repeatable)":                                             "Отбросить как мусор записи по этому шаблону (можно повторять)",
+	"Print the result (counts, paths, errors, per-entry data) as JSON on stdout; logs stay on stderr":  "Вывести результат (счётчики, пути, ошибки, данные записей) в формате JSON на stdout; журнал остаётся в stderr",
+	"Rebuild each file with its original method and deflate level hint instead of -compression/-level": "Собрать каждый файл с исходным методом и уровнем deflate вместо -compression/-level",
+	"Recover nested ZIPs found inside the archive, up to this depth":                                   "Восстанавливать вложенные ZIP внутри архива до этой глубины",
+	"Directory to extract into":                                                                        "Папка для извлечения",
+
+	// batch and bench
+	"Job manifest (JSON)":                 "Манифест заданий (JSON)",
+	"Number of jobs run at the same time": "Число заданий, выполняемых одновременно",
+	"Print the result of every job as JSON on stdout (logs stay on stderr)": "Вывести результат каждого задания в формате JSON на stdout (журнал остаётся в stderr)",
+	"jobs: %v":                          "задания: %v",
+	"Directory to take the sample from": "Папка, из которой берётся выборка",
+	"Bench a synthetic corpus of -sample bytes instead of -src: %s":                    "Проверить на синтетическом наборе размером -sample вместо -src: %s",
+	"Also recover every archive and report the time it took":                           "Также восстановить каждый архив и показать, сколько это заняло",
+	"Comma-separated deflate levels to try (store is always tried)":                    "Уровни deflate через запятую (store пробуется всегда)",
+	"Comma-separated worker counts to try (auto and percentages such as 50% work too)": "Число потоков через запятую (подходят также auto и проценты, например 50%)",
+	"Most source data to put in the sample (0 for all of it)":                          "Наибольший объём исходных данных в выборке (0 — все)",
+	"Directory for the test archives and temp files (default: OS temp directory)":      "Папка для пробных архивов и временных файлов (по умолчанию временная папка ОС)",
+	"Print the results as JSON on stdout":                                              "Вывести результаты в формате JSON на stdout",
+
+	// checksum, diff, encodings, report, init-config, version
+	"Digest algorithm (%s)": "Алгоритм контрольной суммы (%s)",
+	"Also hash the decoded data of every file recover would write": "Также посчитать сумму распакованных данных каждого файла, который записал бы recover",
+	"Print the differences as JSON on stdout":                      "Вывести различия в формате JSON на stdout",
+	"Encoding to store the name with, as for noise mode":           "Кодировка для записи имени, как в режиме шума",
+	"Filename to try":                          "Проверяемое имя файла",
+	"Print the lists as JSON":                  "Вывести списки в формате JSON",
+	"test options:":                            "параметры test:",
+	"Report file written by -report":           "Файл отчёта, записанный -report",
+	"Config file to write, - for stdout":       "Записываемый файл конфигурации, - для stdout",
+	"Overwrite an existing file":               "Перезаписать существующий файл",
+	"Print version and build settings as JSON": "Вывести версию и параметры сборки в формате JSON",
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// configKeyDoc describes one config file key for init-config. An empty key
+// starts a new section titled doc.
+type configKeyDoc struct {
+	key   string
+	value string
+	doc   string
+}
+
+// configKeyDocs lists every key of fileConfig with its default value, in the
+// order init-config writes them.
+var configKeyDocs = []configKeyDoc{
+	{doc: "Shared by noise, decoy and the ZIP rebuilt by recover"},
+	{"compression", `"deflate"`, `Compression method: deflate or store.`},
+	{"encoding", `"utf-8"`, "Filename encoding of written entries: utf-8 or cp1251."},
+	{"level", "6", "Deflate level, 0-9."},
+	{"strategy", `"default"`, "Deflate strategy: default or huffman."},
+	{"workers", "1", `Worker goroutines: a number, "auto" (one per CPU) or a percentage of the CPUs such as "50%".`},
+	{"seed", `"123"`, "Fixed seed (integer) for reproducible noise; unset means random."},
+	{"include-hidden", "false", "Include hidden files."},
+	{"tmp-dir", `"/var/tmp"`, "Directory for temp files; unset means the OS temp directory."},
+	{"log-file", `"noisyzip.log"`, "Also append every log and progress line, with a timestamp, to this file."},
+	{"log-max-size", `"10m"`, "Rotate log-file once it reaches this size, keeping 3 old files."},
+
+	{doc: "Noise (noisyzip -src <dir> -out <zip>) and decoy"},
+	{"src", `"path/to/folder"`, "Input directory."},
+	{"files-from", `"files.txt"`, "Archive the files listed here (one per line, relative to src) instead of walking src."},
+	{"max-depth", "0", "Only take files this many directories deep, 1 being src itself; 0 means no limit."},
+	{"out", `"path/to/out.zip"`, "Output ZIP; the output directory for recover -no-rezip and extract."},
+	{"out-template", `"backup-{date}-{rand6}.zip"`, "noise: output ZIP name with {date}, {time}, {seed}, {src} and {randN} filled in per run; out wins."},
+	{"no-overwrite-cdir", "false", "Keep the central directory instead of overwriting it."},
+	{"comment-size", "0", "ZIP comment junk size, 0-65535 bytes."},
+	{"fixed-time", "false", "Overwrite file timestamps."},
+	{"noise-files", "0", "Number of noise files."},
+	{"noise-size", "0", "Size of each noise file in bytes."},
+	{"noise-generator", `"random"`, "Noise generator: random or decoy."},
+	{"bait", "false", "Add decoy top-level files with fake content."},
+	{"dedup", "false", "noise: store identical files once, the copies pointing at the same data."},
+	{"pad-names", "false", "Pad all entry names to the same length."},
+	{"pad-bucket", `"64k"`, "Pad deflated streams to a multiple of this size."},
+	{"mem-entry-size", `"4m"`, "noise: stage files up to this size in memory instead of temp files; 0 turns it off."},
+	{"mem-total", `"64m"`, "noise: memory the files staged in memory may take together."},
+	{"buffer-size", `"1m"`, "noise: size of the chunks files are read in and of the output write buffer; raise it on network filesystems."},
+	{"resume", `"backup.resume"`, "noise: save progress to this file and carry on from it after a crash or Ctrl-C."},
+	{"report", `"report.json"`, "Write a JSON report of the applied obfuscations."},
+	{"entries", "50", "decoy: number of decoy entries."},
+	{"total-size", `"10m"`, "decoy: total payload size."},
+
+	{doc: "Recover, extract, repair, inspect, diff and checksum"},
+	{"in", `"path/to/input.zip"`, "Input ZIP, or an array with the parts of a split archive in order."},
+	{"no-rezip", "false", "Write the recovered files into the out directory instead of rebuilding a ZIP."},
+	{"format", `"zip"`, "Output format: zip or tar.gz."},
+	{"list", "false", "Only list the entries that would be recovered."},
+	{"only", `["docs/**"]`, "Recover only entries matching these globs."},
+	{"skip", `["*.iso"]`, "Skip entries matching these globs."},
+	{"junk-prefix", `".junk"`, "Directory noise entries were written under; empty turns the rule off."},
+	{"junk", `["*.bin"]`, "Treat entries matching these globs as junk too."},
+	{"keep-junk", `"junk"`, "Write junk entries to this directory instead of dropping them."},
+	{"unpad-names", "false", "Strip the padding added by pad-names."},
+	{"force-scan", "false", "Ignore the central directory and scan for local headers."},
+	{"encodings", `["utf-8", "cp866", "cp1251", "cp437"]`, "Charsets tried for names without the UTF-8 flag."},
+	{"recurse", "0", "Recover ZIPs inside the archive up to this depth."},
+	{"max-memory", `"256m"`, "Largest decoded entry kept in memory; unset means no limit."},
+	{"max-entry-size", `"4g"`, "Abandon entries that decode to more than this."},
+	{"max-total-size", `"20g"`, "Stop once the recovered data reaches this size."},
+	{"max-ratio", "1000", "Abandon entries that expand more than this many times."},
+	{"manifest", `"manifest.json"`, "Write a manifest (path, size, SHA-256, offset) of the recovered files."},
+	{"comments", `"comments.json"`, "Write the archive and entry comments to this file."},
+	{"order", `"offset"`, "Order of recovered files: offset or name."},
+	{"path-policy", `"strip"`, "Unsafe names: strip, replace or reject."},
+	{"strict", "false", "Accept only entries with a verified CRC and an unambiguous name."},
+	{"keep-methods", "false", "Rebuild each file with its original compression method and level."},
+	{"checkpoint", `"recover.checkpoint"`, "Save progress to this file and resume from it."},
+	{"incremental", "false", "With no-rezip, skip files already written by an earlier incremental run."},
+	{"timeout", `"10m"`, "Give up after this long."},
+	{"digest", `"sha256"`, "Digest algorithm of checksum: sha256 or blake3."},
+
+	{doc: "Named profiles, picked with -profile <name>; their keys replace the ones above"},
+	{"profiles", `{"fast": {"compression": "store"}, "paranoid": {"level": 9, "noise-files": 200, "bait": true}}`, "Profiles by name."},
+}
+
+// renderDefaultConfig writes a config file in which every key is present
+// but commented out, with its default or an example value.
+func renderDefaultConfig(w io.Writer) {
+	fmt.Fprintln(w, "// NoisyZip config file, passed with -config. Flags given on the command line")
+	fmt.Fprintln(w, "// override it. Remove the // in front of a key to use it. Passwords are never")
+	fmt.Fprintln(w, "// read from here.")
+	fmt.Fprintln(w, "{")
+	fmt.Fprintln(w, "  // Config format version; older files are upgraded with a warning.")
+	fmt.Fprintf(w, "  \"version\": %d,\n", configVersion)
+	fmt.Fprintln(w, "")
+	for i, k := range configKeyDocs {
+		if k.key == "" {
+			if i > 0 {
+				fmt.Fprintln(w, "")
+			}
+			fmt.Fprintf(w, "  // ---- %s ----\n", k.doc)
+			continue
+		}
+		fmt.Fprintf(w, "  // %s\n", k.doc)
+		fmt.Fprintf(w, "  // %q: %s,\n", k.key, k.value)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+type initConfigOptions struct {
+	help  bool
+	out   string
+	force bool
+}
+
+func newInitConfigFlagSet(output io.Writer) (*flag.FlagSet, *initConfigOptions) {
+	opts := &initConfigOptions{out: "noisyzip.json"}
+	fs := flag.NewFlagSet("init-config", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.out, "out", opts.out, "Config file to write, - for stdout")
+	fs.BoolVar(&opts.force, "force", false, "Overwrite an existing file")
+	addFlagAliases(fs)
+	return fs, opts
+}
+
+func printInitConfigHelp(w io.Writer) {
+	fs, _ := newInitConfigFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip init-config [-out <file>] [-force]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Writes a config file listing every supported key, commented out, with its default.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+func runInitConfig(args []string) int {
+	flags, opts := newInitConfigFlagSet(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		printError("%v", err)
+		printInitConfigHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printInitConfigHelp(os.Stdout)
+		return 0
+	}
+	out := strings.TrimSpace(opts.out)
+	if out == "" {
+		printError("-out is required")
+		return ExitUsage
+	}
+	if out == "-" {
+		renderDefaultConfig(os.Stdout)
+		return 0
+	}
+
+	mode := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if opts.force {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(out, mode, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		printError("%s already exists (use -force to overwrite)", out)
+		return ExitFailure
+	}
+	if err != nil {
+		printError("%v", err)
+		return ExitIO
+	}
+	renderDefaultConfig(f)
+	if err := f.Close(); err != nil {
+		printError("%v", err)
+		return ExitIO
+	}
+	fmt.Fprintf(os.Stdout, "Wrote %s\n", out)
+	return 0
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type inspectOptions struct {
+	help       bool
+	configPath string
+	profile    string
+	inZip      stringListFlag
+	encodings  string
+	junk       stringListFlag
+	junkPrefix string
+	asJSON     bool
+}
+
+func newInspectFlagSet(output io.Writer) (*flag.FlagSet, *inspectOptions) {
+	opts := &inspectOptions{junkPrefix: ".junk"}
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.configPath, "config", "", "Path to JSON config file")
+	fs.StringVar(&opts.profile, "profile", "", "Named profile from the config file to apply over its top-level keys")
+	fs.Var(&opts.inZip, "in", "Input ZIP path, - for stdin (repeat for the parts of a split archive, in order)")
+	fs.StringVar(&opts.encodings, "encodings", "", trf("Comma-separated charsets tried for non-UTF-8 names (%s)", strings.Join(core.NameDecoders(), ", ")))
+	fs.Var(&opts.junk, "junk", "Count entries matching this glob as junk (repeatable)")
+	fs.StringVar(&opts.junkPrefix, "junk-prefix", opts.junkPrefix, "Directory noise entries were written under (empty disables)")
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the result as JSON on stdout")
+	addFlagAliases(fs)
+	return fs, opts
+}
+
+func printInspectHelp(w io.Writer) {
+	fs, _ := newInspectFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip inspect -in <zip> [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Reports the obfuscations found in an archive without recovering it.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+func runInspect(args []string) int {
+	fs, opts := newInspectFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printInspectHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printInspectHelp(os.Stdout)
+		return 0
+	}
+	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
+		cfg, err := readConfig(cfgPath, opts.profile)
+		if err != nil {
+			printError("config: %v", err)
+			return ExitUsage
+		}
+		applyInspectConfig(opts, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+
+	inParts, err := splitParts(opts.inZip)
+	if err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+	if len(inParts) == 0 {
+		printError("-in is required")
+		printInspectHelp(os.Stderr)
+		return ExitUsage
+	}
+	inZip := inParts[0]
+	if len(inParts) < 2 {
+		inParts = nil
+	}
+
+	rep, err := core.InspectZip(core.InspectConfig{
+		InZip:     inZip,
+		InParts:   inParts,
+		Encodings: splitList(opts.encodings),
+		Junk:      junkPatterns(opts.junkPrefix, opts.junk),
+	})
+	if err != nil {
+		return failJSON(opts.asJSON, "inspect", err)
+	}
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "inspect", OK: true, Report: rep})
+		return 0
+	}
+	printInspectReport(os.Stdout, rep)
+	return 0
+}
+
+func printInspectReport(w io.Writer, rep core.InspectReport) {
+	fmt.Fprintf(w, "Size: %d\n", rep.Size)
+	fmt.Fprintf(w, "Central directory: %s\n", rep.CentralDir)
+	fmt.Fprintf(w, "Entries: %d (from %s)\n", rep.Entries, rep.Source)
+	fmt.Fprintf(w, "Junk entries: %d\n", rep.Junk)
+	fmt.Fprintf(w, "Hidden sizes: %d\n", rep.HiddenSizes)
+	fmt.Fprintf(w, "Scrambled CRCs: %d\n", rep.ScrambledCRCs)
+	fmt.Fprintf(w, "Padded names: %d\n", rep.PaddedNames)
+	fmt.Fprintf(w, "Padded streams: %d\n", rep.PaddedStreams)
+	fmt.Fprintf(w, "Encrypted entries: %d\n", rep.Encrypted)
+	fmt.Fprintf(w, "Fake EOCDs: %d\n", rep.FakeEOCDs)
+	fmt.Fprintf(w, "Fake central directories: %d\n", rep.FakeCentralDirs)
+	fmt.Fprintf(w, "Poison tail: %d bytes\n", rep.PoisonTail)
+	fmt.Fprintf(w, "Comment junk: %d bytes\n", rep.CommentJunk)
+	for _, p := range rep.Poison {
+		fmt.Fprintf(w, "Poison: %s at %d (%d bytes): %s\n", p.Kind, p.Offset, p.Size, p.Detail)
+	}
+	verdict := "no"
+	if rep.NoisyZip {
+		verdict = "yes"
+	}
+	fmt.Fprintf(w, "NoisyZip signature: %s\n", verdict)
+}
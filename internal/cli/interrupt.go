@@ -0,0 +1,15 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptContext is canceled by Ctrl-C or SIGTERM, so the run can stop its
+// workers and remove temp files and partial output before exiting with
+// ExitInterrupted. A second signal kills the process as usual.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// jsonResult is what -json prints on stdout in place of the text summary.
+// Logs and progress stay on stderr, so stdout holds only this document.
+type jsonResult struct {
+	Command string `json:"command"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	// Kind names the exit code of a failed or partial run (see exitKinds).
+	Kind   string `json:"kind,omitempty"`
+	Output string `json:"output,omitempty"`
+	// Files is the number of files written into a ZIP.
+	Files  int `json:"files,omitempty"`
+	Report any `json:"report,omitempty"`
+}
+
+func writeJSON(w io.Writer, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		printError("%v", err)
+		return
+	}
+	fmt.Fprintln(w, string(data))
+}
+
+// failJSON reports a failed run: the error on stderr as usual and, with
+// -json, an ok=false result on stdout. It returns the exit code err
+// classifies as.
+func failJSON(asJSON bool, command string, err error) int {
+	code := exitCode(err)
+	if code == ExitInterrupted {
+		fmt.Fprintln(os.Stderr, tr("Interrupted"))
+	} else {
+		printError("%v", err)
+	}
+	logAudit("Error: " + err.Error())
+	if asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: command, Error: err.Error(), Kind: exitKinds[code]})
+	}
+	return code
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logBackups is how many rotated log files (path.1 is the newest) are kept
+// next to the one being written.
+const logBackups = 3
+
+const defaultLogMaxSize = "10m"
+
+// rotatingLog appends timestamped lines to a file and moves it aside once
+// it grows past maxSize. Batch jobs share it, so it locks.
+type rotatingLog struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	f       *os.File
+	size    int64
+}
+
+// auditLog is the -log-file of the running command; nil when there is none.
+// It gets every log and progress line whatever the verbosity.
+var auditLog *rotatingLog
+
+func openRotatingLog(path string, maxSize int64) (*rotatingLog, error) {
+	l := &rotatingLog{path: path, maxSize: maxSize}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	if l.size >= maxSize {
+		if err := l.rotate(); err != nil {
+			l.f.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *rotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, dropping the oldest, moves the current
+// file to path.1 and starts an empty one.
+func (l *rotatingLog) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	for i := logBackups - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// println writes msg as one line. A write error is reported once on stderr
+// and turns the log off: it must not fail the run it records.
+func (l *rotatingLog) println(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	line := time.Now().Format(time.RFC3339) + " " + strings.TrimRight(msg, "\n") + "\n"
+	var err error
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		err = l.rotate()
+	}
+	if err == nil {
+		var n int
+		n, err = l.f.WriteString(line)
+		l.size += int64(n)
+	}
+	if err != nil {
+		printError("log-file: %v", err)
+		if l.f != nil {
+			l.f.Close()
+		}
+		l.f = nil
+	}
+}
+
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// openAuditLog starts the -log-file of command, if one was given.
+func (v verbosityFlags) openAuditLog(command string) error {
+	path := strings.TrimSpace(v.logFile)
+	if path == "" {
+		return nil
+	}
+	maxSize, err := parseByteSize(v.logMaxSize)
+	if err != nil {
+		return fmt.Errorf("-log-max-size: %w", err)
+	}
+	if maxSize <= 0 {
+		return errors.New("-log-max-size must be positive")
+	}
+	l, err := openRotatingLog(path, maxSize)
+	if err != nil {
+		return err
+	}
+	auditLog = l
+	logAudit("Start: " + command)
+	return nil
+}
+
+// logAudit writes msg to the -log-file, if any.
+func logAudit(msg string) {
+	if auditLog != nil {
+		auditLog.println(msg)
+	}
+}
+
+// closeAuditLog records the exit code and closes the -log-file.
+func closeAuditLog(code int) {
+	if auditLog == nil {
+		return
+	}
+	logAudit(fmt.Sprintf("Exit: %d", code))
+	auditLog.Close()
+	auditLog = nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"crypto/rand"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// outPlaceholder matches a {name} or {nameN} placeholder of -out-template.
+var outPlaceholder = regexp.MustCompile(`\{([a-z]+)(\d*)\}`)
+
+// randAlphabet is what {randN} is drawn from: it survives every file system
+// and needs no quoting in a shell.
+const randAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// expandOutTemplate fills in the placeholders of an -out-template:
+//
+//	{date}   the local date, 20060102
+//	{time}   the local time, 150405
+//	{seed}   the -seed value, or "random" without one
+//	{src}    the base name of the source directory
+//	{randN}  N random letters and digits, 1 to 64 of them
+//
+// An unknown placeholder is an error, so a typo does not end up in every
+// archive name.
+func expandOutTemplate(tmpl string, now time.Time, seed, src string) (string, error) {
+	var firstErr error
+	out := outPlaceholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+		fail := func(err error) string {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return m
+		}
+		parts := outPlaceholder.FindStringSubmatch(m)
+		name, digits := parts[1], parts[2]
+		if name == "rand" && digits != "" {
+			n, _ := strconv.Atoi(digits)
+			if n < 1 || n > 64 {
+				return fail(fmt.Errorf("%s: the length must be 1 to 64", m))
+			}
+			return randomName(n)
+		}
+		if digits != "" {
+			return fail(fmt.Errorf("unknown placeholder %s", m))
+		}
+		switch name {
+		case "date":
+			return now.Format("20060102")
+		case "time":
+			return now.Format("150405")
+		case "seed":
+			if seed == "" {
+				return "random"
+			}
+			return seed
+		case "src":
+			return filepath.Base(filepath.Clean(src))
+		case "rand":
+			return fail(fmt.Errorf("%s needs a length, such as {rand6}", m))
+		}
+		return fail(fmt.Errorf("unknown placeholder %s (use {date}, {time}, {seed}, {src} or {randN})", m))
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("%q expands to an empty name", tmpl)
+	}
+	return out, nil
+}
+
+// randomName is n characters of randAlphabet from crypto/rand.
+func randomName(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	for i := range b {
+		b[i] = randAlphabet[int(b[i])%len(randAlphabet)]
+	}
+	return string(b)
+}
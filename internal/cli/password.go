@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// passwordFlags back a password option and the ways to pass it without
+// putting it on the command line: -NAME itself, -NAME-prompt, -NAME-env
+// and -NAME-file.
+type passwordFlags struct {
+	name   string
+	value  string
+	prompt bool
+	env    string
+	file   string
+}
+
+func addPasswordFlags(fs *flag.FlagSet, p *passwordFlags, name, usage string) {
+	p.name = name
+	what := strings.ReplaceAll(name, "-", " ")
+	fs.StringVar(&p.value, name, "", trf("%s (shows up in shell history and process listings; prefer the -%s-* options)", tr(usage), name))
+	fs.BoolVar(&p.prompt, name+"-prompt", false, trf("Ask for the %s on the terminal", tr(what)))
+	fs.StringVar(&p.env, name+"-env", "", trf("Read the %s from this environment variable", tr(what)))
+	fs.StringVar(&p.file, name+"-file", "", trf("Read the %s from the first line of this file (- for stdin)", tr(what)))
+}
+
+// resolve fills in value from whichever source was given. At most one may
+// be. confirm asks twice at the prompt, for passwords that encrypt
+// something.
+func (p *passwordFlags) resolve(confirm bool) error {
+	given := 0
+	for _, set := range []bool{p.value != "", p.prompt, p.env != "", p.file != ""} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return fmt.Errorf("use only one of -%[1]s, -%[1]s-prompt, -%[1]s-env and -%[1]s-file", p.name)
+	}
+	what := strings.ReplaceAll(p.name, "-", " ")
+	switch {
+	case p.env != "":
+		val, ok := os.LookupEnv(p.env)
+		if !ok {
+			return fmt.Errorf("-%s-env: %s is not set", p.name, p.env)
+		}
+		if val == "" {
+			return fmt.Errorf("-%s-env: %s is empty", p.name, p.env)
+		}
+		p.value = val
+	case p.file != "":
+		val, err := readPasswordFile(p.file)
+		if err != nil {
+			return fmt.Errorf("-%s-file: %w", p.name, err)
+		}
+		p.value = val
+	case p.prompt:
+		val, err := promptPassword(what, confirm)
+		if err != nil {
+			return fmt.Errorf("-%s-prompt: %w", p.name, err)
+		}
+		p.value = val
+	}
+	return nil
+}
+
+// readPasswordFile returns the first line of path, or of stdin for "-",
+// without its line ending.
+func readPasswordFile(path string) (string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("the password is empty")
+	}
+	return line, nil
+}
+
+// promptPassword reads a password from the terminal without echoing it. The
+// prompt goes to stderr so that stdout stays clean for -json.
+func promptPassword(what string, confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("stdin is not a terminal")
+	}
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	val, err := read(trf("Enter %s: ", tr(what)))
+	if err != nil {
+		return "", err
+	}
+	if val == "" {
+		return "", errors.New("the password is empty")
+	}
+	if confirm {
+		again, err := read(trf("Repeat %s: ", tr(what)))
+		if err != nil {
+			return "", err
+		}
+		if again != val {
+			return "", errors.New("the passwords do not match")
+		}
+	}
+	return val, nil
+}
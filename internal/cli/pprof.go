@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"strings"
+)
+
+// selectPprof takes the hidden -pprof flag, which may appear anywhere before
+// a "--" like -lang, and returns args without it and its address.
+func selectPprof(args []string) ([]string, string, error) {
+	var addr string
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			out = append(out, args[i:]...)
+			break
+		}
+		name, val, hasVal := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "pprof" {
+			out = append(out, arg)
+			continue
+		}
+		if !hasVal {
+			if i+1 >= len(args) {
+				return nil, "", fmt.Errorf("flag needs an argument: -pprof")
+			}
+			i++
+			val = args[i]
+		}
+		addr = strings.TrimSpace(val)
+	}
+	return out, addr, nil
+}
+
+// startPprof serves the net/http/pprof handlers on addr for the rest of the
+// process, so a slow run can be profiled while it goes, e.g. with
+// go tool pprof http://localhost:6060/debug/pprof/profile?seconds=30.
+func startPprof(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("pprof: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "pprof: serving http://%s/debug/pprof/\n", ln.Addr())
+	go http.Serve(ln, nil)
+	return nil
+}
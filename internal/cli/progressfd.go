@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// progressEvent is one NDJSON line of -progress-fd: "start" once, then
+// "progress" per entry, then "end" with the exit code.
+type progressEvent struct {
+	Event   string `json:"event"`
+	Command string `json:"command"`
+	// Job is the batch job the line belongs to, such as "2/5 b.zip".
+	Job   string `json:"job,omitempty"`
+	Done  int    `json:"done,omitempty"`
+	Total int    `json:"total,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Bytes is the source data of the entries done so far, where the
+	// command knows it.
+	Bytes int64 `json:"bytes,omitempty"`
+	Exit  *int  `json:"exit,omitempty"`
+}
+
+// progressStream writes progress events to the -progress-fd descriptor.
+// Batch jobs share it, so it locks.
+type progressStream struct {
+	mu      sync.Mutex
+	w       io.WriteCloser
+	command string
+	enc     *json.Encoder
+}
+
+// progressOut is the -progress-fd of the running command; nil when there is
+// none.
+var progressOut *progressStream
+
+// openProgressFD starts the -progress-fd stream of command, if one was given.
+// Stdout is refused when it carries the -json result, as the two would
+// interleave into a stream neither reader could parse.
+func (v verbosityFlags) openProgressFD(command string, jsonStdout bool) error {
+	if v.progressFD == 0 {
+		return nil
+	}
+	if v.progressFD < 0 {
+		return fmt.Errorf("-progress-fd must be a file descriptor number")
+	}
+	var w io.WriteCloser
+	switch v.progressFD {
+	case 1:
+		if jsonStdout {
+			return fmt.Errorf("-progress-fd 1 cannot be combined with -json, which prints to stdout; use 2 or another descriptor")
+		}
+		w = os.Stdout
+	case 2:
+		w = os.Stderr
+	default:
+		f := os.NewFile(uintptr(v.progressFD), "progress-fd")
+		if f == nil {
+			return fmt.Errorf("-progress-fd %d is not open", v.progressFD)
+		}
+		if _, err := f.Stat(); err != nil {
+			return fmt.Errorf("-progress-fd %d is not open", v.progressFD)
+		}
+		w = f
+	}
+	progressOut = &progressStream{w: w, command: command, enc: json.NewEncoder(w)}
+	progressOut.emit(progressEvent{Event: "start"})
+	return nil
+}
+
+// emit writes ev as one line. Write errors are ignored: a reader that went
+// away must not fail the run.
+func (p *progressStream) emit(ev progressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev.Command = p.command
+	_ = p.enc.Encode(ev)
+}
+
+// closeProgressFD sends the "end" event. Stdout and stderr stay open.
+func closeProgressFD(code int) {
+	if progressOut == nil {
+		return
+	}
+	progressOut.emit(progressEvent{Event: "end", Exit: &code})
+	if progressOut.w != os.Stdout && progressOut.w != os.Stderr {
+		progressOut.w.Close()
+	}
+	progressOut = nil
+}
+
+// sourceSizes is a sizeOf for entries named by their path under srcDir.
+// Noise and bait entries are not there and count as 0.
+func sourceSizes(srcDir string) func(name string) int64 {
+	return func(name string) int64 {
+		info, err := os.Stat(filepath.Join(srcDir, filepath.FromSlash(name)))
+		if err != nil || !info.Mode().IsRegular() {
+			return 0
+		}
+		return info.Size()
+	}
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type repairOptions struct {
+	help       bool
+	configPath string
+	profile    string
+	inZip      stringListFlag
+	outZip     string
+	forceScan  bool
+	encodings  string
+	junk       stringListFlag
+	junkPrefix string
+	asJSON     bool
+	verbosity  verbosityFlags
+}
+
+func newRepairFlagSet(output io.Writer) (*flag.FlagSet, *repairOptions) {
+	opts := &repairOptions{junkPrefix: ".junk"}
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.configPath, "config", "", "Path to JSON config file")
+	fs.StringVar(&opts.profile, "profile", "", "Named profile from the config file to apply over its top-level keys")
+	fs.Var(&opts.inZip, "in", "Input ZIP path, - for stdin (repeat for the parts of a split archive, in order)")
+	fs.StringVar(&opts.outZip, "out", "", "Output ZIP path")
+	fs.BoolVar(&opts.forceScan, "force-scan", false, "Ignore the central directory and scan local headers")
+	fs.StringVar(&opts.encodings, "encodings", "", trf("Comma-separated charsets tried for non-UTF-8 names (%s)", strings.Join(core.NameDecoders(), ", ")))
+	fs.Var(&opts.junk, "junk", "Drop entries matching this glob as junk (repeatable)")
+	fs.StringVar(&opts.junkPrefix, "junk-prefix", opts.junkPrefix, "Directory noise entries were written under (empty disables)")
+	fs.BoolVar(&opts.asJSON, "json", false, "Print the result as JSON on stdout (logs stay on stderr)")
+	addVerbosityFlags(fs, &opts.verbosity)
+	addFlagAliases(fs)
+	return fs, opts
+}
+
+func printRepairHelp(w io.Writer) {
+	fs, _ := newRepairFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip repair -in <zip> -out <zip> [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Rebuilds a standard ZIP around the original compressed data, without recompressing.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+func runRepair(args []string) int {
+	fs, opts := newRepairFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printRepairHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printRepairHelp(os.Stdout)
+		return 0
+	}
+	if cfgPath := strings.TrimSpace(opts.configPath); cfgPath != "" {
+		cfg, err := readConfig(cfgPath, opts.profile)
+		if err != nil {
+			printError("config: %v", err)
+			return ExitUsage
+		}
+		applyRepairConfig(opts, cfg, collectVisitedFlags(fs))
+	} else if opts.profile != "" {
+		printError("-profile needs -config")
+		return ExitUsage
+	}
+
+	inParts, err := splitParts(opts.inZip)
+	if err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+	inZip := ""
+	if len(inParts) > 0 {
+		inZip = inParts[0]
+	}
+	if len(inParts) < 2 {
+		inParts = nil
+	}
+	outZip := strings.TrimSpace(opts.outZip)
+	if inZip == "" || outZip == "" {
+		printError("-in and -out are required")
+		printRepairHelp(os.Stderr)
+		return ExitUsage
+	}
+	if !strings.HasSuffix(strings.ToLower(outZip), ".zip") {
+		outZip += ".zip"
+	}
+
+	if err := opts.verbosity.openLogs("repair", opts.asJSON); err != nil {
+		return openLogsFailed(err)
+	}
+	level := opts.verbosity.level()
+	progress, logCb := level.callbacks()
+	rep, err := core.RepairZip(core.RepairConfig{
+		InZip:     inZip,
+		InParts:   inParts,
+		OutZip:    outZip,
+		ForceScan: opts.forceScan,
+		Encodings: splitList(opts.encodings),
+		Junk:      junkPatterns(opts.junkPrefix, opts.junk),
+	}, progress, logCb)
+	if err != nil {
+		return failJSON(opts.asJSON, "repair", err)
+	}
+	if opts.asJSON {
+		writeJSON(os.Stdout, jsonResult{Command: "repair", OK: true, Output: outZip, Files: rep.Kept, Report: rep})
+		return 0
+	}
+	fmt.Fprintf(level.stdout(), "Done. Source: %s, headers: %d, kept: %d, junk: %d, dropped: %d\nOutput: %s\n",
+		rep.Source, rep.Headers, rep.Kept, rep.Junk, rep.Dropped, outZip)
+	return 0
+}
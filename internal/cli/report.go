@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+type reportOptions struct {
+	help     bool
+	inPath   string
+	password passwordFlags
+}
+
+func newReportFlagSet(output io.Writer) (*flag.FlagSet, *reportOptions) {
+	opts := &reportOptions{}
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.help, "h", false, "Show help")
+	fs.BoolVar(&opts.help, "help", false, "Show help")
+	fs.StringVar(&opts.inPath, "in", "", "Report file written by -report")
+	addPasswordFlags(fs, &opts.password, "password", "Password used with -report-password")
+	addFlagAliases(fs)
+	return fs, opts
+}
+
+func printReportHelp(w io.Writer) {
+	fs, _ := newReportFlagSet(w)
+	fmt.Fprintln(w, tr("Usage:")+" noisyzip report -in <report.json> [-password <pass>]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, tr("Options:"))
+	printDefaults(fs)
+}
+
+func runReport(args []string) int {
+	fs, opts := newReportFlagSet(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		printReportHelp(os.Stderr)
+		return ExitUsage
+	}
+	if opts.help {
+		printReportHelp(os.Stdout)
+		return 0
+	}
+	inPath := strings.TrimSpace(opts.inPath)
+	if inPath == "" {
+		printError("-in is required")
+		printReportHelp(os.Stderr)
+		return ExitUsage
+	}
+	if err := opts.password.resolve(false); err != nil {
+		printError("%v", err)
+		return ExitUsage
+	}
+
+	rep, err := core.ReadReport(inPath, opts.password.value)
+	if err != nil {
+		printError("%v", err)
+		return exitCode(err)
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		printError("%v", err)
+		return ExitFailure
+	}
+	fmt.Fprintln(os.Stdout, string(data))
+	return 0
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseByteSize accepts plain byte counts and k/m/g/t suffixes (powers of
+// 1024), optionally followed by "b" or "ib": 512, 64k, 100m, 2GiB.
+func parseByteSize(s string) (int64, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	if text == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+	text = strings.TrimSuffix(text, "ib")
+	text = strings.TrimSuffix(text, "b")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(text, "k"):
+		mult = 1 << 10
+	case strings.HasSuffix(text, "m"):
+		mult = 1 << 20
+	case strings.HasSuffix(text, "g"):
+		mult = 1 << 30
+	case strings.HasSuffix(text, "t"):
+		mult = 1 << 40
+	}
+	if mult > 1 {
+		text = text[:len(text)-1]
+	}
+	val, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	if err != nil || val < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if val > (1<<63-1)/mult {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return val * mult, nil
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// verbosity is how much a command prints besides errors, set by -q and
+// repeated -v.
+type verbosity int
+
+const (
+	// verbosityQuiet prints errors only.
+	verbosityQuiet verbosity = iota
+	// verbosityNormal prints the summary and warnings.
+	verbosityNormal
+	// verbosityVerbose adds a line per file.
+	verbosityVerbose
+	// verbosityDebug adds timestamps and the run time.
+	verbosityDebug
+)
+
+// detailPrefixes mark core log lines about one entry that are routine
+// rather than a warning; they are shown from verbosityVerbose.
+var detailPrefixes = []string{"Junk: ", "Nested archive: "}
+
+// verbosityFlags backs -q and -v, and -log-file and -progress-fd with them.
+// -v counts: once for per-file lines, twice for debug output.
+type verbosityFlags struct {
+	quiet      bool
+	verbose    int
+	logFile    string
+	logMaxSize string
+	progressFD int
+}
+
+type verboseCountFlag struct{ n *int }
+
+func (f verboseCountFlag) String() string {
+	if f.n == nil {
+		return "0"
+	}
+	return strconv.Itoa(*f.n)
+}
+
+func (f verboseCountFlag) Set(val string) error {
+	on, err := strconv.ParseBool(val)
+	if err != nil {
+		return err
+	}
+	if on {
+		*f.n++
+	} else {
+		*f.n = 0
+	}
+	return nil
+}
+
+func (f verboseCountFlag) IsBoolFlag() bool {
+	return true
+}
+
+func addVerbosityFlags(fs *flag.FlagSet, v *verbosityFlags) {
+	fs.BoolVar(&v.quiet, "q", false, "Print errors only")
+	fs.BoolVar(&v.quiet, "quiet", false, "Print errors only")
+	fs.Var(verboseCountFlag{&v.verbose}, "v", "Print a line per file; repeat (-v -v) for debug output")
+	fs.Var(verboseCountFlag{&v.verbose}, "verbose", "Same as -v")
+	fs.StringVar(&v.logFile, "log-file", "", "Also append every log and progress line, with a timestamp, to this file")
+	if v.logMaxSize == "" {
+		v.logMaxSize = defaultLogMaxSize
+	}
+	fs.StringVar(&v.logMaxSize, "log-max-size", v.logMaxSize, trf("Rotate -log-file once it reaches this size, keeping %d old files", logBackups))
+	fs.IntVar(&v.progressFD, "progress-fd", 0, "Write progress as NDJSON events to this file descriptor (e.g. 3; 1 for stdout, not with -json)")
+}
+
+func (v verbosityFlags) level() verbosity {
+	if v.quiet {
+		return verbosityQuiet
+	}
+	return min(verbosityNormal+verbosity(v.verbose), verbosityDebug)
+}
+
+// stdout is where the summary goes: nowhere when quiet.
+func (v verbosity) stdout() io.Writer {
+	if v == verbosityQuiet {
+		return io.Discard
+	}
+	return os.Stdout
+}
+
+// callbacks returns the progress and log callbacks for core at this level.
+// Progress is nil below verbosityVerbose so core skips it altogether, unless
+// there is a -log-file or -progress-fd to write it to.
+func (v verbosity) callbacks() (func(done, total int, name string), func(string)) {
+	return v.sizedCallbacks("", nil)
+}
+
+// sizedCallbacks is callbacks with every line prefixed by tag, to tell apart
+// runs that log at the same time, and sizeOf giving the source size of an
+// entry by name for the bytes of -progress-fd events. Both are optional.
+func (v verbosity) sizedCallbacks(tag string, sizeOf func(name string) int64) (func(done, total int, name string), func(string)) {
+	start := time.Now()
+	emit := func(msg string, show bool) {
+		if tag != "" {
+			msg = tag + " " + msg
+		}
+		logAudit(msg)
+		if !show {
+			return
+		}
+		if v >= verbosityDebug {
+			msg = fmt.Sprintf("[%8.3fs] %s", time.Since(start).Seconds(), msg)
+		}
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	logCb := func(msg string) {
+		if strings.TrimSpace(msg) == "" {
+			return
+		}
+		show := v > verbosityQuiet
+		if v < verbosityVerbose {
+			for _, p := range detailPrefixes {
+				if strings.HasPrefix(msg, p) {
+					show = false
+				}
+			}
+		}
+		emit(msg, show)
+	}
+	if v < verbosityVerbose && auditLog == nil && progressOut == nil {
+		return nil, logCb
+	}
+	var bytes int64
+	progress := func(done, total int, name string) {
+		emit(fmt.Sprintf("%d/%d: %s", done, total, name), v >= verbosityVerbose)
+		if progressOut == nil {
+			return
+		}
+		if sizeOf != nil {
+			bytes += sizeOf(name)
+		}
+		progressOut.emit(progressEvent{
+			Event: "progress",
+			Job:   strings.Trim(tag, "[]"),
+			Done:  done,
+			Total: total,
+			Name:  name,
+			Bytes: bytes,
+		})
+	}
+	return progress, logCb
+}
+
+// openLogs starts the -log-file and -progress-fd outputs of command, which
+// prints its result as JSON on stdout when jsonStdout is set.
+func (v verbosityFlags) openLogs(command string, jsonStdout bool) error {
+	if err := v.openAuditLog(command); err != nil {
+		return err
+	}
+	return v.openProgressFD(command, jsonStdout)
+}
+
+// openLogsFailed reports a -log-file or -progress-fd that could not be
+// started.
+func openLogsFailed(err error) int {
+	printError("%v", err)
+	if exitCode(err) == ExitIO {
+		return ExitIO
+	}
+	return ExitUsage
+}
+
+// closeLogs ends the outputs openLogs started, recording the exit code.
+func closeLogs(code int) {
+	closeAuditLog(code)
+	closeProgressFD(code)
+}
+
+// debugf prints a debug line on stderr at verbosityDebug. The -log-file
+// gets it at any level.
+func (v verbosity) debugf(format string, args ...any) {
+	msg := fmt.Sprintf("Debug: "+format, args...)
+	logAudit(msg)
+	if v >= verbosityDebug {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+}
@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"os"
+	"runtime"
 	"runtime/debug"
 	"strings"
 )
@@ -14,6 +18,65 @@ func printVersion(w io.Writer) {
 	fmt.Fprintln(w, "noisyzip", versionString())
 }
 
+// versionInfo is the -json output of the version command.
+type versionInfo struct {
+	Version   string            `json:"version"`
+	GoVersion string            `json:"goVersion"`
+	OS        string            `json:"os"`
+	Arch      string            `json:"arch"`
+	Module    string            `json:"module,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+}
+
+func buildVersionInfo() versionInfo {
+	vi := versionInfo{
+		Version:   versionString(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		vi.Module = info.Main.Path
+		vi.Settings = make(map[string]string, len(info.Settings))
+		for _, setting := range info.Settings {
+			vi.Settings[setting.Key] = setting.Value
+		}
+	}
+	return vi
+}
+
+func runVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "Print version and build settings as JSON")
+	help := fs.Bool("h", false, "Show help")
+	fs.BoolVar(help, "help", false, "Show help")
+	if err := fs.Parse(args); err != nil {
+		printError("%v", err)
+		fmt.Fprintln(os.Stderr, tr("Usage:")+" noisyzip version [-json]")
+		return ExitUsage
+	}
+	if *help {
+		fmt.Fprintln(os.Stdout, tr("Usage:")+" noisyzip version [-json]")
+		fmt.Fprintln(os.Stdout, "")
+		fmt.Fprintln(os.Stdout, tr("Options:"))
+		fs.SetOutput(os.Stdout)
+		printDefaults(fs)
+		return 0
+	}
+	if !*asJSON {
+		printVersion(os.Stdout)
+		return 0
+	}
+	data, err := json.MarshalIndent(buildVersionInfo(), "", "  ")
+	if err != nil {
+		printError("%v", err)
+		return ExitFailure
+	}
+	fmt.Fprintln(os.Stdout, string(data))
+	return 0
+}
+
 func versionString() string {
 	if v := strings.TrimSpace(Version); v != "" && v != "dev" {
 		return v
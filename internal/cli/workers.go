@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// parseWorkers reads a worker count: a positive number, "auto" for one per
+// CPU, or a percentage of the CPUs such as "50%", which is at least 1.
+func parseWorkers(val string) (int, error) {
+	text := strings.ToLower(strings.TrimSpace(val))
+	if text == "auto" {
+		return runtime.NumCPU(), nil
+	}
+	if pct, ok := strings.CutSuffix(text, "%"); ok {
+		p, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || p <= 0 || math.IsInf(p, 0) {
+			return 0, fmt.Errorf("%q: a percentage must be a number above 0", val)
+		}
+		return max(1, int(math.Round(float64(runtime.NumCPU())*p/100))), nil
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number, auto or a percentage of the CPUs such as 50%%", val)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("%d is below 1 (auto is one per CPU)", n)
+	}
+	return n, nil
+}
+
+// workersFlag is -workers, parsed by parseWorkers.
+type workersFlag struct{ n *int }
+
+func (f workersFlag) String() string {
+	if f.n == nil {
+		return ""
+	}
+	return strconv.Itoa(*f.n)
+}
+
+func (f workersFlag) Set(val string) error {
+	n, err := parseWorkers(val)
+	if err != nil {
+		return err
+	}
+	*f.n = n
+	return nil
+}
+
+// parseWorkersList parses a comma-separated list of worker counts.
+func parseWorkersList(val string) ([]int, error) {
+	var out []int
+	for _, item := range splitList(val) {
+		n, err := parseWorkers(item)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
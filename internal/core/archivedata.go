@@ -0,0 +1,99 @@
+package core
+
+import (
+	"bytes"
+	"io"
+)
+
+// archiveData gives the header parsers access to an archive by offset,
+// whether it is held in memory or read through an io.ReaderAt, as
+// NoisyReader does to keep the archive on disk.
+type archiveData interface {
+	// at returns the n bytes at off, false when they run past the end.
+	at(off, n int) ([]byte, bool)
+	len() int
+}
+
+// memData is an archive held in memory; at slices it without copying.
+type memData []byte
+
+func (d memData) at(off, n int) ([]byte, bool) {
+	if off < 0 || n < 0 || off+n > len(d) {
+		return nil, false
+	}
+	return d[off : off+n], true
+}
+
+func (d memData) len() int { return len(d) }
+
+// readerAtData is an archive read through an io.ReaderAt a piece at a
+// time. A read that fails looks like the end of the archive to the
+// parsers; err keeps the first such failure for the caller.
+type readerAtData struct {
+	ra   io.ReaderAt
+	size int
+	err  error
+}
+
+func (d *readerAtData) at(off, n int) ([]byte, bool) {
+	if off < 0 || n < 0 || off+n > d.size {
+		return nil, false
+	}
+	buf := make([]byte, n)
+	if k, err := d.ra.ReadAt(buf, int64(off)); k < n {
+		if d.err == nil {
+			d.err = err
+		}
+		return nil, false
+	}
+	return buf, true
+}
+
+func (d *readerAtData) len() int { return d.size }
+
+// scanChunk is how much of an archive the signature scans look at in one
+// piece.
+const scanChunk = 1 << 20
+
+// indexSig returns the offset of every sig in d, in order.
+func indexSig(d archiveData, sig []byte) []int {
+	var found []int
+	for start := 0; start+len(sig) <= d.len(); start += scanChunk {
+		// Each chunk overlaps the next by len(sig)-1 bytes, so a
+		// signature across the boundary is found once, in this chunk.
+		n := min(scanChunk+len(sig)-1, d.len()-start)
+		chunk, ok := d.at(start, n)
+		if !ok {
+			break
+		}
+		for i := 0; ; {
+			j := bytes.Index(chunk[i:], sig)
+			if j < 0 || i+j >= scanChunk {
+				break
+			}
+			found = append(found, start+i+j)
+			i += j + 1
+		}
+	}
+	return found
+}
+
+// lastIndexSig returns the offset of the last sig that ends by end in d,
+// or -1.
+func lastIndexSig(d archiveData, end int, sig []byte) int {
+	for end >= len(sig) {
+		start := max(0, end-scanChunk)
+		chunk, ok := d.at(start, end-start)
+		if !ok {
+			return -1
+		}
+		if i := bytes.LastIndex(chunk, sig); i >= 0 {
+			return start + i
+		}
+		if start == 0 {
+			break
+		}
+		end = start + len(sig) - 1
+	}
+	return -1
+}
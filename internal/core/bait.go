@@ -0,0 +1,205 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type baitFile struct {
+	name    string
+	content func(randReader io.Reader) ([]byte, error)
+}
+
+var baitFiles = []baitFile{
+	{name: "README.txt", content: baitReadme},
+	{name: "passwords.txt", content: baitPasswords},
+}
+
+var baitProjects = []string{"archive", "backup", "export", "vault", "records", "migration"}
+
+var baitServices = []string{
+	"mail.google.com", "github.com", "aws console", "office365", "vpn.corp.local",
+	"router admin", "bank online", "dropbox", "jira", "db-prod-01",
+}
+
+var baitUsers = []string{"admin", "root", "j.smith", "backup", "svc_deploy", "a.petrova", "it-support"}
+
+func makeBaitEntries(
+	tmpDir string,
+	randReader io.Reader,
+	items []fileItem,
+	encName func(string) ([]byte, error),
+	nameFlag uint16,
+	comp Compressor,
+	level int,
+	strategy string,
+	fixedTime bool,
+) ([]entry, []string, error) {
+	taken := make(map[string]bool, len(items))
+	var newest time.Time
+	for _, it := range items {
+		taken[strings.ToLower(it.name)] = true
+		if it.modTime.After(newest) {
+			newest = it.modTime
+		}
+	}
+	if newest.IsZero() {
+		newest = time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	}
+
+	var entries []entry
+	var names []string
+	for _, bf := range baitFiles {
+		if taken[strings.ToLower(bf.name)] {
+			continue
+		}
+		age, err := randIntn(randReader, 90*24*3600)
+		if err != nil {
+			removeTemps(entries)
+			return nil, nil, fmt.Errorf("bait %s: %w", bf.name, err)
+		}
+		modTime := newest.Add(-time.Duration(age) * time.Second)
+		var ent entry
+		content, err := bf.content(randReader)
+		if err == nil {
+			ent, err = makeBaitEntry(tmpDir, bf.name, content, modTime, encName, nameFlag, comp, level, strategy, fixedTime)
+		}
+		if err != nil {
+			removeTemps(entries)
+			return nil, nil, fmt.Errorf("bait %s: %w", bf.name, err)
+		}
+		entries = append(entries, ent)
+		names = append(names, bf.name)
+	}
+	return entries, names, nil
+}
+
+func makeBaitEntry(
+	tmpDir string,
+	name string,
+	content []byte,
+	modTime time.Time,
+	encName func(string) ([]byte, error),
+	nameFlag uint16,
+	comp Compressor,
+	level int,
+	strategy string,
+	fixedTime bool,
+) (entry, error) {
+	nameBytes, err := encName(name)
+	if err != nil {
+		return entry{}, err
+	}
+	dosT, dosD := DOSTimeDate(modTime, fixedTime)
+	tmp, err := os.CreateTemp(tmpDir, "enczip_bait_*")
+	if err != nil {
+		return entry{}, err
+	}
+	ok := false
+	defer func() {
+		tmp.Close()
+		if !ok {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	crc, usize, csize, err := compressTo(tmp, comp, level, strategy, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+	if err != nil {
+		return entry{}, err
+	}
+
+	ok = true
+	return entry{
+		name:   nameBytes,
+		flags:  nameFlag,
+		method: comp.Method(),
+		dosT:   dosT,
+		dosD:   dosD,
+		crc:    crc,
+		csize:  uint32(csize),
+		usize:  usize,
+		tmp:    tmp.Name(),
+		label:  name,
+		kind:   entryBait,
+	}, nil
+}
+
+func baitReadme(randReader io.Reader) ([]byte, error) {
+	i, err := randIntn(randReader, len(baitProjects))
+	if err != nil {
+		return nil, err
+	}
+	project := baitProjects[i]
+	tag, err := randHex(randReader, 2)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := randHex(randReader, 16)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s-%s\r\n", strings.ToUpper(project), tag)
+	b.WriteString("====================\r\n\r\n")
+	fmt.Fprintf(&b, "This archive contains the %s snapshot prepared for offsite storage.\r\n", project)
+	b.WriteString("Do not modify the folder layout, the restore scripts depend on it.\r\n\r\n")
+	b.WriteString("Restore:\r\n")
+	b.WriteString("  1. Unpack into an empty directory.\r\n")
+	b.WriteString("  2. Credentials for the services are in passwords.txt.\r\n")
+	b.WriteString("  3. Contact IT support if checksums do not match.\r\n\r\n")
+	fmt.Fprintf(&b, "Checksum: %s\r\n", sum)
+	return []byte(b.String()), nil
+}
+
+func baitPasswords(randReader io.Reader) ([]byte, error) {
+	const alphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789!@#$%"
+	var b strings.Builder
+	rng := randPicker{r: randReader}
+	count := 6 + rng.intn(6)
+	for i := 0; i < count && rng.err == nil; i++ {
+		service := baitServices[rng.intn(len(baitServices))]
+		user := baitUsers[rng.intn(len(baitUsers))]
+		pass := make([]byte, 10+rng.intn(6))
+		for j := range pass {
+			pass[j] = alphabet[rng.intn(len(alphabet))]
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\r\n", service, user, pass)
+	}
+	if rng.err != nil {
+		return nil, rng.err
+	}
+	return []byte(b.String()), nil
+}
+
+func randIntn(randReader io.Reader, n int) (int, error) {
+	if n <= 1 {
+		return 0, nil
+	}
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(randReader, buf); err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint64(buf) % uint64(n)), nil
+}
+
+// randPicker makes a series of randIntn draws from r, keeping the first
+// read error for the caller to check once the series is done.
+type randPicker struct {
+	r   io.Reader
+	err error
+}
+
+func (p *randPicker) intn(n int) int {
+	v, err := randIntn(p.r, n)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return v
+}
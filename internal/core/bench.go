@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BenchConfig describes a bench run: a sample of the files under SrcDir, or
+// a synthetic corpus, is written once per setting.
+type BenchConfig struct {
+	SrcDir        string
+	IncludeHidden bool
+	// Corpus, when set, is one of BenchCorpora, made under TmpDir and
+	// benched instead of SrcDir, so runs on different machines and
+	// releases compare.
+	Corpus string
+	// SampleSize caps the bytes of source data in the sample; 0 takes
+	// every file. It is the size of a Corpus, 64 MiB when 0.
+	SampleSize int64
+	// Levels are the deflate levels tried. Store is always tried too.
+	Levels []int
+	// Workers are the worker counts tried with every method and level.
+	Workers []int
+	TmpDir  string
+	// Recover also recovers every archive written, and times that.
+	Recover bool
+}
+
+// BenchResult is one setting and how it did on the sample.
+type BenchResult struct {
+	Compression string        `json:"compression"`
+	Level       int           `json:"level"`
+	Workers     int           `json:"workers"`
+	Files       int           `json:"files"`
+	InBytes     int64         `json:"inBytes"`
+	OutBytes    int64         `json:"outBytes"`
+	Duration    time.Duration `json:"durationNs"`
+	// RecoverDuration is the time recover took on the archive, with
+	// BenchConfig.Recover.
+	RecoverDuration time.Duration `json:"recoverNs,omitempty"`
+}
+
+// Ratio is the archive size as a fraction of the input.
+func (r BenchResult) Ratio() float64 {
+	if r.InBytes == 0 {
+		return 0
+	}
+	return float64(r.OutBytes) / float64(r.InBytes)
+}
+
+// Throughput is the input read per second, in bytes.
+func (r BenchResult) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.InBytes) / r.Duration.Seconds()
+}
+
+// RunBench writes the sample with store and every level in cfg.Levels, each
+// with every worker count in cfg.Workers, and returns the results in that
+// order, levels and worker counts ascending. The archives go to a temporary
+// directory and are removed. No noise is added, so the numbers are those of
+// the real files alone.
+func RunBench(ctx context.Context, cfg BenchConfig, progress func(done, total int, name string)) ([]BenchResult, error) {
+	if len(cfg.Workers) == 0 {
+		cfg.Workers = []int{1}
+	}
+	levels := slices.Compact(slices.Sorted(slices.Values(cfg.Levels)))
+	counts := slices.Compact(slices.Sorted(slices.Values(cfg.Workers)))
+	for _, lvl := range cfg.Levels {
+		if lvl < 0 || lvl > 9 {
+			return nil, withKind(ErrInvalidConfig, fmt.Errorf("level must be in range 0..9"))
+		}
+	}
+	for _, w := range cfg.Workers {
+		if w < 1 {
+			return nil, withKind(ErrInvalidConfig, fmt.Errorf("workers must be at least 1"))
+		}
+	}
+	if cfg.SampleSize < 0 {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("sample size must be >= 0"))
+	}
+	makeCorpus, ok := benchCorpora[cfg.Corpus]
+	if cfg.Corpus != "" && !ok {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("unknown corpus %q (available: %s)", cfg.Corpus, strings.Join(BenchCorpora(), ", ")))
+	}
+
+	dir, err := os.MkdirTemp(cfg.TmpDir, "noisyzip-bench-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	srcDir, sampleSize := cfg.SrcDir, cfg.SampleSize
+	if makeCorpus != nil {
+		srcDir, sampleSize = filepath.Join(dir, "corpus"), 0
+		size := cfg.SampleSize
+		if size == 0 {
+			size = defaultCorpusSize
+		}
+		if err := makeCorpus(srcDir, size); err != nil {
+			return nil, fmt.Errorf("corpus %s: %w", cfg.Corpus, err)
+		}
+	}
+	files, err := listFiles(srcDir, "", cfg.IncludeHidden, 0)
+	if err != nil {
+		return nil, err
+	}
+	names, inBytes := benchSample(files, sampleSize)
+	if len(names) == 0 {
+		return nil, withKind(ErrNoFiles, errors.New("no files to bench"))
+	}
+
+	type setting struct {
+		compression string
+		level       int
+	}
+	settings := []setting{{"store", 0}}
+	for _, lvl := range levels {
+		settings = append(settings, setting{"deflate", lvl})
+	}
+
+	var results []BenchResult
+	for _, s := range settings {
+		for _, workers := range counts {
+			if err := ctx.Err(); err != nil {
+				return results, err
+			}
+			if progress != nil {
+				progress(len(results)+1, len(settings)*len(counts), fmt.Sprintf("%s level %d, %d workers", s.compression, s.level, workers))
+			}
+			out := filepath.Join(dir, fmt.Sprintf("%s-%d-%d.zip", s.compression, s.level, workers))
+			start := time.Now()
+			n, err := RunEncryptContext(ctx, Config{
+				SrcDir:      srcDir,
+				OutZip:      out,
+				Compression: s.compression,
+				Encoding:    "utf-8",
+				Level:       s.level,
+				Strategy:    "default",
+				DictSize:    32768,
+				Workers:     workers,
+				TmpDir:      cfg.TmpDir,
+				Files:       names,
+			}, nil, nil)
+			elapsed := time.Since(start)
+			if err != nil {
+				return results, err
+			}
+			info, err := os.Stat(out)
+			if err != nil {
+				return results, err
+			}
+			var recoverTime time.Duration
+			if cfg.Recover {
+				if recoverTime, err = benchRecover(ctx, out, filepath.Join(dir, "recovered")); err != nil {
+					return results, err
+				}
+			}
+			os.Remove(out)
+			r := BenchResult{
+				Compression:     s.compression,
+				Level:           s.level,
+				Workers:         workers,
+				Files:           n,
+				InBytes:         inBytes,
+				OutBytes:        info.Size(),
+				Duration:        elapsed,
+				RecoverDuration: recoverTime,
+			}
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// benchRecover times the recovery of zipPath into outDir, which it removes
+// after.
+func benchRecover(ctx context.Context, zipPath, outDir string) (time.Duration, error) {
+	defer os.RemoveAll(outDir)
+	start := time.Now()
+	_, err := RecoverZipContext(ctx, RecoverConfig{InZip: zipPath, OutDir: outDir}, nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("recover: %w", err)
+	}
+	return time.Since(start), nil
+}
+
+// benchSample picks files in a fixed pseudo-random order, so the sample
+// spreads over the whole tree, until limit bytes are taken. The names come
+// back in path order.
+func benchSample(files []fileItem, limit int64) ([]string, int64) {
+	picked := make([]bool, len(files))
+	var total int64
+	order := rand.New(rand.NewPCG(1, 2)).Perm(len(files))
+	for _, i := range order {
+		if limit > 0 && total > 0 && total+files[i].size > limit {
+			continue
+		}
+		picked[i] = true
+		total += files[i].size
+	}
+	var names []string
+	for i, f := range files {
+		if picked[i] {
+			names = append(names, f.rel)
+		}
+	}
+	return names, total
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchCorpusSize keeps the synthetic corpora of the benchmarks small
+// enough for go test -bench to make each of them in a moment.
+const benchCorpusSize = 8 << 20
+
+// benchCorpusFiles makes the corpus name under a temp dir of b and lists
+// its files.
+func benchCorpusFiles(b *testing.B, name string) ([]fileItem, int64) {
+	b.Helper()
+	dir := b.TempDir()
+	if err := benchCorpora[name](dir, benchCorpusSize); err != nil {
+		b.Fatal(err)
+	}
+	items, err := listFiles(dir, "", false, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var size int64
+	for _, item := range items {
+		size += item.size
+	}
+	return items, size
+}
+
+// benchStage compresses items into tmpDir as an encrypt run with the
+// default settings would.
+func benchStage(b *testing.B, tmpDir string, items []fileItem) []entry {
+	b.Helper()
+	encName, nameFlag, err := NewNameEncoder("utf-8")
+	if err != nil {
+		b.Fatal(err)
+	}
+	entries := make([]entry, len(items))
+	for i, item := range items {
+		entries[i], err = compressFile(context.Background(), tmpDir, nil, true, false, chunkSize, item,
+			encName, nameFlag, compressorFor(true), 6, "default", true)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return entries
+}
+
+// benchEachCorpus runs bench as a sub-benchmark for every corpus.
+func benchEachCorpus(b *testing.B, bench func(b *testing.B, items []fileItem)) {
+	for _, name := range BenchCorpora() {
+		b.Run(name, func(b *testing.B) {
+			items, size := benchCorpusFiles(b, name)
+			b.SetBytes(size)
+			bench(b, items)
+		})
+	}
+}
+
+func BenchmarkCompressFile(b *testing.B) {
+	benchEachCorpus(b, func(b *testing.B, items []fileItem) {
+		tmpDir := b.TempDir()
+		for b.Loop() {
+			removeTemps(benchStage(b, tmpDir, items))
+		}
+	})
+}
+
+func BenchmarkWriteZip(b *testing.B) {
+	benchEachCorpus(b, func(b *testing.B, items []fileItem) {
+		// writeZip removes the staged files it writes, so each round
+		// stages them again first.
+		dir := b.TempDir()
+		out := filepath.Join(dir, "out.zip")
+		for b.Loop() {
+			b.StopTimer()
+			entries := benchStage(b, dir, items)
+			b.StartTimer()
+			if _, err := writeZip(context.Background(), SeededRandSource(1), out, entries, 1, chunkSize, false, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRecoverZip(b *testing.B) {
+	benchEachCorpus(b, func(b *testing.B, items []fileItem) {
+		dir := b.TempDir()
+		in := filepath.Join(dir, "in.zip")
+		if _, err := writeZip(context.Background(), SeededRandSource(1), in, benchStage(b, dir, items), 1, chunkSize, false, 0); err != nil {
+			b.Fatal(err)
+		}
+		out := filepath.Join(dir, "out")
+		for b.Loop() {
+			if _, err := RecoverZipContext(context.Background(), RecoverConfig{InZip: in, OutDir: out}, nil, nil); err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+			os.RemoveAll(out)
+			b.StartTimer()
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// benchCorpora are the synthetic corpora RunBench can make instead of
+// sampling SrcDir, each of BenchConfig.SampleSize bytes: many small text
+// files, two huge text files, and files of incompressible data.
+var benchCorpora = map[string]func(dir string, size int64) error{
+	"small":  makeSmallCorpus,
+	"huge":   makeHugeCorpus,
+	"random": makeRandomCorpus,
+}
+
+// defaultCorpusSize is the size of a synthetic corpus when SampleSize is 0.
+const defaultCorpusSize = 64 << 20
+
+// BenchCorpora returns the values BenchConfig.Corpus accepts.
+func BenchCorpora() []string {
+	names := make([]string, 0, len(benchCorpora))
+	for name := range benchCorpora {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// benchWords are what the text of the small and huge corpora is made of,
+// so deflate finds in it about the redundancy of source code or logs.
+var benchWords = []string{
+	"the", "of", "and", "to", "in", "is", "for", "return", "func", "err",
+	"nil", "if", "else", "string", "int", "byte", "archive", "entry", "header",
+	"offset", "size", "name", "data", "file", "error", "value", "config",
+	"2024-05-17T10:42:13Z", "INFO", "DEBUG", "WARN", "request", "response",
+	"user", "id", "status", "200", "404", "{", "}", "(", ")", ":=", "==",
+}
+
+// writeBenchText writes size bytes of text drawn from benchWords by rng.
+func writeBenchText(w io.Writer, rng *rand.Rand, size int64) error {
+	bw := bufio.NewWriter(w)
+	var n int64
+	for col := 0; n < size; {
+		word := benchWords[rng.IntN(len(benchWords))]
+		sep := byte(' ')
+		if col += len(word) + 1; col > 72 {
+			sep, col = '\n', 0
+		}
+		if rest := size - n; int64(len(word)+1) > rest {
+			word, sep = word[:rest-1], '\n'
+		}
+		bw.WriteString(word)
+		bw.WriteByte(sep)
+		n += int64(len(word) + 1)
+	}
+	return bw.Flush()
+}
+
+// writeBenchFile creates path and fills it with fill.
+func writeBenchFile(path string, fill func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fill(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// makeSmallCorpus writes text files of 1 to 16 KiB, a hundred to a
+// directory, until size bytes are written.
+func makeSmallCorpus(dir string, size int64) error {
+	rng := rand.New(rand.NewPCG(1, 1))
+	for i, n := 0, int64(0); n < size; i++ {
+		fileSize := min(int64(1<<10+rng.IntN(15<<10)), size-n)
+		path := filepath.Join(dir, fmt.Sprintf("d%03d", i/100), fmt.Sprintf("f%05d.txt", i))
+		err := writeBenchFile(path, func(w io.Writer) error {
+			return writeBenchText(w, rng, fileSize)
+		})
+		if err != nil {
+			return err
+		}
+		n += fileSize
+	}
+	return nil
+}
+
+// makeHugeCorpus writes size bytes of text in two files.
+func makeHugeCorpus(dir string, size int64) error {
+	rng := rand.New(rand.NewPCG(2, 2))
+	for i, fileSize := range []int64{size / 2, size - size/2} {
+		err := writeBenchFile(filepath.Join(dir, fmt.Sprintf("huge%d.log", i)), func(w io.Writer) error {
+			return writeBenchText(w, rng, fileSize)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// makeRandomCorpus writes size bytes of random data in sixteen files, which
+// deflate cannot shrink.
+func makeRandomCorpus(dir string, size int64) error {
+	var seed [32]byte
+	src := rand.NewChaCha8(seed)
+	for i := range int64(16) {
+		fileSize := size/16 + min(1, max(0, size%16-i))
+		err := writeBenchFile(filepath.Join(dir, fmt.Sprintf("r%02d.bin", i)), func(w io.Writer) error {
+			_, err := io.CopyN(w, src, fileSize)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ctxReader fails once ctx is done, so copying a large file stops promptly
+// on cancellation.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// runTempDir makes the directory inside tmpDir, "" meaning the OS temp
+// directory, where one run stages its entries, so that runs sharing a
+// TmpDir never touch each other's files. The run removes it as it returns.
+func runTempDir(tmpDir string) (string, error) {
+	dir, err := os.MkdirTemp(tmpDir, "noisyzip-run-*")
+	if err != nil {
+		return "", fmt.Errorf("temp dir: %w", err)
+	}
+	return dir, nil
+}
+
+// removeTemps deletes the staged temp files of entries.
+func removeTemps(entries []entry) {
+	for _, ent := range entries {
+		if ent.tmp != "" {
+			os.Remove(ent.tmp)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+)
+
+const (
+	zipSigCDir = 0x02014B50
+	zipSigEOCD = 0x06054B50
+)
+
+type cdEntry struct {
+	flags    uint16
+	comp     uint16
+	dosT     uint16
+	dosD     uint16
+	crc      uint32
+	csize    uint32
+	usize    uint32
+	extAttr  uint32
+	madeBy   uint16
+	name     []byte
+	extra    []byte
+	comment  []byte
+	localOff uint32
+}
+
+type eocdRecord struct {
+	off     int
+	count   int
+	cdSize  uint32
+	cdStart uint32
+	comment []byte
+}
+
+// findCentralDir walks EOCD signatures from the end of buf and returns the
+// first one whose central directory parses cleanly and whose entries all
+// point at matching local headers. Fake EOCDs such as the poison tail fail
+// these checks and are skipped.
+func findCentralDir(buf archiveData) (eocdRecord, []cdEntry, bool) {
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, zipSigEOCD)
+	end := buf.len()
+	for end > 0 {
+		at := lastIndexSig(buf, end, sig)
+		if at < 0 {
+			break
+		}
+		end = at
+		eocd, ok := parseEOCD(buf, at)
+		if !ok {
+			continue
+		}
+		entries, ok := parseCentralDir(buf, eocd)
+		if !ok {
+			continue
+		}
+		return eocd, entries, true
+	}
+	return eocdRecord{}, nil, false
+}
+
+func parseEOCD(buf archiveData, off int) (eocdRecord, bool) {
+	rec, ok := buf.at(off, 22)
+	if !ok {
+		return eocdRecord{}, false
+	}
+	disk := binary.LittleEndian.Uint16(rec[4:6])
+	cdDisk := binary.LittleEndian.Uint16(rec[6:8])
+	countDisk := binary.LittleEndian.Uint16(rec[8:10])
+	count := binary.LittleEndian.Uint16(rec[10:12])
+	cdSize := binary.LittleEndian.Uint32(rec[12:16])
+	cdStart := binary.LittleEndian.Uint32(rec[16:20])
+	commentLen := int(binary.LittleEndian.Uint16(rec[20:22]))
+	if disk != 0 || cdDisk != 0 || countDisk != count {
+		return eocdRecord{}, false
+	}
+	n := int(count)
+	if count == 0xFFFF || cdSize == 0xFFFFFFFF || cdStart == 0xFFFFFFFF {
+		if n, cdSize, cdStart, ok = parseZip64EOCD(buf, off); !ok {
+			return eocdRecord{}, false
+		}
+	}
+	if int64(cdStart)+int64(cdSize) > int64(off) {
+		return eocdRecord{}, false
+	}
+	comment, ok := buf.at(off+22, commentLen)
+	if !ok {
+		return eocdRecord{}, false
+	}
+	return eocdRecord{
+		off:     off,
+		count:   n,
+		cdSize:  cdSize,
+		cdStart: cdStart,
+		comment: comment,
+	}, true
+}
+
+const (
+	zipSigZip64EOCD    = 0x06064B50
+	zipSigZip64Locator = 0x07064B50
+)
+
+// parseZip64EOCD follows the ZIP64 end of central directory locator that
+// precedes the EOCD at off. Only values that fit the 32-bit fields are
+// accepted.
+func parseZip64EOCD(buf archiveData, off int) (int, uint32, uint32, bool) {
+	loc := off - 20
+	locator, ok := buf.at(loc, 20)
+	if !ok || binary.LittleEndian.Uint32(locator[0:4]) != zipSigZip64Locator {
+		return 0, 0, 0, false
+	}
+	recOff := binary.LittleEndian.Uint64(locator[8:16])
+	if recOff > uint64(loc) || uint64(loc)-recOff < 56 {
+		return 0, 0, 0, false
+	}
+	rec, ok := buf.at(int(recOff), 56)
+	if !ok || binary.LittleEndian.Uint32(rec[0:4]) != zipSigZip64EOCD {
+		return 0, 0, 0, false
+	}
+	count := binary.LittleEndian.Uint64(rec[32:40])
+	cdSize := binary.LittleEndian.Uint64(rec[40:48])
+	cdStart := binary.LittleEndian.Uint64(rec[48:56])
+	if count > 0xFFFFFF || cdSize >= 0xFFFFFFFF || cdStart >= 0xFFFFFFFF {
+		return 0, 0, 0, false
+	}
+	return int(count), uint32(cdSize), uint32(cdStart), true
+}
+
+func parseCentralDir(buf archiveData, eocd eocdRecord) ([]cdEntry, bool) {
+	// A central directory entry takes 46 bytes at least, which bounds
+	// the count, and the read, of a bogus record.
+	if int64(eocd.cdSize) < 46*int64(eocd.count) || int64(eocd.cdSize) > (46+3*0xFFFF)*int64(eocd.count) {
+		return nil, false
+	}
+	cd, ok := buf.at(int(eocd.cdStart), int(eocd.cdSize))
+	if !ok {
+		return nil, false
+	}
+	pos, limit := 0, len(cd)
+	entries := make([]cdEntry, 0, eocd.count)
+	// An entry may share the local header of an earlier one whose name
+	// matched, as the duplicates of a noisyzip run with Config.Dedup do,
+	// when it also has its method, sizes and CRC.
+	shared := make(map[uint32]cdEntry)
+	for i := 0; i < eocd.count; i++ {
+		if pos+46 > limit || binary.LittleEndian.Uint32(cd[pos:pos+4]) != zipSigCDir {
+			return nil, false
+		}
+		nameLen := int(binary.LittleEndian.Uint16(cd[pos+28 : pos+30]))
+		extraLen := int(binary.LittleEndian.Uint16(cd[pos+30 : pos+32]))
+		commentLen := int(binary.LittleEndian.Uint16(cd[pos+32 : pos+34]))
+		next := pos + 46 + nameLen + extraLen + commentLen
+		if next > limit {
+			return nil, false
+		}
+		ent := cdEntry{
+			madeBy:   binary.LittleEndian.Uint16(cd[pos+4 : pos+6]),
+			flags:    binary.LittleEndian.Uint16(cd[pos+8 : pos+10]),
+			comp:     binary.LittleEndian.Uint16(cd[pos+10 : pos+12]),
+			dosT:     binary.LittleEndian.Uint16(cd[pos+12 : pos+14]),
+			dosD:     binary.LittleEndian.Uint16(cd[pos+14 : pos+16]),
+			crc:      binary.LittleEndian.Uint32(cd[pos+16 : pos+20]),
+			csize:    binary.LittleEndian.Uint32(cd[pos+20 : pos+24]),
+			usize:    binary.LittleEndian.Uint32(cd[pos+24 : pos+28]),
+			extAttr:  binary.LittleEndian.Uint32(cd[pos+38 : pos+42]),
+			localOff: binary.LittleEndian.Uint32(cd[pos+42 : pos+46]),
+			name:     cd[pos+46 : pos+46+nameLen],
+			extra:    cd[pos+46+nameLen : pos+46+nameLen+extraLen],
+			comment:  cd[pos+46+nameLen+extraLen : next],
+		}
+		if ent.csize == 0xFFFFFFFF || ent.usize == 0xFFFFFFFF || ent.localOff == 0xFFFFFFFF {
+			zip64Sizes(ent.extra, &ent.usize, &ent.csize, &ent.localOff)
+		}
+		if localMatches(buf, ent) {
+			if _, ok := shared[ent.localOff]; !ok {
+				shared[ent.localOff] = ent
+			}
+		} else if !sharesData(ent, shared[ent.localOff]) {
+			return nil, false
+		}
+		entries = append(entries, ent)
+		pos = next
+	}
+	return entries, true
+}
+
+func localMatches(buf archiveData, ent cdEntry) bool {
+	off := int(ent.localOff)
+	hdr, ok := buf.at(off, 30)
+	if !ok || binary.LittleEndian.Uint32(hdr[0:4]) != zipSigLocal {
+		return false
+	}
+	nameLen := int(binary.LittleEndian.Uint16(hdr[26:28]))
+	extraLen := int(binary.LittleEndian.Uint16(hdr[28:30]))
+	dataOff := off + 30 + nameLen + extraLen
+	if int64(dataOff)+int64(ent.csize) > int64(buf.len()) {
+		return false
+	}
+	name, ok := buf.at(off+30, nameLen)
+	return ok && bytes.Equal(name, ent.name)
+}
+
+// sharesData reports whether dup, whose name is not that of its local
+// header, describes the same data as first, the entry that matched it.
+func sharesData(dup, first cdEntry) bool {
+	return first.name != nil && dup.comp == first.comp && dup.crc == first.crc &&
+		dup.csize == first.csize && dup.usize == first.usize
+}
+
+// centralDirHeaders converts central directory entries into local headers
+// with authoritative sizes and CRCs.
+func centralDirHeaders(buf archiveData, entries []cdEntry, names *filenameDecoder) []localHeader {
+	headers := make([]localHeader, 0, len(entries))
+	for _, ent := range entries {
+		// findCentralDir read these bytes already, as it matched the
+		// local header; only a reader that fails now can lose them.
+		off := int(ent.localOff)
+		lens, ok := buf.at(off+26, 4)
+		if !ok {
+			headers = append(headers, localHeader{off: off})
+			continue
+		}
+		nameLen := int(binary.LittleEndian.Uint16(lens[0:2]))
+		extraLen := int(binary.LittleEndian.Uint16(lens[2:4]))
+		extra, ok := buf.at(off+30+nameLen, extraLen)
+		if !ok {
+			headers = append(headers, localHeader{off: off})
+			continue
+		}
+		fname, ok := names.decode(ent.name, ent.extra, ent.flags)
+		headers = append(headers, localHeader{
+			off:     off,
+			flags:   ent.flags,
+			comp:    ent.comp,
+			crc:     ent.crc,
+			csize:   ent.csize,
+			usize:   ent.usize,
+			modTime: ent.dosT,
+			modDate: ent.dosD,
+			fname:   fname,
+			extra:   extra,
+			dataOff: off + 30 + nameLen + extraLen,
+			mode:    cdMode(ent),
+			comment: ent.comment,
+			guessed: nameGuessed(ent.name, ent.extra, ent.flags),
+			badUTF8: badUTF8Name(ent.name, ent.extra, ent.flags),
+			exact:   true,
+			valid:   ok,
+		})
+	}
+	return headers
+}
+
+// cdMode returns the Unix permission bits stored in the high half of the
+// external attributes by Unix zip tools.
+func cdMode(ent cdEntry) os.FileMode {
+	if ent.madeBy>>8 != 3 {
+		return 0
+	}
+	return os.FileMode(ent.extAttr >> 16).Perm()
+}
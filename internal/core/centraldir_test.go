@@ -0,0 +1,183 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dedupArchive writes an archive whose f1.txt is a duplicate sharing the
+// local header and data of f0.txt, and returns it with the offset of the
+// central directory record of f1.txt.
+func dedupArchive(t *testing.T) ([]byte, int) {
+	t.Helper()
+	content := strings.Repeat("shared ", 200)
+	entries := stageTestEntries(t, "", []string{content, content, "other"})
+	for i := range entries {
+		entries[i].sum = append([]byte(nil), entries[i].name...)
+	}
+	entries[1].sum = entries[0].sum
+	if n, _ := dedupEntries(entries); n != 1 {
+		t.Fatalf("%d duplicates, want 1", n)
+	}
+	path := filepath.Join(t.TempDir(), "dedup.zip")
+	if _, err := writeZip(context.Background(), SeededRandSource(1), path, entries, 1, 0, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eocd, _, ok := findCentralDir(memData(buf))
+	if !ok {
+		t.Fatal("no central directory")
+	}
+	rec := bytes.Index(buf[eocd.cdStart:], []byte("f1.txt"))
+	if rec < 46 {
+		t.Fatal("no central directory record for f1.txt")
+	}
+	return buf, int(eocd.cdStart) + rec - 46
+}
+
+func TestCentralDirDuplicateRecord(t *testing.T) {
+	buf, _ := dedupArchive(t)
+	checkZipContents(t, buf, []string{strings.Repeat("shared ", 200), strings.Repeat("shared ", 200), "other"})
+	if _, entries, ok := findCentralDir(memData(buf)); !ok || len(entries) != 3 {
+		t.Fatal("central directory with a duplicate was rejected")
+	}
+}
+
+func TestCentralDirDuplicateRecordOversized(t *testing.T) {
+	buf, rec := dedupArchive(t)
+	binary.LittleEndian.PutUint32(buf[rec+20:rec+24], 1<<30)
+	if _, _, ok := findCentralDir(memData(buf)); ok {
+		t.Fatal("duplicate with a different compressed size was accepted")
+	}
+
+	// Neither recovery nor the reader may panic on it.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crafted.zip")
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecoverZipContext(context.Background(), RecoverConfig{InZip: path, OutDir: filepath.Join(dir, "out")}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenNoisy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, ent := range r.Entries() {
+		rc, err := r.Open(ent.Name)
+		if err != nil {
+			continue
+		}
+		io.Copy(io.Discard, rc)
+		rc.Close()
+	}
+}
+
+func TestReadEntryDataPastEnd(t *testing.T) {
+	buf := make([]byte, 64)
+	h := localHeader{exact: true, dataOff: 30, csize: 1 << 30}
+	out := newSpillBuffer(0, t.TempDir())
+	defer out.Close()
+	if err := readEntryData(buf, h, nil, 0, "", out); err == nil {
+		t.Fatal("entry data past the end of the archive was read")
+	}
+}
+
+// sharedArchive writes an archive of copies entries with the same content,
+// all but the first central directory records sharing its local header.
+func sharedArchive(t *testing.T, content string, copies int) string {
+	t.Helper()
+	contents := make([]string, copies)
+	for i := range contents {
+		contents[i] = content
+	}
+	entries := stageTestEntries(t, "", contents)
+	for i := range entries {
+		entries[i].sum = []byte("same")
+	}
+	if n, _ := dedupEntries(entries); n != copies-1 {
+		t.Fatalf("%d duplicates, want %d", n, copies-1)
+	}
+	path := filepath.Join(t.TempDir(), "shared.zip")
+	if _, err := writeZip(context.Background(), SeededRandSource(1), path, entries, 1, 0, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRecoverSharedData(t *testing.T) {
+	content := strings.Repeat("shared ", 200)
+	in := sharedArchive(t, content, 5)
+
+	out := t.TempDir()
+	rep, err := RecoverZipContext(context.Background(), RecoverConfig{InZip: in, OutDir: out}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Recovered != 5 {
+		t.Fatalf("%d entries recovered, want 5", rep.Recovered)
+	}
+	for i := range 5 {
+		data, err := os.ReadFile(filepath.Join(out, fmt.Sprintf("f%d.txt", i)))
+		if err != nil || string(data) != content {
+			t.Fatalf("f%d.txt: wrong content (%v)", i, err)
+		}
+	}
+
+	files, _, err := RecoverEntries(context.Background(), RecoverConfig{InZip: in}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 5 {
+		t.Fatalf("%d entries recovered in memory, want 5", len(files))
+	}
+	for _, f := range files {
+		if string(f.Data) != content {
+			t.Fatalf("%s: wrong content in memory", f.Name)
+		}
+	}
+}
+
+// Copies of shared data count against MaxTotalSize like any entry.
+func TestRecoverSharedDataTotalLimit(t *testing.T) {
+	content := strings.Repeat("\x00", 1<<20)
+	in := sharedArchive(t, content, 50)
+	rep, err := RecoverZipContext(context.Background(), RecoverConfig{InZip: in, OutDir: t.TempDir(), MaxTotalSize: 10 << 20}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Recovered != 10 || rep.Failed != 40 {
+		t.Fatalf("%d recovered and %d failed, want 10 and 40", rep.Recovered, rep.Failed)
+	}
+}
+
+// RecoverEntries writes nothing to disk, so options that write files are
+// refused rather than ignored.
+func TestRecoverEntriesRefusesFileOutputs(t *testing.T) {
+	in := sharedArchive(t, "data", 1)
+	dir := t.TempDir()
+	for _, cfg := range []RecoverConfig{
+		{InZip: in, KeepJunk: filepath.Join(dir, "junk")},
+		{InZip: in, Manifest: filepath.Join(dir, "manifest.json")},
+		{InZip: in, Comments: filepath.Join(dir, "comments.json")},
+	} {
+		if _, _, err := RecoverEntries(context.Background(), cfg, nil, nil); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("%+v: %v, want ErrInvalidConfig", cfg, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("%d files written", len(entries))
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointInterval is how often the state of a run is written to
+// RecoverConfig.Checkpoint or Config.Resume, besides when the run stops
+// early.
+const checkpointInterval = 10 * time.Second
+
+// checkpointFingerprintSize is how much of each end of the input is hashed
+// to tell whether a checkpoint belongs to it.
+const checkpointFingerprintSize = 1 << 20
+
+// recoverCheckpoint is the state persisted between runs: the number of
+// top-level entries finished, in the visiting order, and the report they
+// produced. The input fingerprint, output directory and order must match
+// for a resume.
+type recoverCheckpoint struct {
+	Size      int64         `json:"size"`
+	HeadCRC   uint32        `json:"headCrc"`
+	TailCRC   uint32        `json:"tailCrc"`
+	OutDir    string        `json:"outDir"`
+	Order     string        `json:"order"`
+	Processed int           `json:"processed"`
+	Bytes     int64         `json:"bytes"`
+	Report    RecoverReport `json:"report"`
+
+	path  string
+	saved time.Time
+}
+
+func newCheckpoint(path string, cfg RecoverConfig, buf []byte) (*recoverCheckpoint, error) {
+	outDir, err := filepath.Abs(cfg.OutDir)
+	if err != nil {
+		return nil, err
+	}
+	head := buf[:min(len(buf), checkpointFingerprintSize)]
+	tail := buf[max(0, len(buf)-checkpointFingerprintSize):]
+	return &recoverCheckpoint{
+		Size:    int64(len(buf)),
+		HeadCRC: crc32.ChecksumIEEE(head),
+		TailCRC: crc32.ChecksumIEEE(tail),
+		OutDir:  outDir,
+		Order:   cfg.Order,
+		path:    path,
+		saved:   time.Now(),
+	}, nil
+}
+
+// loadCheckpoint returns the state saved at cp.path, or cp itself when there
+// is none. A checkpoint made for another input or output is an error rather
+// than silently discarded.
+func loadCheckpoint(cp *recoverCheckpoint) (*recoverCheckpoint, bool, error) {
+	data, err := os.ReadFile(cp.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var saved recoverCheckpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, false, fmt.Errorf("checkpoint %s: %w", cp.path, err)
+	}
+	if saved.Size != cp.Size || saved.HeadCRC != cp.HeadCRC || saved.TailCRC != cp.TailCRC {
+		return nil, false, fmt.Errorf("checkpoint %s was made for a different input; delete it to start over", cp.path)
+	}
+	if saved.OutDir != cp.OutDir || saved.Order != cp.Order {
+		return nil, false, fmt.Errorf("checkpoint %s was made with another output directory or order; delete it to start over", cp.path)
+	}
+	saved.Report.bytes = saved.Bytes
+	saved.path = cp.path
+	saved.saved = time.Now()
+	return &saved, true, nil
+}
+
+// mark records that the first processed entries are done, producing rep,
+// and writes the checkpoint when the last write is old enough.
+func (cp *recoverCheckpoint) mark(processed int, rep RecoverReport) error {
+	cp.Processed = processed
+	cp.Report = rep
+	cp.Report.Entries = rep.Entries[:len(rep.Entries):len(rep.Entries)]
+	cp.Bytes = rep.bytes
+	if time.Since(cp.saved) < checkpointInterval {
+		return nil
+	}
+	return cp.save()
+}
+
+// save writes the checkpoint through a temp file so a crash never leaves a
+// half-written one behind.
+func (cp *recoverCheckpoint) save() error {
+	if err := saveJSONAtomic(cp.path, cp); err != nil {
+		return err
+	}
+	cp.saved = time.Now()
+	return nil
+}
+
+// saveJSONAtomic writes v as JSON to path through a temp file renamed into
+// place.
+func saveJSONAtomic(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"lukechampine.com/blake3"
+)
+
+// Digest algorithms for RecoverConfig.Digest and ChecksumFiles.
+const (
+	DigestSHA256 = "sha256"
+	DigestBLAKE3 = "blake3"
+)
+
+// DigestAlgorithms lists the digest algorithms, the default first.
+var DigestAlgorithms = []string{DigestSHA256, DigestBLAKE3}
+
+// newDigest returns a fresh hash for algorithm.
+func newDigest(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(strings.TrimSpace(algorithm)) {
+	case DigestSHA256:
+		return sha256.New(), nil
+	case DigestBLAKE3:
+		return blake3.New(32, nil), nil
+	}
+	return nil, fmt.Errorf("unknown digest %q (available: %s)", algorithm, strings.Join(DigestAlgorithms, ", "))
+}
+
+// ChecksumFiles hashes paths, read one after the other as the parts of a
+// split archive are, and returns the hex digest and the total size.
+// StdinPath reads standard input.
+func ChecksumFiles(ctx context.Context, paths []string, algorithm string) (string, int64, error) {
+	h, err := newDigest(algorithm)
+	if err != nil {
+		return "", 0, withKind(ErrInvalidConfig, err)
+	}
+	var size int64
+	for _, path := range paths {
+		n, err := copyPart(ctx, h, path)
+		size += n
+		if err != nil {
+			return "", size, err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// copyPart copies the file at path, or standard input, to w and closes it
+// before the next part is opened.
+func copyPart(ctx context.Context, w io.Writer, path string) (int64, error) {
+	var r io.Reader = os.Stdin
+	if path != StdinPath {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+	n, err := io.Copy(w, ctxReader{ctx, r})
+	if err != nil {
+		return n, fmt.Errorf("read %s: %w", path, err)
+	}
+	return n, nil
+}
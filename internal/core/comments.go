@@ -0,0 +1,92 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RecoverComments holds the archive comment and the entry comments of a
+// recovery run, as written to RecoverConfig.Comments.
+type RecoverComments struct {
+	Archive string         `json:"archive,omitempty"`
+	Entries []EntryComment `json:"entries"`
+}
+
+type EntryComment struct {
+	Path    string `json:"path"`
+	Comment string `json:"comment"`
+}
+
+// archiveComment returns the comment of the last EOCD record that parses.
+// The poison tail's fake EOCD does not.
+func archiveComment(buf []byte) []byte {
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, zipSigEOCD)
+	for end := len(buf); end > 0; {
+		at := bytes.LastIndex(buf[:end], sig)
+		if at < 0 {
+			break
+		}
+		end = at
+		if eocd, ok := parseEOCD(memData(buf), at); ok {
+			return eocd.comment
+		}
+	}
+	return nil
+}
+
+// commentText decodes a comment like a filename and keeps it only when it
+// reads as text. The comment junk of the noise mode is random bytes, which
+// decode to control characters or mostly symbols.
+func commentText(names *filenameDecoder, raw []byte, flags uint16) (string, bool) {
+	if len(raw) == 0 {
+		return "", false
+	}
+	text, ok := names.decode(raw, nil, flags)
+	if !ok {
+		return "", false
+	}
+	total, plain := 0, 0
+	for _, r := range text {
+		total++
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			plain++
+		case r == utf8.RuneError || unicode.IsControl(r):
+			return "", false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) || (r < utf8.RuneSelf && unicode.IsPunct(r)):
+			plain++
+		}
+	}
+	if plain*10 < total*9 {
+		return "", false
+	}
+	return text, true
+}
+
+func writeComments(path string, rep RecoverReport) error {
+	out := RecoverComments{Archive: rep.Comment, Entries: []EntryComment{}}
+	for _, ent := range rep.Entries {
+		if ent.Comment == "" {
+			continue
+		}
+		name := ent.Path
+		if name == "" {
+			name = ent.Name
+		}
+		out.Entries = append(out.Entries, EntryComment{Path: name, Comment: ent.Comment})
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"compress/flate"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Compressor encodes the data of entries for one ZIP compression method.
+// Method is the method ID written to the headers (8 for deflate, 12 for
+// bzip2, 93 for zstd and so on). NewWriter returns a writer compressing to
+// w at level, 1 to 9; its Close ends the stream and must not close w.
+//
+// Recovery reads stored and deflated data only: entries of other methods
+// are listed, but fail to extract.
+type Compressor interface {
+	Method() uint16
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// Built-in compressors for Config.Compression.
+const (
+	CompressDeflate = "deflate"
+	CompressStore   = "store"
+)
+
+const (
+	zipMethodStore   = 0
+	zipMethodDeflate = 8
+)
+
+var (
+	compressorMu sync.RWMutex
+	compressors  = map[string]Compressor{
+		CompressDeflate: deflateCompressor{},
+		CompressStore:   storeCompressor{},
+	}
+)
+
+// RegisterCompressor makes c available under name for Config.Compression.
+// Registering an existing name replaces it.
+func RegisterCompressor(name string, c Compressor) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || c == nil {
+		panic("core: RegisterCompressor requires a name and a compressor")
+	}
+	compressorMu.Lock()
+	defer compressorMu.Unlock()
+	compressors[name] = c
+}
+
+// Compressors returns the sorted names of all registered compressors.
+func Compressors() []string {
+	compressorMu.RLock()
+	defer compressorMu.RUnlock()
+	return sortedKeys(compressors)
+}
+
+func lookupCompressor(name string) (Compressor, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	compressorMu.RLock()
+	defer compressorMu.RUnlock()
+	c, ok := compressors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q (available: %s)", name, strings.Join(sortedKeys(compressors), ", "))
+	}
+	return c, nil
+}
+
+// compressorFor is the built-in compressor of a FileMethod.
+func compressorFor(deflate bool) Compressor {
+	if deflate {
+		return deflateCompressor{}
+	}
+	return storeCompressor{}
+}
+
+type deflateCompressor struct{}
+
+func (deflateCompressor) Method() uint16 { return zipMethodDeflate }
+
+// NewWriter hands out a deflate writer from flateWriters, reset to write to
+// w; Close returns it there.
+func (deflateCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return flate.NewWriter(w, level)
+	}
+	p, _ := flateWriters[level-flate.HuffmanOnly].Get().(*pooledFlate)
+	if p == nil {
+		p = &pooledFlate{level: level}
+		fw, err := flate.NewWriter(&p.dst, level)
+		if err != nil {
+			return nil, err
+		}
+		p.fw = fw
+	} else {
+		p.fw.Reset(&p.dst)
+	}
+	p.dst.w = w
+	p.open = true
+	return p, nil
+}
+
+// flateWriters keeps the deflate writers of finished entries, by level from
+// HuffmanOnly up, for the next ones: each holds about a megabyte of tables
+// that would otherwise be allocated again per file.
+var flateWriters [flate.BestCompression - flate.HuffmanOnly + 1]sync.Pool
+
+// pooledFlate is a deflate writer of flateWriters. It writes through dst so
+// that, back in the pool, it keeps no hold on the last entry's output.
+type pooledFlate struct {
+	fw    *flate.Writer
+	dst   switchWriter
+	level int
+	open  bool
+}
+
+func (p *pooledFlate) Write(b []byte) (int, error) {
+	return p.fw.Write(b)
+}
+
+func (p *pooledFlate) Close() error {
+	if !p.open {
+		return nil
+	}
+	err := p.fw.Close()
+	p.dst.w = nil
+	p.open = false
+	flateWriters[p.level-flate.HuffmanOnly].Put(p)
+	return err
+}
+
+type switchWriter struct {
+	w io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+type storeCompressor struct{}
+
+func (storeCompressor) Method() uint16 { return zipMethodStore }
+
+func (storeCompressor) NewWriter(w io.Writer, _ int) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// compressTo writes what fill produces to w through comp and returns the
+// CRC and size of the data and the size it took. The huffman strategy
+// applies to deflate only.
+func compressTo(w io.Writer, comp Compressor, level int, strategy string, fill func(w io.Writer) error) (crc, usize uint32, csize int64, err error) {
+	if strategy == "huffman" && comp.Method() == zipMethodDeflate {
+		level = flate.HuffmanOnly
+	}
+	counter := &countingWriter{w: w}
+	zw, err := comp.NewWriter(counter, level)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	cw := &crcWriter{w: zw}
+	if err := fill(cw); err != nil {
+		zw.Close()
+		return 0, 0, 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, 0, 0, err
+	}
+	return cw.crc, cw.usize, counter.n, nil
+}
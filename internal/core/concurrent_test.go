@@ -0,0 +1,100 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentRuns runs encrypt and recover runs side by side, sharing
+// a source and a TmpDir, and checks each got its own output right and
+// left no temp files behind. Run it with -race.
+func TestConcurrentRuns(t *testing.T) {
+	const runs = 4
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	want := make(map[string][]byte)
+	for i := range 6 {
+		rel := fmt.Sprintf("d%d/f%d.txt", i%2, i)
+		data := []byte(strings.Repeat(fmt.Sprintf("file %d ", i), 2000+i*500))
+		if err := writeBenchFile(filepath.Join(src, filepath.FromSlash(rel)), func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		want[rel] = data
+	}
+	tmpDir := filepath.Join(dir, "tmp")
+	if err := os.Mkdir(tmpDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	parallel := func(run func(i int) error) {
+		t.Helper()
+		errs := make([]error, runs)
+		var wg sync.WaitGroup
+		for i := range runs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = run(i)
+			}()
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("run %d: %v", i, err)
+			}
+		}
+	}
+	zipPath := func(i int) string { return filepath.Join(dir, fmt.Sprintf("out%d.zip", i)) }
+	outDir := func(i int) string { return filepath.Join(dir, fmt.Sprintf("out%d", i)) }
+
+	parallel(func(i int) error {
+		_, err := RunEncrypt(Config{
+			SrcDir:              src,
+			OutZip:              zipPath(i),
+			Compression:         "deflate",
+			Encoding:            "utf-8",
+			OverwriteCentralDir: i%2 == 1,
+			NoiseFiles:          3,
+			NoiseSize:           4 << 10,
+			Level:               6,
+			Strategy:            "default",
+			DictSize:            32768,
+			Workers:             2,
+			Bait:                true,
+			TmpDir:              tmpDir,
+		}, nil, nil)
+		return err
+	})
+	parallel(func(i int) error {
+		_, err := RecoverZip(RecoverConfig{InZip: zipPath(i), OutDir: outDir(i), MaxMemory: 1 << 10}, nil, nil)
+		return err
+	})
+
+	if left, err := os.ReadDir(tmpDir); err != nil || len(left) > 0 {
+		t.Fatalf("TmpDir holds %d entries after the runs (%v)", len(left), err)
+	}
+	for i := range runs {
+		for rel, data := range want {
+			got, err := os.ReadFile(filepath.Join(outDir(i), filepath.FromSlash(rel)))
+			if err != nil {
+				t.Fatalf("run %d: %v", i, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("run %d: %s has the wrong content", i, rel)
+			}
+		}
+		spills, _ := filepath.Glob(filepath.Join(outDir(i), ".recover-*"))
+		if len(spills) > 0 {
+			t.Fatalf("run %d left spill files %v", i, spills)
+		}
+	}
+}
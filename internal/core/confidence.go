@@ -0,0 +1,56 @@
+package core
+
+// Confidence penalties, subtracted from 100.
+const (
+	confCRCUnknown  = 30
+	confCRCMismatch = 60
+	confNameGuessed = 15
+	confNameOdd     = 10
+	confEndGuessed  = 20
+	confTruncated   = 40
+)
+
+// entryConfidence rates how far a recovered entry can be trusted, from 0 to
+// 100, and lists what lowered the rating.
+func entryConfidence(buf []byte, h localHeader, ent RecoveredEntry, truncated bool) (int, []string) {
+	score := 100
+	var notes []string
+	switch {
+	case ent.CRCCheck == CRCMismatch:
+		score -= confCRCMismatch
+		notes = append(notes, "CRC mismatch")
+	case ent.CRCCheck == CRCUnknown && !truncated && h.comp != zipMethodAES:
+		score -= confCRCUnknown
+		notes = append(notes, "no CRC to check")
+	}
+	if h.guessed {
+		score -= confNameGuessed
+		notes = append(notes, "name charset guessed")
+		if scoreName(h.fname) < 0 {
+			score -= confNameOdd
+			notes = append(notes, "name looks garbled")
+		}
+	}
+	switch {
+	case truncated:
+		score -= confTruncated
+		notes = append(notes, "truncated")
+	case endGuessed(buf, h):
+		score -= confEndGuessed
+		notes = append(notes, "data end guessed from the next header")
+	}
+	return max(score, 0), notes
+}
+
+// endGuessed reports whether the end of an entry's data came from the next
+// local header rather than from a size or the deflate stream itself.
+func endGuessed(buf []byte, h localHeader) bool {
+	if h.exact || (h.comp == 8 && h.flags&zipFlagEncrypted == 0) {
+		return false
+	}
+	if h.flags&zipFlagDataDesc == 0 && h.csize > 0 {
+		return false
+	}
+	_, ok := findDataDescriptor(buf, h.dataOff)
+	return !ok
+}
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const DecoyNoiseGenerator = "decoy"
+
+func init() {
+	RegisterNoiseGenerator(DecoyNoiseGenerator, newDecoyNoise)
+}
+
+var (
+	decoyDirs  = []string{"", "docs", "docs/archive", "photos", "photos/2023", "backup", "projects", "finance", "finance/reports", "work"}
+	decoyWords = []string{"report", "scan", "invoice", "contract", "draft", "notes", "budget", "export", "IMG", "DSC", "backup", "summary", "plan", "data"}
+	decoyExts  = []string{".pdf", ".docx", ".xlsx", ".jpg", ".png", ".zip", ".7z", ".dat", ".bin", ".db"}
+)
+
+// decoyNoise is a NoiseGenerator whose entries look like ordinary user files
+// instead of living under the .junk/ prefix.
+type decoyNoise struct {
+	randReader io.Reader
+	used       map[string]bool
+	random     *randomNoise
+}
+
+func newDecoyNoise(randReader io.Reader) NoiseGenerator {
+	return &decoyNoise{
+		randReader: randReader,
+		used:       make(map[string]bool),
+		random:     &randomNoise{randReader: randReader},
+	}
+}
+
+func (g *decoyNoise) Name(i int) string {
+	rng := randPicker{r: g.randReader}
+	for {
+		dir := decoyDirs[rng.intn(len(decoyDirs))]
+		word := decoyWords[rng.intn(len(decoyWords))]
+		ext := decoyExts[rng.intn(len(decoyExts))]
+		name := fmt.Sprintf("%s_%04d%s", word, rng.intn(10000), ext)
+		if dir != "" {
+			name = dir + "/" + name
+		}
+		if rng.err != nil {
+			// Name cannot return the error; Content does.
+			if g.random.err == nil {
+				g.random.err = rng.err
+			}
+			return name
+		}
+		key := strings.ToLower(name)
+		if !g.used[key] {
+			g.used[key] = true
+			return name
+		}
+	}
+}
+
+func (g *decoyNoise) Content(w io.Writer, size int) error {
+	return g.random.Content(w, size)
+}
+
+// RunDecoy writes an archive made entirely of decoy entries. The total payload
+// of totalSize bytes is split randomly across the entries. cfg supplies the
+// output path and the same compression and obfuscation options RunEncrypt
+// uses; SrcDir and the noise settings are ignored.
+func RunDecoy(cfg Config, entries int, totalSize int64, progress func(done, total int, name string), log func(msg string)) (int, error) {
+	return RunDecoyContext(context.Background(), cfg, entries, totalSize, progress, log)
+}
+
+// RunDecoyContext is RunDecoy with cancellation, cleaning up as
+// RunEncryptContext does.
+func RunDecoyContext(ctx context.Context, cfg Config, entries int, totalSize int64, progress func(done, total int, name string), log func(msg string)) (_ int, err error) {
+	if entries < 1 {
+		return 0, withKind(ErrInvalidConfig, fmt.Errorf("entries must be >= 1"))
+	}
+	if totalSize < 0 {
+		return 0, withKind(ErrInvalidConfig, fmt.Errorf("total-size must be >= 0"))
+	}
+	cfg.NoiseFiles = 0
+	cfg.NoiseSize = 0
+	start := time.Now()
+	lg := runLogger(cfg.Logger, log)
+	if err := normalizeConfig(&cfg); err != nil {
+		return 0, withKind(ErrInvalidConfig, err)
+	}
+	// Random data does not compress, so the archive holds all of
+	// totalSize, and ZIP64 is not written: every offset and size must fit
+	// 32 bits.
+	if entries > 0xFFFF || totalSize+totalSize>>10+int64(entries)*(decoyEntryOverhead+cfg.PadBucket) > 0xFFFFFFFF {
+		return 0, withKind(ErrInvalidConfig, fmt.Errorf("entries and total-size exceed ZIP limits, ZIP64 is not supported"))
+	}
+	if err := checkWriteSpace(cfg, totalSize+int64(entries)*cfg.PadBucket, true); err != nil {
+		return 0, err
+	}
+	if cfg.TmpDir, err = runTempDir(cfg.TmpDir); err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(cfg.TmpDir)
+
+	encName, nameFlag, err := NewNameEncoder(cfg.Encoding)
+	if err != nil {
+		return 0, fmt.Errorf("encoding: %w", err)
+	}
+	comp, err := lookupCompressor(cfg.Compression)
+	if err != nil {
+		return 0, withKind(ErrInvalidConfig, err)
+	}
+
+	randReader := runRandSource(cfg)
+	gen := newDecoyNoise(randReader)
+	sizes, err := splitSize(randReader, totalSize, entries)
+	if err != nil {
+		return 0, fmt.Errorf("decoy: %w", err)
+	}
+	base := time.Now()
+	if cfg.HasSeed {
+		base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	}
+
+	results := make([]entry, 0, entries)
+	defer func() {
+		if err != nil {
+			removeTemps(results)
+		}
+	}()
+	for i := 0; i < entries; i++ {
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("decoy: %w", err)
+		}
+		name := gen.Name(i)
+		age, err := randIntn(randReader, 365*24*3600)
+		if err != nil {
+			return 0, fmt.Errorf("decoy: %w", err)
+		}
+		modTime := base.Add(-time.Duration(age) * time.Second)
+		ent, err := makeNoiseEntry(cfg.TmpDir, gen, name, encName, nameFlag, comp, cfg.Level, cfg.Strategy, cfg.FixedTime, int(sizes[i]), modTime)
+		if err != nil {
+			return 0, fmt.Errorf("decoy: %w", err)
+		}
+		results = append(results, ent)
+		if progress != nil {
+			progress(i+1, entries, name)
+		}
+	}
+	if err := applyPadding(randReader, cfg, results, lg, eventSink{}.warner(lg)); err != nil {
+		return 0, err
+	}
+
+	layout, err := writeZip(ctx, randReader, cfg.OutZip, results, cfg.Workers, cfg.BufferSize, cfg.OverwriteCentralDir, cfg.CommentSize)
+	if err != nil {
+		return 0, fmt.Errorf("write zip: %w", err)
+	}
+	if cfg.ReportPath != "" {
+		cfg.NoiseGenerator = DecoyNoiseGenerator
+		cfg.NoiseFiles = entries
+		if err := writeReport(cfg.ReportPath, cfg.ReportPassword, buildReport(cfg, results, layout)); err != nil {
+			return 0, fmt.Errorf("write report: %w", err)
+		}
+		lg.Info(fmt.Sprintf("Report: %s", cfg.ReportPath), "path", cfg.ReportPath)
+	}
+	var stats EncryptStats
+	stats.tally(results, layout, start)
+	stats.record(runMetrics(cfg.Metrics))
+	return len(results), nil
+}
+
+// decoyEntryOverhead bounds the headers, name and data descriptor of a
+// decoy entry; with a sliver of deflate overhead on the data it keeps the
+// size check of RunDecoyContext on the safe side of 4 GiB.
+const decoyEntryOverhead = 256
+
+// splitSize distributes total bytes over n parts with random weights.
+func splitSize(randReader io.Reader, total int64, n int) ([]int64, error) {
+	weights := make([]int64, n)
+	var sum int64
+	for i := range weights {
+		w, err := randIntn(randReader, 1000)
+		if err != nil {
+			return nil, err
+		}
+		weights[i] = int64(1 + w)
+		sum += weights[i]
+	}
+	sizes := make([]int64, n)
+	var assigned int64
+	for i := range sizes {
+		sizes[i] = total * weights[i] / sum
+		assigned += sizes[i]
+	}
+	sizes[n-1] += total - assigned
+	return sizes, nil
+}
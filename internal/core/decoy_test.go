@@ -0,0 +1,30 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Sizes whose offsets would wrap 32 bits are refused before anything is
+// written.
+func TestDecoyZip64Sizes(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "decoy.zip")
+	cfg := Config{OutZip: out, Compression: CompressStore, Encoding: "utf-8", Level: 6, Strategy: "default", DictSize: 32768}
+	for _, c := range []struct {
+		entries int
+		total   int64
+	}{
+		{1, 5 << 30},
+		{10, 4<<30 - 1},
+		{0x10000, 0},
+	} {
+		if _, err := RunDecoy(cfg, c.entries, c.total, nil, nil); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("%d entries of %d bytes: %v, want ErrInvalidConfig", c.entries, c.total, err)
+		}
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("output written: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"os"
+	"slices"
+)
+
+// dedupEntries marks every real entry whose sum an earlier one has as a
+// duplicate of it and drops its staged data, which the archive never
+// holds. It returns the duplicates and the compressed bytes they save.
+func dedupEntries(entries []entry) (int, int64) {
+	seen := make(map[string]bool)
+	var files int
+	var saved int64
+	for i := range entries {
+		ent := &entries[i]
+		if ent.kind != entryReal || ent.sum == nil || ent.dup {
+			continue
+		}
+		if !seen[string(ent.sum)] {
+			seen[string(ent.sum)] = true
+			continue
+		}
+		if ent.tmp != "" {
+			os.Remove(ent.tmp)
+		}
+		ent.dup, ent.tmp, ent.data, ent.src = true, "", nil, ""
+		files++
+		saved += int64(ent.csize)
+	}
+	return files, saved
+}
+
+// dupTargets maps the sum of every entry that duplicates share the data
+// of to its index in entries, or is nil when there are no duplicates.
+func dupTargets(entries []entry) map[string]int {
+	if !slices.ContainsFunc(entries, func(ent entry) bool { return ent.dup }) {
+		return nil
+	}
+	targets := make(map[string]int)
+	for i, ent := range entries {
+		if ent.sum == nil || ent.dup {
+			continue
+		}
+		if _, ok := targets[string(ent.sum)]; !ok {
+			targets[string(ent.sum)] = i
+		}
+	}
+	return targets
+}
+
+// linkTo makes ent, a duplicate, point at the local header and data of
+// target, with the method, sizes, CRC and flags they were written with.
+// Its own name and time stay.
+func (ent *entry) linkTo(target *entry) {
+	ent.flags, ent.method = target.flags, target.method
+	ent.crc, ent.csize, ent.usize = target.crc, target.csize, target.usize
+	ent.offset = target.offset
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"cmp"
+	"slices"
+)
+
+// DiffEntry is a file present in at least one of two recovered archives.
+// The Old fields are zero for an added file, the New fields for a removed
+// one.
+type DiffEntry struct {
+	Path    string `json:"path"`
+	OldSize int64  `json:"oldSize,omitempty"`
+	NewSize int64  `json:"newSize,omitempty"`
+	OldCRC  uint32 `json:"oldCrc,omitempty"`
+	NewCRC  uint32 `json:"newCrc,omitempty"`
+}
+
+// ArchiveDiff is what DiffReports found between two archives, each list in
+// path order.
+type ArchiveDiff struct {
+	Added     []DiffEntry `json:"added"`
+	Removed   []DiffEntry `json:"removed"`
+	Changed   []DiffEntry `json:"changed"`
+	Unchanged int         `json:"unchanged"`
+}
+
+// Empty reports whether both archives hold the same files.
+func (d ArchiveDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffReports compares the files recovered from two archives by path, size
+// and CRC. Junk, skipped and failed entries take no part, so archives that
+// differ only in their noise compare equal. A truncated entry counts with
+// what was recovered of it.
+func DiffReports(old, new RecoverReport) ArchiveDiff {
+	oldFiles, newFiles := diffFiles(old), diffFiles(new)
+	var d ArchiveDiff
+	for p, o := range oldFiles {
+		n, ok := newFiles[p]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, DiffEntry{Path: p, OldSize: o.Size, OldCRC: o.CRC})
+		case n.Size != o.Size || n.CRC != o.CRC:
+			d.Changed = append(d.Changed, DiffEntry{Path: p, OldSize: o.Size, NewSize: n.Size, OldCRC: o.CRC, NewCRC: n.CRC})
+		default:
+			d.Unchanged++
+		}
+	}
+	for p, n := range newFiles {
+		if _, ok := oldFiles[p]; !ok {
+			d.Added = append(d.Added, DiffEntry{Path: p, NewSize: n.Size, NewCRC: n.CRC})
+		}
+	}
+	byPath := func(a, b DiffEntry) int { return cmp.Compare(a.Path, b.Path) }
+	slices.SortFunc(d.Added, byPath)
+	slices.SortFunc(d.Removed, byPath)
+	slices.SortFunc(d.Changed, byPath)
+	return d
+}
+
+// diffFiles maps the path of every recovered file to its entry. When a path
+// repeats, the later entry wins, as it would on disk.
+func diffFiles(r RecoverReport) map[string]RecoveredEntry {
+	files := map[string]RecoveredEntry{}
+	for _, ent := range r.Entries {
+		if ent.Status == EntryOK || ent.Status == EntryTruncated {
+			files[ent.Path] = ent
+		}
+	}
+	return files
+}
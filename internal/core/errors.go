@@ -0,0 +1,39 @@
+package core
+
+import "errors"
+
+// Kinds of failure that errors returned by this package can be matched
+// against with errors.Is, on top of the fs errors of failed file
+// operations. The message of a matching error is the specific one.
+var (
+	// ErrInvalidConfig marks a config value that is out of range or
+	// malformed: nothing was read or written.
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrNoFiles is returned when the source directory holds no files to
+	// archive.
+	ErrNoFiles = errors.New("no files found in source directory")
+	// ErrVerification marks data that failed an integrity check, such as
+	// an encrypted report whose authentication tag does not match.
+	ErrVerification = errors.New("verification failed")
+	// ErrNoSpace is returned before anything is written when a temp or
+	// output directory has less free space than the run is estimated to
+	// need.
+	ErrNoSpace = errors.New("not enough disk space")
+)
+
+// kindError is err, also matching kind.
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// withKind makes err match kind as well, keeping its message.
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{err: err, kind: kind}
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// noiseSampleSize caps the noise content compressed to estimate one noise
+// entry; bigger entries are scaled from it.
+const noiseSampleSize = 1 << 20
+
+// SizeEstimate is the predicted size of the archive RunEncrypt would write,
+// split by where the bytes go.
+type SizeEstimate struct {
+	Files       int   `json:"files"`
+	SourceBytes int64 `json:"sourceBytes"`
+	// SampledFiles and SampledBytes are the files actually compressed;
+	// the rest are scaled by the ratio they gave.
+	SampledFiles int     `json:"sampledFiles"`
+	SampledBytes int64   `json:"sampledBytes"`
+	Ratio        float64 `json:"ratio"`
+	// Full is set when every file was compressed, at level 1 at most.
+	Full bool `json:"full"`
+
+	Data    int64 `json:"data"`
+	Padding int64 `json:"padding"`
+	Noise   int64 `json:"noise"`
+	Bait    int64 `json:"bait"`
+	Headers int64 `json:"headers"`
+	Trailer int64 `json:"trailer"`
+	Total   int64 `json:"total"`
+}
+
+// EstimateEncrypt predicts the size of the archive cfg describes without
+// writing it. Deflate is measured on a sample of up to sampleSize bytes of
+// the source, or, with full, on every file using a fast level-1 pass, which
+// errs on the large side for higher levels. Noise, bait, padding, headers,
+// the comment and the poison tail are added as RunEncrypt would write them.
+func EstimateEncrypt(ctx context.Context, cfg Config, sampleSize int64, full bool, progress func(done, total int, name string)) (SizeEstimate, error) {
+	var est SizeEstimate
+	if err := normalizeConfig(&cfg); err != nil {
+		return est, withKind(ErrInvalidConfig, err)
+	}
+	if sampleSize < 0 {
+		return est, withKind(ErrInvalidConfig, fmt.Errorf("sample size must be >= 0"))
+	}
+	items, err := sourceFiles(cfg)
+	if err != nil {
+		return est, err
+	}
+	encName, _, err := NewNameEncoder(cfg.Encoding)
+	if err != nil {
+		return est, fmt.Errorf("encoding: %w", err)
+	}
+	comp, err := lookupCompressor(cfg.Compression)
+	if err != nil {
+		return est, withKind(ErrInvalidConfig, err)
+	}
+	compressed := comp.Method() != zipMethodStore
+	level := cfg.Level
+	if full && level > 1 {
+		level = 1
+	}
+
+	est.Files = len(items)
+	for _, it := range items {
+		est.SourceBytes += it.size
+	}
+
+	// Compressed size of every real file: measured for the sample, scaled
+	// for the rest.
+	csizes := make([]int64, len(items))
+	measured := make([]bool, len(items))
+	if compressed {
+		var picked map[string]bool
+		if !full {
+			names, _ := benchSample(items, sampleSize)
+			picked = make(map[string]bool, len(names))
+			for _, name := range names {
+				picked[name] = true
+			}
+		}
+		var in, out int64
+		for i, it := range items {
+			if !full && !picked[it.rel] {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return est, err
+			}
+			f, err := os.Open(it.path)
+			if err != nil {
+				return est, err
+			}
+			n, err := compressedSize(f, comp, level, cfg.Strategy)
+			f.Close()
+			if err != nil {
+				return est, fmt.Errorf("compress %s: %w", it.rel, err)
+			}
+			csizes[i], measured[i] = n, true
+			in += it.size
+			out += n
+			est.SampledFiles++
+			if progress != nil {
+				progress(est.SampledFiles, len(items), it.rel)
+			}
+		}
+		est.SampledBytes = in
+		est.Ratio = 1
+		if in > 0 {
+			est.Ratio = float64(out) / float64(in)
+		}
+	} else {
+		est.Ratio = 1
+	}
+	est.Full = full && compressed
+	for i, it := range items {
+		if !measured[i] {
+			csizes[i] = int64(float64(it.size) * est.Ratio)
+		}
+		est.Data += csizes[i]
+	}
+
+	// Names as they end up in the archive, for the headers.
+	var names [][]byte
+	for _, it := range items {
+		name, err := encName(it.rel)
+		if err != nil {
+			return est, fmt.Errorf("encode name %q: %w", it.rel, err)
+		}
+		names = append(names, name)
+	}
+
+	randReader := runRandSource(cfg)
+	if cfg.NoiseFiles > 0 {
+		factory, err := lookupNoiseGenerator(cfg.NoiseGenerator)
+		if err != nil {
+			return est, err
+		}
+		gen := factory(randReader)
+		var buf bytes.Buffer
+		sample := min(cfg.NoiseSize, noiseSampleSize)
+		if err := gen.Content(&buf, sample); err != nil {
+			return est, fmt.Errorf("noise: %w", err)
+		}
+		n, err := compressedSize(&buf, comp, cfg.Level, cfg.Strategy)
+		if err != nil {
+			return est, fmt.Errorf("noise: %w", err)
+		}
+		if sample > 0 && sample < cfg.NoiseSize {
+			n = int64(float64(n) * float64(cfg.NoiseSize) / float64(sample))
+		}
+		for i := 0; i < cfg.NoiseFiles; i++ {
+			name, err := encName(gen.Name(i))
+			if err != nil {
+				return est, err
+			}
+			names = append(names, name)
+			csizes = append(csizes, n)
+			est.Noise += n
+		}
+	}
+
+	if cfg.Bait {
+		taken := make(map[string]bool, len(items))
+		for _, it := range items {
+			taken[strings.ToLower(it.rel)] = true
+		}
+		baitRand := SeededRandSource(1)
+		for _, bf := range baitFiles {
+			if taken[strings.ToLower(bf.name)] {
+				continue
+			}
+			content, err := bf.content(baitRand)
+			if err != nil {
+				return est, fmt.Errorf("bait %s: %w", bf.name, err)
+			}
+			n, err := compressedSize(bytes.NewReader(content), comp, cfg.Level, cfg.Strategy)
+			if err != nil {
+				return est, fmt.Errorf("bait %s: %w", bf.name, err)
+			}
+			name, err := encName(bf.name)
+			if err != nil {
+				return est, err
+			}
+			names = append(names, name)
+			csizes = append(csizes, n)
+			est.Bait += n
+		}
+	}
+
+	if cfg.PadBucket > 0 && comp.Method() == zipMethodDeflate {
+		for _, n := range csizes {
+			extra := (cfg.PadBucket - n%cfg.PadBucket) % cfg.PadBucket
+			if n+extra <= 0xffffffff {
+				est.Padding += extra
+			}
+		}
+	}
+
+	padded := 0
+	for _, name := range names {
+		padded = max(padded, len(name)+1)
+	}
+	for _, name := range names {
+		nameLen := len(name)
+		if cfg.PadNames {
+			nameLen = padded
+		}
+		// Local header and central directory record, each followed by
+		// the name.
+		est.Headers += 30 + 46 + 2*int64(nameLen)
+		if cfg.OverwriteCentralDir {
+			est.Headers += 16
+		}
+	}
+	est.Trailer = 22 + int64(cfg.CommentSize)
+	if cfg.OverwriteCentralDir {
+		est.Trailer += 32 + 22 + 96
+	}
+	est.Total = est.Data + est.Padding + est.Noise + est.Bait + est.Headers + est.Trailer
+	return est, nil
+}
+
+// compressedSize is the size r takes in the archive through comp.
+func compressedSize(r io.Reader, comp Compressor, level int, strategy string) (int64, error) {
+	_, _, csize, err := compressTo(io.Discard, comp, level, strategy, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+	return csize, err
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Event is a typed progress event of an encrypt run, sent on
+// Config.Events: one of PhaseChanged, FileStarted, FileDone, NoiseDone and
+// Warning.
+type Event interface {
+	isEvent()
+}
+
+// Phases of an encrypt run, in the order PhaseChanged reports them. Every
+// run ends with PhaseDone, failed ones included.
+const (
+	PhaseScan     = "scan"
+	PhaseCompress = "compress"
+	PhaseNoise    = "noise"
+	PhaseWrite    = "write"
+	PhaseDone     = "done"
+)
+
+// PhaseChanged starts a phase of the run.
+type PhaseChanged struct {
+	Phase string
+}
+
+// FileStarted is sent when a worker picks up a source file.
+type FileStarted struct {
+	Name   string
+	Size   int64
+	Worker int
+}
+
+// FileDone is sent when a source file is compressed and staged. Done of
+// Total counts the source files and the noise entries to come, not bait,
+// whose entries depend on the names of the files and are only known once
+// every file is done; NoiseDone.Bait says how many were added.
+type FileDone struct {
+	Name        string
+	Done, Total int
+	// Bytes is the size of the source file, Compressed what it takes in
+	// the archive before padding, and Ratio the one over the other.
+	Bytes      int64
+	Compressed int64
+	Ratio      float64
+}
+
+// NoiseDone is sent once the noise and bait entries are made.
+type NoiseDone struct {
+	Entries int
+	Bait    int
+	Bytes   int64
+}
+
+// Warning is an option that could not be honored, or another condition
+// the run carried on past.
+type Warning struct {
+	Message string
+}
+
+func (PhaseChanged) isEvent() {}
+func (FileStarted) isEvent()  {}
+func (FileDone) isEvent()     {}
+func (NoiseDone) isEvent()    {}
+func (Warning) isEvent()      {}
+
+// eventSink sends the events of a run on a channel that may be nil.
+type eventSink struct {
+	ctx context.Context
+	ch  chan<- Event
+}
+
+// send blocks until ev is received or ctx is done.
+func (s eventSink) send(ev Event) {
+	if s.ch == nil {
+		return
+	}
+	select {
+	case s.ch <- ev:
+	case <-s.ctx.Done():
+	}
+}
+
+// end sends PhaseDone, the last event of every run. Unlike send it waits
+// for the event to be received even once ctx is done, so that a reader
+// looping until it never misses the end.
+func (s eventSink) end() {
+	if s.ch != nil {
+		s.ch <- PhaseChanged{Phase: PhaseDone}
+	}
+}
+
+// warner reports warnings both to lg, as "Note:" records, and as Warning
+// events.
+func (s eventSink) warner(lg *slog.Logger) func(msg string) {
+	return func(msg string) {
+		lg.Warn("Note: " + msg)
+		s.send(Warning{Message: msg})
+	}
+}
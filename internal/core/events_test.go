@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// Every run ends with PhaseDone on the channel, failed ones included, and
+// leaves it open for the next run.
+func TestEventsEndEveryRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeBenchFile(filepath.Join(dir, "src", "a.txt"), func(w io.Writer) error {
+		_, err := io.WriteString(w, "data")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan Event)
+	ended := make(chan int)
+	go func() {
+		for {
+			n := 0
+			for ev := range events {
+				n++
+				if p, ok := ev.(PhaseChanged); ok && p.Phase == PhaseDone {
+					break
+				}
+			}
+			ended <- n
+		}
+	}()
+	cfg := Config{
+		SrcDir:      filepath.Join(dir, "src"),
+		OutZip:      filepath.Join(dir, "out.zip"),
+		Compression: CompressDeflate,
+		Encoding:    "utf-8",
+		Level:       6,
+		Strategy:    "default",
+		DictSize:    32768,
+		Events:      events,
+	}
+
+	if _, err := RunEncryptTo(context.Background(), nil, cfg, nil, nil); err == nil {
+		t.Fatal("run without a writer succeeded")
+	}
+	if n := <-ended; n != 1 {
+		t.Fatalf("run without a writer sent %d events, want PhaseDone alone", n)
+	}
+	bad := cfg
+	bad.Level = 12
+	if _, err := RunEncrypt(bad, nil, nil); err == nil {
+		t.Fatal("run with a bad level succeeded")
+	}
+	<-ended
+	if _, err := RunEncrypt(cfg, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := <-ended; n < 3 {
+		t.Fatalf("run sent %d events", n)
+	}
+}
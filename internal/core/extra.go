@@ -0,0 +1,85 @@
+package core
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+const (
+	extraZip64 = 0x0001
+	extraNTFS  = 0x000a
+)
+
+// extraField returns the data of the first field with the given ID in an
+// extra block. A malformed block ends the search.
+func extraField(extra []byte, id uint16) ([]byte, bool) {
+	for len(extra) >= 4 {
+		fid := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+size > len(extra) {
+			return nil, false
+		}
+		if fid == id {
+			return extra[4 : 4+size], true
+		}
+		extra = extra[4+size:]
+	}
+	return nil, false
+}
+
+// zip64Sizes applies a ZIP64 extended information field (0x0001) to sizes
+// and an offset that are 0xFFFFFFFF placeholders. The field lists only the
+// values that overflowed, in the order usize, csize, offset. Values that
+// really need 64 bits cannot be used and leave ok false, so the caller
+// treats the size as unknown.
+func zip64Sizes(extra []byte, usize, csize, offset *uint32) bool {
+	data, found := extraField(extra, extraZip64)
+	if !found {
+		return false
+	}
+	ok := true
+	for _, v := range []*uint32{usize, csize, offset} {
+		if v == nil || *v != 0xFFFFFFFF {
+			continue
+		}
+		if len(data) < 8 {
+			return false
+		}
+		val := binary.LittleEndian.Uint64(data[:8])
+		data = data[8:]
+		if val >= 0xFFFFFFFF {
+			ok = false
+			continue
+		}
+		*v = uint32(val)
+	}
+	return ok
+}
+
+// ntfsModTime reads the mtime of an NTFS extra field (0x000a), a Windows
+// FILETIME in attribute tag 1.
+func ntfsModTime(extra []byte) (time.Time, bool) {
+	data, ok := extraField(extra, extraNTFS)
+	if !ok || len(data) < 4 {
+		return time.Time{}, false
+	}
+	data = data[4:]
+	for len(data) >= 4 {
+		tag := binary.LittleEndian.Uint16(data[0:2])
+		size := int(binary.LittleEndian.Uint16(data[2:4]))
+		if 4+size > len(data) {
+			break
+		}
+		if tag == 1 && size >= 8 {
+			ft := int64(binary.LittleEndian.Uint64(data[4:12]))
+			// 100ns intervals since 1601-01-01.
+			const epochDiff = 116444736000000000
+			if ft <= epochDiff {
+				return time.Time{}, false
+			}
+			return time.Unix(0, (ft-epochDiff)*100), true
+		}
+		data = data[4+size:]
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package core
+
+// FreeSpace is unknown here, so the space check is skipped.
+func FreeSpace(dir string) (uint64, bool) {
+	return 0, false
+}
+
+// sameFileSystem cannot tell here, so each dir is checked on its own.
+func sameFileSystem(a, b string) bool {
+	return false
+}
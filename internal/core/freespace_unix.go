@@ -0,0 +1,24 @@
+//go:build linux || darwin || freebsd
+
+package core
+
+import "syscall"
+
+// FreeSpace returns the bytes available to unprivileged users on the file
+// system holding dir.
+func FreeSpace(dir string) (uint64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), true
+}
+
+// sameFileSystem reports whether dirs a and b are on one file system.
+func sameFileSystem(a, b string) bool {
+	var sa, sb syscall.Stat_t
+	if syscall.Stat(a, &sa) != nil || syscall.Stat(b, &sb) != nil {
+		return false
+	}
+	return sa.Dev == sb.Dev
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package core
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procGetDiskFreeSpaceEx = kernel32.NewProc("GetDiskFreeSpaceExW")
+	procGetVolumePathNameW = kernel32.NewProc("GetVolumePathNameW")
+)
+
+// FreeSpace returns the bytes available to the calling user on the volume
+// holding dir.
+func FreeSpace(dir string) (uint64, bool) {
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, false
+	}
+	var avail uint64
+	if r, _, _ := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&avail)), 0, 0); r == 0 {
+		return 0, false
+	}
+	return avail, true
+}
+
+// sameFileSystem reports whether dirs a and b are on one volume.
+func sameFileSystem(a, b string) bool {
+	va, ok := volumePath(a)
+	if !ok {
+		return false
+	}
+	vb, ok := volumePath(b)
+	return ok && strings.EqualFold(va, vb)
+}
+
+// volumePath returns the mount point of the volume holding dir.
+func volumePath(dir string) (string, bool) {
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return "", false
+	}
+	buf := make([]uint16, syscall.MAX_PATH+1)
+	if r, _, _ := procGetVolumePathNameW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))); r == 0 {
+		return "", false
+	}
+	return syscall.UTF16ToString(buf), true
+}
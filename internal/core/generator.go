@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NoiseGenerator produces the names and contents of noise entries.
+// Name is called once per entry with its index, Content writes exactly size
+// bytes of payload for that entry.
+type NoiseGenerator interface {
+	Name(i int) string
+	Content(w io.Writer, size int) error
+}
+
+// NoiseGeneratorFactory creates a generator for a single run. randReader is the
+// run's random source, so generators that draw from it stay reproducible when
+// a seed is set.
+type NoiseGeneratorFactory func(randReader io.Reader) NoiseGenerator
+
+const DefaultNoiseGenerator = "random"
+
+var (
+	noiseGenMu sync.RWMutex
+	noiseGens  = map[string]NoiseGeneratorFactory{
+		DefaultNoiseGenerator: newRandomNoise,
+	}
+)
+
+// RegisterNoiseGenerator makes a generator available under name for
+// Config.NoiseGenerator. Registering an existing name replaces it.
+func RegisterNoiseGenerator(name string, factory NoiseGeneratorFactory) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || factory == nil {
+		panic("core: RegisterNoiseGenerator requires a name and a factory")
+	}
+	noiseGenMu.Lock()
+	defer noiseGenMu.Unlock()
+	noiseGens[name] = factory
+}
+
+// NoiseGenerators returns the sorted names of all registered generators.
+func NoiseGenerators() []string {
+	noiseGenMu.RLock()
+	defer noiseGenMu.RUnlock()
+	return sortedKeys(noiseGens)
+}
+
+func lookupNoiseGenerator(name string) (NoiseGeneratorFactory, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = DefaultNoiseGenerator
+	}
+	noiseGenMu.RLock()
+	defer noiseGenMu.RUnlock()
+	factory, ok := noiseGens[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown noise generator %q (available: %s)", name, strings.Join(sortedKeys(noiseGens), ", "))
+	}
+	return factory, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type randomNoise struct {
+	randReader io.Reader
+	// err is the first read of randReader that failed in Name, which
+	// cannot return it; Content does.
+	err error
+}
+
+func newRandomNoise(randReader io.Reader) NoiseGenerator {
+	return &randomNoise{randReader: randReader}
+}
+
+func (g *randomNoise) Name(i int) string {
+	suffix, err := randHex(g.randReader, 6)
+	if err != nil && g.err == nil {
+		g.err = err
+	}
+	return fmt.Sprintf(".junk/%04d_%s.bin", i, suffix)
+}
+
+func (g *randomNoise) Content(w io.Writer, size int) error {
+	if g.err != nil {
+		return g.err
+	}
+	chunk := getChunk(0)
+	defer putChunk(chunk)
+	buf := *chunk
+	remaining := size
+	for remaining > 0 {
+		n := chunkSize
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := io.ReadFull(g.randReader, buf[:n]); err != nil {
+			return err
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+type crcWriter struct {
+	w     io.Writer
+	crc   uint32
+	usize uint32
+}
+
+func (c *crcWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.crc = crc32.Update(c.crc, crc32.IEEETable, p[:n])
+	c.usize += uint32(n)
+	return n, err
+}
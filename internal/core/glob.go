@@ -0,0 +1,72 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether the slash-separated name matches pattern.
+// Segments use path.Match syntax and "**" matches any number of segments.
+// A pattern without a slash is matched against the base name only, so
+// "*.iso" skips ISO images at any depth.
+func matchGlob(pattern, name string) bool {
+	pattern = strings.Trim(strings.ReplaceAll(pattern, "\\", "/"), "/")
+	name = strings.Trim(strings.ReplaceAll(name, "\\", "/"), "/")
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for len(pat) > 0 && pat[0] == "**" {
+				pat = pat[1:]
+			}
+			if len(pat) == 0 {
+				return true
+			}
+			for i := range parts {
+				if matchSegments(pat, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], parts[0]); !ok {
+			return false
+		}
+		pat = pat[1:]
+		parts = parts[1:]
+	}
+	return len(parts) == 0
+}
+
+func validateGlobs(patterns []string) error {
+	for _, p := range patterns {
+		for _, seg := range strings.Split(strings.ReplaceAll(p, "\\", "/"), "/") {
+			if seg == "**" {
+				continue
+			}
+			if _, err := path.Match(seg, ""); err != nil {
+				return fmt.Errorf("bad pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EntryInfo is a source file about to be compressed, as Config.BeforeEntry
+// sees it. The hook may change Name and Modified, or set Skip.
+type EntryInfo struct {
+	// Path is the slash path of the file relative to SrcDir.
+	Path string
+	// Name is the name of its entry in the archive; it starts as Path.
+	Name string
+	Size int64
+	// Modified is the timestamp of the entry; FixedTime still overrides
+	// it.
+	Modified time.Time
+	// Skip leaves the file out of the archive.
+	Skip bool
+}
+
+// EntryResult is a source file compressed and staged, as Config.AfterEntry
+// sees it, or an entry written, as EncryptStats.Results lists it.
+type EntryResult struct {
+	// Path is the slash path of the source file relative to SrcDir, ""
+	// for noise and bait.
+	Path string `json:"path,omitempty"`
+	Name string `json:"name"`
+	// Size is the source size and Compressed the size of its data in the
+	// archive: before any padding for AfterEntry, after it in Results.
+	Size       int64  `json:"size"`
+	Compressed int64  `json:"compressed"`
+	CRC        uint32 `json:"crc"`
+	// Method is the ZIP compression method: 0 for stored data, 8 for
+	// deflated.
+	Method uint16 `json:"method"`
+	// Offset is where the local header of the entry starts in the
+	// archive; AfterEntry sees 0, as nothing is written yet.
+	Offset int64 `json:"offset"`
+	// Noise marks the noise and bait entries.
+	Noise bool `json:"noise,omitempty"`
+}
+
+// applyBeforeEntry runs hook over items in order and returns the files it
+// kept, renamed and retimed as it asked. A new name must be unique, stay
+// relative without ".." components, and keep out of the .junk/ prefix
+// recovery drops as noise.
+func applyBeforeEntry(items []fileItem, hook func(info *EntryInfo) error) ([]fileItem, error) {
+	if hook == nil {
+		return items, nil
+	}
+	kept := items[:0]
+	names := make(map[string]bool, len(items))
+	for _, it := range items {
+		info := EntryInfo{Path: it.rel, Name: it.name, Size: it.size, Modified: it.modTime}
+		if err := hook(&info); err != nil {
+			return nil, fmt.Errorf("before entry %s: %w", it.rel, err)
+		}
+		if info.Skip {
+			continue
+		}
+		if info.Name != it.name {
+			if err := checkEntryName(info.Name); err != nil {
+				return nil, withKind(ErrInvalidConfig, fmt.Errorf("before entry %s: %w", it.rel, err))
+			}
+		}
+		if names[info.Name] {
+			return nil, withKind(ErrInvalidConfig, fmt.Errorf("before entry %s: duplicate entry %q", it.rel, info.Name))
+		}
+		names[info.Name] = true
+		it.name, it.modTime = info.Name, info.Modified
+		it.index = len(kept)
+		kept = append(kept, it)
+	}
+	if len(kept) == 0 {
+		return nil, ErrNoFiles
+	}
+	return kept, nil
+}
+
+// checkEntryName reports why a BeforeEntry hook cannot rename a source
+// file to name.
+func checkEntryName(name string) error {
+	if name == "" {
+		return errors.New("empty name")
+	}
+	rel, why, ok := safeRelPath(name, PathReject)
+	if !ok {
+		if len(why) == 0 {
+			return fmt.Errorf("name %q has no path components", name)
+		}
+		return fmt.Errorf("name %q: %s", name, why[0])
+	}
+	if strings.HasPrefix(filepath.ToSlash(rel), ".junk/") {
+		return fmt.Errorf("name %q is under the .junk/ prefix of noise", name)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+// A BeforeEntry hook may rename files, but not onto another entry, out
+// of the archive root or under the prefix recovery drops as noise.
+func TestBeforeEntryRename(t *testing.T) {
+	items := func() []fileItem {
+		return []fileItem{{rel: "a.txt", name: "a.txt"}, {rel: "b.txt", name: "b.txt"}}
+	}
+	for _, name := range []string{"", "a.txt", "../b.txt", "x/../../b.txt", "/b.txt", `\b.txt`, ".junk/b.txt", "./.junk/b.txt"} {
+		_, err := applyBeforeEntry(items(), func(info *EntryInfo) error {
+			if info.Path == "b.txt" {
+				info.Name = name
+			}
+			return nil
+		})
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("rename to %q: %v, want ErrInvalidConfig", name, err)
+		}
+	}
+	kept, err := applyBeforeEntry(items(), func(info *EntryInfo) error {
+		info.Name = "docs/" + info.Name
+		return nil
+	})
+	if err != nil || len(kept) != 2 || kept[1].name != "docs/b.txt" {
+		t.Fatalf("rename into a directory: %v, %+v", err, kept)
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// IncrementalStateName is the file in the output directory where an
+// incremental run records what it wrote.
+const IncrementalStateName = ".noisyzip-recover.json"
+
+// incrementalRecord ties a file in the output directory to the entry it
+// came from: its offset and the CRC its headers announce.
+type incrementalRecord struct {
+	Offset    int64          `json:"offset"`
+	HeaderCRC uint32         `json:"headerCrc"`
+	Entry     RecoveredEntry `json:"entry"`
+}
+
+// incrementalState is the previous run's records, keyed by path, and the
+// records of this run.
+type incrementalState struct {
+	prev map[string]incrementalRecord
+	next []incrementalRecord
+	// kept lists the paths left in place, which a stopped run must not
+	// remove.
+	kept map[string]bool
+}
+
+func loadIncrementalState(outDir string) (*incrementalState, error) {
+	st := &incrementalState{prev: map[string]incrementalRecord{}, kept: map[string]bool{}}
+	data, err := os.ReadFile(filepath.Join(outDir, IncrementalStateName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var recs []incrementalRecord
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return nil, fmt.Errorf("%s: %w", IncrementalStateName, err)
+	}
+	for _, rec := range recs {
+		st.prev[rec.Entry.Path] = rec
+	}
+	return st, nil
+}
+
+// unchanged returns the entry recorded for path when it came from the same
+// offset with the same header CRC and its file is still there with the
+// recorded size. needSHA rejects records made without a SHA-256.
+func (st *incrementalState) unchanged(path string, offset int64, crc uint32, target string, needSHA bool) (RecoveredEntry, bool) {
+	rec, ok := st.prev[path]
+	if !ok || rec.Offset != offset || rec.HeaderCRC != crc || (needSHA && rec.Entry.SHA256 == "") {
+		return RecoveredEntry{}, false
+	}
+	fi, err := os.Stat(target)
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() != rec.Entry.Size {
+		return RecoveredEntry{}, false
+	}
+	return rec.Entry, true
+}
+
+func (st *incrementalState) record(ent RecoveredEntry, crc uint32) {
+	st.next = append(st.next, incrementalRecord{Offset: ent.Offset, HeaderCRC: crc, Entry: ent})
+}
+
+func (st *incrementalState) save(outDir string) error {
+	recs := st.next
+	if recs == nil {
+		recs = []incrementalRecord{}
+	}
+	data, err := json.MarshalIndent(recs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outDir, IncrementalStateName), append(data, '\n'), 0o644)
+}
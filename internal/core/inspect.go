@@ -0,0 +1,156 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// InspectConfig controls InspectZip.
+type InspectConfig struct {
+	InZip     string
+	InParts   []string
+	Encodings []string
+	// Junk lists glob patterns for noise entries. nil means
+	// DefaultJunkPatterns.
+	Junk []string
+}
+
+// Central directory states reported in InspectReport.CentralDir.
+const (
+	CentralDirValid   = "valid"
+	CentralDirBroken  = "broken"
+	CentralDirMissing = "missing"
+)
+
+// InspectReport lists the obfuscations found in an archive without
+// recovering it.
+type InspectReport struct {
+	Size int64 `json:"size"`
+	// CentralDir is CentralDirValid when an EOCD leads to a central
+	// directory that checks out, CentralDirBroken when EOCD records exist
+	// but none does, and CentralDirMissing when there is no EOCD at all.
+	CentralDir string `json:"centralDir"`
+	Source     string `json:"source"`
+	Entries    int    `json:"entries"`
+	Junk       int    `json:"junk"`
+	Encrypted  int    `json:"encrypted"`
+	// HiddenSizes counts local headers that leave the sizes at zero and
+	// defer them to a data descriptor.
+	HiddenSizes int `json:"hiddenSizes"`
+	// ScrambledCRCs counts local headers whose CRC disagrees with the
+	// central directory or the entry's data descriptor.
+	ScrambledCRCs int `json:"scrambledCrcs"`
+	PaddedNames   int `json:"paddedNames"`
+	// PaddedStreams counts deflated entries whose stream ends before the
+	// compressed size says.
+	PaddedStreams   int               `json:"paddedStreams"`
+	FakeEOCDs       int               `json:"fakeEocds"`
+	FakeCentralDirs int               `json:"fakeCentralDirs"`
+	PoisonTail      int64             `json:"poisonTail"`
+	CommentJunk     int64             `json:"commentJunk"`
+	Poison          []PoisonStructure `json:"poison,omitempty"`
+	// NoisyZip is set when the archive carries structures only NoisyZip
+	// writes: its poison tail record or entries under its junk prefix.
+	NoisyZip bool `json:"noisyZip"`
+}
+
+// InspectZip reads an archive and reports how it was obfuscated. Entries
+// are not decoded, except deflate streams to measure their padding.
+func InspectZip(cfg InspectConfig) (InspectReport, error) {
+	var rep InspectReport
+	if err := validateGlobs(cfg.Junk); err != nil {
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("junk: %w", err))
+	}
+	names, err := newFilenameDecoder(cfg.Encodings, nil)
+	if err != nil {
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("encodings: %w", err))
+	}
+	buf, err := readRecoverInput(RecoverConfig{InZip: cfg.InZip, InParts: cfg.InParts})
+	if err != nil {
+		return rep, err
+	}
+	rep.Size = int64(len(buf))
+
+	source, headers, positions := locateHeaders(memData(buf), false, names)
+	rep.Source = source
+	rep.Poison = findPoison(buf, names)
+	rep.CentralDir = CentralDirMissing
+	if source == SourceCentralDir {
+		rep.CentralDir = CentralDirValid
+	}
+	for _, p := range rep.Poison {
+		switch p.Kind {
+		case PoisonFakeEOCD:
+			rep.FakeEOCDs++
+			if rep.CentralDir == CentralDirMissing {
+				rep.CentralDir = CentralDirBroken
+			}
+			if strings.HasPrefix(p.Detail, "NoisyZip") {
+				rep.NoisyZip = true
+			}
+		case PoisonFakeCentralDir:
+			rep.FakeCentralDirs++
+		case PoisonTail:
+			rep.PoisonTail += p.Size
+		case PoisonCommentJunk:
+			rep.CommentJunk += p.Size
+		}
+	}
+
+	if source == SourceScan {
+		for _, off := range positions {
+			if h, ok := parseLocalHeader(memData(buf), off, names); ok {
+				headers = append(headers, h)
+			}
+		}
+	}
+	rep.Entries = len(headers)
+	for _, h := range headers {
+		if !h.valid {
+			continue
+		}
+		name, padded := unpadName(h.fname)
+		if padded {
+			rep.PaddedNames++
+		}
+		if rel, _, ok := safeRelPath(name, PathStrip); ok && isJunkPath(cfg.Junk, rel) {
+			rep.Junk++
+			rep.NoisyZip = rep.NoisyZip || isJunkPath(DefaultJunkPatterns, rel)
+		}
+		if h.flags&zipFlagEncrypted != 0 {
+			rep.Encrypted++
+		}
+		localCRC := binary.LittleEndian.Uint32(buf[h.off+14 : h.off+18])
+		localCSize := binary.LittleEndian.Uint32(buf[h.off+18 : h.off+22])
+		localUSize := binary.LittleEndian.Uint32(buf[h.off+22 : h.off+26])
+		if h.flags&zipFlagDataDesc != 0 && localCSize == 0 && localUSize == 0 && (!h.exact || h.csize > 0) {
+			rep.HiddenSizes++
+		}
+		if want, ok := headerCRC(buf, h); ok && localCRC != 0 && localCRC != want {
+			rep.ScrambledCRCs++
+		}
+		if h.exact && h.comp == 8 && h.flags&zipFlagEncrypted == 0 && h.dataOff+int(h.csize) <= len(buf) {
+			if n, err := inflateLen(io.Discard, buf[h.dataOff:h.dataOff+int(h.csize)]); err == nil && n < int(h.csize) {
+				rep.PaddedStreams++
+			}
+		}
+	}
+	return rep, nil
+}
+
+// headerCRC returns the CRC a local header's CRC field is checked against:
+// the central directory's, or the data descriptor's for scanned headers.
+func headerCRC(buf []byte, h localHeader) (uint32, bool) {
+	if h.exact {
+		return h.crc, true
+	}
+	if h.flags&zipFlagDataDesc == 0 {
+		return 0, false
+	}
+	if dd, ok := findDataDescriptor(buf, h.dataOff); ok {
+		return dd.crc, true
+	}
+	return 0, false
+}
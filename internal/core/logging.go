@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler adapts a func(string) log callback, as the Run and Recover
+// functions take, to slog: each record at Info level or above is passed on
+// as its message, which spells out what its attributes hold. Debug records
+// are dropped.
+func LogHandler(log func(msg string)) slog.Handler {
+	return callbackHandler{log: log}
+}
+
+type callbackHandler struct {
+	log func(msg string)
+}
+
+func (h callbackHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h callbackHandler) Handle(_ context.Context, r slog.Record) error {
+	h.log(r.Message)
+	return nil
+}
+
+func (h callbackHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h callbackHandler) WithGroup(string) slog.Handler      { return h }
+
+// runLogger is the logger of a run: logger when set, otherwise log through
+// LogHandler, otherwise one that discards everything.
+func runLogger(logger *slog.Logger, log func(msg string)) *slog.Logger {
+	switch {
+	case logger != nil:
+		return logger
+	case log != nil:
+		return slog.New(callbackHandler{log: log})
+	}
+	return slog.New(slog.DiscardHandler)
+}
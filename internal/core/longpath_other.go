@@ -0,0 +1,7 @@
+//go:build !windows
+
+package core
+
+func longPath(p string) string {
+	return p
+}
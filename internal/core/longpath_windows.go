@@ -0,0 +1,29 @@
+//go:build windows
+
+package core
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxShortPath is the longest path the Win32 file APIs take without the
+// \\?\ prefix (MAX_PATH less room for a 8.3 file name).
+const maxShortPath = 248
+
+// longPath returns p in the \\?\ form when it is too long for the classic
+// Win32 limit. The os package does this by itself only for absolute paths,
+// and recovered paths are usually relative to the output directory.
+func longPath(p string) string {
+	if len(p) < maxShortPath || strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
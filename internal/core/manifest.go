@@ -0,0 +1,52 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RecoverManifest lists the files written by a recovery run so the output
+// can be checked later with standard tools.
+type RecoverManifest struct {
+	Generated time.Time       `json:"generated"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Offset int64  `json:"offset"`
+	// Truncated marks a file that holds only the part of the entry found
+	// before the end of the archive.
+	Truncated bool `json:"truncated,omitempty"`
+	// Confidence is RecoveredEntry.Confidence.
+	Confidence int `json:"confidence"`
+}
+
+func writeManifest(path string, rep RecoverReport) error {
+	man := RecoverManifest{Generated: time.Now().UTC(), Files: []ManifestEntry{}}
+	for _, ent := range rep.Entries {
+		if ent.Status != EntryOK && ent.Status != EntryTruncated {
+			continue
+		}
+		man.Files = append(man.Files, ManifestEntry{
+			Path:       ent.Path,
+			Size:       ent.Size,
+			SHA256:     ent.SHA256,
+			Offset:     ent.Offset,
+			Truncated:  ent.Status == EntryTruncated,
+			Confidence: ent.Confidence,
+		})
+	}
+	data, err := json.MarshalIndent(man, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
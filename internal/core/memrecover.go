@@ -0,0 +1,188 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// MemoryEntry is a file recovered by RecoverEntries: its report entry, with
+// Path, Confidence and the rest, and its decoded data.
+type MemoryEntry struct {
+	RecoveredEntry
+	Data []byte
+	// Mode is the permission bits the archive recorded, 0 when none.
+	Mode fs.FileMode
+}
+
+// memSink collects the files of a run in memory mode.
+type memSink struct {
+	entries []MemoryEntry
+}
+
+// RecoverEntries recovers the archive of cfg into memory and returns the
+// files it wrote, truncated ones included, in the order of the report.
+// Nothing is written to disk: OutDir, ListOnly and MaxMemory are ignored,
+// as every entry is held in memory whole, so the MaxEntrySize and
+// MaxTotalSize limits are the way to bound it. TarOut, Checkpoint,
+// Incremental, KeepJunk, Manifest and Comments write files of their own
+// and are refused.
+func RecoverEntries(ctx context.Context, cfg RecoverConfig, progressCb func(done, total int, name string), logCb func(string)) ([]MemoryEntry, RecoverReport, error) {
+	switch {
+	case cfg.TarOut != nil:
+		return nil, RecoverReport{}, withKind(ErrInvalidConfig, errors.New("in-memory recovery cannot write a tar stream"))
+	case cfg.Checkpoint != "" || cfg.Incremental:
+		return nil, RecoverReport{}, withKind(ErrInvalidConfig, errors.New("in-memory recovery cannot be combined with a checkpoint or incremental recovery"))
+	case cfg.KeepJunk != "" || cfg.Manifest != "" || cfg.Comments != "":
+		return nil, RecoverReport{}, withKind(ErrInvalidConfig, errors.New("in-memory recovery cannot keep junk or write a manifest or comments file"))
+	}
+	cfg.OutDir = ""
+	cfg.ListOnly = false
+	cfg.MaxMemory = 0
+	cfg.mem = &memSink{}
+	rep, err := RecoverZipContext(ctx, cfg, progressCb, logCb)
+	return cfg.mem.entries, rep, err
+}
+
+// RecoverToFS is RecoverEntries with the files laid out as an fs.FS, by
+// their recovered paths. Directories come from those paths; a later entry
+// with the path of an earlier one replaces it, as on disk. Files opened
+// from it also implement io.Seeker and io.ReaderAt.
+func RecoverToFS(ctx context.Context, cfg RecoverConfig, progressCb func(done, total int, name string), logCb func(string)) (fs.FS, RecoverReport, error) {
+	entries, rep, err := RecoverEntries(ctx, cfg, progressCb, logCb)
+	if err != nil {
+		return nil, rep, err
+	}
+	return newMemFS(entries), rep, nil
+}
+
+// memFS is a read-only tree of recovered files.
+type memFS struct {
+	root *memNode
+}
+
+type memNode struct {
+	name     string
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children map[string]*memNode // nil for files
+}
+
+func newMemFS(entries []MemoryEntry) memFS {
+	root := &memNode{name: ".", mode: fs.ModeDir | 0o755, children: make(map[string]*memNode)}
+	for _, ent := range entries {
+		if !fs.ValidPath(ent.Path) {
+			continue
+		}
+		parts := strings.Split(path.Clean(ent.Path), "/")
+		dir := root
+		for _, p := range parts[:len(parts)-1] {
+			next := dir.children[p]
+			if next == nil {
+				next = &memNode{name: p, mode: fs.ModeDir | 0o755, children: make(map[string]*memNode)}
+				dir.children[p] = next
+			}
+			dir = next
+			if dir.children == nil {
+				break
+			}
+		}
+		base := parts[len(parts)-1]
+		if dir.children == nil {
+			// A file stands where a directory of the path should be.
+			continue
+		}
+		if prev := dir.children[base]; prev != nil && prev.children != nil {
+			continue
+		}
+		mode := ent.Mode.Perm()
+		if mode == 0 {
+			mode = 0o644
+		}
+		dir.children[base] = &memNode{name: base, data: ent.Data, mode: mode, modTime: ent.Modified}
+	}
+	return memFS{root: root}
+}
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	n := m.root
+	if name != "." {
+		for _, p := range strings.Split(name, "/") {
+			if n.children == nil || n.children[p] == nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+			n = n.children[p]
+		}
+	}
+	return &memFile{node: n, path: name, Reader: bytes.NewReader(n.data)}, nil
+}
+
+// ReadFile returns a copy of the data of a file, for fs.ReadFile.
+func (m memFS) ReadFile(name string) ([]byte, error) {
+	f, err := m.Open(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.Unwrap(err)}
+	}
+	n := f.(*memFile).node
+	if n.children != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+	return bytes.Clone(n.data), nil
+}
+
+type memFile struct {
+	*bytes.Reader
+	node *memNode
+	path string
+	// dirRead is how many directory entries ReadDir has returned.
+	dirRead int
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return memInfo{f.node}, nil }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.node.children != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: errors.New("is a directory")}
+	}
+	return f.Reader.Read(p)
+}
+
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.node.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.path, Err: errors.New("not a directory")}
+	}
+	names := sortedKeys(f.node.children)[f.dirRead:]
+	if n > 0 && len(names) > n {
+		names = names[:n]
+	}
+	if n > 0 && len(names) == 0 {
+		return nil, io.EOF
+	}
+	f.dirRead += len(names)
+	list := make([]fs.DirEntry, len(names))
+	for i, name := range names {
+		list[i] = fs.FileInfoToDirEntry(memInfo{f.node.children[name]})
+	}
+	return list, nil
+}
+
+type memInfo struct {
+	node *memNode
+}
+
+func (i memInfo) Name() string       { return i.node.name }
+func (i memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memInfo) ModTime() time.Time { return i.node.modTime }
+func (i memInfo) IsDir() bool        { return i.node.children != nil }
+func (i memInfo) Sys() any           { return nil }
@@ -0,0 +1,49 @@
+package core
+
+import "sync"
+
+// Defaults of MemEntrySize and MemTotal the command and the noisyzip
+// package use.
+const (
+	DefaultMemEntrySize = 4 << 20
+	DefaultMemTotal     = 64 << 20
+)
+
+// memBudget is the memory the workers of a run may stage entries in,
+// instead of temp files: files of up to max bytes while left lasts. A nil
+// budget stages everything on disk.
+type memBudget struct {
+	max  int64
+	mu   sync.Mutex
+	left int64
+}
+
+func newMemBudget(cfg Config) *memBudget {
+	if cfg.MemEntrySize <= 0 || cfg.MemTotal <= 0 {
+		return nil
+	}
+	return &memBudget{max: cfg.MemEntrySize, left: cfg.MemTotal}
+}
+
+// take reserves size bytes for an entry staged in memory and reports
+// whether it may be.
+func (b *memBudget) take(size int64) bool {
+	if b == nil || size > b.max {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if size > b.left {
+		return false
+	}
+	b.left -= size
+	return true
+}
+
+// settle corrects a reservation of took bytes to the used bytes the staged
+// stream turned out to take; a stream that could not be staged uses 0.
+func (b *memBudget) settle(took, used int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.left += took - used
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Empty files staged in memory must be written as such, stored or
+// deflated.
+func TestMemStageEmptyFiles(t *testing.T) {
+	for _, compression := range []string{CompressStore, CompressDeflate} {
+		t.Run(compression, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "src")
+			want := map[string]string{"empty.txt": "", "full.txt": "some data"}
+			for name, content := range want {
+				if err := writeBenchFile(filepath.Join(src, name), func(w io.Writer) error {
+					_, err := io.WriteString(w, content)
+					return err
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out := filepath.Join(dir, "out.zip")
+			_, err := RunEncrypt(Config{
+				SrcDir:       src,
+				OutZip:       out,
+				Compression:  compression,
+				Encoding:     "utf-8",
+				Level:        6,
+				Strategy:     "default",
+				DictSize:     32768,
+				Workers:      1,
+				MemEntrySize: 1 << 20,
+				MemTotal:     1 << 20,
+			}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := zip.OpenReader(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			got := make(map[string]string)
+			for _, f := range r.File {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatalf("%s: %v", f.Name, err)
+				}
+				data, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatalf("%s: %v", f.Name, err)
+				}
+				got[f.Name] = string(data)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("archive holds %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// countMetrics keeps the counters a run reports.
+type countMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (m *countMetrics) Add(name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[name] += delta
+}
+
+func (m *countMetrics) Observe(string, time.Duration) {}
+
+// Data staged in memory or copied from its source never goes through a
+// temp file, and MetricTempBytes must not count it.
+func TestMemStageTempBytes(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := writeBenchFile(filepath.Join(src, "a.txt"), func(w io.Writer) error {
+		_, err := io.WriteString(w, strings.Repeat("data ", 1000))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{
+		SrcDir:      src,
+		Compression: CompressDeflate,
+		Encoding:    "utf-8",
+		Level:       6,
+		Strategy:    "default",
+		DictSize:    32768,
+		Workers:     1,
+	}
+	for _, c := range []struct {
+		name     string
+		memEntry int64
+		want     bool
+	}{
+		{"temp", 0, true},
+		{"memory", 1 << 20, false},
+	} {
+		m := &countMetrics{counts: make(map[string]int64)}
+		run := cfg
+		run.OutZip = filepath.Join(dir, c.name+".zip")
+		run.MemEntrySize, run.MemTotal = c.memEntry, c.memEntry
+		run.Metrics = m
+		if _, err := RunEncrypt(run, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.counts[MetricTempBytes] > 0; got != c.want {
+			t.Fatalf("%s: %s is %d", c.name, MetricTempBytes, m.counts[MetricTempBytes])
+		}
+	}
+}
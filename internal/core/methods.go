@@ -0,0 +1,89 @@
+package core
+
+// Deflate option hints from bits 1-2 of the general purpose flags, reported
+// in RecoveredEntry.Deflate.
+const (
+	DeflateNormal    = "normal"
+	DeflateMaximum   = "maximum"
+	DeflateFast      = "fast"
+	DeflateSuperFast = "superfast"
+)
+
+const deflateHintMask = 3 << 1
+
+// FileMethod is how one file is compressed when a ZIP is written, in place
+// of Config.Compression and Config.Level.
+type FileMethod struct {
+	Deflate bool
+	Level   int
+}
+
+// deflateHint returns the deflate option hint of an entry whose data is
+// deflated, looking through WinZip AES to the method inside, or "" for
+// stored data.
+func deflateHint(h localHeader) string {
+	method := h.comp
+	if method == zipMethodAES {
+		ae, ok := parseAESExtra(h.extra)
+		if !ok {
+			return ""
+		}
+		method = ae.method
+	}
+	if method != 8 {
+		return ""
+	}
+	switch (h.flags & deflateHintMask) >> 1 {
+	case 1:
+		return DeflateMaximum
+	case 2:
+		return DeflateFast
+	case 3:
+		return DeflateSuperFast
+	}
+	return DeflateNormal
+}
+
+// hintLevel maps a deflate hint to the compress/flate level that zip tools
+// set it for.
+func hintLevel(hint string) int {
+	switch hint {
+	case DeflateMaximum:
+		return 9
+	case DeflateFast:
+		return 2
+	case DeflateSuperFast:
+		return 1
+	}
+	return 6
+}
+
+// levelHintFlags returns the general purpose flag bits announcing level.
+func levelHintFlags(level int) uint16 {
+	switch {
+	case level >= 8:
+		return 1 << 1
+	case level == 2:
+		return 2 << 1
+	case level == 1:
+		return 3 << 1
+	}
+	return 0
+}
+
+// FileMethods returns, for Config.FileMethods, the compression of every file
+// written: deflate at the level its hint names, or store.
+func (r RecoverReport) FileMethods() map[string]FileMethod {
+	methods := map[string]FileMethod{}
+	for _, ent := range r.Entries {
+		if ent.Status != EntryOK && ent.Status != EntryTruncated {
+			continue
+		}
+		if ent.Deflate == "" {
+			methods[ent.Path] = FileMethod{}
+		} else {
+			methods[ent.Path] = FileMethod{Deflate: true, Level: hintLevel(ent.Deflate)}
+		}
+	}
+	return methods
+}
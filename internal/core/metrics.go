@@ -0,0 +1,86 @@
+package core
+
+import (
+	"expvar"
+	"time"
+)
+
+// Metrics receives the counters and timings of runs, for embedders that
+// export them: Add bumps the counter name by delta and Observe records one
+// timing of name. Both may be called from several goroutines at once.
+type Metrics interface {
+	Add(name string, delta int64)
+	Observe(name string, d time.Duration)
+}
+
+// Names of the counters and timings runs report to Metrics.
+const (
+	// MetricFilesCompressed counts source files compressed and staged,
+	// MetricBytesIn their size and MetricCompressTime the time each took.
+	MetricFilesCompressed = "files_compressed"
+	MetricBytesIn         = "bytes_in"
+	MetricCompressTime    = "compress_time"
+	// MetricBytesOut counts the bytes of the archives written and
+	// MetricNoiseBytes the noise and bait data in them.
+	MetricBytesOut   = "bytes_out"
+	MetricNoiseBytes = "noise_bytes"
+	// MetricTempBytes counts the bytes staged in temp files and copied
+	// back out into an archive.
+	MetricTempBytes = "temp_bytes"
+	// MetricEncryptRuns counts encrypt runs that completed and
+	// MetricEncryptTime the time each took.
+	MetricEncryptRuns = "encrypt_runs"
+	MetricEncryptTime = "encrypt_time"
+	// MetricRecoverAttempts counts the entries recovery tried to decode,
+	// MetricRecoverFailed those that failed, MetricRecoverBytes the data
+	// it decoded and MetricRecoverTime the time each run took.
+	MetricRecoverAttempts = "recover_attempts"
+	MetricRecoverFailed   = "recover_failed"
+	MetricRecoverBytes    = "recover_bytes"
+	MetricRecoverTime     = "recover_time"
+)
+
+// ExpvarMetrics publishes the metrics as the expvar map name, served with
+// the other expvars at /debug/vars: counters under their own names, and
+// each timing as name_count and name_ns, its total in nanoseconds. Like
+// expvar.NewMap it panics when name is already in use.
+func ExpvarMetrics(name string) Metrics {
+	return expvarMetrics{m: expvar.NewMap(name)}
+}
+
+type expvarMetrics struct {
+	m *expvar.Map
+}
+
+func (e expvarMetrics) Add(name string, delta int64) {
+	e.m.Add(name, delta)
+}
+
+func (e expvarMetrics) Observe(name string, d time.Duration) {
+	e.m.Add(name+"_count", 1)
+	e.m.Add(name+"_ns", int64(d))
+}
+
+// noMetrics is the Metrics of a run without any.
+type noMetrics struct{}
+
+func (noMetrics) Add(string, int64)             {}
+func (noMetrics) Observe(string, time.Duration) {}
+
+// runMetrics is m, or one that drops everything when m is nil.
+func runMetrics(m Metrics) Metrics {
+	if m == nil {
+		return noMetrics{}
+	}
+	return m
+}
+
+// record reports the totals of a completed encrypt run; the per-file
+// counters are reported as files are done.
+func (s EncryptStats) record(m Metrics) {
+	m.Add(MetricBytesOut, s.OutputBytes)
+	m.Add(MetricNoiseBytes, s.NoiseBytes+s.BaitBytes)
+	m.Add(MetricTempBytes, s.tempBytes)
+	m.Add(MetricEncryptRuns, 1)
+	m.Observe(MetricEncryptTime, s.Duration)
+}
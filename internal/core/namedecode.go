@@ -0,0 +1,219 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// NameDecoder turns the raw bytes of a filename without the UTF-8 flag into a
+// candidate name. ok is false when the bytes are not valid in its charset.
+type NameDecoder interface {
+	Decode(name []byte) (string, bool)
+}
+
+// NameScorer rates how plausible a decoded filename looks. The candidate with
+// the highest score wins; ties go to the decoder listed first.
+type NameScorer func(name string) int
+
+// DefaultNameDecoders is the candidate list used when
+// RecoverConfig.Encodings is empty.
+var DefaultNameDecoders = []string{"utf-8", "cp866", "cp1251", "cp437"}
+
+var (
+	nameDecoderMu sync.RWMutex
+	nameDecoders  = map[string]NameDecoder{
+		"utf-8":      utf8Decoder{},
+		"cp866":      charsetDecoder{charmap.CodePage866},
+		"cp1251":     charsetDecoder{charmap.Windows1251},
+		"cp437":      charsetDecoder{charmap.CodePage437},
+		"koi8-r":     charsetDecoder{charmap.KOI8R},
+		"iso-8859-1": charsetDecoder{charmap.ISO8859_1},
+		"iso-8859-2": charsetDecoder{charmap.ISO8859_2},
+		"shift-jis":  charsetDecoder{japanese.ShiftJIS},
+		"gbk":        charsetDecoder{simplifiedchinese.GBK},
+		"big5":       charsetDecoder{traditionalchinese.Big5},
+	}
+)
+
+// RegisterNameDecoder makes a decoder available under name for
+// RecoverConfig.Encodings. Registering an existing name replaces it.
+func RegisterNameDecoder(name string, dec NameDecoder) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || dec == nil {
+		panic("core: RegisterNameDecoder requires a name and a decoder")
+	}
+	nameDecoderMu.Lock()
+	defer nameDecoderMu.Unlock()
+	nameDecoders[name] = dec
+}
+
+// NameDecoders returns the sorted names of all registered decoders.
+func NameDecoders() []string {
+	nameDecoderMu.RLock()
+	defer nameDecoderMu.RUnlock()
+	return sortedKeys(nameDecoders)
+}
+
+// DecodeName reads raw with the decoder registered as encoding. ok is false
+// when the bytes are not valid in that charset.
+func DecodeName(encoding string, raw []byte) (name string, ok bool, err error) {
+	key := strings.ToLower(strings.TrimSpace(encoding))
+	nameDecoderMu.RLock()
+	dec, found := nameDecoders[key]
+	nameDecoderMu.RUnlock()
+	if !found {
+		return "", false, fmt.Errorf("unknown encoding %q (available: %s)", encoding, strings.Join(NameDecoders(), ", "))
+	}
+	name, ok = dec.Decode(raw)
+	return name, ok, nil
+}
+
+// GuessName is the name RecoverZip gives an entry whose name bytes are raw,
+// with or without the UTF-8 flag, trying encodings as RecoverConfig.Encodings
+// does. ok is false when no candidate could decode it.
+func GuessName(raw []byte, utf8Flag bool, encodings []string) (name string, ok bool, err error) {
+	fd, err := newFilenameDecoder(encodings, nil)
+	if err != nil {
+		return "", false, err
+	}
+	var flags uint16
+	if utf8Flag {
+		flags = zipFlagUTF8
+	}
+	name, ok = fd.decode(raw, nil, flags)
+	return name, ok, nil
+}
+
+type utf8Decoder struct{}
+
+func (utf8Decoder) Decode(name []byte) (string, bool) {
+	if !utf8.Valid(name) {
+		return "", false
+	}
+	return string(name), true
+}
+
+type charsetDecoder struct {
+	enc encoding.Encoding
+}
+
+func (d charsetDecoder) Decode(name []byte) (string, bool) {
+	out, err := d.enc.NewDecoder().Bytes(name)
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+// filenameDecoder picks the best-scoring name among its candidate decoders.
+type filenameDecoder struct {
+	decoders []NameDecoder
+	score    NameScorer
+}
+
+func newFilenameDecoder(names []string, score NameScorer) (*filenameDecoder, error) {
+	if len(names) == 0 {
+		names = DefaultNameDecoders
+	}
+	if score == nil {
+		score = scoreName
+	}
+	nameDecoderMu.RLock()
+	defer nameDecoderMu.RUnlock()
+	fd := &filenameDecoder{score: score}
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		dec, ok := nameDecoders[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown encoding %q (available: %s)", name, strings.Join(sortedKeys(nameDecoders), ", "))
+		}
+		fd.decoders = append(fd.decoders, dec)
+	}
+	return fd, nil
+}
+
+// decode returns the name of an entry. An Info-ZIP Unicode Path field in
+// extra is authoritative; otherwise the UTF-8 flag or the candidate decoders
+// decide. A UTF-8 flag on bytes that are not UTF-8 is ignored, so the name
+// still gets a charset guess instead of the entry being lost.
+func (fd *filenameDecoder) decode(name, extra []byte, flags uint16) (string, bool) {
+	if uname, ok := unicodePathName(name, extra); ok {
+		return uname, true
+	}
+	if flags&zipFlagUTF8 != 0 && utf8.Valid(name) {
+		return string(name), true
+	}
+
+	best, bestScore, found := "", 0, false
+	for _, dec := range fd.decoders {
+		decoded, ok := dec.Decode(name)
+		if !ok {
+			continue
+		}
+		if s := fd.score(decoded); !found || s > bestScore {
+			best, bestScore, found = decoded, s, true
+		}
+	}
+	return best, found
+}
+
+// nameGuessed reports whether the charset of a name had to be guessed: it is
+// not plain ASCII and neither a truthful UTF-8 flag nor a valid Unicode Path
+// field says how to read it.
+func nameGuessed(name, extra []byte, flags uint16) bool {
+	if isASCII(name) || (flags&zipFlagUTF8 != 0 && utf8.Valid(name)) {
+		return false
+	}
+	_, ok := unicodePathName(name, extra)
+	return !ok
+}
+
+// badUTF8Name reports whether a name carries the UTF-8 flag without being
+// UTF-8, and no Unicode Path field makes up for it.
+func badUTF8Name(name, extra []byte, flags uint16) bool {
+	if flags&zipFlagUTF8 == 0 || utf8.Valid(name) {
+		return false
+	}
+	_, ok := unicodePathName(name, extra)
+	return !ok
+}
+
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+const extraUnicodePath = 0x7075
+
+// unicodePathName reads the Info-ZIP Unicode Path extra field (0x7075). The
+// field is ignored unless it is version 1, its name CRC matches the main
+// name bytes (so it was not left stale by a renaming tool) and it holds valid
+// UTF-8.
+func unicodePathName(name, extra []byte) (string, bool) {
+	data, ok := extraField(extra, extraUnicodePath)
+	if !ok || len(data) < 5 || data[0] != 1 {
+		return "", false
+	}
+	if binary.LittleEndian.Uint32(data[1:5]) != crc32.ChecksumIEEE(name) {
+		return "", false
+	}
+	uname := data[5:]
+	if len(uname) == 0 || !utf8.Valid(uname) {
+		return "", false
+	}
+	return string(uname), true
+}
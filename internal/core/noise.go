@@ -1,15 +1,20 @@
 package core
 
 import (
-	"compress/flate"
-	crand "crypto/rand"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"io"
-	mrand "math/rand"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -28,10 +33,37 @@ const (
 	chunkSize = 1024 * 1024
 )
 
+// chunkPool holds the buffers entry data is copied through, so a run over
+// many files does not allocate one per file.
+var chunkPool = sync.Pool{New: func() any {
+	buf := make([]byte, chunkSize)
+	return &buf
+}}
+
+// getChunk returns a buffer of size bytes from chunkPool, chunkSize when
+// size is 0.
+func getChunk(size int) *[]byte {
+	if size <= 0 {
+		size = chunkSize
+	}
+	buf := chunkPool.Get().(*[]byte)
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+	*buf = (*buf)[:size]
+	return buf
+}
+
+func putChunk(buf *[]byte) {
+	chunkPool.Put(buf)
+}
+
 type fileItem struct {
 	index   int
 	path    string
 	rel     string
+	name    string // entry name: rel, unless BeforeEntry renamed it
+	size    int64
 	modTime time.Time
 }
 
@@ -46,117 +78,352 @@ type entry struct {
 	usize  uint32
 	offset uint32
 	tmp    string
+	// data, when not nil, is the stream staged in memory instead of tmp.
+	data []byte
+	// src, when set, is the source file a stored entry is copied from at
+	// write time instead of tmp, with the size and time it had when its
+	// CRC was taken.
+	src    string
+	srcMod time.Time
+	label  string
+	// rel is the source path of a real entry, relative to SrcDir.
+	rel  string
+	kind entryKind
+	// sum, with Config.Dedup, is the SHA-256 of the source data of a real
+	// entry. dup marks an entry whose data is that of an earlier entry
+	// with the same sum: it has no local header or data of its own and
+	// its central directory record points at the earlier one.
+	sum []byte
+	dup bool
 }
 
+type entryKind int
+
+const (
+	entryReal entryKind = iota
+	entryNoise
+	entryBait
+)
+
 type result struct {
 	index int
 	name  string
+	path  string
 	entry entry
+	took  time.Duration
 	err   error
 }
 
 type Config struct {
-	SrcDir        string
-	OutZip        string
-	Compression   string
-	Encoding      string
+	SrcDir              string
+	OutZip              string
+	Compression         string
+	Encoding            string
 	OverwriteCentralDir bool
-	CommentSize   int
-	FixedTime     bool
-	NoiseFiles    int
-	NoiseSize     int
-	Level         int
-	Strategy      string
-	DictSize      int
-	Workers       int
-	IncludeHidden bool
-	Seed          int64
-	HasSeed       bool
+	CommentSize         int
+	FixedTime           bool
+	NoiseFiles          int
+	NoiseSize           int
+	Level               int
+	Strategy            string
+	DictSize            int
+	Workers             int
+	NoiseGenerator      string
+	IncludeHidden       bool
+	Bait                bool
+	PadNames            bool
+	PadBucket           int64
+	ReportPath          string
+	ReportPassword      string
+	// Seed, when HasSeed is set, makes the random bytes of the run
+	// reproducible through SeededRandSource.
+	Seed    int64
+	HasSeed bool
+	// Resume, when set, is a file where the state of the run is saved as
+	// it goes and when it stops: the files staged, kept in Resume+".files"
+	// instead of TmpDir, and how much of OutZip is written. A later run with
+	// the same source, output and compression settings carries on from it
+	// rather than starting over, restaging only files that changed. Both
+	// are removed once the archive is written.
+	Resume string
+	// Rand, when set, is the source of the random bytes of the run, such
+	// as an HSM or a fixed stream for tests, in place of crypto/rand or
+	// the seeded stream.
+	Rand RandSource
+	// FileOrder lists slash paths relative to SrcDir that are written
+	// first, in this order. Other files follow sorted by path.
+	FileOrder []string
+	// FileMethods overrides Compression and Level for the files it lists,
+	// by slash path relative to SrcDir. Deflated files also get the
+	// matching option hint in their flags.
+	FileMethods map[string]FileMethod
+	// TmpDir is where compressed entries are staged before the ZIP is
+	// written, in a directory of the run's own that is removed when it
+	// returns; "" means the OS temp directory.
+	TmpDir string
+	// MemEntrySize and MemTotal, when both above 0, stage the entries of
+	// files of up to MemEntrySize bytes in memory, MemTotal bytes at most
+	// between them, rather than in temp files, and write them straight
+	// into the output: a tree of many small files then costs no temp file
+	// per file. Files past either limit are staged on disk as before. They
+	// do not apply with Resume, which keeps every staged entry on disk.
+	MemEntrySize int64
+	MemTotal     int64
+	// BufferSize is the size of the chunks source files are read in and
+	// of the buffer the archive is written through, so that headers,
+	// names and small entries reach it in few large writes, which network
+	// filesystems take far better than many small ones. 0 means 1 MiB.
+	BufferSize int
+	// Dedup stores the data of identical source files once: the files are
+	// hashed as they are compressed, and each file with the content of an
+	// earlier one gets only a central directory record pointing at that
+	// file's local header and data. Info-ZIP unzip rejects such an archive
+	// as overlapping, Python's zipfile refuses those entries for their
+	// local name, and recovery by scanning local headers does not see them.
+	Dedup bool
+	// Files, when non-nil, is the list of files to archive, as paths
+	// relative to SrcDir, in the order given. SrcDir is not walked and
+	// IncludeHidden does not apply; directories in the list are skipped.
+	Files []string
+	// MaxDepth bounds the walk of SrcDir: files directly in it are at
+	// depth 1, those in its subdirectories at 2, and so on. 0 means no
+	// limit. It does not apply to Files.
+	MaxDepth int
+	// BeforeEntry, when set, is called with each source file before it is
+	// compressed, one at a time in archive order. It may skip the file,
+	// rename its entry or change its timestamp; an error stops the run. A
+	// new name must be unique, relative, free of ".." and outside .junk/.
+	BeforeEntry func(info *EntryInfo) error
+	// AfterEntry, when set, is called as each source file is compressed
+	// and staged, one at a time.
+	AfterEntry func(res EntryResult)
+	// Events, when set, receives the typed progress events of an encrypt
+	// run alongside the progress and log callbacks. The run waits for each
+	// event to be received, and the last one, whether the run succeeded or
+	// not, is PhaseChanged with PhaseDone. The channel is not closed, so it
+	// may serve one run after another.
+	Events chan<- Event
+	// Logger, when set, receives the log records of the run, with levels
+	// and attributes such as the entry, its bytes and the time it took, in
+	// place of the log callback.
+	Logger *slog.Logger
+	// Metrics, when set, receives the counters and timings of the run.
+	Metrics Metrics
 }
 
+// RunEncrypt writes the files of cfg.SrcDir to cfg.OutZip among noise and
+// returns the number of entries written.
+//
+// Runs share nothing but the registries of compressors, noise generators
+// and name decoders, which are safe for concurrent use, and what cfg points
+// to: any number of RunEncrypt, RecoverZip and other runs may go at once
+// in one process, as long as they do not write the same output and the
+// Logger, Metrics and hooks they are given can take it.
 func RunEncrypt(cfg Config, progress func(done, total int, name string), log func(msg string)) (int, error) {
-	if cfg.CommentSize < 0 || cfg.CommentSize > 0xffff {
-		return 0, fmt.Errorf("comment-size must be in range 0..65535")
-	}
-	if cfg.NoiseFiles < 0 || cfg.NoiseSize < 0 {
-		return 0, fmt.Errorf("noise-files and noise-size must be >= 0")
-	}
-	if cfg.Level < 0 || cfg.Level > 9 {
-		return 0, fmt.Errorf("level must be in range 0..9")
-	}
-	if cfg.DictSize != 32768 {
-		return 0, fmt.Errorf("dict-size must be 32768 (Go stdlib deflate uses fixed 32 KB window)")
-	}
+	return RunEncryptContext(context.Background(), cfg, progress, log)
+}
 
-	comp := strings.ToLower(strings.TrimSpace(cfg.Compression))
-	if comp != "deflate" && comp != "store" {
-		return 0, fmt.Errorf("compression must be deflate or store")
-	}
-	cfg.Compression = comp
+// RunEncryptContext is RunEncrypt with cancellation. When ctx is done the
+// workers stop, the staged temp files and the partial output ZIP are
+// removed, and the error wraps ctx.Err(). With Resume they are kept for
+// the next run instead.
+func RunEncryptContext(ctx context.Context, cfg Config, progress func(done, total int, name string), log func(msg string)) (int, error) {
+	stats, err := RunEncryptStats(ctx, cfg, progress, log)
+	return stats.Entries, err
+}
 
-	strategyVal := strings.ToLower(strings.TrimSpace(cfg.Strategy))
-	switch strategyVal {
-	case "default", "filtered", "huffman", "rle", "fixed":
-	default:
-		return 0, fmt.Errorf("strategy must be one of: default, filtered, huffman, rle, fixed")
+// RunEncryptStats is RunEncryptContext returning the totals of the run:
+// bytes in and out, noise, time taken and what each worker did.
+func RunEncryptStats(ctx context.Context, cfg Config, progress func(done, total int, name string), log func(msg string)) (EncryptStats, error) {
+	return runEncrypt(ctx, cfg, nil, progress, log)
+}
+
+// RunEncryptTo is RunEncryptStats writing the archive to w instead of
+// cfg.OutZip, for buffers, sockets or uploads. The archive is written
+// front to back without seeking, so any io.Writer will do; w is not closed.
+// cfg.OutZip may be empty: when set it is only left out of the source
+// files. On failure w may hold part of an archive.
+func RunEncryptTo(ctx context.Context, w io.Writer, cfg Config, progress func(done, total int, name string), log func(msg string)) (EncryptStats, error) {
+	if w == nil {
+		eventSink{ch: cfg.Events}.end()
+		return EncryptStats{}, withKind(ErrInvalidConfig, errors.New("no writer for the archive"))
 	}
-	cfg.Strategy = strategyVal
-	if cfg.Workers < 1 {
-		cfg.Workers = 1
+	return runEncrypt(ctx, cfg, w, progress, log)
+}
+
+// runEncrypt writes the archive to w, or to cfg.OutZip when w is nil.
+func runEncrypt(ctx context.Context, cfg Config, w io.Writer, progress func(done, total int, name string), log func(msg string)) (stats EncryptStats, err error) {
+	start := time.Now()
+	events := eventSink{ctx: ctx, ch: cfg.Events}
+	defer events.end()
+	lg := runLogger(cfg.Logger, log)
+	metrics := runMetrics(cfg.Metrics)
+	warn := events.warner(lg)
+	events.send(PhaseChanged{Phase: PhaseScan})
+	if err := normalizeConfig(&cfg); err != nil {
+		return stats, withKind(ErrInvalidConfig, err)
 	}
+	strategyVal := cfg.Strategy
 
-	items, err := listFiles(cfg.SrcDir, cfg.OutZip, cfg.IncludeHidden)
+	items, err := sourceFiles(cfg)
 	if err != nil {
-		return 0, fmt.Errorf("list files: %w", err)
+		return stats, err
 	}
-	if len(items) == 0 {
-		return 0, fmt.Errorf("no files found in source directory")
+	if items, err = applyBeforeEntry(items, cfg.BeforeEntry); err != nil {
+		return stats, err
+	}
+	estimate := int64(cfg.NoiseFiles)*int64(cfg.NoiseSize) + int64(len(items)+cfg.NoiseFiles)*cfg.PadBucket
+	for _, it := range items {
+		stats.InputBytes += it.size
+	}
+	estimate += stats.InputBytes
+	if err := checkWriteSpace(cfg, estimate, w == nil); err != nil {
+		return stats, err
+	}
+	var cp *encryptCheckpoint
+	var mem *memBudget
+	if cfg.Resume != "" {
+		if w != nil {
+			return stats, withKind(ErrInvalidConfig, errors.New("resume needs an output file, not a writer"))
+		}
+		if cp, err = newEncryptCheckpoint(cfg.Resume, cfg); err != nil {
+			return stats, err
+		}
+		if cp, _, err = loadEncryptCheckpoint(cp); err != nil {
+			return stats, err
+		}
+		cfg.TmpDir = resumeDir(cfg.Resume)
+		if err := os.MkdirAll(cfg.TmpDir, 0o755); err != nil {
+			return stats, err
+		}
+	} else {
+		mem = newMemBudget(cfg)
+		if cfg.TmpDir, err = runTempDir(cfg.TmpDir); err != nil {
+			return stats, err
+		}
+		defer os.RemoveAll(cfg.TmpDir)
 	}
-	if log != nil {
-		log(fmt.Sprintf("Files found: %d", len(items)))
+	stats.Files = len(items)
+	if len(cfg.FileOrder) > 0 {
+		orderFiles(items, cfg.FileOrder)
 	}
+	lg.Info(fmt.Sprintf("Files found: %d", len(items)), "files", len(items), "bytes", stats.InputBytes)
 
-	encName, nameFlag, err := makeNameEncoder(cfg.Encoding)
+	if cp != nil && cp.Entries != nil {
+		// Staged, padded and partly written already: only the write is
+		// left.
+		results := cp.entries()
+		lg.Info(fmt.Sprintf("Resuming the write: %d of %d entries written", cp.Written, len(results)), "written", cp.Written, "entries", len(results))
+		events.send(PhaseChanged{Phase: PhaseWrite})
+		layout, err := writeZipResume(ctx, runRandSource(cfg), cfg.OutZip, results, cp, cfg.BufferSize, cfg.OverwriteCentralDir, cfg.CommentSize)
+		if err != nil {
+			return stats, fmt.Errorf("write zip: %w", err)
+		}
+		return stats, finishEncrypt(cfg, results, layout, cp, &stats, start, lg, metrics)
+	}
+
+	encName, nameFlag, err := NewNameEncoder(cfg.Encoding)
 	if err != nil {
-		return 0, fmt.Errorf("encoding: %w", err)
+		return stats, fmt.Errorf("encoding: %w", err)
 	}
 
-	useDeflate := cfg.Compression == "deflate"
-	method := uint16(0)
-	if useDeflate {
-		method = 8
+	comp, err := lookupCompressor(cfg.Compression)
+	if err != nil {
+		return stats, withKind(ErrInvalidConfig, err)
 	}
 
 	if strategyVal != "default" && strategyVal != "huffman" {
-		if log != nil {
-			log(fmt.Sprintf("Note: strategy %q is not supported by Go stdlib; ignored.", strategyVal))
-		}
+		warn(fmt.Sprintf("strategy %q is not supported by Go stdlib; ignored.", strategyVal))
 	}
 
-	randReader := io.Reader(crand.Reader)
-	if cfg.HasSeed {
-		randReader = mrand.New(mrand.NewSource(cfg.Seed))
+	randReader := runRandSource(cfg)
+	var noiseGen NoiseGenerator
+	if cfg.NoiseFiles > 0 {
+		factory, err := lookupNoiseGenerator(cfg.NoiseGenerator)
+		if err != nil {
+			return stats, err
+		}
+		noiseGen = factory(randReader)
 	}
 
 	results := make([]entry, len(items))
+	defer func() {
+		switch {
+		case err == nil:
+		case cp != nil:
+			cp.abandon(results)
+			if errors.Is(err, context.Canceled) {
+				err = fmt.Errorf("%w (run again with the same resume file to carry on)", err)
+			}
+		default:
+			removeTemps(results)
+		}
+	}()
+	// A failed file stops the other workers as Ctrl-C does.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	jobs := make(chan fileItem)
 	out := make(chan result)
 	var wg sync.WaitGroup
 
+	// Each worker writes only its own slot; they are read once out is
+	// closed.
+	events.send(PhaseChanged{Phase: PhaseCompress})
+	stats.Workers = make([]WorkerStats, cfg.Workers)
 	for i := 0; i < cfg.Workers; i++ {
 		wg.Add(1)
-		go func() {
+		go func(worker int, ws *WorkerStats) {
 			defer wg.Done()
 			for item := range jobs {
-				ent, err := compressFile(item, encName, nameFlag, method, useDeflate, cfg.Level, strategyVal, cfg.FixedTime)
-				out <- result{index: item.index, name: item.rel, entry: ent, err: err}
+				events.send(FileStarted{Name: item.name, Size: item.size, Worker: worker})
+				began := time.Now()
+				fileComp, level := comp, cfg.Level
+				fm, override := cfg.FileMethods[item.rel]
+				if override {
+					fileComp, level = compressorFor(fm.Deflate), fm.Level
+				}
+				ent, err := compressFile(ctx, cfg.TmpDir, mem, cp == nil, cfg.Dedup, cfg.BufferSize, item, encName, nameFlag, fileComp, level, strategyVal, cfg.FixedTime)
+				if override && fm.Deflate {
+					ent.flags |= levelHintFlags(level)
+				}
+				took := time.Since(began)
+				ws.Busy += took
+				if err == nil {
+					ws.Files++
+					ws.Bytes += item.size
+				}
+				out <- result{index: item.index, name: item.name, path: item.rel, entry: ent, took: took, err: err}
 			}
-		}()
+		}(i, &stats.Workers[i])
 	}
 
-	go func() {
+	// Files staged by an earlier run with the same Resume are taken as
+	// they are.
+	pending := items
+	reused := 0
+	if cp != nil {
+		pending = nil
 		for _, it := range items {
-			jobs <- it
+			if ent, ok := cp.reuse(it); ok {
+				results[it.index] = ent
+				reused++
+			} else {
+				pending = append(pending, it)
+			}
+		}
+		if reused > 0 {
+			lg.Info(fmt.Sprintf("Resuming: %d of %d files already staged", reused, len(items)), "files", reused, "total", len(items))
+		}
+	}
+
+	go func() {
+	feed:
+		for _, it := range pending {
+			select {
+			case jobs <- it:
+			case <-ctx.Done():
+				break feed
+			}
 		}
 		close(jobs)
 		wg.Wait()
@@ -164,39 +431,289 @@ func RunEncrypt(cfg Config, progress func(done, total int, name string), log fun
 	}()
 
 	total := len(items) + cfg.NoiseFiles
-	done := 0
+	done := reused
+	var compressErr error
 	for res := range out {
+		// Keep draining after a failure so every temp file is known and
+		// removed.
+		res.entry.rel = res.path
+		results[res.index] = res.entry
 		if res.err != nil {
-			return 0, fmt.Errorf("compress: %w", res.err)
+			if compressErr == nil {
+				compressErr = fmt.Errorf("compress: %w", res.err)
+			}
+			cancel()
+			continue
 		}
-		results[res.index] = res.entry
 		done++
+		if compressErr != nil {
+			continue
+		}
+		if cp != nil {
+			if err := cp.stage(items[res.index], res.entry); err != nil {
+				compressErr = fmt.Errorf("resume: %w", err)
+				cancel()
+				continue
+			}
+		}
 		if progress != nil {
 			progress(done, total, res.name)
 		}
+		if cfg.AfterEntry != nil {
+			cfg.AfterEntry(EntryResult{
+				Path:       res.path,
+				Name:       res.name,
+				Size:       int64(res.entry.usize),
+				Compressed: int64(res.entry.csize),
+				CRC:        res.entry.crc,
+				Method:     res.entry.method,
+			})
+		}
+		metrics.Add(MetricFilesCompressed, 1)
+		metrics.Add(MetricBytesIn, int64(res.entry.usize))
+		metrics.Observe(MetricCompressTime, res.took)
+		lg.Debug("Compressed "+res.name, "entry", res.name, "bytes", res.entry.usize, "compressed", res.entry.csize, "duration", res.took)
+		ev := FileDone{Name: res.name, Done: done, Total: total, Bytes: int64(res.entry.usize), Compressed: int64(res.entry.csize)}
+		if ev.Bytes > 0 {
+			ev.Ratio = float64(ev.Compressed) / float64(ev.Bytes)
+		}
+		events.send(ev)
+	}
+	if err := ctx.Err(); err != nil && compressErr == nil {
+		compressErr = fmt.Errorf("compress: %w", err)
+	}
+	if compressErr != nil {
+		return stats, compressErr
+	}
+	if cfg.Dedup {
+		if n, saved := dedupEntries(results); n > 0 {
+			lg.Info(fmt.Sprintf("Duplicates: %d files share the data of another", n), "files", n, "bytes", saved)
+		}
 	}
 
+	if cfg.NoiseFiles > 0 || cfg.Bait {
+		events.send(PhaseChanged{Phase: PhaseNoise})
+	}
+	var extra NoiseDone
 	for i := 0; i < cfg.NoiseFiles; i++ {
-		name := fmt.Sprintf(".junk/%04d_%s.bin", i, randHex(randReader, 6))
-		ent, err := makeNoiseEntry(randReader, name, encName, nameFlag, method, useDeflate, cfg.Level, strategyVal, cfg.FixedTime, cfg.NoiseSize)
+		if err := ctx.Err(); err != nil {
+			return stats, fmt.Errorf("noise: %w", err)
+		}
+		name := noiseGen.Name(i)
+		ent, err := makeNoiseEntry(cfg.TmpDir, noiseGen, name, encName, nameFlag, comp, cfg.Level, strategyVal, cfg.FixedTime, cfg.NoiseSize, time.Unix(0, 0))
 		if err != nil {
-			return 0, fmt.Errorf("noise: %w", err)
+			return stats, fmt.Errorf("noise: %w", err)
 		}
 		results = append(results, ent)
+		extra.Entries++
+		extra.Bytes += int64(ent.csize)
 		done++
 		if progress != nil {
 			progress(done, total, name)
 		}
 	}
 
-	if err := writeZip(randReader, cfg.OutZip, results, cfg.OverwriteCentralDir, cfg.CommentSize); err != nil {
-		return 0, fmt.Errorf("write zip: %w", err)
+	if cfg.Bait {
+		baits, names, err := makeBaitEntries(cfg.TmpDir, randReader, items, encName, nameFlag, comp, cfg.Level, strategyVal, cfg.FixedTime)
+		if err != nil {
+			return stats, err
+		}
+		total += len(baits)
+		for i, ent := range baits {
+			results = append(results, ent)
+			extra.Bait++
+			extra.Bytes += int64(ent.csize)
+			done++
+			if progress != nil {
+				progress(done, total, names[i])
+			}
+		}
+	}
+
+	if extra.Entries > 0 || extra.Bait > 0 {
+		events.send(extra)
+	}
+
+	events.send(PhaseChanged{Phase: PhaseWrite})
+	if err := applyPadding(randReader, cfg, results, lg, warn); err != nil {
+		return stats, err
+	}
+
+	var layout zipLayout
+	switch {
+	case w != nil:
+		layout, err = writeZipTo(ctx, randReader, w, results, cfg.BufferSize, cfg.OverwriteCentralDir, cfg.CommentSize)
+	case cp != nil:
+		if err := cp.startWrite(results); err != nil {
+			return stats, fmt.Errorf("resume: %w", err)
+		}
+		layout, err = writeZipResume(ctx, randReader, cfg.OutZip, results, cp, cfg.BufferSize, cfg.OverwriteCentralDir, cfg.CommentSize)
+	default:
+		layout, err = writeZip(ctx, randReader, cfg.OutZip, results, cfg.Workers, cfg.BufferSize, cfg.OverwriteCentralDir, cfg.CommentSize)
 	}
+	if err != nil {
+		return stats, fmt.Errorf("write zip: %w", err)
+	}
+	return stats, finishEncrypt(cfg, results, layout, cp, &stats, start, lg, metrics)
+}
 
-	return len(results), nil
+// finishEncrypt writes the report of a run whose archive is written, drops
+// its resume state and totals it into stats.
+func finishEncrypt(cfg Config, results []entry, layout zipLayout, cp *encryptCheckpoint, stats *EncryptStats, start time.Time, lg *slog.Logger, metrics Metrics) error {
+	if cfg.ReportPath != "" {
+		if err := writeReport(cfg.ReportPath, cfg.ReportPassword, buildReport(cfg, results, layout)); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+		lg.Info(fmt.Sprintf("Report: %s", cfg.ReportPath), "path", cfg.ReportPath)
+	}
+	if cp != nil {
+		cp.remove()
+	}
+	stats.tally(results, layout, start)
+	stats.record(metrics)
+	lg.Debug("Archive written", "entries", stats.Entries, "bytes", stats.OutputBytes, "duration", stats.Duration)
+	return nil
 }
 
-func listFiles(srcDir, outZip string, includeHidden bool) ([]fileItem, error) {
+// sourceFiles lists the files cfg archives: cfg.Files when set, otherwise
+// the walk of cfg.SrcDir.
+func sourceFiles(cfg Config) ([]fileItem, error) {
+	var items []fileItem
+	var err error
+	if cfg.Files != nil {
+		items, err = namedFiles(cfg.SrcDir, cfg.OutZip, cfg.Files)
+	} else {
+		items, err = listFiles(cfg.SrcDir, cfg.OutZip, cfg.IncludeHidden, cfg.MaxDepth)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, ErrNoFiles
+	}
+	return items, nil
+}
+
+// ValidateConfig checks the values of cfg as an encrypt run would before
+// starting, and reports every problem at once, joined, rather than the
+// first: a frontend can check a whole form before a run. Each problem
+// matches ErrInvalidConfig. Nothing is read from or written to disk, so
+// paths are not checked.
+func ValidateConfig(cfg Config) error {
+	var errs []error
+	bad := func(format string, args ...any) {
+		errs = append(errs, withKind(ErrInvalidConfig, fmt.Errorf(format, args...)))
+	}
+	if cfg.CommentSize < 0 || cfg.CommentSize > 0xffff {
+		bad("comment-size must be in range 0..65535")
+	}
+	if cfg.NoiseFiles < 0 {
+		bad("noise-files must be >= 0")
+	}
+	if cfg.NoiseSize < 0 {
+		bad("noise-size must be >= 0")
+	}
+	if cfg.Level < 0 || cfg.Level > 9 {
+		bad("level must be in range 0..9")
+	}
+	if cfg.DictSize != 32768 {
+		bad("dict-size must be 32768 (Go stdlib deflate uses fixed 32 KB window)")
+	}
+	if _, err := lookupCompressor(cfg.Compression); err != nil {
+		bad("%v", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Strategy)) {
+	case "default", "filtered", "huffman", "rle", "fixed":
+	default:
+		bad("strategy must be one of: default, filtered, huffman, rle, fixed")
+	}
+	if _, _, err := NewNameEncoder(cfg.Encoding); err != nil {
+		bad("encoding: %v", err)
+	}
+	if cfg.NoiseFiles > 0 {
+		if _, err := lookupNoiseGenerator(cfg.NoiseGenerator); err != nil {
+			bad("%v", err)
+		}
+	}
+	if cfg.Workers < 0 {
+		bad("workers must be >= 1, or 0 for one")
+	}
+	if cfg.PadBucket < 0 {
+		bad("pad-bucket must be >= 0")
+	}
+	if cfg.MaxDepth < 0 {
+		bad("max-depth must be >= 0")
+	}
+	if cfg.MemEntrySize < 0 || cfg.MemTotal < 0 {
+		bad("mem-entry-size and mem-total must be >= 0")
+	}
+	if cfg.BufferSize < 0 {
+		bad("buffer-size must be >= 0")
+	}
+	return errors.Join(errs...)
+}
+
+func normalizeConfig(cfg *Config) error {
+	if err := ValidateConfig(*cfg); err != nil {
+		return err
+	}
+	cfg.Compression = strings.ToLower(strings.TrimSpace(cfg.Compression))
+	cfg.Strategy = strings.ToLower(strings.TrimSpace(cfg.Strategy))
+	// Unset, it is one worker; the CLI maps auto to one per CPU itself.
+	if cfg.Workers == 0 {
+		cfg.Workers = 1
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = chunkSize
+	}
+	return nil
+}
+
+// applyPadding pads the streams and names of results as cfg asks, logging
+// what it did and warning about what it could not do.
+func applyPadding(randReader io.Reader, cfg Config, results []entry, lg *slog.Logger, warn func(msg string)) error {
+	if cfg.PadBucket > 0 {
+		if cfg.Compression != "deflate" {
+			warn("pad-bucket needs deflate compression; stored entries are not padded.")
+		} else {
+			padded, err := padStreams(randReader, results, cfg.PadBucket)
+			if err != nil {
+				return fmt.Errorf("pad streams: %w", err)
+			}
+			lg.Info(fmt.Sprintf("Padded streams: %d (bucket %d bytes)", padded, cfg.PadBucket), "entries", padded, "bucket", cfg.PadBucket)
+		}
+	}
+	if cfg.PadNames {
+		padNames(results)
+	}
+	return nil
+}
+
+// orderFiles moves the files named in order to the front, in that order,
+// keeping the rest sorted by path.
+func orderFiles(files []fileItem, order []string) {
+	rank := make(map[string]int, len(order))
+	for i, rel := range order {
+		if _, dup := rank[rel]; !dup {
+			rank[rel] = i
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		ri, iok := rank[files[i].rel]
+		rj, jok := rank[files[j].rel]
+		if iok && jok {
+			return ri < rj
+		}
+		return iok && !jok
+	})
+	for i := range files {
+		files[i].index = i
+	}
+}
+
+// listFiles walks srcDir for the files to archive, sorted by path. With
+// maxDepth > 0 directories deeper than that are not entered.
+func listFiles(srcDir, outZip string, includeHidden bool, maxDepth int) ([]fileItem, error) {
 	srcAbs, err := filepath.Abs(srcDir)
 	if err != nil {
 		return nil, err
@@ -224,6 +741,9 @@ func listFiles(srcDir, outZip string, includeHidden bool) ([]fileItem, error) {
 			}
 		}
 		if d.IsDir() {
+			if maxDepth > 0 && pathDepth(srcAbs, path) >= maxDepth {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		abs, _ := filepath.Abs(path)
@@ -243,6 +763,8 @@ func listFiles(srcDir, outZip string, includeHidden bool) ([]fileItem, error) {
 			index:   len(files),
 			path:    path,
 			rel:     rel,
+			name:    rel,
+			size:    info.Size(),
 			modTime: info.ModTime(),
 		})
 		return nil
@@ -260,220 +782,539 @@ func listFiles(srcDir, outZip string, includeHidden bool) ([]fileItem, error) {
 	return files, nil
 }
 
+// pathDepth is the number of elements of path below root.
+func pathDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// namedFiles resolves names, relative to srcDir, into the files to archive.
+// Names that leave srcDir are an error; directories, repeats and the output
+// ZIP itself are dropped.
+func namedFiles(srcDir, outZip string, names []string) ([]fileItem, error) {
+	srcAbs, err := filepath.Abs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	outAbs, _ := filepath.Abs(outZip)
+
+	var files []fileItem
+	seen := map[string]bool{}
+	for _, name := range names {
+		rel := filepath.Clean(filepath.FromSlash(name))
+		if filepath.IsAbs(rel) || !filepath.IsLocal(rel) {
+			return nil, withKind(ErrInvalidConfig, fmt.Errorf("%q is not inside the source directory", name))
+		}
+		path := filepath.Join(srcAbs, rel)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() || path == outAbs {
+			continue
+		}
+		slash := filepath.ToSlash(rel)
+		if seen[slash] {
+			continue
+		}
+		seen[slash] = true
+		files = append(files, fileItem{
+			index:   len(files),
+			path:    path,
+			rel:     slash,
+			name:    slash,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+// compressFile stages the file of item, compressed, in memory when mem has
+// room for it and in a temp file under tmpDir otherwise, reading it in
+// chunks of bufSize bytes. A file stored rather than compressed is, with
+// fromSource, not staged at all: only its CRC is taken, and it is copied
+// from the source as the archive is written. With dedup the entry gets the
+// sum of the data read.
 func compressFile(
+	ctx context.Context,
+	tmpDir string,
+	mem *memBudget,
+	fromSource bool,
+	dedup bool,
+	bufSize int,
 	item fileItem,
 	encName func(string) ([]byte, error),
 	nameFlag uint16,
-	method uint16,
-	useDeflate bool,
+	comp Compressor,
 	level int,
 	strategy string,
 	fixedTime bool,
 ) (entry, error) {
-	nameBytes, err := encName(item.rel)
+	f, err := os.Open(item.path)
 	if err != nil {
-		return entry{}, fmt.Errorf("encode name %q: %w", item.rel, err)
+		return entry{}, err
 	}
-	dosT, dosD := dosTimeDate(item.modTime, fixedTime)
-	tmp, err := os.CreateTemp("", "enczip_*")
+	defer f.Close()
+	var r io.Reader = f
+	var sum hash.Hash
+	if dedup {
+		sum = sha256.New()
+		r = io.TeeReader(f, sum)
+	}
+	var ent entry
+	switch {
+	case mem.take(item.size):
+		ent, err = compressReader(ctx, "", bufSize, item.name, item.modTime, r, encName, nameFlag, comp, level, strategy, fixedTime)
+		mem.settle(item.size, int64(len(ent.data)))
+	case isStore(comp) && fromSource:
+		ent, err = storeFromSource(ctx, item, f, r, bufSize, encName, nameFlag, fixedTime)
+	default:
+		ent, err = compressReader(ctx, tmpDir, bufSize, item.name, item.modTime, r, encName, nameFlag, comp, level, strategy, fixedTime)
+	}
+	if err == nil && sum != nil {
+		ent.sum = sum.Sum(nil)
+	}
+	return ent, err
+}
+
+func isStore(comp Compressor) bool {
+	_, ok := comp.(storeCompressor)
+	return ok
+}
+
+// storeFromSource makes the stored entry of item, whose file f is read once
+// through r for its CRC; copyStaged copies it into the archive.
+func storeFromSource(ctx context.Context, item fileItem, f *os.File, r io.Reader, bufSize int, encName func(string) ([]byte, error), nameFlag uint16, fixedTime bool) (entry, error) {
+	nameBytes, err := encName(item.name)
+	if err != nil {
+		return entry{}, fmt.Errorf("encode name %q: %w", item.name, err)
+	}
+	fi, err := f.Stat()
 	if err != nil {
 		return entry{}, err
 	}
-	defer tmp.Close()
-
-	src, err := os.Open(item.path)
+	h := crc32.NewIEEE()
+	buf := getChunk(bufSize)
+	defer putChunk(buf)
+	n, err := io.CopyBuffer(h, ctxReader{ctx: ctx, r: r}, *buf)
 	if err != nil {
 		return entry{}, err
 	}
-	defer src.Close()
+	if n != fi.Size() {
+		return entry{}, fmt.Errorf("%s changed while it was read", item.path)
+	}
+	dosT, dosD := DOSTimeDate(item.modTime, fixedTime)
+	return entry{
+		name:   nameBytes,
+		flags:  nameFlag,
+		method: zipMethodStore,
+		dosT:   dosT,
+		dosD:   dosD,
+		crc:    h.Sum32(),
+		csize:  uint32(n),
+		usize:  uint32(n),
+		src:    item.path,
+		srcMod: fi.ModTime(),
+		label:  item.name,
+	}, nil
+}
 
-	var crc uint32
-	var usize uint32
-	var csize uint32
+// compressReader stages the data of r, read in chunks of bufSize bytes and
+// compressed, as the entry name: in a temp file under tmpDir, or in memory
+// when tmpDir is "".
+func compressReader(
+	ctx context.Context,
+	tmpDir string,
+	bufSize int,
+	name string,
+	modTime time.Time,
+	r io.Reader,
+	encName func(string) ([]byte, error),
+	nameFlag uint16,
+	comp Compressor,
+	level int,
+	strategy string,
+	fixedTime bool,
+) (entry, error) {
+	nameBytes, err := encName(name)
+	if err != nil {
+		return entry{}, fmt.Errorf("encode name %q: %w", name, err)
+	}
+	dosT, dosD := DOSTimeDate(modTime, fixedTime)
+	ent := entry{
+		name:   nameBytes,
+		flags:  nameFlag,
+		method: comp.Method(),
+		dosT:   dosT,
+		dosD:   dosD,
+		label:  name,
+	}
+	src := ctxReader{ctx: ctx, r: r}
+	fill := func(w io.Writer) error {
+		buf := getChunk(bufSize)
+		defer putChunk(buf)
+		_, err := io.CopyBuffer(w, src, *buf)
+		return err
+	}
 
-	if useDeflate {
-		counter := &countingWriter{w: tmp}
-		levelVal := level
-		if strategy == "huffman" {
-			levelVal = flate.HuffmanOnly
-		}
-		w, err := flate.NewWriter(counter, levelVal)
+	if tmpDir == "" {
+		var buf bytes.Buffer
+		crc, usize, csize, err := compressTo(&buf, comp, level, strategy, fill)
 		if err != nil {
 			return entry{}, err
 		}
-		crc, usize, err = copyDeflateWithCRC(w, src)
-		if err != nil {
-			w.Close()
-			return entry{}, err
+		ent.crc, ent.usize, ent.csize = crc, usize, uint32(csize)
+		// Non-nil data is what marks an entry staged in memory, so an
+		// empty stored file gets an empty slice rather than nil.
+		if ent.data = buf.Bytes(); ent.data == nil {
+			ent.data = []byte{}
 		}
-		if err := w.Close(); err != nil {
-			return entry{}, err
-		}
-		csize = uint32(counter.n)
-	} else {
-		crc, usize, err = copyStoreWithCRC(tmp, src)
-		if err != nil {
-			return entry{}, err
+		return ent, nil
+	}
+
+	tmp, err := os.CreateTemp(tmpDir, "enczip_*")
+	if err != nil {
+		return entry{}, err
+	}
+	ok := false
+	defer func() {
+		tmp.Close()
+		if !ok {
+			os.Remove(tmp.Name())
 		}
-		csize = usize
+	}()
+
+	crc, usize, csize, err := compressTo(tmp, comp, level, strategy, fill)
+	if err != nil {
+		return entry{}, err
 	}
 
-	return entry{
-		name:   nameBytes,
-		flags:  nameFlag,
-		method: method,
-		dosT:   dosT,
-		dosD:   dosD,
-		crc:    crc,
-		csize:  csize,
-		usize:  usize,
-		tmp:    tmp.Name(),
-	}, nil
+	ok = true
+	ent.crc, ent.usize, ent.csize = crc, usize, uint32(csize)
+	ent.tmp = tmp.Name()
+	return ent, nil
 }
 
 func makeNoiseEntry(
-	randReader io.Reader,
+	tmpDir string,
+	gen NoiseGenerator,
 	name string,
 	encName func(string) ([]byte, error),
 	nameFlag uint16,
-	method uint16,
-	useDeflate bool,
+	comp Compressor,
 	level int,
 	strategy string,
 	fixedTime bool,
 	size int,
+	modTime time.Time,
 ) (entry, error) {
 	nameBytes, err := encName(name)
 	if err != nil {
 		return entry{}, err
 	}
-	dosT, dosD := dosTimeDate(time.Unix(0, 0), fixedTime)
-	tmp, err := os.CreateTemp("", "enczip_noise_*")
+	dosT, dosD := DOSTimeDate(modTime, fixedTime)
+	tmp, err := os.CreateTemp(tmpDir, "enczip_noise_*")
 	if err != nil {
 		return entry{}, err
 	}
-	defer tmp.Close()
-
-	var crc uint32
-	var usize uint32
-	var csize uint32
-
-	if useDeflate {
-		counter := &countingWriter{w: tmp}
-		levelVal := level
-		if strategy == "huffman" {
-			levelVal = flate.HuffmanOnly
-		}
-		w, err := flate.NewWriter(counter, levelVal)
-		if err != nil {
-			return entry{}, err
-		}
-		crc, usize, err = writeRandomWithCRC(randReader, w, size)
-		if err != nil {
-			w.Close()
-			return entry{}, err
-		}
-		if err := w.Close(); err != nil {
-			return entry{}, err
-		}
-		csize = uint32(counter.n)
-	} else {
-		crc, usize, err = writeRandomWithCRC(randReader, tmp, size)
-		if err != nil {
-			return entry{}, err
+	ok := false
+	defer func() {
+		tmp.Close()
+		if !ok {
+			os.Remove(tmp.Name())
 		}
-		csize = usize
+	}()
+
+	crc, usize, csize, err := compressTo(tmp, comp, level, strategy, func(w io.Writer) error {
+		return gen.Content(w, size)
+	})
+	if err != nil {
+		return entry{}, err
 	}
 
+	ok = true
 	return entry{
 		name:   nameBytes,
 		flags:  nameFlag,
-		method: method,
+		method: comp.Method(),
 		dosT:   dosT,
 		dosD:   dosD,
 		crc:    crc,
-		csize:  csize,
+		csize:  uint32(csize),
 		usize:  usize,
 		tmp:    tmp.Name(),
+		label:  name,
+		kind:   entryNoise,
 	}, nil
 }
 
-func writeZip(randReader io.Reader, outZip string, entries []entry, overwriteCentralDir bool, commentSize int) error {
+type zipLayout struct {
+	cdStart       int64
+	cdSize        int64
+	eocdOffset    int64
+	commentOffset int64
+	poisonOffset  int64
+	poisonSize    int64
+	fakeEOCD      int64
+	size          int64
+}
+
+// writeZip writes entries to outZip, their data by workers goroutines at
+// once when there are more than one, through buffers of bufSize bytes. On
+// failure, cancellation included, the partial file is removed; the temp
+// files are the caller's to clean up.
+func writeZip(ctx context.Context, randReader io.Reader, outZip string, entries []entry, workers, bufSize int, overwriteCentralDir bool, commentSize int) (_ zipLayout, err error) {
+	var layout zipLayout
 	if err := os.MkdirAll(filepath.Dir(outZip), 0o755); err != nil {
-		return err
+		return layout, err
+	}
+	f, err := os.Create(outZip)
+	if err != nil {
+		return layout, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(outZip)
+		}
+	}()
+	if workers <= 1 || len(entries) < 2 {
+		return writeZipTo(ctx, randReader, f, entries, bufSize, overwriteCentralDir, commentSize)
 	}
-	out, err := os.Create(outZip)
+	end, err := writeEntriesAt(ctx, f, entries, workers, bufSize, overwriteCentralDir)
 	if err != nil {
+		return layout, err
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		return layout, err
+	}
+	return writeZipFrom(ctx, randReader, f, entries, len(entries), end, bufSize, overwriteCentralDir, commentSize, nil)
+}
+
+// writeEntriesAt lays entries out from the start of f, each at the offset
+// the sizes of those before it give, and has workers goroutines write them
+// there at once with WriteAt, each through a buffer of bufSize bytes, so
+// the data of one entry is copied while another is read. It returns where
+// the last entry ends. The bytes are those writeZipFrom would write.
+func writeEntriesAt(ctx context.Context, f *os.File, entries []entry, workers, bufSize int, overwriteCentralDir bool) (int64, error) {
+	end := layoutEntries(entries, overwriteCentralDir)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		writeErr error
+	)
+	jobs := make(chan *entry)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bw := bufio.NewWriterSize(nil, bufSize)
+			for ent := range jobs {
+				if err := writeEntryAt(f, bw, ent, overwriteCentralDir); err != nil {
+					errOnce.Do(func() {
+						writeErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := range entries {
+		if entries[i].dup {
+			continue
+		}
+		select {
+		case jobs <- &entries[i]:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if writeErr != nil {
+		return 0, writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return end, nil
+}
+
+// layoutEntries sets the flags and offsets entries get written one after
+// another from the start of the archive, duplicates taking the place of
+// the entry they share data with, and returns where the last one ends.
+func layoutEntries(entries []entry, overwriteCentralDir bool) int64 {
+	var flags uint16
+	var descSize int64
+	if overwriteCentralDir {
+		flags, descSize = flagDataDesc, 16
+	}
+	targets := dupTargets(entries)
+	var end int64
+	for i := range entries {
+		ent := &entries[i]
+		if ent.dup {
+			ent.linkTo(&entries[targets[string(ent.sum)]])
+			continue
+		}
+		ent.flags |= flags
+		ent.offset = uint32(end)
+		end += 30 + int64(len(ent.name)) + int64(ent.csize) + descSize
+	}
+	return end
+}
+
+// writeEntryAt writes the local header, name, data and, with
+// overwriteCentralDir, data descriptor of ent at its offset in f, through
+// bw.
+func writeEntryAt(f *os.File, bw *bufio.Writer, ent *entry, overwriteCentralDir bool) error {
+	bw.Reset(io.NewOffsetWriter(f, int64(ent.offset)))
+	w := bw
+	csize, usize := ent.csize, ent.usize
+	if overwriteCentralDir {
+		csize, usize = 0, 0
+	}
+	if err := writeLocalHeader(w, ent, ent.crc, csize, usize); err != nil {
 		return err
 	}
-	defer out.Close()
+	if _, err := w.Write(ent.name); err != nil {
+		return err
+	}
+	if err := copyStaged(w, ent); err != nil {
+		return err
+	}
+	if overwriteCentralDir {
+		if err := writeDataDesc(w, ent); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
 
+// writeZipTo writes entries to w in one pass, through a buffer of bufSize
+// bytes, then removes their temp files.
+func writeZipTo(ctx context.Context, randReader io.Reader, w io.Writer, entries []entry, bufSize int, overwriteCentralDir bool, commentSize int) (zipLayout, error) {
+	return writeZipFrom(ctx, randReader, w, entries, 0, 0, bufSize, overwriteCentralDir, commentSize, nil)
+}
+
+// writeZipFrom is writeZipTo carrying on after the first start entries,
+// which end at offset in the output and whose offsets are set. wrote, when
+// set, is called after each entry with the number written and the offset
+// reached, all of it flushed to w.
+func writeZipFrom(ctx context.Context, randReader io.Reader, w io.Writer, entries []entry, start int, offset int64, bufSize int, overwriteCentralDir bool, commentSize int, wrote func(done int, offset int64) error) (zipLayout, error) {
+	var layout zipLayout
+	bw := bufio.NewWriterSize(w, bufSize)
+	out := &countingWriter{w: bw, n: offset}
 	flags := uint16(0)
 	if overwriteCentralDir {
 		flags |= flagDataDesc
 	}
+	if start > 0 {
+		// Those written before get back the flags and offsets they were
+		// written with, for the central directory.
+		layoutEntries(entries[:start], overwriteCentralDir)
+	}
+	targets := dupTargets(entries)
 
-	for i := range entries {
+	for i := start; i < len(entries); i++ {
+		if err := ctx.Err(); err != nil {
+			return layout, err
+		}
 		ent := &entries[i]
+		if ent.dup {
+			// Nothing is written for it: a resumed run that starts here
+			// only links it again.
+			ent.linkTo(&entries[targets[string(ent.sum)]])
+			continue
+		}
 		ent.flags |= flags
 
-		offset, _ := out.Seek(0, io.SeekCurrent)
-		ent.offset = uint32(offset)
+		ent.offset = uint32(out.n)
 
+		// The sizes are left to the data descriptor; the CRC, known from
+		// staging, goes in the header as well.
 		if overwriteCentralDir {
-			if err := writeLocalHeader(out, ent, 0, 0, 0); err != nil {
-				return err
+			if err := writeLocalHeader(out, ent, ent.crc, 0, 0); err != nil {
+				return layout, err
 			}
 		} else {
 			if err := writeLocalHeader(out, ent, ent.crc, ent.csize, ent.usize); err != nil {
-				return err
+				return layout, err
 			}
 		}
 		if _, err := out.Write(ent.name); err != nil {
-			return err
+			return layout, err
 		}
-		if err := copyTemp(out, ent.tmp); err != nil {
-			return err
+		if err := copyStaged(out, ent); err != nil {
+			return layout, err
 		}
 		if overwriteCentralDir {
-			if err := patchCRC(out, int64(ent.offset), ent.crc); err != nil {
-				return err
-			}
 			if err := writeDataDesc(out, ent); err != nil {
-				return err
+				return layout, err
+			}
+		}
+		if wrote != nil {
+			if err := bw.Flush(); err != nil {
+				return layout, err
+			}
+			if err := wrote(i+1, out.n); err != nil {
+				return layout, err
 			}
 		}
 	}
 
-	cdStart, _ := out.Seek(0, io.SeekCurrent)
+	cdStart := out.n
 	for _, ent := range entries {
 		if err := writeCDir(out, ent); err != nil {
-			return err
+			return layout, err
 		}
 		if _, err := out.Write(ent.name); err != nil {
-			return err
+			return layout, err
 		}
 	}
-	cdEnd, _ := out.Seek(0, io.SeekCurrent)
+	cdEnd := out.n
 	cdSize := cdEnd - cdStart
+	layout.cdStart = cdStart
+	layout.cdSize = cdSize
+	layout.eocdOffset = cdEnd
 	if err := writeEOCD(out, len(entries), cdSize, cdStart, commentSize); err != nil {
-		return err
+		return layout, err
 	}
+	layout.commentOffset = cdEnd + 22
 	if commentSize > 0 {
 		if err := writeRand(randReader, out, commentSize); err != nil {
-			return err
+			return layout, err
 		}
 	}
 	if overwriteCentralDir {
+		layout.poisonOffset = out.n
 		if err := writePoisonTail(randReader, out); err != nil {
-			return err
+			return layout, err
 		}
+		layout.fakeEOCD = layout.poisonOffset + 32
+	}
+	if err := bw.Flush(); err != nil {
+		return layout, err
+	}
+	layout.size = out.n
+	if overwriteCentralDir {
+		layout.poisonSize = layout.size - layout.poisonOffset
 	}
 
 	for _, ent := range entries {
 		_ = os.Remove(ent.tmp)
 	}
-	return nil
+	return layout, nil
 }
 
 func writeLocalHeader(w io.Writer, ent *entry, crc, csize, usize uint32) error {
@@ -540,23 +1381,6 @@ func writeDataDesc(w io.Writer, ent *entry) error {
 	return err
 }
 
-func patchCRC(f *os.File, off int64, crc uint32) error {
-	cur, err := f.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return err
-	}
-	if _, err := f.Seek(off+14, io.SeekStart); err != nil {
-		return err
-	}
-	buf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(buf, crc)
-	if _, err := f.Write(buf); err != nil {
-		return err
-	}
-	_, err = f.Seek(cur, io.SeekStart)
-	return err
-}
-
 func writePoisonTail(randReader io.Reader, w io.Writer) error {
 	if err := writeRand(randReader, w, 32); err != nil {
 		return err
@@ -576,113 +1400,83 @@ func writePoisonTail(randReader io.Reader, w io.Writer) error {
 	return writeRand(randReader, w, 96)
 }
 
-func copyTemp(out *os.File, tmpPath string) error {
-	tmp, err := os.Open(tmpPath)
+// copyStaged copies the staged stream of ent to out, from memory or its
+// temp file.
+func copyStaged(out io.Writer, ent *entry) error {
+	if ent.data != nil {
+		_, err := out.Write(ent.data)
+		return err
+	}
+	path := ent.tmp
+	if ent.src != "" {
+		path = ent.src
+	}
+	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
-	defer tmp.Close()
-	_, err = io.CopyBuffer(out, tmp, make([]byte, chunkSize))
-	return err
-}
-
-func copyDeflateWithCRC(w io.Writer, r io.Reader) (uint32, uint32, error) {
-	hash := crc32.NewIEEE()
-	var usize uint32
-	buf := make([]byte, chunkSize)
-	for {
-		n, err := r.Read(buf)
-		if n > 0 {
-			usize += uint32(n)
-			if _, err := hash.Write(buf[:n]); err != nil {
-				return 0, 0, err
-			}
-			if _, err := w.Write(buf[:n]); err != nil {
-				return 0, 0, err
-			}
-		}
-		if err == io.EOF {
-			break
-		}
+	defer f.Close()
+	if ent.src != "" {
+		// The CRC in the headers is of the file as it was read then.
+		fi, err := f.Stat()
 		if err != nil {
-			return 0, 0, err
-		}
-	}
-	return hash.Sum32(), usize, nil
-}
-
-func copyStoreWithCRC(w io.Writer, r io.Reader) (uint32, uint32, error) {
-	hash := crc32.NewIEEE()
-	var usize uint32
-	buf := make([]byte, chunkSize)
-	for {
-		n, err := r.Read(buf)
-		if n > 0 {
-			usize += uint32(n)
-			if _, err := hash.Write(buf[:n]); err != nil {
-				return 0, 0, err
-			}
-			if _, err := w.Write(buf[:n]); err != nil {
-				return 0, 0, err
-			}
-		}
-		if err == io.EOF {
-			break
+			return err
 		}
-		if err != nil {
-			return 0, 0, err
+		if fi.Size() != int64(ent.csize) || !fi.ModTime().Equal(ent.srcMod) {
+			return fmt.Errorf("%s changed while it was archived", ent.src)
 		}
 	}
-	return hash.Sum32(), usize, nil
+	return copyFile(out, f, int64(ent.csize))
 }
 
-func writeRandomWithCRC(randReader io.Reader, w io.Writer, size int) (uint32, uint32, error) {
-	hash := crc32.NewIEEE()
-	var usize uint32
-	buf := make([]byte, chunkSize)
-	remaining := size
-	for remaining > 0 {
-		n := chunkSize
-		if remaining < n {
-			n = remaining
-		}
-		if _, err := randReader.Read(buf[:n]); err != nil {
-			return 0, 0, err
-		}
-		usize += uint32(n)
-		if _, err := hash.Write(buf[:n]); err != nil {
-			return 0, 0, err
-		}
-		if _, err := w.Write(buf[:n]); err != nil {
-			return 0, 0, err
-		}
-		remaining -= n
+// copyFile copies the first size bytes of f to out. An out that is an
+// io.ReaderFrom, such as the output file through countingWriter, gets f
+// whole, so the system copies it (copy_file_range, sendfile) where it can
+// rather than through a buffer.
+func copyFile(out io.Writer, f *os.File, size int64) error {
+	src := io.LimitReader(f, size)
+	var n int64
+	var err error
+	if _, ok := out.(io.ReaderFrom); ok {
+		n, err = io.Copy(out, src)
+	} else {
+		buf := getChunk(0)
+		defer putChunk(buf)
+		n, err = io.CopyBuffer(out, src, *buf)
 	}
-	return hash.Sum32(), usize, nil
+	if err == nil && n != size {
+		err = fmt.Errorf("%s: %w", f.Name(), io.ErrUnexpectedEOF)
+	}
+	return err
 }
 
 func writeRand(randReader io.Reader, w io.Writer, size int) error {
 	buf := make([]byte, size)
-	if _, err := randReader.Read(buf); err != nil {
+	if _, err := io.ReadFull(randReader, buf); err != nil {
 		return err
 	}
 	_, err := w.Write(buf)
 	return err
 }
 
-func randHex(randReader io.Reader, n int) string {
+func randHex(randReader io.Reader, n int) (string, error) {
 	buf := make([]byte, n)
-	_, _ = randReader.Read(buf)
+	if _, err := io.ReadFull(randReader, buf); err != nil {
+		return "", err
+	}
 	const hexd = "0123456789abcdef"
 	out := make([]byte, n*2)
 	for i, b := range buf {
 		out[i*2] = hexd[b>>4]
 		out[i*2+1] = hexd[b&0x0f]
 	}
-	return string(out)
+	return string(out), nil
 }
 
-func dosTimeDate(t time.Time, fixed bool) (uint16, uint16) {
+// DOSTimeDate returns t as the MS-DOS time and date fields of a ZIP header,
+// in local time at two-second precision. Times before 1980, which the format
+// cannot hold, and every time when fixed is set, become 1980-01-01 00:00.
+func DOSTimeDate(t time.Time, fixed bool) (uint16, uint16) {
 	if fixed || t.Year() < 1980 {
 		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.Local)
 	}
@@ -691,6 +1485,18 @@ func dosTimeDate(t time.Time, fixed bool) (uint16, uint16) {
 	return dosTime, dosDate
 }
 
+// DOSToTime is the inverse of DOSTimeDate, in local time. ok is false for
+// a zero or out-of-range date.
+func DOSToTime(dosTime, dosDate uint16) (time.Time, bool) {
+	day := int(dosDate & 0x1f)
+	month := int(dosDate >> 5 & 0x0f)
+	if day == 0 || month == 0 || month > 12 {
+		return time.Time{}, false
+	}
+	year := 1980 + int(dosDate>>9)
+	return time.Date(year, time.Month(month), day, int(dosTime>>11), int(dosTime>>5&0x3f), int(dosTime&0x1f)*2, 0, time.Local), true
+}
+
 type countingWriter struct {
 	w io.Writer
 	n int64
@@ -702,24 +1508,77 @@ func (c *countingWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
-type nameEncoder func(string) ([]byte, error)
+// ReadFrom passes r on to w when w is an io.ReaderFrom, so io.Copy keeps
+// the zero-copy paths of an *os.File or a socket behind the count.
+func (c *countingWriter) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if rf, ok := c.w.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		buf := getChunk(0)
+		defer putChunk(buf)
+		n, err = io.CopyBuffer(struct{ io.Writer }{c.w}, r, *buf)
+	}
+	c.n += n
+	return n, err
+}
 
-func makeNameEncoder(enc string) (nameEncoder, uint16, error) {
-	switch strings.ToLower(strings.TrimSpace(enc)) {
-	case "utf-8", "utf8":
-		return func(s string) ([]byte, error) {
-			return []byte(s), nil
-		}, flagUTF8, nil
-	case "cp1251":
-		return func(s string) ([]byte, error) {
-			return encodeCP1251(s)
-		}, 0, nil
-	default:
-		return nil, 0, fmt.Errorf("unsupported encoding %q", enc)
+// NameEncoder turns a slash-separated entry name into the raw bytes of a
+// header, failing on a rune its charset lacks.
+type NameEncoder func(string) ([]byte, error)
+
+// NewNameEncoder returns the encoder of one of NameEncodings and the general
+// purpose flag bits its names need: the UTF-8 flag, 0x800, for utf-8 and
+// none for cp1251.
+func NewNameEncoder(enc string) (NameEncoder, uint16, error) {
+	key := strings.ToLower(strings.TrimSpace(enc))
+	for _, ne := range nameEncodings {
+		if slices.Contains(ne.names, key) {
+			return ne.encode, ne.flags, nil
+		}
 	}
+	return nil, 0, fmt.Errorf("unsupported encoding %q", enc)
+}
+
+// nameEncodings are the encodings NewNameEncoder knows, in the order
+// NameEncodings lists them: the first of names is the one listed, the
+// rest other spellings it accepts.
+var nameEncodings = []struct {
+	names  []string
+	encode NameEncoder
+	flags  uint16
+}{
+	{[]string{"utf-8", "utf8"}, func(s string) ([]byte, error) { return []byte(s), nil }, flagUTF8},
+	{[]string{"cp1251"}, EncodeCP1251, 0},
 }
 
-func encodeCP1251(s string) ([]byte, error) {
+// NameEncodings returns the values Config.Encoding accepts.
+func NameEncodings() []string {
+	names := make([]string, len(nameEncodings))
+	for i, ne := range nameEncodings {
+		names[i] = ne.names[0]
+	}
+	return names
+}
+
+// EncodeName returns name as RunEncrypt stores it with encoding, and whether
+// the entry gets the UTF-8 flag.
+func EncodeName(encoding, name string) ([]byte, bool, error) {
+	enc, flags, err := NewNameEncoder(encoding)
+	if err != nil {
+		return nil, false, err
+	}
+	raw, err := enc(name)
+	if err != nil {
+		return nil, false, err
+	}
+	return raw, flags&flagUTF8 != 0, nil
+}
+
+// EncodeCP1251 returns s in Windows-1251, as names are stored with the
+// cp1251 encoding.
+func EncodeCP1251(s string) ([]byte, error) {
 	var out []byte
 	for _, r := range s {
 		if r < 0x80 {
@@ -754,6 +1613,30 @@ var cp1251Decode = [128]rune{
 	0x0448, 0x0449, 0x044A, 0x044B, 0x044C, 0x044D, 0x044E, 0x044F,
 }
 
+// DecodeCP1251 is the inverse of EncodeCP1251. ok is false when b holds
+// 0x98, the one byte Windows-1251 leaves undefined.
+func DecodeCP1251(b []byte) (s string, ok bool) {
+	out := make([]rune, len(b))
+	for i, c := range b {
+		if c < 0x80 {
+			out[i] = rune(c)
+			continue
+		}
+		r := cp1251Decode[c-0x80]
+		if r == 0 {
+			return "", false
+		}
+		out[i] = r
+	}
+	return string(out), true
+}
+
+// CP1251Table returns the runes of the Windows-1251 bytes 0x80 to 0xFF, by
+// byte minus 0x80; the undefined 0x98 maps to 0.
+func CP1251Table() [128]rune {
+	return cp1251Decode
+}
+
 var cp1251Encode = func() map[rune]byte {
 	m := make(map[rune]byte, 256)
 	for i, r := range cp1251Decode {
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+)
+
+// A RandSource may return fewer bytes than asked, as an HSM or a pipe
+// does; the random bytes must then be the same as from one that fills
+// every read.
+func TestRandShortReads(t *testing.T) {
+	var want, got bytes.Buffer
+	if err := writeRand(SeededRandSource(1), &want, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRand(iotest.OneByteReader(SeededRandSource(1)), &got, 100); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Fatal("writeRand differs on a source of short reads")
+	}
+
+	wantHex, err := randHex(SeededRandSource(2), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotHex, err := randHex(iotest.OneByteReader(SeededRandSource(2)), 16)
+	if err != nil || gotHex != wantHex {
+		t.Fatalf("randHex on a source of short reads: %q, %v; want %q", gotHex, err, wantHex)
+	}
+
+	fail := errors.New("source gone")
+	if err := writeRand(iotest.ErrReader(fail), &got, 10); !errors.Is(err, fail) {
+		t.Fatalf("writeRand on a failing source: %v", err)
+	}
+	if _, err := randHex(iotest.ErrReader(fail), 4); !errors.Is(err, fail) {
+		t.Fatalf("randHex on a failing source: %v", err)
+	}
+	if _, err := randIntn(iotest.ErrReader(fail), 10); !errors.Is(err, fail) {
+		t.Fatalf("randIntn on a failing source: %v", err)
+	}
+	if _, err := baitPasswords(iotest.ErrReader(fail)); !errors.Is(err, fail) {
+		t.Fatalf("baitPasswords on a failing source: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// Padded names have the form <name>~<fill...>. The marker is always appended,
+// so the last '~' in a padded name is the one added here and the original
+// name can be restored by cutting at it.
+const (
+	namePadMarker = '~'
+	namePadFill   = '_'
+)
+
+func padNames(entries []entry) {
+	target := 0
+	for _, ent := range entries {
+		if n := len(ent.name) + 1; n > target {
+			target = n
+		}
+	}
+	for i := range entries {
+		name := entries[i].name
+		padded := make([]byte, 0, target)
+		padded = append(padded, name...)
+		padded = append(padded, namePadMarker)
+		padded = append(padded, bytes.Repeat([]byte{namePadFill}, target-len(padded))...)
+		entries[i].name = padded
+	}
+}
+
+func unpadName(name string) (string, bool) {
+	idx := strings.LastIndexByte(name, namePadMarker)
+	if idx < 0 {
+		return name, false
+	}
+	if strings.Trim(name[idx+1:], string(namePadFill)) != "" {
+		return name, false
+	}
+	return name[:idx], true
+}
+
+// padStreams appends random bytes to every deflated stream so its compressed
+// size becomes a multiple of bucket. Inflaters stop at the final deflate
+// block, so the tail is claimed by csize but never decoded. Stored entries
+// cannot be padded without changing their content and are left alone.
+func padStreams(randReader io.Reader, entries []entry, bucket int64) (int, error) {
+	padded := 0
+	for i := range entries {
+		ent := &entries[i]
+		if ent.method != 8 || ent.dup {
+			continue
+		}
+		extra := (bucket - int64(ent.csize)%bucket) % bucket
+		if extra == 0 {
+			continue
+		}
+		if int64(ent.csize)+extra > 0xffffffff {
+			continue
+		}
+		if ent.data != nil {
+			buf := bytes.NewBuffer(ent.data)
+			if err := writeRand(randReader, buf, int(extra)); err != nil {
+				return padded, err
+			}
+			ent.data = buf.Bytes()
+			ent.csize += uint32(extra)
+			padded++
+			continue
+		}
+		f, err := os.OpenFile(ent.tmp, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return padded, err
+		}
+		err = writeRand(randReader, f, int(extra))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return padded, err
+		}
+		ent.csize += uint32(extra)
+		padded++
+	}
+	return padded, nil
+}
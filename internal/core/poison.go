@@ -0,0 +1,138 @@
+package core
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// Kinds of PoisonStructure.
+const (
+	PoisonFakeEOCD       = "fake-eocd"
+	PoisonTail           = "poison-tail"
+	PoisonFakeCentralDir = "fake-central-dir"
+	PoisonCommentJunk    = "comment-junk"
+)
+
+// PoisonStructure is a piece of an archive that exists to mislead readers
+// rather than to hold data: an EOCD record that leads nowhere, bytes after
+// the real end of the archive, central directory records outside the real
+// central directory, or a comment of random bytes.
+type PoisonStructure struct {
+	Kind   string `json:"kind"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// noisyZipPoisonEOCD reports whether the EOCD at off carries the values of
+// the poison tail written by the noise mode: no entries, a central
+// directory of 0xFFFFFFFF bytes at 0x80000000.
+func noisyZipPoisonEOCD(buf []byte, off int) bool {
+	if off+22 > len(buf) {
+		return false
+	}
+	return binary.LittleEndian.Uint16(buf[off+10:off+12]) == 0 &&
+		binary.LittleEndian.Uint32(buf[off+12:off+16]) == 0xFFFFFFFF &&
+		binary.LittleEndian.Uint32(buf[off+16:off+20]) == 0x80000000
+}
+
+// findPoison lists the misleading structures in buf. Which EOCD is real is
+// decided as in findCentralDir; without one, every EOCD is reported as
+// fake and there is no tail to speak of.
+func findPoison(buf []byte, names *filenameDecoder) []PoisonStructure {
+	var found []PoisonStructure
+	real, _, haveCD := findCentralDir(memData(buf))
+
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, zipSigEOCD)
+	for at := 0; ; at++ {
+		n := bytes.Index(buf[at:], sig)
+		if n < 0 {
+			break
+		}
+		at += n
+		if haveCD && at == real.off {
+			continue
+		}
+		p := PoisonStructure{Kind: PoisonFakeEOCD, Offset: int64(at), Size: int64(min(22, len(buf)-at))}
+		switch eocd, ok := parseEOCD(memData(buf), at); {
+		case noisyZipPoisonEOCD(buf, at):
+			p.Detail = "NoisyZip poison tail record"
+		case !ok:
+			p.Detail = "record fields are inconsistent"
+		default:
+			p.Size += int64(len(eocd.comment))
+			p.Detail = fmt.Sprintf("central directory at %d (%d entries) does not match the local headers", eocd.cdStart, eocd.count)
+		}
+		found = append(found, p)
+	}
+
+	if haveCD {
+		end := real.off + 22 + len(real.comment)
+		if end < len(buf) {
+			found = append(found, PoisonStructure{
+				Kind:   PoisonTail,
+				Offset: int64(end),
+				Size:   int64(len(buf) - end),
+				Detail: "data after the end of central directory record",
+			})
+		}
+		if _, ok := commentText(names, real.comment, 0); len(real.comment) > 0 && !ok {
+			found = append(found, PoisonStructure{
+				Kind:   PoisonCommentJunk,
+				Offset: int64(real.off + 22),
+				Size:   int64(len(real.comment)),
+				Detail: "archive comment is not text",
+			})
+		}
+	}
+
+	binary.LittleEndian.PutUint32(sig, zipSigCDir)
+	cdStart, cdEnd := -1, -1
+	if haveCD {
+		cdStart, cdEnd = int(real.cdStart), int(real.cdStart)+int(real.cdSize)
+	}
+	for at := 0; ; {
+		n := bytes.Index(buf[at:], sig)
+		if n < 0 {
+			break
+		}
+		at += n
+		if at >= cdStart && at < cdEnd {
+			at = cdEnd
+			continue
+		}
+		// Records that chain one after another make up one directory.
+		count, next := 0, at
+		for next+46 <= len(buf) && binary.LittleEndian.Uint32(buf[next:next+4]) == zipSigCDir {
+			size := 46 + int(binary.LittleEndian.Uint16(buf[next+28:next+30])) +
+				int(binary.LittleEndian.Uint16(buf[next+30:next+32])) +
+				int(binary.LittleEndian.Uint16(buf[next+32:next+34]))
+			if next+size > len(buf) {
+				break
+			}
+			count++
+			next += size
+		}
+		if count == 0 {
+			at++
+			continue
+		}
+		detail := fmt.Sprintf("%d records outside the central directory", count)
+		if !haveCD {
+			detail = fmt.Sprintf("%d records that do not match the local headers", count)
+		}
+		found = append(found, PoisonStructure{
+			Kind:   PoisonFakeCentralDir,
+			Offset: int64(at),
+			Size:   int64(next - at),
+			Detail: detail,
+		})
+		at = next
+	}
+	slices.SortStableFunc(found, func(a, b PoisonStructure) int { return cmp.Compare(a.Offset, b.Offset) })
+	return found
+}
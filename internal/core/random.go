@@ -0,0 +1,54 @@
+package core
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// RandSource is where a run draws its random bytes: noise data and names,
+// bait content, padding and the junk comment. Read fills p or fails. A run
+// reads it from one goroutine at a time; a source shared by runs must be
+// safe for concurrent use.
+type RandSource interface {
+	Read(p []byte) (n int, err error)
+}
+
+// SeededRandSource returns the deterministic RandSource of Config.Seed: the
+// ChaCha20 keystream under a key derived from seed, the same on every
+// platform. It is not safe for concurrent use.
+func SeededRandSource(seed int64) RandSource {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+	key := sha256.Sum256(append([]byte("noisyzip seed "), buf[:]...))
+	c, err := chacha20.NewUnauthenticatedCipher(key[:], make([]byte, chacha20.NonceSize))
+	if err != nil {
+		// The key and nonce sizes are right by construction.
+		panic(err)
+	}
+	return &chachaSource{c: c}
+}
+
+type chachaSource struct {
+	c *chacha20.Cipher
+}
+
+func (s *chachaSource) Read(p []byte) (int, error) {
+	clear(p)
+	s.c.XORKeyStream(p, p)
+	return len(p), nil
+}
+
+// runRandSource is the randomness of a run: cfg.Rand when set, otherwise
+// the seeded stream when cfg.HasSeed, otherwise crypto/rand.
+func runRandSource(cfg Config) RandSource {
+	switch {
+	case cfg.Rand != nil:
+		return cfg.Rand
+	case cfg.HasSeed:
+		return SeededRandSource(cfg.Seed)
+	}
+	return crand.Reader
+}
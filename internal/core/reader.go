@@ -0,0 +1,283 @@
+package core
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// readerMaxMemory is how much of an opened entry NoisyReader decodes in
+// memory before it moves to a temp file.
+const readerMaxMemory = 64 << 20
+
+// NoisyReader gives random access to the real entries of a noisy archive,
+// found the way recovery finds them: through the central directory when one
+// checks out, otherwise by scanning for local headers. Junk entries,
+// directories and headers that do not parse are left out of the index.
+// The archive stays on disk: it is indexed a piece at a time and each entry
+// is read from its offset when opened. Close releases it.
+type NoisyReader struct {
+	// Password decrypts ZipCrypto and WinZip AES entries.
+	Password string
+
+	file *os.File
+	// stdin is set when file is a temp copy of standard input, which
+	// Close removes.
+	stdin     bool
+	data      *readerAtData
+	positions []int
+	headers   []localHeader
+	entries   []NoisyEntry
+	// scanIdx is the position of each entry in positions, for the scan
+	// bounds of readEntryData.
+	scanIdx []int
+	byName  map[string]int
+}
+
+// NoisyEntry is one real entry of a NoisyReader.
+type NoisyEntry struct {
+	// Name is the slash path of the entry, with name padding removed.
+	Name   string
+	Offset int64
+	Method uint16
+	// Size is the uncompressed size the headers claim, -1 when they leave
+	// it to a data descriptor or the data itself.
+	Size      int64
+	Modified  time.Time
+	Encrypted bool
+}
+
+// OpenNoisy opens the archive at path and indexes its real entries.
+// StdinPath copies standard input to a temp file first, as entries are
+// read by offset.
+func OpenNoisy(path string) (*NoisyReader, error) {
+	names, err := newFilenameDecoder(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &NoisyReader{byName: make(map[string]int)}
+	if path == StdinPath {
+		r.file, err = spoolStdin()
+		r.stdin = true
+	} else {
+		r.file, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	st, err := r.file.Stat()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	r.data = &readerAtData{ra: r.file, size: int(st.Size())}
+	if err := r.index(names); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// spoolStdin copies standard input to a temp file.
+func spoolStdin() (*os.File, error) {
+	f, err := os.CreateTemp("", "enczip_stdin_*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, os.Stdin); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("read stdin: %w", err)
+	}
+	return f, nil
+}
+
+// Close closes the archive.
+func (r *NoisyReader) Close() error {
+	err := r.file.Close()
+	if r.stdin {
+		os.Remove(r.file.Name())
+	}
+	return err
+}
+
+func (r *NoisyReader) index(names *filenameDecoder) error {
+	source, headers, positions := locateHeaders(r.data, false, names)
+	r.positions = positions
+	var found []localHeader
+	var foundIdx []int
+	if source == SourceCentralDir {
+		for i, h := range headers {
+			if h.valid {
+				found, foundIdx = append(found, h), append(foundIdx, i)
+			}
+		}
+	} else {
+		for i, off := range positions {
+			if h, ok := parseLocalHeader(r.data, off, names); ok {
+				found, foundIdx = append(found, h), append(foundIdx, i)
+			}
+		}
+	}
+
+	// Names are only unpadded when every one of them was padded, so a
+	// real name ending in ~ survives an archive written without padding.
+	padded := len(found) > 0
+	for _, h := range found {
+		if _, ok := unpadName(h.fname); !ok {
+			padded = false
+			break
+		}
+	}
+	for i, h := range found {
+		name := strings.ReplaceAll(h.fname, "\\", "/")
+		if padded {
+			name, _ = unpadName(name)
+		}
+		if name == "" || strings.HasSuffix(name, "/") || isJunkPath(nil, name) {
+			continue
+		}
+		if _, dup := r.byName[name]; dup {
+			continue
+		}
+		ent := NoisyEntry{
+			Name:      name,
+			Offset:    int64(h.off),
+			Method:    h.comp,
+			Size:      -1,
+			Encrypted: h.flags&zipFlagEncrypted != 0,
+		}
+		if h.exact || h.flags&zipFlagDataDesc == 0 {
+			ent.Size = int64(h.usize)
+		}
+		ent.Modified, _ = entryModTime(h)
+		r.byName[name] = len(r.entries)
+		r.entries = append(r.entries, ent)
+		r.headers = append(r.headers, h)
+		r.scanIdx = append(r.scanIdx, foundIdx[i])
+	}
+	if r.data.err != nil {
+		return fmt.Errorf("read archive: %w", r.data.err)
+	}
+	return nil
+}
+
+// Entries returns the real entries in archive order.
+func (r *NoisyReader) Entries() []NoisyEntry {
+	return r.entries
+}
+
+// Open decodes the entry name and returns its data. A CRC that does not
+// match fails with ErrVerification; an entry cut off by the end of the file
+// fails too. The caller must close the reader.
+func (r *NoisyReader) Open(name string) (io.ReadCloser, error) {
+	i, ok := r.byName[strings.ReplaceAll(name, "\\", "/")]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	h := r.headers[i]
+	idx := 0
+	if r.positions != nil {
+		idx = r.scanIdx[i]
+	}
+	out := newSpillBuffer(readerMaxMemory, os.TempDir())
+	want, ok, err := r.readEntry(h, idx, out)
+	if err != nil {
+		out.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if ok && want != out.Sum32() {
+		out.Close()
+		return nil, withKind(ErrVerification, fmt.Errorf("%s: CRC mismatch (expected %08x, got %08x)", name, want, out.Sum32()))
+	}
+	if data, ok := out.Bytes(); ok {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	f := out.file
+	out.file = nil
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spilledEntry{f}.Close()
+		return nil, err
+	}
+	return spilledEntry{f}, nil
+}
+
+// readEntry decodes the data of h, the idx-th header the scan found, into
+// out and returns the CRC it claims, if any. Data the central directory
+// gives the range of is streamed from the archive. Other entries are read
+// into memory from their local header to the next one, where their data
+// most likely ends; if it does not, as when the data holds a header
+// signature, they are read again to the end of the archive.
+func (r *NoisyReader) readEntry(h localHeader, idx int, out *spillBuffer) (uint32, bool, error) {
+	if h.exact && h.flags&zipFlagEncrypted == 0 && (h.comp == 0 || h.comp == 8) {
+		var src io.Reader = io.NewSectionReader(r.file, int64(h.dataOff), int64(h.csize))
+		if h.comp == 8 {
+			fr := flate.NewReader(src)
+			defer fr.Close()
+			src = fr
+		}
+		if _, err := io.Copy(out, src); err != nil {
+			return 0, false, err
+		}
+		want, ok := expectedCRC(nil, h)
+		return want, ok, nil
+	}
+
+	end := r.data.size
+	if h.exact {
+		end = min(h.dataOff+int(h.csize), end)
+	} else {
+		for _, pos := range r.positions[idx+1:] {
+			if pos >= h.dataOff {
+				end = pos
+				break
+			}
+		}
+		if h.flags&zipFlagDataDesc == 0 && h.csize != 0 {
+			end = max(end, min(h.dataOff+int(h.csize), r.data.size))
+		}
+	}
+	for {
+		buf := make([]byte, end-h.off)
+		if _, err := r.file.ReadAt(buf, int64(h.off)); err != nil {
+			return 0, false, fmt.Errorf("read archive: %w", err)
+		}
+		// The window starts at the local header and stops before the
+		// next one, if any, as readEntryData expects of positions.
+		w := h
+		w.off, w.dataOff = 0, h.dataOff-h.off
+		positions := []int{0}
+		if end < r.data.size {
+			positions = append(positions, end-h.off)
+		}
+		err := readEntryData(buf, w, positions, 0, r.Password, out)
+		if errors.Is(err, errTruncated) && end < r.data.size {
+			out.Reset()
+			end = r.data.size
+			continue
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		want, ok := expectedCRC(buf, w)
+		return want, ok, nil
+	}
+}
+
+// spilledEntry is an opened entry too big for memory, read back from its
+// temp file, which Close removes.
+type spilledEntry struct {
+	*os.File
+}
+
+func (f spilledEntry) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
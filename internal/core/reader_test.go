@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// NoisyReader reads entries by offset, through the central directory or,
+// once it is overwritten, through a scan; stored data holding a local
+// header signature must still come back whole.
+func TestNoisyReaderByOffset(t *testing.T) {
+	contents := []string{
+		strings.Repeat("plain text ", 300),
+		"before PK\x03\x04 after " + strings.Repeat("x", 100),
+		"",
+	}
+	encName, nameFlag, err := NewNameEncoder("utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, overwriteCentralDir := range []bool{false, true} {
+		for _, deflate := range []bool{false, true} {
+			t.Run(fmt.Sprintf("overwriteCentralDir=%t,deflate=%t", overwriteCentralDir, deflate), func(t *testing.T) {
+				dir := t.TempDir()
+				entries := make([]entry, len(contents))
+				for i, content := range contents {
+					entries[i], err = compressReader(context.Background(), dir, 0, fmt.Sprintf("f%d.txt", i), time.Time{}, strings.NewReader(content),
+						encName, nameFlag, compressorFor(deflate), 6, "default", true)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				path := filepath.Join(dir, "in.zip")
+				if _, err := writeZip(context.Background(), SeededRandSource(1), path, entries, 1, 0, overwriteCentralDir, 0); err != nil {
+					t.Fatal(err)
+				}
+				r, err := OpenNoisy(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer r.Close()
+				if len(r.Entries()) != len(contents) {
+					t.Fatalf("%d entries indexed, want %d", len(r.Entries()), len(contents))
+				}
+				for i, content := range contents {
+					rc, err := r.Open(fmt.Sprintf("f%d.txt", i))
+					if err != nil {
+						t.Fatal(err)
+					}
+					data, err := io.ReadAll(rc)
+					rc.Close()
+					if err != nil || string(data) != content {
+						t.Fatalf("f%d.txt: %q, %v", i, data, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// Signatures across the boundary of two scan chunks are found once.
+func TestIndexSigChunks(t *testing.T) {
+	sig := []byte{'P', 'K', 3, 4}
+	buf := make([]byte, 2*scanChunk+10)
+	want := []int{0, scanChunk - 3, scanChunk + 1, 2*scanChunk - 2, len(buf) - 4}
+	for _, at := range want {
+		copy(buf[at:], sig)
+	}
+	if got := indexSig(memData(buf), sig); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("indexSig found %v, want %v", got, want)
+	}
+	var last []int
+	for end := len(buf); ; {
+		at := lastIndexSig(memData(buf), end, sig)
+		if at < 0 {
+			break
+		}
+		last = append([]int{at}, last...)
+		end = at
+	}
+	if fmt.Sprint(last) != fmt.Sprint(want) {
+		t.Fatalf("lastIndexSig found %v, want %v", last, want)
+	}
+}
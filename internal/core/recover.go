@@ -1,23 +1,28 @@
 package core
 
 import (
+	"archive/tar"
 	"bytes"
 	"compress/flate"
+	"compress/gzip"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strings"
+	"time"
 	"unicode"
-	"unicode/utf8"
-
-	"golang.org/x/text/encoding/charmap"
 )
 
 const (
 	zipSigLocal     = 0x04034B50
+	zipSigDataDesc  = 0x08074B50
 	zipFlagUTF8     = 1 << 11
 	zipFlagDataDesc = 1 << 3
 )
@@ -26,9 +31,29 @@ type localHeader struct {
 	off     int
 	flags   uint16
 	comp    uint16
+	crc     uint32
 	csize   uint32
+	usize   uint32
+	modTime uint16
+	modDate uint16
 	fname   string
+	extra   []byte
 	dataOff int
+	// mode holds Unix permission bits from the central directory, 0 when
+	// the entry was not made on Unix.
+	mode os.FileMode
+	// comment is the raw entry comment, which only the central directory
+	// carries.
+	comment []byte
+	// guessed is set when the name's charset was picked by scoring.
+	guessed bool
+	// badUTF8 is set when the UTF-8 flag was set on a name that is not
+	// UTF-8, so its charset was guessed anyway.
+	badUTF8 bool
+	// exact is set for headers taken from a valid central directory, whose
+	// sizes and CRC are authoritative.
+	exact bool
+	valid bool
 }
 
 func scoreName(s string) int {
@@ -60,225 +85,1377 @@ func scoreName(s string) int {
 	return score
 }
 
-func decodeWith(enc *charmap.Charmap, b []byte) (string, bool) {
-	dec := enc.NewDecoder()
-	out, err := dec.Bytes(b)
-	if err != nil {
-		return "", false
-	}
-	return string(out), true
-}
-
-func decodeFilename(name []byte, flags uint16) (string, bool) {
-	if flags&zipFlagUTF8 != 0 {
-		if utf8.Valid(name) {
-			return string(name), true
-		}
-		return "", false
-	}
-
-	candidates := make([]struct {
-		score int
-		name  string
-	}, 0, 4)
-
-	if utf8.Valid(name) {
-		decoded := string(name)
-		candidates = append(candidates, struct {
-			score int
-			name  string
-		}{scoreName(decoded), decoded})
-	}
-	if decoded, ok := decodeWith(charmap.CodePage866, name); ok {
-		candidates = append(candidates, struct {
-			score int
-			name  string
-		}{scoreName(decoded), decoded})
-	}
-	if decoded, ok := decodeWith(charmap.Windows1251, name); ok {
-		candidates = append(candidates, struct {
-			score int
-			name  string
-		}{scoreName(decoded), decoded})
-	}
-	if decoded, ok := decodeWith(charmap.CodePage437, name); ok {
-		candidates = append(candidates, struct {
-			score int
-			name  string
-		}{scoreName(decoded), decoded})
-	}
-
-	if len(candidates) == 0 {
-		return "", false
-	}
+// Path policies for RecoverConfig.PathPolicy, applied to ".." components
+// and absolute names.
+const (
+	PathStrip   = "strip"
+	PathReplace = "replace"
+	PathReject  = "reject"
+)
 
-	best := candidates[0]
-	for _, c := range candidates[1:] {
-		if c.score > best.score {
-			best = c
+// safeRelPath turns an entry name into a relative path that stays inside
+// the output directory. Empty and "." components are always dropped; ".."
+// (and any other dots-only component) and a leading slash are dropped,
+// replaced by "_" or make the name rejected, depending on policy. changes
+// describes every edit so callers can log it, or why a name was rejected.
+func safeRelPath(name, policy string) (string, []string, bool) {
+	n := strings.ReplaceAll(name, "\\", "/")
+	var changes []string
+	if strings.HasPrefix(n, "/") {
+		if policy == PathReject {
+			return "", []string{"absolute path"}, false
 		}
+		n = strings.TrimLeft(n, "/")
+		changes = append(changes, "removed leading /")
 	}
-	return best.name, true
-}
-
-func safeRelPath(name string) (string, bool) {
-	n := strings.ReplaceAll(name, "\\", "/")
-	n = regexp.MustCompile(`^\.*/+`).ReplaceAllString(n, "")
-	parts := strings.Split(n, "/")
+	parts := strings.Split(strings.TrimSuffix(n, "/"), "/")
 	clean := make([]string, 0, len(parts))
 	for _, p := range parts {
-		if p == "" || p == "." || p == ".." {
-			continue
+		switch {
+		case p == "":
+			changes = append(changes, "removed empty component")
+		case p == ".":
+			changes = append(changes, `removed "."`)
+		case strings.Trim(p, ".") == "":
+			switch policy {
+			case PathReject:
+				return "", []string{fmt.Sprintf("%q component", p)}, false
+			case PathReplace:
+				clean = append(clean, "_")
+				changes = append(changes, fmt.Sprintf("replaced %q with _", p))
+			default:
+				changes = append(changes, fmt.Sprintf("removed %q", p))
+			}
+		default:
+			clean = append(clean, p)
 		}
-		clean = append(clean, p)
 	}
 	if len(clean) == 0 {
-		return "", false
+		return "", nil, false
 	}
-	return filepath.Join(clean...), true
+	return filepath.Join(clean...), changes, true
 }
 
-func isJunkPath(rel string) bool {
-	rel = strings.ReplaceAll(rel, "\\", "/")
-	if rel == ".junk" {
-		return true
+// DefaultJunkPatterns classifies the entries of the built-in random noise
+// generator as junk. It is used when RecoverConfig.Junk is nil.
+var DefaultJunkPatterns = []string{".junk/**"}
+
+func isJunkPath(patterns []string, rel string) bool {
+	if patterns == nil {
+		patterns = DefaultJunkPatterns
 	}
-	return strings.HasPrefix(rel, ".junk/")
+	return matchAny(patterns, filepath.ToSlash(rel))
 }
 
-func parseLocalHeader(buf []byte, off int) (localHeader, bool) {
-	if off+30 > len(buf) {
+func parseLocalHeader(buf archiveData, off int, names *filenameDecoder) (localHeader, bool) {
+	hdr, ok := buf.at(off, 30)
+	if !ok {
 		return localHeader{}, false
 	}
-	if binary.LittleEndian.Uint32(buf[off:off+4]) != zipSigLocal {
+	if binary.LittleEndian.Uint32(hdr[0:4]) != zipSigLocal {
 		return localHeader{}, false
 	}
-	flags := binary.LittleEndian.Uint16(buf[off+6 : off+8])
-	comp := binary.LittleEndian.Uint16(buf[off+8 : off+10])
-	csize := binary.LittleEndian.Uint32(buf[off+18 : off+22])
-	fnlen := binary.LittleEndian.Uint16(buf[off+26 : off+28])
-	exlen := binary.LittleEndian.Uint16(buf[off+28 : off+30])
+	flags := binary.LittleEndian.Uint16(hdr[6:8])
+	comp := binary.LittleEndian.Uint16(hdr[8:10])
+	crc := binary.LittleEndian.Uint32(hdr[14:18])
+	csize := binary.LittleEndian.Uint32(hdr[18:22])
+	fnlen := int(binary.LittleEndian.Uint16(hdr[26:28]))
+	exlen := int(binary.LittleEndian.Uint16(hdr[28:30]))
 
-	nameStart := off + 30
-	nameEnd := nameStart + int(fnlen)
-	extraEnd := nameEnd + int(exlen)
-	if extraEnd > len(buf) || nameEnd > len(buf) {
+	rest, ok := buf.at(off+30, fnlen+exlen)
+	if !ok {
 		return localHeader{}, false
 	}
-
-	nameBytes := buf[nameStart:nameEnd]
-	fname, ok := decodeFilename(nameBytes, flags)
+	nameBytes, extra := rest[:fnlen], rest[fnlen:]
+	fname, ok := names.decode(nameBytes, extra, flags)
 	if !ok {
 		return localHeader{}, false
 	}
+	usize := binary.LittleEndian.Uint32(hdr[22:26])
+	if csize == 0xFFFFFFFF || usize == 0xFFFFFFFF {
+		// ZIP64 placeholders. Sizes that need 64 bits are left unknown,
+		// as with a data descriptor, for the stream end to decide.
+		if !zip64Sizes(extra, &usize, &csize, nil) {
+			csize, usize = 0, 0
+		}
+	}
 
 	return localHeader{
 		off:     off,
 		flags:   flags,
 		comp:    comp,
+		crc:     crc,
 		csize:   csize,
+		usize:   usize,
+		modTime: binary.LittleEndian.Uint16(hdr[10:12]),
+		modDate: binary.LittleEndian.Uint16(hdr[12:14]),
 		fname:   fname,
-		dataOff: extraEnd,
+		extra:   extra,
+		dataOff: off + 30 + fnlen + exlen,
+		guessed: nameGuessed(nameBytes, extra, flags),
+		badUTF8: badUTF8Name(nameBytes, extra, flags),
+		valid:   true,
 	}, true
 }
 
-func inflateRaw(data []byte) ([]byte, error) {
+func inflateTo(out io.Writer, data []byte) error {
 	r := flate.NewReader(bytes.NewReader(data))
 	defer r.Close()
-	return io.ReadAll(r)
+	_, err := io.Copy(out, r)
+	return err
 }
 
-func inflateIncremental(buf []byte, start int, positions []int, i int) ([]byte, error) {
-	endIndex := i + 1
-	tries := 0
-	for endIndex < len(positions) {
-		end := positions[endIndex]
-		if end > start {
-			out, err := inflateRaw(buf[start:end])
-			if err == nil {
-				return out, nil
-			}
+// inflateLen inflates the deflate stream at the start of data into out and
+// returns how many compressed bytes it took. flate reads a bytes.Reader
+// directly through io.ByteReader, so nothing past the stream is consumed.
+func inflateLen(out io.Writer, data []byte) (int, error) {
+	br := bytes.NewReader(data)
+	r := flate.NewReader(br)
+	defer r.Close()
+	if _, err := io.Copy(out, r); err != nil {
+		return 0, err
+	}
+	return len(data) - br.Len(), nil
+}
+
+// inflateIncremental inflates the deflate stream at start when its
+// compressed size is unknown. A deflate stream marks its own end, so it is
+// decoded once against the rest of buf; running out of input first means
+// the file was cut off.
+func inflateIncremental(buf []byte, start int, out *spillBuffer) error {
+	if start >= len(buf) {
+		return errTruncated
+	}
+	_, err := inflateLen(out, buf[start:])
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return errTruncated
+	}
+	return err
+}
+
+type RecoverConfig struct {
+	// InZip is the archive to read; StdinPath reads it from standard input.
+	InZip string
+	// InParts lists the pieces of a split or spanned archive in order
+	// (file.z01, file.z02, ..., file.zip). When set they are concatenated
+	// and InZip is ignored.
+	InParts    []string
+	OutDir     string
+	UnpadNames bool
+	// ListOnly scans and decodes entries without writing anything.
+	ListOnly bool
+	// Only and Skip filter entries by path glob (see matchGlob). An entry is
+	// recovered when it matches any Only pattern (or Only is empty) and no
+	// Skip pattern.
+	Only []string
+	Skip []string
+	// Junk lists glob patterns for noise entries, which are reported as
+	// junk and never written. Nil means DefaultJunkPatterns; an empty
+	// non-nil slice disables junk detection.
+	Junk []string
+	// ForceScan ignores a valid central directory and always brute-force
+	// scans for local headers.
+	ForceScan bool
+	// BytesProgress, when set, is called with the archive offset reached
+	// and the archive size as entries are processed. Unlike the per-header
+	// progress callback it stays proportional when entry sizes vary widely.
+	BytesProgress func(done, total int64)
+	// MaxMemory caps the decoded bytes of one entry held in memory (0 means
+	// no cap). Larger entries stream to a temp file in OutDir, or are only
+	// hashed in list mode, and nested archives past it are not expanded.
+	MaxMemory int64
+	// MaxEntrySize, MaxTotalSize and MaxRatio guard against archive bombs:
+	// an entry is abandoned once its decoded size passes MaxEntrySize, its
+	// compressed size times MaxRatio, or what is left of MaxTotalSize for
+	// the whole run. Zero disables a limit.
+	MaxEntrySize int64
+	MaxTotalSize int64
+	MaxRatio     float64
+	// Order is the order entries are visited, written and reported in:
+	// OrderOffset (the default when empty) or OrderName.
+	Order string
+	// PathPolicy decides what happens to ".." components and absolute
+	// names: PathStrip (the default when empty) drops them, PathReplace
+	// turns them into "_" and PathReject fails the entry. Every change is
+	// logged.
+	PathPolicy string
+	// Strict accepts only entries whose content is verified by a CRC (or
+	// the WinZip AES authentication code) and whose name needed no charset
+	// guess. Everything else fails instead of being reconstructed.
+	Strict bool
+	// Incremental skips entries whose file is already in OutDir from an
+	// earlier incremental run, matched by path, offset and header CRC, so
+	// recovering the same or an appended-to archive again only decodes
+	// what changed. The record is kept in IncrementalStateName in OutDir.
+	Incremental bool
+	// Checkpoint, when set, is a file where progress is saved every few
+	// seconds and when the run is stopped. A later run with the same input,
+	// OutDir and Order resumes after the entries it lists, keeping their
+	// files; it is removed once a run completes. The resumed run still
+	// reads the whole input into memory.
+	Checkpoint string
+	// KeepJunk, when set, is a directory where entries classified as junk
+	// are written for inspection instead of being dropped.
+	KeepJunk string
+	// Manifest, when set, is the path of a JSON file listing the path, size,
+	// SHA-256 and source offset of every file written.
+	Manifest string
+	// Comments, when set, is the path of a JSON file receiving the archive
+	// comment and the entry comments that read as text.
+	Comments string
+	// Digest, when set to one of DigestAlgorithms, hashes the decoded data
+	// of every entry read into RecoveredEntry.Digest, in list mode too.
+	Digest string
+	// Password decrypts ZipCrypto and WinZip AES entries. Encrypted entries
+	// fail when it is empty or wrong.
+	Password string
+	// Recurse is how many levels of nested archives are recovered in place
+	// of being written as .zip files. 0 disables it.
+	Recurse int
+	// Encodings lists the NameDecoders tried for names without the UTF-8
+	// flag, in order of preference. Empty means DefaultNameDecoders.
+	Encodings []string
+	// NameScorer picks among the decoded candidates. Nil uses the built-in
+	// scorer.
+	NameScorer NameScorer
+	// TarOut, when set, receives the recovered files as a gzip-compressed
+	// tar stream instead of having them written under OutDir. Entries past
+	// MaxMemory are spilled to the system temp directory meanwhile.
+	TarOut io.Writer
+	// Logger, when set, receives the log records of the run, with levels
+	// and attributes, in place of the log callback.
+	Logger *slog.Logger
+	// Metrics, when set, receives the counters and timings of the run.
+	Metrics Metrics
+
+	tar         *tar.Writer
+	mem         *memSink
+	ctx         context.Context
+	checkpoint  *recoverCheckpoint
+	incremental *incrementalState
+}
+
+// Entry orders for RecoverConfig.Order.
+const (
+	OrderOffset = "offset"
+	OrderName   = "name"
+)
+
+// Entry statuses reported in RecoveredEntry.Status.
+const (
+	EntryOK      = "ok"
+	EntryJunk    = "junk"
+	EntrySkipped = "skipped"
+	EntryFailed  = "failed"
+	EntryInvalid = "invalid"
+	// EntryTruncated marks an entry cut off by the end of the file. Whatever
+	// decoded before the cut is written.
+	EntryTruncated = "truncated"
+	// EntryNested marks an archive that was recovered recursively; its
+	// entries follow it in the report.
+	EntryNested = "nested"
+	// EntryDir marks a directory entry. The directory is created but not
+	// counted as recovered.
+	EntryDir = "dir"
+)
+
+// errTruncated is returned with the partial data of an entry that runs past
+// the end of the file.
+var errTruncated = errors.New("truncated at end of file")
+
+// RecoveredEntry describes one local header found while scanning.
+type RecoveredEntry struct {
+	Name   string `json:"name"`
+	Path   string `json:"path,omitempty"`
+	Offset int64  `json:"offset"`
+	Method uint16 `json:"method"`
+	Size   int64  `json:"size"`
+	CRC    uint32 `json:"crc"`
+	// CRCCheck is "ok", "mismatch" or "unknown" when no trustworthy CRC
+	// was found in the local header or a data descriptor.
+	CRCCheck string    `json:"crcCheck,omitempty"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Modified time.Time `json:"modified,omitzero"`
+	// SHA256 is set when RecoverConfig.Manifest is.
+	SHA256 string `json:"sha256,omitempty"`
+	// Digest is the hex digest of the data with RecoverConfig.Digest.
+	Digest string `json:"digest,omitempty"`
+	// Comment is the entry comment from the central directory, when it
+	// reads as text.
+	Comment string `json:"comment,omitempty"`
+	// Confidence rates a decoded entry from 0 to 100: a verified CRC, a
+	// name that needed no charset guess and a known data end keep it at
+	// 100. ConfidenceNotes says what lowered it.
+	Confidence      int      `json:"confidence,omitempty"`
+	ConfidenceNotes []string `json:"confidenceNotes,omitempty"`
+	// Deflate is the deflate option hint (DeflateNormal, DeflateMaximum,
+	// DeflateFast or DeflateSuperFast) of deflated data, "" for stored.
+	Deflate string `json:"deflate,omitempty"`
+}
+
+const (
+	CRCOK       = "ok"
+	CRCMismatch = "mismatch"
+	CRCUnknown  = "unknown"
+)
+
+// Recovery sources reported in RecoverReport.Source.
+const (
+	SourceCentralDir = "central-directory"
+	SourceScan       = "scan"
+)
+
+type RecoverReport struct {
+	Source    string `json:"source"`
+	Headers   int    `json:"headers"`
+	Recovered int    `json:"recovered"`
+	Junk      int    `json:"junk"`
+	Skipped   int    `json:"skipped"`
+	Failed    int    `json:"failed"`
+	Truncated int    `json:"truncated"`
+	Nested    int    `json:"nested"`
+	BadCRC    int    `json:"badCrc"`
+	// Unchanged counts the recovered entries an incremental run found
+	// already in place and left alone.
+	Unchanged int              `json:"unchanged,omitempty"`
+	Entries   []RecoveredEntry `json:"entries"`
+	// Comment is the archive comment, unless it is junk.
+	Comment string `json:"comment,omitempty"`
+	// Poison lists the structures planted to mislead ZIP readers, in
+	// file order.
+	Poison []PoisonStructure `json:"poison,omitempty"`
+
+	// bytes is the decoded size of everything recovered, for MaxTotalSize.
+	bytes int64
+}
+
+// StdinPath as an input path reads the archive from standard input.
+const StdinPath = "-"
+
+// readInputFile reads path, or all of standard input for StdinPath. The
+// scan needs random access, so a piped archive is buffered whole.
+func readInputFile(path string) ([]byte, error) {
+	if path == StdinPath {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("read stdin: %w", err)
 		}
-		endIndex++
-		tries++
-		if tries > 20000 {
-			break
+		return data, nil
+	}
+	return os.ReadFile(path)
+}
+
+func readRecoverInput(cfg RecoverConfig) ([]byte, error) {
+	if len(cfg.InParts) == 0 {
+		return readInputFile(cfg.InZip)
+	}
+	var buf []byte
+	for _, part := range cfg.InParts {
+		data, err := readInputFile(part)
+		if err != nil {
+			return nil, err
 		}
+		buf = append(buf, data...)
 	}
-	if start < len(buf) {
-		if out, err := inflateRaw(buf[start:]); err == nil {
-			return out, nil
+	// Spanned archives open with a data descriptor signature as a split
+	// marker. Dropping it keeps offsets of a single-disk central directory
+	// in line with the joined buffer.
+	if len(buf) >= 8 && binary.LittleEndian.Uint32(buf[:4]) == zipSigDataDesc && isZipData(buf[4:]) {
+		buf = buf[4:]
+	}
+	return buf, nil
+}
+
+// RecoveredPaths returns the slash paths of the files written, in the order
+// they were recovered.
+func (r RecoverReport) RecoveredPaths() []string {
+	var paths []string
+	for _, ent := range r.Entries {
+		if ent.Status == EntryOK || ent.Status == EntryTruncated {
+			paths = append(paths, ent.Path)
 		}
 	}
-	return nil, fmt.Errorf("failed to locate end of deflate stream")
+	return paths
+}
+
+func (r *RecoverReport) merge(sub RecoverReport) {
+	r.Headers += sub.Headers
+	r.Recovered += sub.Recovered
+	r.Junk += sub.Junk
+	r.Skipped += sub.Skipped
+	r.Failed += sub.Failed
+	r.Truncated += sub.Truncated
+	r.Nested += sub.Nested
+	r.BadCRC += sub.BadCRC
+	r.Unchanged += sub.Unchanged
+	r.Entries = append(r.Entries, sub.Entries...)
+	r.bytes += sub.bytes
 }
 
-func RecoverZip(zipPath string, outDir string, progressCb func(done, total int, name string), logCb func(string)) (int, error) {
-	buf, err := os.ReadFile(zipPath)
+func RecoverZip(cfg RecoverConfig, progressCb func(done, total int, name string), logCb func(string)) (RecoverReport, error) {
+	return RecoverZipContext(context.Background(), cfg, progressCb, logCb)
+}
+
+// RecoverZipContext is RecoverZip with cancellation. When ctx is done the
+// run stops between entries or inside the decoder, the files written so far
+// are removed and the partial report is returned with an error wrapping
+// ctx.Err(). With TarOut the caller owns the stream and discards it. With
+// Checkpoint the files the saved checkpoint covers are kept instead, and the
+// report returned is the one it holds.
+func RecoverZipContext(ctx context.Context, cfg RecoverConfig, progressCb func(done, total int, name string), logCb func(string)) (RecoverReport, error) {
+	var rep RecoverReport
+	start := time.Now()
+	lg := runLogger(cfg.Logger, logCb)
+	if err := validateGlobs(cfg.Only); err != nil {
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("only: %w", err))
+	}
+	if err := validateGlobs(cfg.Skip); err != nil {
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("skip: %w", err))
+	}
+	if err := validateGlobs(cfg.Junk); err != nil {
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("junk: %w", err))
+	}
+	switch cfg.Order {
+	case "", OrderOffset, OrderName:
+	default:
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("order must be %s or %s", OrderOffset, OrderName))
+	}
+	switch cfg.PathPolicy {
+	case "", PathStrip, PathReplace, PathReject:
+	default:
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("path policy must be %s, %s or %s", PathStrip, PathReplace, PathReject))
+	}
+	names, err := newFilenameDecoder(cfg.Encodings, cfg.NameScorer)
 	if err != nil {
-		return 0, err
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("encodings: %w", err))
 	}
-
-	positions := make([]int, 0)
-	for i := 0; i+4 <= len(buf); i++ {
-		if buf[i] == 'P' && buf[i+1] == 'K' && buf[i+2] == 3 && buf[i+3] == 4 {
-			positions = append(positions, i)
+	if cfg.Digest != "" {
+		if _, err := newDigest(cfg.Digest); err != nil {
+			return rep, withKind(ErrInvalidConfig, err)
+		}
+	}
+	buf, err := readRecoverInput(cfg)
+	if err != nil {
+		return rep, err
+	}
+	cfg.ctx = ctx
+	if cfg.Checkpoint != "" && !cfg.ListOnly {
+		if cfg.TarOut != nil {
+			return rep, withKind(ErrInvalidConfig, fmt.Errorf("checkpoint needs an output directory, not a tar stream"))
+		}
+		cp, err := newCheckpoint(cfg.Checkpoint, cfg, buf)
+		if err != nil {
+			return rep, err
 		}
+		cp, resumed, err := loadCheckpoint(cp)
+		if err != nil {
+			return rep, err
+		}
+		if resumed {
+			lg.Info(fmt.Sprintf("Resuming from %s: %d entries done", cfg.Checkpoint, cp.Processed), "checkpoint", cfg.Checkpoint, "entries", cp.Processed)
+		}
+		cfg.checkpoint = cp
+	}
+	if cfg.Incremental && !cfg.ListOnly {
+		if cfg.TarOut != nil || cfg.checkpoint != nil {
+			return rep, withKind(ErrInvalidConfig, fmt.Errorf("incremental recovery cannot be combined with a tar stream or a checkpoint"))
+		}
+		inc, err := loadIncrementalState(cfg.OutDir)
+		if err != nil {
+			return rep, err
+		}
+		cfg.incremental = inc
 	}
+	if cfg.TarOut != nil && !cfg.ListOnly {
+		gz := gzip.NewWriter(cfg.TarOut)
+		cfg.tar = tar.NewWriter(gz)
+		rep = recoverBuffer(cfg, names, buf, "", progressCb, lg)
+		if err := cfg.tar.Close(); err != nil {
+			return rep, err
+		}
+		if err := gz.Close(); err != nil {
+			return rep, err
+		}
+	} else {
+		rep = recoverBuffer(cfg, names, buf, "", progressCb, lg)
+	}
+	metrics := runMetrics(cfg.Metrics)
+	metrics.Add(MetricRecoverAttempts, int64(rep.Recovered+rep.Truncated+rep.Failed+rep.Nested))
+	metrics.Add(MetricRecoverFailed, int64(rep.Failed))
+	metrics.Add(MetricRecoverBytes, rep.bytes)
+	metrics.Observe(MetricRecoverTime, time.Since(start))
+	rep.Comment, _ = commentText(names, archiveComment(buf), 0)
+	rep.Poison = findPoison(buf, names)
+	for _, p := range rep.Poison {
+		lg.Info(fmt.Sprintf("Poison: %s at %d (%d bytes): %s", p.Kind, p.Offset, p.Size, p.Detail), "kind", p.Kind, "offset", p.Offset, "bytes", p.Size)
+	}
+	if err := ctx.Err(); err != nil {
+		if cp := cfg.checkpoint; cp != nil {
+			// Keep what the checkpoint covers; only entries after it,
+			// possibly cut short, go.
+			removeRecovered(cfg, RecoverReport{Entries: rep.Entries[len(cp.Report.Entries):]})
+			if serr := cp.save(); serr != nil {
+				return cp.Report, fmt.Errorf("recovery stopped: %w (save checkpoint: %v)", err, serr)
+			}
+			return cp.Report, fmt.Errorf("recovery stopped: %w (resume with the same checkpoint)", err)
+		}
+		if !cfg.ListOnly && cfg.tar == nil && cfg.mem == nil {
+			written := rep
+			if inc := cfg.incremental; inc != nil {
+				written.Entries = slices.DeleteFunc(slices.Clone(rep.Entries), func(ent RecoveredEntry) bool {
+					return inc.kept[ent.Path]
+				})
+			}
+			removeRecovered(cfg, written)
+		}
+		return rep, fmt.Errorf("recovery stopped: %w", err)
+	}
+	if cfg.Manifest != "" && !cfg.ListOnly {
+		if err := writeManifest(cfg.Manifest, rep); err != nil {
+			return rep, fmt.Errorf("write manifest: %w", err)
+		}
+		lg.Info(fmt.Sprintf("Manifest: %s", cfg.Manifest), "path", cfg.Manifest)
+	}
+	if cfg.Comments != "" && !cfg.ListOnly {
+		if err := writeComments(cfg.Comments, rep); err != nil {
+			return rep, fmt.Errorf("write comments: %w", err)
+		}
+		lg.Info(fmt.Sprintf("Comments: %s", cfg.Comments), "path", cfg.Comments)
+	}
+	if cfg.incremental != nil {
+		if err := cfg.incremental.save(cfg.OutDir); err != nil {
+			return rep, fmt.Errorf("write incremental state: %w", err)
+		}
+		if rep.Unchanged > 0 {
+			lg.Info(fmt.Sprintf("Unchanged: %d", rep.Unchanged), "entries", rep.Unchanged)
+		}
+	}
+	if cfg.checkpoint != nil {
+		os.Remove(cfg.Checkpoint)
+	}
+	lg.Debug("Recovery done", "recovered", rep.Recovered, "failed", rep.Failed, "bytes", rep.bytes, "duration", time.Since(start))
+	return rep, nil
+}
 
-	if logCb != nil {
-		logCb(fmt.Sprintf("Found local headers: %d", len(positions)))
+// recoverBuffer recovers the archive held in buf. prefix is the slash path of
+// the enclosing archive's extraction directory for nested archives; it is
+// prepended to reported paths and used for -only/-skip matching.
+func recoverBuffer(cfg RecoverConfig, names *filenameDecoder, buf []byte, prefix string, progressCb func(done, total int, name string), lg *slog.Logger) RecoverReport {
+	var rep RecoverReport
+	var headers []localHeader
+	var positions []int
+	rep.Source, headers, positions = locateHeaders(memData(buf), cfg.ForceScan, names)
+	total := len(headers)
+	if rep.Source == SourceCentralDir {
+		lg.Info(fmt.Sprintf("Using central directory: %d entries", len(headers)), "entries", len(headers))
+	} else {
+		total = len(positions)
+		lg.Info(fmt.Sprintf("Found local headers: %d", len(positions)), "headers", len(positions))
+	}
+	rep.Headers = total
+	var scanned int64
+	cp, inc := cfg.checkpoint, cfg.incremental
+	cfg.checkpoint, cfg.incremental = nil, nil
+	if cp != nil {
+		rep.Recovered, rep.Junk, rep.Skipped = cp.Report.Recovered, cp.Report.Junk, cp.Report.Skipped
+		rep.Failed, rep.Truncated, rep.Nested = cp.Report.Failed, cp.Report.Truncated, cp.Report.Nested
+		rep.BadCRC, rep.Entries, rep.bytes = cp.Report.BadCRC, cp.Report.Entries, cp.Report.bytes
 	}
 
-	recovered := 0
-	total := len(positions)
-	for idx, off := range positions {
-		h, ok := parseLocalHeader(buf, off)
+	// Central directory records sharing a local header, as the duplicates
+	// of a Config.Dedup archive do, get what the first of them decoded to
+	// rather than decoding the data again: overlapping records are a
+	// classic archive bomb.
+	shared := make(map[int]sharedOutput)
+	order := entryOrder(cfg, names, buf, headers, positions)
+	for n, idx := range order {
+		if cfg.ctx != nil && cfg.ctx.Err() != nil {
+			break
+		}
+		if cp != nil {
+			if n < cp.Processed {
+				continue
+			}
+			if err := cp.mark(n, rep); err != nil {
+				lg.Warn(fmt.Sprintf("Checkpoint: %v", err), "error", err)
+			}
+		}
+		var h localHeader
+		var off int
+		ok := false
+		if rep.Source == SourceCentralDir {
+			h = headers[idx]
+			off, ok = h.off, h.valid
+		} else {
+			off = positions[idx]
+			h, ok = parseLocalHeader(memData(buf), off, names)
+		}
 		nameForProgress := ""
 		if ok {
+			if cfg.UnpadNames {
+				h.fname, _ = unpadName(h.fname)
+			}
 			nameForProgress = h.fname
 		}
 		if progressCb != nil {
-			progressCb(idx+1, total, nameForProgress)
+			progressCb(n+1, total, nameForProgress)
+		}
+		if cfg.BytesProgress != nil && int64(off) > scanned {
+			scanned = int64(off)
+			cfg.BytesProgress(scanned, int64(len(buf)))
+		}
+		ent := RecoveredEntry{Name: h.fname, Offset: int64(off), Method: h.comp, Status: EntryInvalid}
+		if ok {
+			ent.Comment, _ = commentText(names, h.comment, h.flags)
+			ent.Deflate = deflateHint(h)
+			if h.badUTF8 {
+				lg.Warn(fmt.Sprintf("Invalid UTF-8 in a name flagged UTF-8, decoded by charset guess: %s", h.fname), "entry", h.fname, "offset", off)
+			}
 		}
 		if !ok {
+			rep.Entries = append(rep.Entries, ent)
 			continue
 		}
 
-		rel, ok := safeRelPath(h.fname)
+		rel, changes, ok := safeRelPath(h.fname, cfg.PathPolicy)
 		if !ok {
+			if len(changes) > 0 {
+				ent.Error = "unsafe path: " + strings.Join(changes, ", ")
+				lg.Warn(fmt.Sprintf("Rejected path: %q (%s)", h.fname, strings.Join(changes, ", ")), "entry", h.fname, "offset", off)
+			}
+			rep.Entries = append(rep.Entries, ent)
 			continue
 		}
-		if isJunkPath(rel) {
+		if len(changes) > 0 {
+			lg.Warn(fmt.Sprintf("Sanitized path: %q -> %q (%s)", h.fname, filepath.ToSlash(rel), strings.Join(changes, ", ")), "entry", h.fname, "path", filepath.ToSlash(rel))
+		}
+		ent.Path = path.Join(prefix, filepath.ToSlash(rel))
+		if isJunkPath(cfg.Junk, rel) {
+			ent.Status = EntryJunk
+			rep.Junk++
+			lg.Info(fmt.Sprintf("Junk: %s", ent.Path), "entry", ent.Path, "offset", off)
+			if cfg.KeepJunk != "" && !cfg.ListOnly {
+				if err := keepJunk(cfg, buf, h, positions, idx, rel, &ent); err != nil {
+					ent.Error = err.Error()
+				}
+			}
+			rep.Entries = append(rep.Entries, ent)
+			continue
+		}
+		if strings.HasSuffix(strings.ReplaceAll(h.fname, "\\", "/"), "/") {
+			ent.Status = EntryDir
+			if !cfg.ListOnly && cfg.tar != nil {
+				mt, _ := entryModTime(h)
+				if err := writeTarDir(cfg.tar, ent.Path, mt); err != nil {
+					ent.Status = EntryFailed
+					ent.Error = err.Error()
+					rep.Failed++
+				}
+			} else if !cfg.ListOnly && cfg.mem == nil {
+				if err := os.MkdirAll(longPath(filepath.Join(cfg.OutDir, rel)), 0o755); err != nil {
+					ent.Status = EntryFailed
+					ent.Error = err.Error()
+					rep.Failed++
+				}
+			}
+			rep.Entries = append(rep.Entries, ent)
+			continue
+		}
+		// With -recurse an archive that fails -only may still hold
+		// matching entries, so the only check waits until the data is read.
+		onlyMatch := len(cfg.Only) == 0 || matchAny(cfg.Only, ent.Path)
+		if matchAny(cfg.Skip, ent.Path) || (!onlyMatch && cfg.Recurse == 0) {
+			ent.Status = EntrySkipped
+			rep.Skipped++
+			rep.Entries = append(rep.Entries, ent)
 			continue
 		}
 
-		var content []byte
-		if h.comp == 8 {
-			content, err = inflateIncremental(buf, h.dataOff, positions, idx)
-			if err != nil {
-				continue
+		if inc != nil && onlyMatch {
+			if crc, ok := expectedCRC(buf, h); ok {
+				if prev, ok := inc.unchanged(ent.Path, ent.Offset, crc, longPath(filepath.Join(cfg.OutDir, rel)), cfg.Manifest != ""); ok {
+					inc.record(prev, crc)
+					inc.kept[prev.Path] = true
+					rep.bytes += prev.Size
+					rep.Recovered++
+					rep.Unchanged++
+					if prev.CRCCheck == CRCMismatch {
+						rep.BadCRC++
+					}
+					rep.Entries = append(rep.Entries, prev)
+					continue
+				}
 			}
-		} else if h.comp == 0 && h.flags&zipFlagDataDesc == 0 {
-			end := h.dataOff + int(h.csize)
-			if end <= len(buf) {
-				content = buf[h.dataOff:end]
+		}
+
+		if prev, ok := shared[h.off]; ok && rep.Source == SourceCentralDir {
+			if !onlyMatch {
+				ent.Status = EntrySkipped
+				rep.Skipped++
+			} else {
+				ent = recoverShared(cfg, h, rel, ent, prev, &rep, inc, buf, lg)
 			}
+			rep.Entries = append(rep.Entries, ent)
+			continue
 		}
 
-		if content == nil {
+		spillDir := cfg.OutDir
+		if cfg.tar != nil {
+			spillDir = os.TempDir()
+		}
+		if cfg.ListOnly {
+			spillDir = ""
+		}
+		out := newSpillBuffer(cfg.MaxMemory, spillDir)
+		out.ctx = cfg.ctx
+		sizeCap, capReason, capped := entrySizeCap(cfg, rep.bytes, compressedBound(buf, h, positions, idx))
+		if capped {
+			out.setCap(sizeCap)
+		}
+		if cfg.Manifest != "" {
+			out.hashSHA256()
+		}
+		if cfg.Digest != "" {
+			d, _ := newDigest(cfg.Digest)
+			out.digestWith(d)
+		}
+		err := readEntryData(buf, h, positions, idx, cfg.Password, out)
+		if errors.Is(err, errSizeLimit) {
+			err = fmt.Errorf("output over %d bytes (%s)", sizeCap, capReason)
+			lg.Warn(fmt.Sprintf("Size limit: %s: %v", ent.Path, err), "entry", ent.Path, "limit", capReason, "bytes", sizeCap)
+		}
+		truncated := errors.Is(err, errTruncated) && out.Len() > 0
+		nested := cfg.Recurse > 0 && err == nil && isZipData(out.Head())
+		content, inMemory := out.Bytes()
+		if nested && !inMemory {
+			nested = false
+			lg.Warn(fmt.Sprintf("Nested archive larger than max-memory, kept as is: %s", ent.Path), "entry", ent.Path, "bytes", out.Len())
+		}
+		if !onlyMatch && !nested {
+			out.Close()
+			ent.Status = EntrySkipped
+			rep.Skipped++
+			rep.Entries = append(rep.Entries, ent)
 			continue
 		}
+		if err != nil && !truncated {
+			out.Close()
+			ent.Status = EntryFailed
+			ent.Error = err.Error()
+			rep.Failed++
+			rep.Entries = append(rep.Entries, ent)
+			shared[h.off] = sharedOutput{err: ent.Error}
+			continue
+		}
+		ent.Size = out.Len()
+		ent.CRC = out.Sum32()
+		ent.SHA256 = out.SHA256()
+		ent.Digest = out.Digest()
+		if mt, ok := entryModTime(h); ok {
+			ent.Modified = mt
+		}
+		ent.CRCCheck = CRCUnknown
+		if truncated {
+			ent.Error = err.Error()
+			lg.Warn(fmt.Sprintf("Truncated: %s (%d bytes recovered)", h.fname, out.Len()), "entry", ent.Path, "bytes", out.Len())
+		} else if want, ok := expectedCRC(buf, h); ok {
+			ent.CRCCheck = CRCOK
+			if want != ent.CRC {
+				ent.CRCCheck = CRCMismatch
+				rep.BadCRC++
+				lg.Warn(fmt.Sprintf("CRC mismatch: %s (expected %08x, got %08x)", h.fname, want, ent.CRC), "entry", ent.Path, "expected", want, "got", ent.CRC)
+			}
+		}
 
-		target := filepath.Join(outDir, rel)
-		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		ent.Confidence, ent.ConfidenceNotes = entryConfidence(buf, h, ent, truncated)
+		if cfg.Strict {
+			if reason := strictReject(h, ent, truncated); reason != "" {
+				out.Close()
+				ent.Status = EntryFailed
+				ent.Error = "strict: " + reason
+				rep.Failed++
+				rep.Entries = append(rep.Entries, ent)
+				continue
+			}
+		}
+
+		if nested {
+			dirRel := strings.TrimSuffix(rel, filepath.Ext(rel))
+			if dirRel == rel {
+				dirRel += "_files"
+			}
+			sub := cfg
+			sub.Recurse--
+			sub.BytesProgress = nil
+			if sub.MaxTotalSize > 0 {
+				sub.MaxTotalSize -= rep.bytes
+			}
+			sub.OutDir = filepath.Join(cfg.OutDir, dirRel)
+			if sub.KeepJunk != "" {
+				sub.KeepJunk = filepath.Join(cfg.KeepJunk, dirRel)
+			}
+			lg.Info(fmt.Sprintf("Nested archive: %s", ent.Path), "entry", ent.Path, "bytes", out.Len())
+			subRep := recoverBuffer(sub, names, content, path.Join(prefix, filepath.ToSlash(dirRel)), nil, lg)
+			if subRep.Recovered+subRep.Truncated > 0 {
+				out.Close()
+				ent.Status = EntryNested
+				rep.Nested++
+				rep.Entries = append(rep.Entries, ent)
+				rep.merge(subRep)
+				continue
+			}
+			if !onlyMatch {
+				out.Close()
+				ent.Status = EntrySkipped
+				rep.Skipped++
+				rep.Entries = append(rep.Entries, ent)
+				continue
+			}
+		}
+
+		var memData []byte
+		if !cfg.ListOnly && cfg.tar != nil {
+			if err := writeTarFile(cfg.tar, ent.Path, h, ent.Modified, out); err != nil {
+				out.Close()
+				ent.Status = EntryFailed
+				ent.Error = err.Error()
+				rep.Failed++
+				rep.Entries = append(rep.Entries, ent)
+				continue
+			}
+		} else if cfg.mem != nil {
+			// MaxMemory is 0 in memory mode, so the data is all held.
+			memData, _ = out.Bytes()
+		} else if !cfg.ListOnly {
+			target := longPath(filepath.Join(cfg.OutDir, rel))
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				out.Close()
+				ent.Status = EntryFailed
+				ent.Error = err.Error()
+				rep.Failed++
+				rep.Entries = append(rep.Entries, ent)
+				continue
+			}
+			if err := out.SaveAs(target); err != nil {
+				out.Close()
+				ent.Status = EntryFailed
+				ent.Error = err.Error()
+				rep.Failed++
+				rep.Entries = append(rep.Entries, ent)
+				continue
+			}
+			if mode, ok := entryMode(h); ok {
+				if err := os.Chmod(target, mode); err != nil {
+					lg.Warn(fmt.Sprintf("Set mode: %s: %v", ent.Path, err), "entry", ent.Path, "error", err)
+				}
+			}
+			if !ent.Modified.IsZero() {
+				if err := os.Chtimes(target, ent.Modified, ent.Modified); err != nil {
+					lg.Warn(fmt.Sprintf("Set time: %s: %v", ent.Path, err), "entry", ent.Path, "error", err)
+				}
+			}
+		}
+		out.Close()
+		rep.bytes += ent.Size
+		if truncated {
+			ent.Status = EntryTruncated
+			rep.Truncated++
+		} else {
+			ent.Status = EntryOK
+			rep.Recovered++
+			if inc != nil {
+				// Truncated entries are left out: the rest of them may
+				// turn up in a later, longer copy of the archive.
+				if crc, ok := expectedCRC(buf, h); ok {
+					inc.record(ent, crc)
+				}
+			}
+		}
+		if cfg.mem != nil {
+			mode, _ := entryMode(h)
+			cfg.mem.entries = append(cfg.mem.entries, MemoryEntry{RecoveredEntry: ent, Data: memData, Mode: mode})
+		}
+		lg.Debug("Recovered "+ent.Path, "entry", ent.Path, "bytes", ent.Size, "status", ent.Status)
+		rep.Entries = append(rep.Entries, ent)
+		shared[h.off] = sharedOutput{ent: ent, target: longPath(filepath.Join(cfg.OutDir, rel)), data: memData}
+	}
+
+	if cfg.BytesProgress != nil {
+		cfg.BytesProgress(int64(len(buf)), int64(len(buf)))
+	}
+	return rep
+}
+
+// sharedOutput is what the first entry recovered from a local header came
+// to, for the other central directory records that point at it.
+type sharedOutput struct {
+	ent RecoveredEntry
+	// target is the file written for it when recovering to OutDir, and
+	// data its content when recovering to memory.
+	target string
+	data   []byte
+	// err says why its data could not be decoded, when it could not.
+	err string
+}
+
+// recoverShared recovers ent, a central directory record that shares the
+// local header and data of an entry recovered before as prev, by copying
+// prev's output. Every copy counts against MaxTotalSize.
+func recoverShared(cfg RecoverConfig, h localHeader, rel string, ent RecoveredEntry, prev sharedOutput, rep *RecoverReport, inc *incrementalState, buf []byte, lg *slog.Logger) RecoveredEntry {
+	fail := func(msg string) RecoveredEntry {
+		ent.Status = EntryFailed
+		ent.Error = msg
+		rep.Failed++
+		return ent
+	}
+	if prev.err != "" {
+		return fail(prev.err)
+	}
+	ent.Size, ent.CRC, ent.SHA256, ent.Digest = prev.ent.Size, prev.ent.CRC, prev.ent.SHA256, prev.ent.Digest
+	ent.CRCCheck, ent.Error = prev.ent.CRCCheck, prev.ent.Error
+	ent.Confidence, ent.ConfidenceNotes = prev.ent.Confidence, prev.ent.ConfidenceNotes
+	if mt, ok := entryModTime(h); ok {
+		ent.Modified = mt
+	}
+	truncated := prev.ent.Status == EntryTruncated
+	if cfg.MaxTotalSize > 0 && rep.bytes+ent.Size > cfg.MaxTotalSize {
+		msg := fmt.Sprintf("output over %d bytes (max-total-size)", max(0, cfg.MaxTotalSize-rep.bytes))
+		lg.Warn(fmt.Sprintf("Size limit: %s: %s", ent.Path, msg), "entry", ent.Path, "limit", "max-total-size")
+		return fail(msg)
+	}
+	if cfg.Strict {
+		if reason := strictReject(h, ent, truncated); reason != "" {
+			return fail("strict: " + reason)
+		}
+	}
+
+	switch {
+	case cfg.ListOnly:
+	case cfg.tar != nil:
+		if err := writeTarLink(cfg.tar, ent.Path, prev.ent.Path, h, ent.Modified); err != nil {
+			return fail(err.Error())
+		}
+	case cfg.mem != nil:
+		mode, _ := entryMode(h)
+		cfg.mem.entries = append(cfg.mem.entries, MemoryEntry{RecoveredEntry: ent, Data: prev.data, Mode: mode})
+	default:
+		target := longPath(filepath.Join(cfg.OutDir, rel))
+		if target == prev.target {
+			break
+		}
+		if err := copyRecovered(prev.target, target); err != nil {
+			return fail(err.Error())
+		}
+		if mode, ok := entryMode(h); ok {
+			if err := os.Chmod(target, mode); err != nil {
+				lg.Warn(fmt.Sprintf("Set mode: %s: %v", ent.Path, err), "entry", ent.Path, "error", err)
+			}
+		}
+		if !ent.Modified.IsZero() {
+			if err := os.Chtimes(target, ent.Modified, ent.Modified); err != nil {
+				lg.Warn(fmt.Sprintf("Set time: %s: %v", ent.Path, err), "entry", ent.Path, "error", err)
+			}
+		}
+	}
+	rep.bytes += ent.Size
+	if ent.CRCCheck == CRCMismatch {
+		rep.BadCRC++
+	}
+	if truncated {
+		ent.Status = EntryTruncated
+		rep.Truncated++
+	} else {
+		ent.Status = EntryOK
+		rep.Recovered++
+		if inc != nil {
+			if crc, ok := expectedCRC(buf, h); ok {
+				inc.record(ent, crc)
+			}
+		}
+	}
+	lg.Debug("Recovered "+ent.Path+" as a copy of "+prev.ent.Path, "entry", ent.Path, "bytes", ent.Size, "status", ent.Status)
+	return ent
+}
+
+// copyRecovered copies the recovered file src to dst.
+func copyRecovered(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// strictReject returns why an entry that decoded fails RecoverConfig.Strict,
+// or "" when it passes.
+func strictReject(h localHeader, ent RecoveredEntry, truncated bool) string {
+	switch {
+	case truncated:
+		return "entry is truncated"
+	case h.guessed:
+		return "name charset was guessed"
+	case ent.CRCCheck == CRCOK:
+		return ""
+	case ent.CRCCheck == CRCMismatch:
+		return "CRC mismatch"
+	case ent.CRCCheck == CRCUnknown && h.comp == zipMethodAES:
+		// AE-2 has no CRC; the HMAC checked during decryption vouches for it.
+		return ""
+	default:
+		return "CRC not verified"
+	}
+}
+
+// removeRecovered deletes the files a stopped run wrote, then the
+// directories left empty by that.
+func removeRecovered(cfg RecoverConfig, rep RecoverReport) {
+	var dirs []string
+	for _, ent := range rep.Entries {
+		var target, root string
+		switch {
+		case ent.Status == EntryOK || ent.Status == EntryTruncated:
+			target, root = filepath.Join(cfg.OutDir, filepath.FromSlash(ent.Path)), cfg.OutDir
+		case ent.Status == EntryJunk && cfg.KeepJunk != "" && ent.Error == "":
+			target, root = filepath.Join(cfg.KeepJunk, filepath.FromSlash(ent.Path)), cfg.KeepJunk
+		case ent.Status == EntryDir:
+			dirs = append(dirs, filepath.Join(cfg.OutDir, filepath.FromSlash(ent.Path)))
+			continue
+		default:
 			continue
 		}
-		if err := os.WriteFile(target, content, 0o644); err != nil {
+		if os.Remove(longPath(target)) != nil {
 			continue
 		}
-		recovered++
+		for dir := filepath.Dir(target); dir != filepath.Clean(root) && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	// Deepest first, so parents are empty by the time they come up.
+	slices.SortFunc(dirs, func(a, b string) int { return len(b) - len(a) })
+	for _, dir := range dirs {
+		os.Remove(longPath(dir))
+	}
+}
+
+// expectedCRC returns the CRC an entry claims. Central directory values are
+// used as is. The local header value is
+// trusted unless the data-descriptor flag is set and it is zero (the usual
+// placeholder), in which case the following data descriptor is consulted.
+func expectedCRC(buf []byte, h localHeader) (uint32, bool) {
+	if h.comp == zipMethodAES {
+		// AE-2 zeroes the CRC and relies on the HMAC instead.
+		if ae, ok := parseAESExtra(h.extra); ok && ae.version == 2 {
+			return 0, false
+		}
+	}
+	if h.exact {
+		return h.crc, true
 	}
+	if h.flags&zipFlagDataDesc == 0 || h.crc != 0 {
+		return h.crc, true
+	}
+	if dd, ok := findDataDescriptor(buf, h.dataOff); ok {
+		return dd.crc, true
+	}
+	return 0, false
+}
+
+const extraExtTime = 0x5455
+
+// entryModTime returns the modification time of an entry: the Unix mtime of
+// an extended timestamp extra field (0x5455), else the NTFS field's mtime,
+// else the DOS time from the header.
+func entryModTime(h localHeader) (time.Time, bool) {
+	if data, ok := extraField(h.extra, extraExtTime); ok && len(data) >= 5 && data[0]&1 != 0 {
+		return time.Unix(int64(int32(binary.LittleEndian.Uint32(data[1:5]))), 0), true
+	}
+	if mt, ok := ntfsModTime(h.extra); ok {
+		return mt, true
+	}
+	return DOSToTime(h.modTime, h.modDate)
+}
+
+const extraAsiUnix = 0x756e
 
-	return recovered, nil
+// entryMode returns the Unix permission bits of an entry from the central
+// directory's external attributes or an ASi Unix extra field (0x756e).
+func entryMode(h localHeader) (os.FileMode, bool) {
+	if h.mode != 0 {
+		return h.mode, true
+	}
+	if data, ok := extraField(h.extra, extraAsiUnix); ok && len(data) >= 6 {
+		if mode := os.FileMode(binary.LittleEndian.Uint16(data[4:6])).Perm(); mode != 0 {
+			return mode, true
+		}
+	}
+	return 0, false
+}
+
+type dataDescriptor struct {
+	off   int
+	crc   uint32
+	csize uint32
+	usize uint32
+}
+
+// findDataDescriptor looks for a signed data descriptor whose compressed size
+// equals its distance from dataOff, which pins it to this entry.
+func findDataDescriptor(buf []byte, dataOff int) (dataDescriptor, bool) {
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, zipSigDataDesc)
+	for pos := dataOff; pos+16 <= len(buf); {
+		i := bytes.Index(buf[pos:], sig)
+		if i < 0 {
+			break
+		}
+		at := pos + i
+		if at+16 > len(buf) {
+			break
+		}
+		csize := binary.LittleEndian.Uint32(buf[at+8 : at+12])
+		if int64(csize) == int64(at-dataOff) {
+			return dataDescriptor{
+				off:   at,
+				crc:   binary.LittleEndian.Uint32(buf[at+4 : at+8]),
+				csize: csize,
+				usize: binary.LittleEndian.Uint32(buf[at+12 : at+16]),
+			}, true
+		}
+		pos = at + 1
+	}
+	return dataDescriptor{}, false
+}
+
+// keepJunk writes a junk entry under cfg.KeepJunk and fills in its size and
+// CRC. The size guards apply as for regular entries.
+func keepJunk(cfg RecoverConfig, buf []byte, h localHeader, positions []int, idx int, rel string, ent *RecoveredEntry) error {
+	out := newSpillBuffer(cfg.MaxMemory, cfg.KeepJunk)
+	out.ctx = cfg.ctx
+	defer out.Close()
+	if sizeCap, _, capped := entrySizeCap(cfg, 0, compressedBound(buf, h, positions, idx)); capped {
+		out.setCap(sizeCap)
+	}
+	if err := readEntryData(buf, h, positions, idx, cfg.Password, out); err != nil && !errors.Is(err, errTruncated) {
+		return err
+	}
+	ent.Size = out.Len()
+	ent.CRC = out.Sum32()
+	target := longPath(filepath.Join(cfg.KeepJunk, rel))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return out.SaveAs(target)
+}
+
+// compressedBound is the compressed size of an entry, or an upper bound on it
+// when the header does not say: the distance to the next local header or the
+// end of the file.
+func compressedBound(buf []byte, h localHeader, positions []int, idx int) int64 {
+	if h.exact || (h.csize > 0 && h.flags&zipFlagDataDesc == 0) {
+		return int64(h.csize)
+	}
+	if h.flags&zipFlagDataDesc != 0 {
+		if dd, ok := findDataDescriptor(buf, h.dataOff); ok {
+			return int64(dd.csize)
+		}
+	}
+	for _, pos := range positions[idx+1:] {
+		if pos > h.dataOff {
+			return int64(pos - h.dataOff)
+		}
+	}
+	return int64(len(buf) - h.dataOff)
+}
+
+// entrySizeCap returns the tightest of the configured size limits for the
+// next entry and names it for the error message.
+func entrySizeCap(cfg RecoverConfig, written, csize int64) (int64, string, bool) {
+	sizeCap, reason, capped := int64(0), "", false
+	tighten := func(n int64, why string) {
+		if n < 0 {
+			n = 0
+		}
+		if !capped || n < sizeCap {
+			sizeCap, reason, capped = n, why, true
+		}
+	}
+	if cfg.MaxEntrySize > 0 {
+		tighten(cfg.MaxEntrySize, "max-entry-size")
+	}
+	if cfg.MaxRatio > 0 {
+		tighten(int64(cfg.MaxRatio*float64(max(csize, 1))), "max-ratio")
+	}
+	if cfg.MaxTotalSize > 0 {
+		tighten(cfg.MaxTotalSize-written, "max-total-size")
+	}
+	return sizeCap, reason, capped
+}
+
+func isZipData(data []byte) bool {
+	return len(data) >= 4 && binary.LittleEndian.Uint32(data[:4]) == zipSigLocal
+}
+
+// entryOrder returns the indexes into headers or positions in the order
+// recoverBuffer visits them. Offset order is archive order, which a central
+// directory does not always follow. Name order compares slash paths byte by
+// byte; headers that do not parse keep their offset order at the end.
+func entryOrder(cfg RecoverConfig, names *filenameDecoder, buf []byte, headers []localHeader, positions []int) []int {
+	total := len(headers)
+	if headers == nil {
+		total = len(positions)
+	}
+	order := make([]int, total)
+	for i := range order {
+		order[i] = i
+	}
+	if headers == nil && cfg.Order != OrderName {
+		return order
+	}
+	keys := make([]string, total)
+	valid := make([]bool, total)
+	offs := make([]int, total)
+	for i := range order {
+		h, ok := localHeader{}, false
+		if headers != nil {
+			h, ok = headers[i], headers[i].valid
+			offs[i] = h.off
+		} else {
+			h, ok = parseLocalHeader(memData(buf), positions[i], names)
+			offs[i] = positions[i]
+		}
+		if ok && cfg.UnpadNames {
+			h.fname, _ = unpadName(h.fname)
+		}
+		keys[i], valid[i] = strings.ReplaceAll(h.fname, "\\", "/"), ok
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if cfg.Order == OrderName {
+			if valid[a] != valid[b] {
+				if valid[a] {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(keys[a], keys[b]); c != 0 && valid[a] {
+				return c
+			}
+		}
+		return offs[a] - offs[b]
+	})
+	return order
+}
+
+// locateHeaders finds the entries of buf: from the central directory when
+// one checks out, otherwise as the positions of every local header
+// signature, left for the caller to parse.
+func locateHeaders(buf archiveData, forceScan bool, names *filenameDecoder) (string, []localHeader, []int) {
+	if !forceScan {
+		if _, cdEntries, ok := findCentralDir(buf); ok {
+			return SourceCentralDir, centralDirHeaders(buf, cdEntries, names), nil
+		}
+	}
+	return SourceScan, nil, scanLocalHeaders(buf)
+}
+
+func scanLocalHeaders(buf archiveData) []int {
+	positions := indexSig(buf, []byte{'P', 'K', 3, 4})
+	if positions == nil {
+		positions = make([]int, 0)
+	}
+	return positions
+}
+
+func readEntryData(buf []byte, h localHeader, positions []int, idx int, password string, out *spillBuffer) error {
+	if h.flags&zipFlagEncrypted != 0 {
+		return readEncryptedEntry(buf, h, positions, idx, password, out)
+	}
+	if h.exact {
+		if h.dataOff+int(h.csize) > len(buf) {
+			return fmt.Errorf("entry data at %d runs %d bytes past the end of the archive", h.dataOff, h.dataOff+int(h.csize)-len(buf))
+		}
+		data := buf[h.dataOff : h.dataOff+int(h.csize)]
+		switch h.comp {
+		case 0:
+			_, err := out.Write(data)
+			return err
+		case 8:
+			return inflateTo(out, data)
+		default:
+			return fmt.Errorf("unsupported compression method %d", h.comp)
+		}
+	}
+	switch {
+	case h.comp == 8 && h.flags&zipFlagDataDesc != 0:
+		// The descriptor's compressed size pins the exact range. A match
+		// that does not inflate was a coincidence inside the data.
+		if dd, ok := findDataDescriptor(buf, h.dataOff); ok {
+			err := inflateTo(out, buf[h.dataOff:dd.off])
+			if err == nil || errors.Is(err, errSizeLimit) {
+				return err
+			}
+			out.Reset()
+		}
+		return inflateIncremental(buf, h.dataOff, out)
+	case h.comp == 8:
+		return inflateIncremental(buf, h.dataOff, out)
+	case h.comp == 0 && h.flags&zipFlagDataDesc == 0:
+		end := h.dataOff + int(h.csize)
+		if end > len(buf) {
+			if _, err := out.Write(buf[h.dataOff:]); err != nil {
+				return err
+			}
+			return errTruncated
+		}
+		_, err := out.Write(buf[h.dataOff:end])
+		return err
+	case h.comp == 0:
+		end, ok := storedDataEnd(buf, h.dataOff, positions, idx)
+		if !ok {
+			return fmt.Errorf("stored entry with data descriptor has unknown size")
+		}
+		_, err := out.Write(buf[h.dataOff:end])
+		return err
+	default:
+		return fmt.Errorf("unsupported compression method %d", h.comp)
+	}
+}
+
+// storedDataEnd finds where a stored entry written with a data descriptor
+// ends. A signed descriptor pinned to dataOff wins; otherwise the data runs
+// up to the next local header, minus an unsigned descriptor if its size
+// field matches.
+func storedDataEnd(buf []byte, dataOff int, positions []int, idx int) (int, bool) {
+	if dd, ok := findDataDescriptor(buf, dataOff); ok {
+		return dd.off, true
+	}
+	next := -1
+	for _, pos := range positions[idx+1:] {
+		if pos >= dataOff {
+			next = pos
+			break
+		}
+	}
+	if next < 0 {
+		return 0, false
+	}
+	if at := next - 12; at >= dataOff {
+		csize := binary.LittleEndian.Uint32(buf[at+4 : at+8])
+		if int64(csize) == int64(at-dataOff) {
+			return at, true
+		}
+	}
+	return next, true
 }
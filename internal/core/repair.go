@@ -0,0 +1,249 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// RepairConfig controls RepairZip.
+type RepairConfig struct {
+	InZip     string
+	InParts   []string
+	OutZip    string
+	ForceScan bool
+	Encodings []string
+	// Junk lists glob patterns for entries to drop. nil means
+	// DefaultJunkPatterns.
+	Junk []string
+	// Logger, when set, receives the log records of the run, with levels
+	// and attributes, in place of the log callback.
+	Logger *slog.Logger
+}
+
+// RepairReport summarizes a repair run.
+type RepairReport struct {
+	Source  string `json:"source"`
+	Headers int    `json:"headers"`
+	Kept    int    `json:"kept"`
+	Junk    int    `json:"junk"`
+	Dropped int    `json:"dropped"`
+}
+
+// repairEntry is an entry of the rebuilt archive. data is the compressed
+// stream, copied as is.
+type repairEntry struct {
+	entry
+	data     []byte
+	dataDesc bool
+}
+
+// RepairZip rewrites a noisy archive as a standard ZIP. Junk and unreadable
+// entries are dropped; the compressed streams of the rest are copied byte
+// for byte behind fresh local headers, central directory and EOCD, so
+// nothing is decompressed and recompressed except to measure deflate
+// streams of unknown length.
+func RepairZip(cfg RepairConfig, progressCb func(done, total int, name string), logCb func(string)) (RepairReport, error) {
+	var rep RepairReport
+	lg := runLogger(cfg.Logger, logCb)
+	if err := validateGlobs(cfg.Junk); err != nil {
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("junk: %w", err))
+	}
+	names, err := newFilenameDecoder(cfg.Encodings, nil)
+	if err != nil {
+		return rep, withKind(ErrInvalidConfig, fmt.Errorf("encodings: %w", err))
+	}
+	buf, err := readRecoverInput(RecoverConfig{InZip: cfg.InZip, InParts: cfg.InParts})
+	if err != nil {
+		return rep, err
+	}
+
+	source, headers, positions := locateHeaders(memData(buf), cfg.ForceScan, names)
+	rep.Source = source
+	total := len(headers)
+	if source == SourceScan {
+		total = len(positions)
+	}
+	rep.Headers = total
+
+	var entries []repairEntry
+	covered := 0
+	for idx := 0; idx < total; idx++ {
+		var h localHeader
+		ok := false
+		if source == SourceCentralDir {
+			h = headers[idx]
+			ok = h.valid
+		} else {
+			h, ok = parseLocalHeader(memData(buf), positions[idx], names)
+		}
+		if progressCb != nil {
+			progressCb(idx+1, total, h.fname)
+		}
+		// A signature inside the data of an entry already kept is part of
+		// that stream, not a header.
+		if !ok || (source == SourceScan && h.off < covered) {
+			continue
+		}
+		rel, changes, ok := safeRelPath(h.fname, PathStrip)
+		if !ok {
+			rep.Dropped++
+			lg.Warn(fmt.Sprintf("Dropped %q: unsafe path", h.fname), "entry", h.fname, "offset", h.off)
+			continue
+		}
+		if len(changes) > 0 {
+			lg.Warn(fmt.Sprintf("Sanitized path: %q -> %q (%s)", h.fname, filepath.ToSlash(rel), strings.Join(changes, ", ")), "entry", h.fname, "path", filepath.ToSlash(rel))
+		}
+		if isJunkPath(cfg.Junk, rel) {
+			rep.Junk++
+			continue
+		}
+		ent, err := repairData(buf, h, positions, idx)
+		if err != nil {
+			rep.Dropped++
+			lg.Warn(fmt.Sprintf("Dropped %s: %v", h.fname, err), "entry", h.fname, "offset", h.off, "error", err)
+			continue
+		}
+		ent.name = []byte(filepath.ToSlash(rel))
+		if strings.HasSuffix(strings.ReplaceAll(h.fname, "\\", "/"), "/") {
+			ent.name = append(ent.name, '/')
+		}
+		if !isASCII(ent.name) && utf8.Valid(ent.name) {
+			ent.flags |= flagUTF8
+		}
+		ent.dosT, ent.dosD = h.modTime, h.modDate
+		entries = append(entries, ent)
+		covered = h.dataOff + len(ent.data)
+	}
+	rep.Kept = len(entries)
+
+	if err := writeRepaired(cfg.OutZip, entries); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
+
+// repairData finds the compressed stream of h with its real CRC and sizes.
+// Unencrypted entries lose the data descriptor flag since the new local
+// header carries the values; encrypted ones keep it because ZipCrypto
+// derives its password check byte from it.
+func repairData(buf []byte, h localHeader, positions []int, idx int) (repairEntry, error) {
+	ent := repairEntry{entry: entry{
+		flags:  h.flags &^ flagUTF8,
+		method: h.comp,
+		crc:    h.crc,
+		csize:  h.csize,
+		usize:  h.usize,
+	}}
+	encrypted := h.flags&zipFlagEncrypted != 0
+	if h.comp == zipMethodAES {
+		return ent, fmt.Errorf("AES entries keep their key data in an extra field, which repair does not write")
+	}
+	dataDesc := h.flags&zipFlagDataDesc != 0 && !h.exact
+	end := h.dataOff + int(h.csize)
+
+	switch {
+	case h.exact:
+	case !encrypted && h.comp == 8:
+		cw := &crcWriter{w: io.Discard}
+		n, err := inflateLen(cw, buf[h.dataOff:])
+		if err != nil {
+			return ent, fmt.Errorf("deflate stream: %w", err)
+		}
+		end = h.dataOff + n
+		ent.crc, ent.usize = cw.crc, cw.usize
+	case !encrypted && h.comp == 0 && dataDesc:
+		e, ok := storedDataEnd(buf, h.dataOff, positions, idx)
+		if !ok {
+			return ent, fmt.Errorf("stored entry has unknown size")
+		}
+		end = e
+	case dataDesc:
+		dd, ok := findDataDescriptor(buf, h.dataOff)
+		if !ok {
+			return ent, fmt.Errorf("no data descriptor")
+		}
+		end = dd.off
+		ent.crc, ent.usize = dd.crc, dd.usize
+	}
+	if end > len(buf) {
+		return ent, errTruncated
+	}
+	ent.data = buf[h.dataOff:end]
+	ent.csize = uint32(len(ent.data))
+	if !encrypted && h.comp == 0 {
+		ent.crc = crc32.ChecksumIEEE(ent.data)
+		ent.usize = ent.csize
+	}
+	if encrypted {
+		ent.dataDesc = h.flags&zipFlagDataDesc != 0
+	} else {
+		ent.flags &^= flagDataDesc
+	}
+	return ent, nil
+}
+
+func writeRepaired(outZip string, entries []repairEntry) (err error) {
+	if err := os.MkdirAll(filepath.Dir(outZip), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(outZip)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(outZip)
+		}
+	}()
+	bw := bufio.NewWriter(f)
+	out := &countingWriter{w: bw}
+
+	for i := range entries {
+		ent := &entries[i]
+		if out.n > 0xFFFFFFFF {
+			return fmt.Errorf("output exceeds 4 GiB, ZIP64 is not supported")
+		}
+		ent.offset = uint32(out.n)
+		if err := writeLocalHeader(out, &ent.entry, ent.crc, ent.csize, ent.usize); err != nil {
+			return err
+		}
+		if _, err := out.Write(ent.name); err != nil {
+			return err
+		}
+		if _, err := out.Write(ent.data); err != nil {
+			return err
+		}
+		if ent.dataDesc {
+			if err := writeDataDesc(out, &ent.entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	cdStart := out.n
+	for _, ent := range entries {
+		if err := writeCDir(out, ent.entry); err != nil {
+			return err
+		}
+		if _, err := out.Write(ent.name); err != nil {
+			return err
+		}
+	}
+	if out.n > 0xFFFFFFFF || len(entries) > 0xFFFF {
+		return fmt.Errorf("output exceeds ZIP limits, ZIP64 is not supported")
+	}
+	if err := writeEOCD(out, len(entries), out.n-cdStart, cdStart, 0); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,22 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A repair that fails part way leaves no partial output behind.
+func TestWriteRepairedRemovesPartialOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "repaired.zip")
+	entries := make([]repairEntry, 0x10000)
+	for i := range entries {
+		entries[i].name = []byte("f")
+	}
+	if err := writeRepaired(out, entries); err == nil {
+		t.Fatal("more entries than the EOCD can count were written")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("partial output left: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ObfuscationReport describes how an archive was mangled. It is written as a
+// sidecar JSON next to the archive when Config.ReportPath is set.
+type ObfuscationReport struct {
+	Archive             string        `json:"archive"`
+	Created             time.Time     `json:"created"`
+	Size                int64         `json:"size"`
+	Seeded              bool          `json:"seeded"`
+	Compression         string        `json:"compression"`
+	Encoding            string        `json:"encoding"`
+	RealEntries         int           `json:"realEntries"`
+	Duplicates          int           `json:"duplicates,omitempty"`
+	NoiseGenerator      string        `json:"noiseGenerator,omitempty"`
+	Noise               []ReportEntry `json:"noise,omitempty"`
+	Bait                []ReportEntry `json:"bait,omitempty"`
+	FixedTime           bool          `json:"fixedTime"`
+	PaddedNames         bool          `json:"paddedNames"`
+	PaddedNameLength    int           `json:"paddedNameLength,omitempty"`
+	PadBucket           int64         `json:"padBucket,omitempty"`
+	OverwriteCentralDir bool          `json:"overwriteCentralDir"`
+	CentralDir          ReportRange   `json:"centralDir"`
+	EOCDOffset          int64         `json:"eocdOffset"`
+	CommentJunk         *ReportRange  `json:"commentJunk,omitempty"`
+	PoisonTail          *ReportPoison `json:"poisonTail,omitempty"`
+}
+
+type ReportEntry struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+	CSize  uint32 `json:"csize"`
+	USize  uint32 `json:"usize"`
+}
+
+type ReportRange struct {
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+}
+
+type ReportPoison struct {
+	Offset   int64 `json:"offset"`
+	Size     int64 `json:"size"`
+	FakeEOCD int64 `json:"fakeEocd"`
+}
+
+type sealedReport struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+const reportKDFIterations = 600000
+
+func buildReport(cfg Config, entries []entry, layout zipLayout) ObfuscationReport {
+	rep := ObfuscationReport{
+		Archive:             cfg.OutZip,
+		Created:             time.Now().UTC(),
+		Size:                layout.size,
+		Seeded:              cfg.HasSeed,
+		Compression:         cfg.Compression,
+		Encoding:            cfg.Encoding,
+		FixedTime:           cfg.FixedTime,
+		PaddedNames:         cfg.PadNames,
+		PadBucket:           cfg.PadBucket,
+		OverwriteCentralDir: cfg.OverwriteCentralDir,
+		CentralDir:          ReportRange{Offset: layout.cdStart, Size: layout.cdSize},
+		EOCDOffset:          layout.eocdOffset,
+	}
+	if cfg.NoiseFiles > 0 {
+		rep.NoiseGenerator = cfg.NoiseGenerator
+		if rep.NoiseGenerator == "" {
+			rep.NoiseGenerator = DefaultNoiseGenerator
+		}
+	}
+	for _, ent := range entries {
+		re := ReportEntry{Name: ent.label, Offset: int64(ent.offset), CSize: ent.csize, USize: ent.usize}
+		switch ent.kind {
+		case entryNoise:
+			rep.Noise = append(rep.Noise, re)
+		case entryBait:
+			rep.Bait = append(rep.Bait, re)
+		default:
+			rep.RealEntries++
+			if ent.dup {
+				rep.Duplicates++
+			}
+		}
+		if cfg.PadNames {
+			rep.PaddedNameLength = len(ent.name)
+		}
+	}
+	if cfg.CommentSize > 0 {
+		rep.CommentJunk = &ReportRange{Offset: layout.commentOffset, Size: int64(cfg.CommentSize)}
+	}
+	if cfg.OverwriteCentralDir {
+		rep.PoisonTail = &ReportPoison{Offset: layout.poisonOffset, Size: layout.poisonSize, FakeEOCD: layout.fakeEOCD}
+	}
+	return rep
+}
+
+func writeReport(path, password string, rep ObfuscationReport) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	if password != "" {
+		sealed, err := sealReport(data, password)
+		if err != nil {
+			return err
+		}
+		if data, err = json.MarshalIndent(sealed, "", "  "); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// ReadReport loads a sidecar written by RunEncrypt. password is required when
+// the report was encrypted and ignored otherwise.
+func ReadReport(path, password string) (ObfuscationReport, error) {
+	var rep ObfuscationReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rep, err
+	}
+	var sealed sealedReport
+	if err := json.Unmarshal(data, &sealed); err == nil && sealed.KDF != "" {
+		if password == "" {
+			return rep, withKind(ErrInvalidConfig, errors.New("report is encrypted, password required"))
+		}
+		if data, err = openReport(sealed, password); err != nil {
+			return rep, err
+		}
+	}
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return rep, fmt.Errorf("parse report: %w", err)
+	}
+	return rep, nil
+}
+
+func sealReport(plain []byte, password string) (sealedReport, error) {
+	salt := make([]byte, 16)
+	if _, err := crand.Read(salt); err != nil {
+		return sealedReport{}, err
+	}
+	gcm, err := reportCipher(password, salt, reportKDFIterations)
+	if err != nil {
+		return sealedReport{}, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := crand.Read(nonce); err != nil {
+		return sealedReport{}, err
+	}
+	return sealedReport{
+		KDF:        "pbkdf2-sha256",
+		Iterations: reportKDFIterations,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plain, nil),
+	}, nil
+}
+
+func openReport(sealed sealedReport, password string) ([]byte, error) {
+	if sealed.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported report kdf %q", sealed.KDF)
+	}
+	gcm, err := reportCipher(password, sealed.Salt, sealed.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, nil)
+	if err != nil {
+		return nil, withKind(ErrVerification, errors.New("wrong password or corrupted report"))
+	}
+	return plain, nil
+}
+
+func reportCipher(password string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
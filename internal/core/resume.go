@@ -0,0 +1,261 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// encryptCheckpoint is the state of an archive run saved to Config.Resume:
+// the real entries staged so far, by source path, and once writing has
+// begun, every entry in archive order with how many of them are in the
+// output and where it ends. The source, output and the settings that shape
+// staged entries must match for a resume.
+type encryptCheckpoint struct {
+	Source   string                 `json:"source"`
+	Output   string                 `json:"output"`
+	Settings string                 `json:"settings"`
+	Staged   map[string]stagedEntry `json:"staged"`
+	Entries  []stagedEntry          `json:"entries,omitempty"`
+	Written  int                    `json:"written"`
+	Offset   int64                  `json:"offset"`
+
+	path  string
+	saved time.Time
+}
+
+// stagedEntry is an entry as the checkpoint keeps it, with the size and
+// timestamp of its source file for real entries.
+type stagedEntry struct {
+	Size     int64     `json:"size,omitempty"`
+	Modified time.Time `json:"modified,omitzero"`
+
+	Name   []byte `json:"name"`
+	Label  string `json:"label"`
+	Rel    string `json:"rel,omitempty"`
+	Kind   int    `json:"kind"`
+	Flags  uint16 `json:"flags"`
+	Method uint16 `json:"method"`
+	DosT   uint16 `json:"dosTime"`
+	DosD   uint16 `json:"dosDate"`
+	CRC    uint32 `json:"crc"`
+	CSize  uint32 `json:"csize"`
+	USize  uint32 `json:"usize"`
+	At     uint32 `json:"offset"`
+	Tmp    string `json:"tmp"`
+	Sum    []byte `json:"sum,omitempty"`
+	Dup    bool   `json:"dup,omitempty"`
+}
+
+func toStaged(ent entry) stagedEntry {
+	return stagedEntry{
+		Name: ent.name, Label: ent.label, Rel: ent.rel, Kind: int(ent.kind),
+		Flags: ent.flags, Method: ent.method, DosT: ent.dosT, DosD: ent.dosD,
+		CRC: ent.crc, CSize: ent.csize, USize: ent.usize, At: ent.offset, Tmp: ent.tmp,
+		Sum: ent.sum, Dup: ent.dup,
+	}
+}
+
+func (s stagedEntry) entry() entry {
+	return entry{
+		name: s.Name, label: s.Label, rel: s.Rel, kind: entryKind(s.Kind),
+		flags: s.Flags, method: s.Method, dosT: s.DosT, dosD: s.DosD,
+		crc: s.CRC, csize: s.CSize, usize: s.USize, offset: s.At, tmp: s.Tmp,
+		sum: s.Sum, dup: s.Dup,
+	}
+}
+
+// resumeDir is where a run with Config.Resume stages its entries, kept
+// until the archive is written.
+func resumeDir(path string) string {
+	return path + ".files"
+}
+
+func newEncryptCheckpoint(path string, cfg Config) (*encryptCheckpoint, error) {
+	src, err := filepath.Abs(cfg.SrcDir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := filepath.Abs(cfg.OutZip)
+	if err != nil {
+		return nil, err
+	}
+	settings := fmt.Sprintf("%s level %d, strategy %s, %s names, fixed time %t, methods %v",
+		cfg.Compression, cfg.Level, cfg.Strategy, cfg.Encoding, cfg.FixedTime, cfg.FileMethods)
+	if cfg.Dedup {
+		// Files staged without it have no sums to compare.
+		settings += ", dedup"
+	}
+	return &encryptCheckpoint{
+		Source:   src,
+		Output:   out,
+		Settings: settings,
+		Staged:   make(map[string]stagedEntry),
+		path:     path,
+		saved:    time.Now(),
+	}, nil
+}
+
+// loadEncryptCheckpoint returns the state saved at cp.path, or cp itself
+// when there is none. One made for another source, output or settings is
+// an error rather than silently discarded.
+func loadEncryptCheckpoint(cp *encryptCheckpoint) (*encryptCheckpoint, bool, error) {
+	data, err := os.ReadFile(cp.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var saved encryptCheckpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, false, fmt.Errorf("resume %s: %w", cp.path, err)
+	}
+	if saved.Source != cp.Source || saved.Output != cp.Output || saved.Settings != cp.Settings {
+		return nil, false, fmt.Errorf("resume %s was made for another source, output or settings; delete it and %s to start over", cp.path, resumeDir(cp.path))
+	}
+	if saved.Staged == nil {
+		saved.Staged = make(map[string]stagedEntry)
+	}
+	saved.path = cp.path
+	saved.saved = time.Now()
+	return &saved, true, nil
+}
+
+// reuse returns the staged entry of it when the file is unchanged since and
+// its temp file is whole, cutting off any padding a stopped run added.
+func (cp *encryptCheckpoint) reuse(it fileItem) (entry, bool) {
+	s, ok := cp.Staged[it.rel]
+	if !ok || s.Size != it.size || !s.Modified.Equal(it.modTime) || s.Label != it.name {
+		return entry{}, false
+	}
+	fi, err := os.Stat(s.Tmp)
+	if err != nil || fi.Size() < int64(s.CSize) {
+		return entry{}, false
+	}
+	if fi.Size() > int64(s.CSize) {
+		if err := os.Truncate(s.Tmp, int64(s.CSize)); err != nil {
+			return entry{}, false
+		}
+	}
+	return s.entry(), true
+}
+
+// stage records the staged entry of it and writes the checkpoint when the
+// last write is old enough.
+func (cp *encryptCheckpoint) stage(it fileItem, ent entry) error {
+	s := toStaged(ent)
+	s.Size, s.Modified = it.size, it.modTime
+	cp.Staged[it.rel] = s
+	if time.Since(cp.saved) < checkpointInterval {
+		return nil
+	}
+	return cp.save()
+}
+
+// startWrite records the final entries, padded and in archive order, so a
+// resumed run writes exactly them.
+func (cp *encryptCheckpoint) startWrite(entries []entry) error {
+	cp.Entries = make([]stagedEntry, len(entries))
+	for i, ent := range entries {
+		cp.Entries[i] = toStaged(ent)
+	}
+	cp.Written, cp.Offset = 0, 0
+	return cp.save()
+}
+
+func (cp *encryptCheckpoint) entries() []entry {
+	entries := make([]entry, len(cp.Entries))
+	for i, s := range cp.Entries {
+		entries[i] = s.entry()
+	}
+	return entries
+}
+
+// save writes the checkpoint through a temp file so a crash never leaves a
+// half-written one behind.
+func (cp *encryptCheckpoint) save() error {
+	if err := saveJSONAtomic(cp.path, cp); err != nil {
+		return err
+	}
+	cp.saved = time.Now()
+	return nil
+}
+
+// abandon saves the state of a run that failed or was stopped. Until the
+// write has begun, the temp files of noise, bait and anything not recorded
+// as staged are removed: a resumed run makes them again.
+func (cp *encryptCheckpoint) abandon(results []entry) {
+	if cp.Entries == nil {
+		for _, ent := range results {
+			if ent.tmp == "" {
+				continue
+			}
+			if s, ok := cp.Staged[ent.rel]; ent.kind != entryReal || !ok || s.Tmp != ent.tmp {
+				os.Remove(ent.tmp)
+			}
+		}
+	}
+	cp.save()
+}
+
+// remove deletes the checkpoint and its staged files once the archive is
+// written.
+func (cp *encryptCheckpoint) remove() {
+	os.Remove(cp.path)
+	os.RemoveAll(resumeDir(cp.path))
+}
+
+// writeZipResume writes entries to outZip from entry cp.Written on, at
+// cp.Offset, keeping the checkpoint up to date as it goes. Unlike writeZip
+// it leaves the partial file in place on failure, for the next run.
+func writeZipResume(ctx context.Context, randReader io.Reader, outZip string, entries []entry, cp *encryptCheckpoint, bufSize int, overwriteCentralDir bool, commentSize int) (_ zipLayout, err error) {
+	var layout zipLayout
+	if err := os.MkdirAll(filepath.Dir(outZip), 0o755); err != nil {
+		return layout, err
+	}
+	f, err := os.OpenFile(outZip, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return layout, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if fi, err := f.Stat(); err != nil {
+		return layout, err
+	} else if fi.Size() < cp.Offset {
+		return layout, fmt.Errorf("%s is shorter than %s records; delete it to start over", outZip, cp.path)
+	}
+	if err := f.Truncate(cp.Offset); err != nil {
+		return layout, err
+	}
+	if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+		return layout, err
+	}
+	wrote := func(done int, offset int64) error {
+		cp.Written, cp.Offset = done, offset
+		if time.Since(cp.saved) < checkpointInterval {
+			return nil
+		}
+		// What the checkpoint claims must be on disk first.
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		return cp.save()
+	}
+	layout, err = writeZipFrom(ctx, randReader, f, entries, cp.Written, cp.Offset, bufSize, overwriteCentralDir, commentSize, wrote)
+	if err != nil {
+		if serr := f.Sync(); serr == nil {
+			cp.save()
+		}
+	}
+	return layout, err
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stopAfter is a context whose Err starts failing after n calls, so a
+// write stops after its first n entries as if it were interrupted.
+type stopAfter struct {
+	context.Context
+	n int
+}
+
+func (c *stopAfter) Err() error {
+	if c.n == 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+// stageTestEntries stages files named f0.txt, f1.txt, ... with contents,
+// in temp files under tmpDir or in memory when it is "".
+func stageTestEntries(t *testing.T, tmpDir string, contents []string) []entry {
+	t.Helper()
+	encName, nameFlag, err := NewNameEncoder("utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make([]entry, len(contents))
+	for i, content := range contents {
+		name := fmt.Sprintf("f%d.txt", i)
+		entries[i], err = compressReader(context.Background(), tmpDir, 0, name, time.Time{}, strings.NewReader(content),
+			encName, nameFlag, compressorFor(true), 6, "default", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return entries
+}
+
+func TestWriteZipResumeAfterInterruption(t *testing.T) {
+	var contents []string
+	for i := range 8 {
+		contents = append(contents, strings.Repeat(fmt.Sprintf("entry %d ", i), 500+i*100))
+	}
+	for _, overwriteCentralDir := range []bool{false, true} {
+		t.Run(fmt.Sprintf("overwriteCentralDir=%t", overwriteCentralDir), func(t *testing.T) {
+			dir := t.TempDir()
+
+			want := filepath.Join(dir, "want.zip")
+			_, err := writeZip(context.Background(), SeededRandSource(1), want, stageTestEntries(t, "", contents), 1, 0, overwriteCentralDir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := Config{SrcDir: dir, OutZip: filepath.Join(dir, "out.zip")}
+			cp, err := newEncryptCheckpoint(filepath.Join(dir, "run.resume"), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tmpDir := resumeDir(cp.path)
+			if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			entries := stageTestEntries(t, tmpDir, contents)
+			if err := cp.startWrite(entries); err != nil {
+				t.Fatal(err)
+			}
+			const written = 3
+			_, err = writeZipResume(&stopAfter{Context: context.Background(), n: written}, SeededRandSource(1), cfg.OutZip, entries, cp, 0, overwriteCentralDir, 0)
+			if err == nil {
+				t.Fatal("interrupted write succeeded")
+			}
+
+			cp, ok, err := loadEncryptCheckpoint(&encryptCheckpoint{Source: cp.Source, Output: cp.Output, Settings: cp.Settings, path: cp.path})
+			if err != nil || !ok {
+				t.Fatalf("load checkpoint: %v, %t", err, ok)
+			}
+			if cp.Written != written {
+				t.Fatalf("checkpoint has %d entries written, want %d", cp.Written, written)
+			}
+			layout, err := writeZipResume(context.Background(), SeededRandSource(1), cfg.OutZip, cp.entries(), cp, 0, overwriteCentralDir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(cfg.OutZip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantData, err := os.ReadFile(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, wantData) {
+				t.Fatal("resumed archive differs from one written in one go")
+			}
+			// archive/zip stops at the poison tail; the archive before it
+			// must be whole.
+			if overwriteCentralDir {
+				got = got[:layout.poisonOffset]
+			}
+			checkZipContents(t, got, contents)
+		})
+	}
+}
+
+// checkZipContents reads archive with archive/zip and checks it holds
+// f0.txt, f1.txt, ... with contents.
+func checkZipContents(t *testing.T, archive []byte, contents []string) {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(contents) {
+		t.Fatalf("%d entries, want %d", len(r.File), len(contents))
+	}
+	for i, f := range r.File {
+		if want := fmt.Sprintf("f%d.txt", i); f.Name != want {
+			t.Fatalf("entry %d is %s, want %s", i, f.Name, want)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if string(data) != contents[i] {
+			t.Fatalf("%s: wrong content", f.Name)
+		}
+	}
+}
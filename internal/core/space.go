@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkSpace fails when the file system holding dir has less than need
+// bytes free. Where free space cannot be read, it passes.
+func checkSpace(dir string, need int64, what string) error {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	free, ok := FreeSpace(dir)
+	if !ok || need <= 0 || uint64(need) <= free {
+		return nil
+	}
+	return withKind(ErrNoSpace, fmt.Errorf("not enough space for %s in %s: about %s needed, %s free", what, dir, FormatBytes(need), FormatBytes(int64(free))))
+}
+
+// checkWriteSpace checks the temp directory and, when the archive goes to
+// a file, the output ZIP's directory against the estimated size of the
+// archive. The temp files are only removed once the archive is written,
+// so when both are on one file system it must hold the two at once.
+func checkWriteSpace(cfg Config, estimate int64, toFile bool) error {
+	if cfg.TmpDir != "" {
+		fi, err := os.Stat(cfg.TmpDir)
+		if err != nil {
+			return fmt.Errorf("tmp-dir: %w", err)
+		}
+		if !fi.IsDir() {
+			return withKind(ErrInvalidConfig, fmt.Errorf("tmp-dir: %s is not a directory", cfg.TmpDir))
+		}
+	}
+	tmpDir := cfg.TmpDir
+	if tmpDir == "" {
+		tmpDir = os.TempDir()
+	}
+	outDir := filepath.Dir(cfg.OutZip)
+	if toFile && sameFileSystem(tmpDir, outDir) {
+		return checkSpace(outDir, 2*estimate, "temp files and the output ZIP (set -tmp-dir)")
+	}
+	if err := checkSpace(tmpDir, estimate, "temp files (set -tmp-dir)"); err != nil {
+		return err
+	}
+	if !toFile {
+		return nil
+	}
+	return checkSpace(outDir, estimate, "the output ZIP")
+}
+
+// FormatBytes renders n in binary units, such as 1.5 MiB.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
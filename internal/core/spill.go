@@ -0,0 +1,214 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// spillBuffer collects the decoded data of one entry. Up to limit bytes are
+// kept in memory; past that the data moves to a temp file in dir, or, when
+// dir is empty (list mode), is only counted and hashed. A limit of 0 keeps
+// everything in memory.
+type spillBuffer struct {
+	limit int64
+	dir   string
+	// sizeCap, when capped, is the most decoded bytes accepted before
+	// Write fails with errSizeLimit.
+	sizeCap int64
+	capped  bool
+	// ctx, when set, makes Write fail once it is done, so a long decode
+	// stops promptly on cancellation.
+	ctx context.Context
+
+	mem       []byte
+	head      []byte
+	file      *os.File
+	discarded bool
+	size      int64
+	crc       uint32
+	sha       hash.Hash
+	digest    hash.Hash
+}
+
+const spillHeadSize = 8
+
+func newSpillBuffer(limit int64, dir string) *spillBuffer {
+	return &spillBuffer{limit: limit, dir: dir}
+}
+
+// errSizeLimit stops decoding of an entry that grows past its size cap.
+var errSizeLimit = errors.New("size limit exceeded")
+
+// hashSHA256 makes the buffer also keep a SHA-256 of the data.
+func (b *spillBuffer) hashSHA256() {
+	b.sha = sha256.New()
+}
+
+// SHA256 returns the hex digest, or "" when hashing was not enabled.
+func (b *spillBuffer) SHA256() string {
+	if b.sha == nil {
+		return ""
+	}
+	return hex.EncodeToString(b.sha.Sum(nil))
+}
+
+// digestWith makes the buffer also feed the data to h, for
+// RecoverConfig.Digest.
+func (b *spillBuffer) digestWith(h hash.Hash) {
+	b.digest = h
+}
+
+// Digest returns the hex digest of h, or "" when none was set.
+func (b *spillBuffer) Digest() string {
+	if b.digest == nil {
+		return ""
+	}
+	return hex.EncodeToString(b.digest.Sum(nil))
+}
+
+func (b *spillBuffer) setCap(n int64) {
+	b.sizeCap = n
+	b.capped = true
+}
+
+func (b *spillBuffer) Write(p []byte) (int, error) {
+	if b.ctx != nil {
+		if err := b.ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	if b.capped && b.size+int64(len(p)) > b.sizeCap {
+		return 0, errSizeLimit
+	}
+	if n := spillHeadSize - len(b.head); n > 0 {
+		b.head = append(b.head, p[:min(n, len(p))]...)
+	}
+	if b.file == nil && !b.discarded {
+		if b.limit > 0 && int64(len(b.mem))+int64(len(p)) > b.limit {
+			if err := b.spill(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	switch {
+	case b.file != nil:
+		if _, err := b.file.Write(p); err != nil {
+			return 0, err
+		}
+	case !b.discarded:
+		b.mem = append(b.mem, p...)
+	}
+	b.size += int64(len(p))
+	b.crc = crc32.Update(b.crc, crc32.IEEETable, p)
+	if b.sha != nil {
+		b.sha.Write(p)
+	}
+	if b.digest != nil {
+		b.digest.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *spillBuffer) spill() error {
+	if b.dir == "" {
+		b.discarded = true
+		b.mem = nil
+		return nil
+	}
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(b.dir, ".recover-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b.mem); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	b.file = f
+	b.mem = nil
+	return nil
+}
+
+// Reset drops everything written so far, including a temp file.
+func (b *spillBuffer) Reset() {
+	b.Close()
+	b.mem = b.mem[:0]
+	b.head = b.head[:0]
+	b.discarded = false
+	b.size = 0
+	b.crc = 0
+	if b.sha != nil {
+		b.sha.Reset()
+	}
+	if b.digest != nil {
+		b.digest.Reset()
+	}
+}
+
+func (b *spillBuffer) Len() int64    { return b.size }
+func (b *spillBuffer) Sum32() uint32 { return b.crc }
+func (b *spillBuffer) Head() []byte  { return b.head }
+
+// Bytes returns the data when all of it is held in memory.
+func (b *spillBuffer) Bytes() ([]byte, bool) {
+	if b.file != nil || b.discarded {
+		return nil, false
+	}
+	return b.mem, true
+}
+
+// SaveAs writes the data to target, moving the temp file into place when
+// the data was spilled.
+func (b *spillBuffer) SaveAs(target string) error {
+	if b.file == nil {
+		if b.discarded {
+			return errors.New("entry data was not kept")
+		}
+		return os.WriteFile(target, b.mem, 0o644)
+	}
+	name := b.file.Name()
+	if err := b.file.Close(); err != nil {
+		return err
+	}
+	b.file = nil
+	if err := os.Rename(name, target); err != nil {
+		os.Remove(name)
+		return err
+	}
+	return os.Chmod(target, 0o644)
+}
+
+// WriteTo copies the data to w, reading it back from the temp file when it
+// was spilled.
+func (b *spillBuffer) WriteTo(w io.Writer) (int64, error) {
+	if b.file == nil {
+		if b.discarded {
+			return 0, errors.New("entry data was not kept")
+		}
+		n, err := w.Write(b.mem)
+		return int64(n), err
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, b.file)
+}
+
+// Close removes a temp file that was not saved.
+func (b *spillBuffer) Close() {
+	if b.file != nil {
+		name := b.file.Name()
+		b.file.Close()
+		os.Remove(name)
+		b.file = nil
+	}
+}
@@ -0,0 +1,92 @@
+package core
+
+import "time"
+
+// EncryptStats totals a RunEncryptStats run.
+type EncryptStats struct {
+	// Entries is every entry written: Files plus noise and bait.
+	Entries      int `json:"entries"`
+	Files        int `json:"files"`
+	NoiseEntries int `json:"noiseEntries"`
+	BaitEntries  int `json:"baitEntries"`
+	// InputBytes is the source data read; DataBytes what it takes in the
+	// archive, padding included.
+	InputBytes int64 `json:"inputBytes"`
+	DataBytes  int64 `json:"dataBytes"`
+	// Ratio is DataBytes as a fraction of InputBytes.
+	Ratio      float64 `json:"ratio"`
+	NoiseBytes int64   `json:"noiseBytes"`
+	BaitBytes  int64   `json:"baitBytes"`
+	// Duplicates counts the files that, with Config.Dedup, share the data
+	// of an identical earlier file; DuplicateBytes is what their data
+	// would have taken, left out of DataBytes.
+	Duplicates     int   `json:"duplicates"`
+	DuplicateBytes int64 `json:"duplicateBytes"`
+	// OutputBytes is the size of the archive written.
+	OutputBytes int64         `json:"outputBytes"`
+	Duration    time.Duration `json:"durationNs"`
+	Workers     []WorkerStats `json:"workers"`
+	// Results lists every entry in archive order, noise and bait
+	// included, as written.
+	Results []EntryResult `json:"results"`
+
+	// tempBytes is the data that went through temp files, for
+	// MetricTempBytes: not what was staged in memory or copied from the
+	// source file.
+	tempBytes int64
+}
+
+// WorkerStats is what one compression worker did.
+type WorkerStats struct {
+	Files int           `json:"files"`
+	Bytes int64         `json:"bytes"`
+	Busy  time.Duration `json:"busyNs"`
+	// Throughput is Bytes per second of Busy.
+	Throughput float64 `json:"throughput"`
+}
+
+// tally fills in the totals of s from the entries written.
+func (s *EncryptStats) tally(entries []entry, layout zipLayout, start time.Time) {
+	s.Entries = len(entries)
+	s.Results = make([]EntryResult, 0, len(entries))
+	for _, e := range entries {
+		s.Results = append(s.Results, EntryResult{
+			Path:       e.rel,
+			Name:       e.label,
+			Size:       int64(e.usize),
+			Compressed: int64(e.csize),
+			CRC:        e.crc,
+			Method:     e.method,
+			Offset:     int64(e.offset),
+			Noise:      e.kind != entryReal,
+		})
+		if !e.dup && e.data == nil && e.src == "" {
+			s.tempBytes += int64(e.csize)
+		}
+		switch e.kind {
+		case entryReal:
+			if e.dup {
+				s.Duplicates++
+				s.DuplicateBytes += int64(e.csize)
+				continue
+			}
+			s.DataBytes += int64(e.csize)
+		case entryNoise:
+			s.NoiseEntries++
+			s.NoiseBytes += int64(e.csize)
+		case entryBait:
+			s.BaitEntries++
+			s.BaitBytes += int64(e.csize)
+		}
+	}
+	if s.InputBytes > 0 {
+		s.Ratio = float64(s.DataBytes) / float64(s.InputBytes)
+	}
+	for i := range s.Workers {
+		if w := &s.Workers[i]; w.Busy > 0 {
+			w.Throughput = float64(w.Bytes) / w.Busy.Seconds()
+		}
+	}
+	s.OutputBytes = layout.size
+	s.Duration = time.Since(start)
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"archive/tar"
+	"time"
+)
+
+// writeTarFile appends a recovered file to tw under the slash path name.
+func writeTarFile(tw *tar.Writer, name string, h localHeader, modTime time.Time, data *spillBuffer) error {
+	mode, ok := entryMode(h)
+	if !ok {
+		mode = 0o644
+	}
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode.Perm()),
+		Size:     data.Len(),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := data.WriteTo(tw)
+	return err
+}
+
+// writeTarLink appends name as a hard link to target, an entry written
+// before with the same content.
+func writeTarLink(tw *tar.Writer, name, target string, h localHeader, modTime time.Time) error {
+	mode, ok := entryMode(h)
+	if !ok {
+		mode = 0o644
+	}
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	return tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeLink,
+		Name:     name,
+		Linkname: target,
+		Mode:     int64(mode.Perm()),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+}
+
+// writeTarDir appends a directory entry to tw.
+func writeTarDir(tw *tar.Writer, name string, modTime time.Time) error {
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	return tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0o755,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+}
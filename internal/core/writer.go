@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Writer builds a noisy archive entry by entry, for data that is not laid
+// out in a directory: database dumps, generated content and the like.
+// Entries are staged in temp files as they are added and the archive is
+// written to the underlying io.Writer by Close, which must always be
+// called, after a failure too, to remove them. A Writer is not safe for
+// concurrent use.
+//
+// Of Config, the source options (SrcDir, Files, FileOrder, FileMethods,
+// IncludeHidden, MaxDepth, the entry hooks and Events), Resume and Workers
+// do not apply, nor does NoiseFiles: noise goes where AddNoise puts it.
+type Writer struct {
+	ctx context.Context
+	cfg Config
+	out io.Writer
+
+	encName    func(string) ([]byte, error)
+	nameFlag   uint16
+	comp       Compressor
+	randReader io.Reader
+	metrics    Metrics
+	start      time.Time
+	noiseGen   NoiseGenerator
+	noise      int
+
+	entries []entry
+	items   []fileItem // the added files, which bait is matched against
+	names   map[string]bool
+	closed  bool
+}
+
+// NewWriter starts an archive written to w when the Writer is closed.
+// Zero Compression, Strategy, DictSize and Encoding mean deflate, default,
+// 32768 and utf-8. Level is taken as given, as by RunEncrypt, so 0 is
+// deflate level 0, which stores the data in uncompressed blocks.
+func NewWriter(ctx context.Context, w io.Writer, cfg Config) (*Writer, error) {
+	if w == nil {
+		return nil, withKind(ErrInvalidConfig, errors.New("no output writer"))
+	}
+	if cfg.Compression == "" {
+		cfg.Compression = CompressDeflate
+	}
+	if cfg.Strategy == "" {
+		cfg.Strategy = "default"
+	}
+	if cfg.DictSize == 0 {
+		cfg.DictSize = 32768
+	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = "utf-8"
+	}
+	if err := normalizeConfig(&cfg); err != nil {
+		return nil, withKind(ErrInvalidConfig, err)
+	}
+	encName, nameFlag, err := NewNameEncoder(cfg.Encoding)
+	if err != nil {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("encoding: %w", err))
+	}
+	zw := &Writer{
+		ctx:        ctx,
+		cfg:        cfg,
+		out:        w,
+		encName:    encName,
+		nameFlag:   nameFlag,
+		randReader: runRandSource(cfg),
+		metrics:    runMetrics(cfg.Metrics),
+		start:      time.Now(),
+		names:      make(map[string]bool),
+	}
+	if zw.comp, err = lookupCompressor(cfg.Compression); err != nil {
+		return nil, withKind(ErrInvalidConfig, err)
+	}
+	if zw.cfg.TmpDir, err = runTempDir(cfg.TmpDir); err != nil {
+		return nil, err
+	}
+	return zw, nil
+}
+
+// AddFile adds the file at path as the entry name; "" means its base name.
+func (zw *Writer) AddFile(path, name string) error {
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return zw.AddReader(name, f, info.ModTime())
+}
+
+// AddReader adds the data of r, up to EOF, as the entry name with the
+// timestamp modified.
+func (zw *Writer) AddReader(name string, r io.Reader, modified time.Time) error {
+	if err := zw.check(name); err != nil {
+		return err
+	}
+	began := time.Now()
+	ent, err := compressReader(zw.ctx, zw.cfg.TmpDir, zw.cfg.BufferSize, name, modified, r, zw.encName, zw.nameFlag, zw.comp, zw.cfg.Level, zw.cfg.Strategy, zw.cfg.FixedTime)
+	if err != nil {
+		return fmt.Errorf("compress %s: %w", name, err)
+	}
+	ent.rel = name
+	zw.metrics.Add(MetricFilesCompressed, 1)
+	zw.metrics.Add(MetricBytesIn, int64(ent.usize))
+	zw.metrics.Observe(MetricCompressTime, time.Since(began))
+	zw.entries = append(zw.entries, ent)
+	zw.items = append(zw.items, fileItem{index: len(zw.items), rel: name, name: name, size: int64(ent.usize), modTime: modified})
+	zw.names[name] = true
+	return nil
+}
+
+// AddNoise adds n junk entries of NoiseSize bytes, made by NoiseGenerator,
+// after the entries added so far.
+func (zw *Writer) AddNoise(n int) error {
+	if zw.closed {
+		return errors.New("writer is closed")
+	}
+	if n < 0 {
+		return withKind(ErrInvalidConfig, fmt.Errorf("noise entries must be >= 0"))
+	}
+	if zw.noiseGen == nil && n > 0 {
+		factory, err := lookupNoiseGenerator(zw.cfg.NoiseGenerator)
+		if err != nil {
+			return withKind(ErrInvalidConfig, err)
+		}
+		zw.noiseGen = factory(zw.randReader)
+	}
+	for i := 0; i < n; i++ {
+		if err := zw.ctx.Err(); err != nil {
+			return fmt.Errorf("noise: %w", err)
+		}
+		name := zw.noiseGen.Name(zw.noise)
+		zw.noise++
+		ent, err := makeNoiseEntry(zw.cfg.TmpDir, zw.noiseGen, name, zw.encName, zw.nameFlag, zw.comp, zw.cfg.Level, zw.cfg.Strategy, zw.cfg.FixedTime, zw.cfg.NoiseSize, time.Unix(0, 0))
+		if err != nil {
+			return fmt.Errorf("noise: %w", err)
+		}
+		zw.entries = append(zw.entries, ent)
+		zw.names[name] = true
+	}
+	return nil
+}
+
+// Close adds the bait entries if Config.Bait is set, pads, and writes the
+// archive, then the report if Config.ReportPath is set. The temp files are
+// removed whether it succeeds or not; w is not closed.
+func (zw *Writer) Close() error {
+	if zw.closed {
+		return errors.New("writer is closed")
+	}
+	zw.closed = true
+	defer os.RemoveAll(zw.cfg.TmpDir)
+	if err := zw.ctx.Err(); err != nil {
+		return err
+	}
+	if len(zw.entries) == 0 {
+		return ErrNoFiles
+	}
+	cfg := zw.cfg
+	if cfg.Bait {
+		baits, _, err := makeBaitEntries(cfg.TmpDir, zw.randReader, zw.items, zw.encName, zw.nameFlag, zw.comp, cfg.Level, cfg.Strategy, cfg.FixedTime)
+		if err != nil {
+			return err
+		}
+		zw.entries = append(zw.entries, baits...)
+	}
+	lg := runLogger(cfg.Logger, nil)
+	if err := applyPadding(zw.randReader, cfg, zw.entries, lg, eventSink{}.warner(lg)); err != nil {
+		return err
+	}
+	layout, err := writeZipTo(zw.ctx, zw.randReader, zw.out, zw.entries, cfg.BufferSize, cfg.OverwriteCentralDir, cfg.CommentSize)
+	if err != nil {
+		return fmt.Errorf("write zip: %w", err)
+	}
+	if cfg.ReportPath != "" {
+		if err := writeReport(cfg.ReportPath, cfg.ReportPassword, buildReport(cfg, zw.entries, layout)); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+	}
+	var stats EncryptStats
+	stats.tally(zw.entries, layout, zw.start)
+	stats.record(zw.metrics)
+	return nil
+}
+
+// check refuses an entry the archive cannot take.
+func (zw *Writer) check(name string) error {
+	if zw.closed {
+		return errors.New("writer is closed")
+	}
+	if err := zw.ctx.Err(); err != nil {
+		return err
+	}
+	if name == "" {
+		return withKind(ErrInvalidConfig, errors.New("empty entry name"))
+	}
+	if zw.names[name] {
+		return fmt.Errorf("duplicate entry %q", name)
+	}
+	return nil
+}
@@ -13,7 +13,7 @@ import (
 	"strings"
 	"sync"
 
-	"noisyzip/internal/core"
+	"github.com/chekomaid/NoisyZip/internal/core"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -28,12 +28,17 @@ type EncryptConfig struct {
 	FixedTime           bool   `json:"fixedTime"`
 	NoiseFiles          int    `json:"noiseFiles"`
 	NoiseSize           int    `json:"noiseSize"`
+	NoiseGenerator      string `json:"noiseGenerator"`
 	Level               int    `json:"level"`
 	Strategy            string `json:"strategy"`
 	DictSize            int    `json:"dictSize"`
 	Workers             int    `json:"workers"`
 	Seed                string `json:"seed"`
 	IncludeHidden       bool   `json:"includeHidden"`
+	Bait                bool   `json:"bait"`
+	PadNames            bool   `json:"padNames"`
+	ReportPath          string `json:"reportPath"`
+	ReportPassword      string `json:"reportPassword"`
 }
 
 type EncryptResult struct {
@@ -42,26 +47,49 @@ type EncryptResult struct {
 }
 
 type RecoverConfig struct {
-	InZip         string `json:"inZip"`
-	OutZip        string `json:"outZip"`
-	Compression   string `json:"compression"`
-	Encoding      string `json:"encoding"`
-	Level         int    `json:"level"`
-	Strategy      string `json:"strategy"`
-	DictSize      int    `json:"dictSize"`
-	Workers       int    `json:"workers"`
-	Seed          string `json:"seed"`
-	IncludeHidden bool   `json:"includeHidden"`
+	InZip         string   `json:"inZip"`
+	InParts       []string `json:"inParts"`
+	OutZip        string   `json:"outZip"`
+	Compression   string   `json:"compression"`
+	Encoding      string   `json:"encoding"`
+	Level         int      `json:"level"`
+	Strategy      string   `json:"strategy"`
+	DictSize      int      `json:"dictSize"`
+	Workers       int      `json:"workers"`
+	Seed          string   `json:"seed"`
+	IncludeHidden bool     `json:"includeHidden"`
+	UnpadNames    bool     `json:"unpadNames"`
+	NoRezip       bool     `json:"noRezip"`
+	ForceScan     bool     `json:"forceScan"`
+	Encodings     []string `json:"encodings"`
+	Recurse       int      `json:"recurse"`
+	Password      string   `json:"password"`
+	Only          []string `json:"only"`
+	Skip          []string `json:"skip"`
+	Junk          []string `json:"junk"`
+	MaxMemory     int64    `json:"maxMemory"`
+	MaxEntrySize  int64    `json:"maxEntrySize"`
+	MaxTotalSize  int64    `json:"maxTotalSize"`
+	MaxRatio      float64  `json:"maxRatio"`
+	Manifest      string   `json:"manifest"`
+	KeepJunk      string   `json:"keepJunk"`
+	Strict        bool     `json:"strict"`
+	Order         string   `json:"order"`
+	PathPolicy    string   `json:"pathPolicy"`
+	KeepMethods   bool     `json:"keepMethods"`
 }
 
 type RecoverResult struct {
 	Recovered int `json:"recovered"`
 	Rebuilt   int `json:"rebuilt"`
+	Truncated int `json:"truncated"`
+	BadCRC    int `json:"badCrc"`
 }
 
 type App struct {
 	ctx     context.Context
 	running bool
+	cancel  context.CancelFunc
 	mu      sync.Mutex
 }
 
@@ -161,14 +189,61 @@ func (a *App) RunEncrypt(uiCfg EncryptConfig) (EncryptResult, error) {
 		a.mu.Unlock()
 	}()
 
+	cfg, err := encryptConfig(uiCfg)
+	if err != nil {
+		return EncryptResult{}, err
+	}
+
+	logCb := func(msg string) {
+		runtime.EventsEmit(a.ctx, "encrypt:log", msg)
+	}
+	progressCb := func(done, total int, name string) {
+		runtime.EventsEmit(a.ctx, "encrypt:progress", map[string]any{
+			"done":  done,
+			"total": total,
+			"name":  name,
+		})
+	}
+
+	total, err := core.RunEncrypt(cfg, progressCb, logCb)
+	if err != nil {
+		return EncryptResult{}, fmt.Errorf("run encrypt: %w", err)
+	}
+	return EncryptResult{Total: total, OutZip: cfg.OutZip}, nil
+}
+
+// ValidateEncrypt lists every problem of the form at once, for the
+// frontend to show before a run is started; none means it is good to go.
+func (a *App) ValidateEncrypt(uiCfg EncryptConfig) []string {
+	cfg, err := encryptConfig(uiCfg)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	err = core.ValidateConfig(cfg)
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []string{err.Error()}
+	}
+	var problems []string
+	for _, e := range joined.Unwrap() {
+		problems = append(problems, e.Error())
+	}
+	return problems
+}
+
+// encryptConfig turns the form into the core config, checking the paths.
+func encryptConfig(uiCfg EncryptConfig) (core.Config, error) {
 	src := strings.TrimSpace(uiCfg.SrcDir)
 	outZip := strings.TrimSpace(uiCfg.OutZip)
 	if src == "" || outZip == "" {
-		return EncryptResult{}, errors.New("please choose input directory and output ZIP")
+		return core.Config{}, errors.New("please choose input directory and output ZIP")
 	}
 	info, err := os.Stat(src)
 	if err != nil || !info.IsDir() {
-		return EncryptResult{}, errors.New("input directory is invalid")
+		return core.Config{}, errors.New("input directory is invalid")
 	}
 	if !strings.HasSuffix(strings.ToLower(outZip), ".zip") {
 		outZip += ".zip"
@@ -184,6 +259,7 @@ func (a *App) RunEncrypt(uiCfg EncryptConfig) (EncryptResult, error) {
 		FixedTime:           uiCfg.FixedTime,
 		NoiseFiles:          uiCfg.NoiseFiles,
 		NoiseSize:           uiCfg.NoiseSize,
+		NoiseGenerator:      uiCfg.NoiseGenerator,
 		Level:               uiCfg.Level,
 		Strategy:            uiCfg.Strategy,
 		DictSize:            uiCfg.DictSize,
@@ -194,29 +270,17 @@ func (a *App) RunEncrypt(uiCfg EncryptConfig) (EncryptResult, error) {
 	if seedText != "" {
 		seedVal, err := strconv.ParseInt(seedText, 10, 64)
 		if err != nil {
-			return EncryptResult{}, errors.New("seed must be an integer")
+			return core.Config{}, errors.New("seed must be an integer")
 		}
 		cfg.Seed = seedVal
 		cfg.HasSeed = true
 	}
 	cfg.IncludeHidden = uiCfg.IncludeHidden
-
-	logCb := func(msg string) {
-		runtime.EventsEmit(a.ctx, "encrypt:log", msg)
-	}
-	progressCb := func(done, total int, name string) {
-		runtime.EventsEmit(a.ctx, "encrypt:progress", map[string]any{
-			"done":  done,
-			"total": total,
-			"name":  name,
-		})
-	}
-
-	total, err := core.RunEncrypt(cfg, progressCb, logCb)
-	if err != nil {
-		return EncryptResult{}, fmt.Errorf("run encrypt: %w", err)
-	}
-	return EncryptResult{Total: total, OutZip: outZip}, nil
+	cfg.Bait = uiCfg.Bait
+	cfg.PadNames = uiCfg.PadNames
+	cfg.ReportPath = strings.TrimSpace(uiCfg.ReportPath)
+	cfg.ReportPassword = uiCfg.ReportPassword
+	return cfg, nil
 }
 
 func (a *App) RunRecover(uiCfg RecoverConfig) (RecoverResult, error) {
@@ -228,20 +292,32 @@ func (a *App) RunRecover(uiCfg RecoverConfig) (RecoverResult, error) {
 		a.mu.Unlock()
 		return RecoverResult{}, errors.New("operation already in progress")
 	}
+	ctx, cancel := context.WithCancel(a.ctx)
 	a.running = true
+	a.cancel = cancel
 	a.mu.Unlock()
 	defer func() {
 		a.mu.Lock()
 		a.running = false
+		a.cancel = nil
 		a.mu.Unlock()
+		cancel()
 	}()
 
 	inZip := strings.TrimSpace(uiCfg.InZip)
 	outZip := strings.TrimSpace(uiCfg.OutZip)
+	if inZip == "" && len(uiCfg.InParts) > 0 {
+		inZip = uiCfg.InParts[0]
+	}
 	if inZip == "" || outZip == "" {
 		return RecoverResult{}, errors.New("please choose input ZIP and output ZIP")
 	}
-	if !strings.HasSuffix(strings.ToLower(outZip), ".zip") {
+	// An empty list from the UI keeps the default junk patterns.
+	var junk []string
+	if len(uiCfg.Junk) > 0 {
+		junk = uiCfg.Junk
+	}
+	if !uiCfg.NoRezip && !strings.HasSuffix(strings.ToLower(outZip), ".zip") {
 		outZip += ".zip"
 	}
 
@@ -255,6 +331,42 @@ func (a *App) RunRecover(uiCfg RecoverConfig) (RecoverResult, error) {
 			"name":  name,
 		})
 	}
+	bytesCb := func(done, total int64) {
+		runtime.EventsEmit(a.ctx, "recover:bytes", map[string]any{
+			"done":  done,
+			"total": total,
+		})
+	}
+
+	if uiCfg.NoRezip {
+		rep, err := core.RecoverZipContext(ctx, core.RecoverConfig{
+			InZip:         filepath.Clean(inZip),
+			InParts:       uiCfg.InParts,
+			OutDir:        filepath.Clean(outZip),
+			UnpadNames:    uiCfg.UnpadNames,
+			ForceScan:     uiCfg.ForceScan,
+			Encodings:     uiCfg.Encodings,
+			Recurse:       uiCfg.Recurse,
+			Password:      uiCfg.Password,
+			Only:          uiCfg.Only,
+			Skip:          uiCfg.Skip,
+			Junk:          junk,
+			BytesProgress: bytesCb,
+			MaxMemory:     uiCfg.MaxMemory,
+			MaxEntrySize:  uiCfg.MaxEntrySize,
+			MaxTotalSize:  uiCfg.MaxTotalSize,
+			MaxRatio:      uiCfg.MaxRatio,
+			Manifest:      uiCfg.Manifest,
+			KeepJunk:      uiCfg.KeepJunk,
+			Strict:        uiCfg.Strict,
+			Order:         uiCfg.Order,
+			PathPolicy:    uiCfg.PathPolicy,
+		}, progressCb, logCb)
+		if err != nil {
+			return RecoverResult{}, fmt.Errorf("recover zip: %w", err)
+		}
+		return RecoverResult{Recovered: rep.Recovered, Truncated: rep.Truncated, BadCRC: rep.BadCRC}, nil
+	}
 
 	tmpDir, err := os.MkdirTemp("", "zip-recover-*")
 	if err != nil {
@@ -262,7 +374,29 @@ func (a *App) RunRecover(uiCfg RecoverConfig) (RecoverResult, error) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	recovered, err := core.RecoverZip(filepath.Clean(inZip), tmpDir, progressCb, logCb)
+	rep, err := core.RecoverZipContext(ctx, core.RecoverConfig{
+		InZip:         filepath.Clean(inZip),
+		InParts:       uiCfg.InParts,
+		OutDir:        tmpDir,
+		UnpadNames:    uiCfg.UnpadNames,
+		ForceScan:     uiCfg.ForceScan,
+		Encodings:     uiCfg.Encodings,
+		Recurse:       uiCfg.Recurse,
+		Password:      uiCfg.Password,
+		Only:          uiCfg.Only,
+		Skip:          uiCfg.Skip,
+		Junk:          junk,
+		BytesProgress: bytesCb,
+		MaxMemory:     uiCfg.MaxMemory,
+		MaxEntrySize:  uiCfg.MaxEntrySize,
+		MaxTotalSize:  uiCfg.MaxTotalSize,
+		MaxRatio:      uiCfg.MaxRatio,
+		Manifest:      uiCfg.Manifest,
+		KeepJunk:      uiCfg.KeepJunk,
+		Strict:        uiCfg.Strict,
+		Order:         uiCfg.Order,
+		PathPolicy:    uiCfg.PathPolicy,
+	}, progressCb, logCb)
 	if err != nil {
 		return RecoverResult{}, fmt.Errorf("recover zip: %w", err)
 	}
@@ -294,10 +428,24 @@ func (a *App) RunRecover(uiCfg RecoverConfig) (RecoverResult, error) {
 		cfg.HasSeed = true
 	}
 
+	cfg.FileOrder = rep.RecoveredPaths()
+	if uiCfg.KeepMethods {
+		cfg.FileMethods = rep.FileMethods()
+	}
 	rebuilt, err := core.RunEncrypt(cfg, nil, nil)
 	if err != nil {
 		return RecoverResult{}, fmt.Errorf("build zip: %w", err)
 	}
 
-	return RecoverResult{Recovered: recovered, Rebuilt: rebuilt}, nil
+	return RecoverResult{Recovered: rep.Recovered, Rebuilt: rebuilt, Truncated: rep.Truncated, BadCRC: rep.BadCRC}, nil
+}
+
+// CancelRecover stops a running recovery. The files it wrote so far are
+// removed and RunRecover returns an error.
+func (a *App) CancelRecover() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cancel != nil {
+		a.cancel()
+	}
 }
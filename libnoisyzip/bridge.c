@@ -0,0 +1,11 @@
+#include "noisyzip.h"
+
+// Go cannot call C function pointers itself; these do it for it.
+
+int nzCallProgress(nz_progress_fn fn, int done, int total, char *name, void *user) {
+	return fn(done, total, name, user);
+}
+
+void nzCallLog(nz_log_fn fn, char *msg, void *user) {
+	fn(msg, user);
+}
@@ -0,0 +1,179 @@
+// Command libnoisyzip is the NoisyZip engine as a C shared library, for
+// applications in other languages to embed; noisyzip.h describes its
+// interface. Build it with
+//
+//	go build -buildmode=c-shared -o libnoisyzip.so ./libnoisyzip
+package main
+
+/*
+#include <stdlib.h>
+#include "noisyzip.h"
+
+int nzCallProgress(nz_progress_fn fn, int done, int total, char *name, void *user);
+void nzCallLog(nz_log_fn fn, char *msg, void *user);
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"unsafe"
+
+	"github.com/chekomaid/NoisyZip/noisyzip"
+)
+
+func main() {}
+
+//export BuildArchive
+func BuildArchive(src, out, options *C.char, progress C.nz_progress_fn, log C.nz_log_fn, user unsafe.Pointer, result **C.char) C.int {
+	var jopts archiveOptions
+	if err := decodeOptions(options, &jopts); err != nil {
+		return finish(result, nil, err)
+	}
+	opts := jopts.options()
+	ctx, cb := newCallbacks(progress, log, user)
+	defer cb.cancel()
+	opts.Progress, opts.Log = cb.progressFunc(), cb.logFunc()
+	stats, err := noisyzip.Archive(ctx, C.GoString(src), C.GoString(out), &opts)
+	return finish(result, newArchiveResult(stats), err)
+}
+
+//export RecoverArchive
+func RecoverArchive(in, out, options *C.char, progress C.nz_progress_fn, log C.nz_log_fn, user unsafe.Pointer, result **C.char) C.int {
+	var jopts recoverOptions
+	if err := decodeOptions(options, &jopts); err != nil {
+		return finish(result, nil, err)
+	}
+	opts := jopts.options()
+	ctx, cb := newCallbacks(progress, log, user)
+	defer cb.cancel()
+	opts.Progress, opts.Log = cb.progressFunc(), cb.logFunc()
+	res, err := noisyzip.Recover(ctx, C.GoString(in), C.GoString(out), &opts)
+	return finish(result, newRecoverResult(res), err)
+}
+
+//export ExtractArchive
+func ExtractArchive(in, dir, options *C.char, progress C.nz_progress_fn, log C.nz_log_fn, user unsafe.Pointer, result **C.char) C.int {
+	var jopts recoverOptions
+	if err := decodeOptions(options, &jopts); err != nil {
+		return finish(result, nil, err)
+	}
+	opts := jopts.options()
+	ctx, cb := newCallbacks(progress, log, user)
+	defer cb.cancel()
+	opts.Progress, opts.Log = cb.progressFunc(), cb.logFunc()
+	res, err := noisyzip.Extract(ctx, C.GoString(in), C.GoString(dir), &opts)
+	return finish(result, newRecoverResult(res), err)
+}
+
+//export NoisyZipFree
+func NoisyZipFree(p *C.char) {
+	C.free(unsafe.Pointer(p))
+}
+
+// invalidOptions is options JSON that does not decode.
+type invalidOptions struct {
+	err error
+}
+
+func (e invalidOptions) Error() string { return "options: " + e.err.Error() }
+func (e invalidOptions) Unwrap() []error {
+	return []error{e.err, noisyzip.ErrInvalidConfig}
+}
+
+// decodeOptions reads the JSON options into opts, an archiveOptions or a
+// recoverOptions; NULL or "" leaves them at their defaults.
+func decodeOptions(options *C.char, opts any) error {
+	if options == nil {
+		return nil
+	}
+	data := C.GoString(options)
+	if data == "" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(opts); err != nil {
+		return invalidOptions{err}
+	}
+	return nil
+}
+
+// callbacks calls the C callbacks of one call; a progress callback
+// returning non-zero cancels it.
+type callbacks struct {
+	progress C.nz_progress_fn
+	log      C.nz_log_fn
+	user     unsafe.Pointer
+	cancel   context.CancelFunc
+}
+
+func newCallbacks(progress C.nz_progress_fn, log C.nz_log_fn, user unsafe.Pointer) (context.Context, callbacks) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return ctx, callbacks{progress: progress, log: log, user: user, cancel: cancel}
+}
+
+func (cb callbacks) progressFunc() func(done, total int, name string) {
+	if cb.progress == nil {
+		return nil
+	}
+	return func(done, total int, name string) {
+		cname := C.CString(name)
+		defer C.free(unsafe.Pointer(cname))
+		if C.nzCallProgress(cb.progress, C.int(done), C.int(total), cname, cb.user) != 0 {
+			cb.cancel()
+		}
+	}
+}
+
+func (cb callbacks) logFunc() func(msg string) {
+	if cb.log == nil {
+		return nil
+	}
+	return func(msg string) {
+		cmsg := C.CString(msg)
+		defer C.free(unsafe.Pointer(cmsg))
+		C.nzCallLog(cb.log, cmsg, cb.user)
+	}
+}
+
+// finish stores the totals of a run as JSON, an archiveResult or a
+// recoverResult, or the error message, in *result and returns the result
+// code of err.
+func finish(result **C.char, totals any, err error) C.int {
+	code := C.int(C.NZ_OK)
+	var msg string
+	if err == nil {
+		data, jerr := json.Marshal(totals)
+		if jerr != nil {
+			err = jerr
+		}
+		msg = string(data)
+	}
+	if err != nil {
+		code = errorCode(err)
+		msg = err.Error()
+	}
+	if result != nil {
+		*result = C.CString(msg)
+	}
+	return code
+}
+
+func errorCode(err error) C.int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return C.NZ_ERR_CANCELED
+	case errors.Is(err, noisyzip.ErrInvalidConfig):
+		return C.NZ_ERR_INVALID_CONFIG
+	case errors.Is(err, noisyzip.ErrNoFiles):
+		return C.NZ_ERR_NO_FILES
+	case errors.Is(err, noisyzip.ErrVerification):
+		return C.NZ_ERR_VERIFICATION
+	case errors.Is(err, noisyzip.ErrNoSpace):
+		return C.NZ_ERR_NO_SPACE
+	}
+	return C.NZ_ERR
+}
@@ -0,0 +1,65 @@
+/*
+ * noisyzip.h - C interface of libnoisyzip, the NoisyZip engine as a shared
+ * library, for programs in C, C#, Python, Rust and the like.
+ *
+ * Build it with
+ *
+ *     go build -buildmode=c-shared -o libnoisyzip.so ./libnoisyzip
+ *
+ * (libnoisyzip.dll on Windows, libnoisyzip.dylib on macOS).
+ *
+ * Options are passed as a JSON object whose keys are the fields of
+ * ArchiveOptions or RecoverOptions in the Go noisyzip package in camel
+ * case, such as {"noiseFiles": 20, "bait": true} or {"password": "secret"};
+ * NULL or "" means the defaults. Keys are matched without regard to case,
+ * and unknown keys, the callbacks and hooks among them, are refused.
+ *
+ * On return *result, when result is not NULL, holds a string to release
+ * with NoisyZipFree: the totals of the run as JSON on success, keyed in
+ * camel case like the options ({"outputBytes": 1234, "durationMs": 56,
+ * ...}), the error message otherwise.
+ *
+ * The callbacks may be NULL. They are called one at a time, though not
+ * always on the calling thread, and their strings are only valid during
+ * the call. A progress callback returning non-zero stops the run, which
+ * then fails with NZ_ERR_CANCELED and removes its partial output.
+ */
+#ifndef NOISYZIP_H
+#define NOISYZIP_H
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+enum {
+	NZ_OK = 0,
+	NZ_ERR = 1,
+	NZ_ERR_INVALID_CONFIG = 2,
+	NZ_ERR_NO_FILES = 3,
+	NZ_ERR_VERIFICATION = 4,
+	NZ_ERR_NO_SPACE = 5,
+	NZ_ERR_CANCELED = 6
+};
+
+typedef int (*nz_progress_fn)(int done, int total, char *name, void *user);
+typedef void (*nz_log_fn)(char *msg, void *user);
+
+/* BuildArchive writes the files under the directory src to the archive out. */
+int BuildArchive(char *src, char *out, char *options, nz_progress_fn progress, nz_log_fn log, void *user, char **result);
+
+/* RecoverArchive writes the real files of the noisy archive in as a clean
+ * ZIP, out. */
+int RecoverArchive(char *in, char *out, char *options, nz_progress_fn progress, nz_log_fn log, void *user, char **result);
+
+/* ExtractArchive writes the real files of the noisy archive in under the
+ * directory dir. */
+int ExtractArchive(char *in, char *dir, char *options, nz_progress_fn progress, nz_log_fn log, void *user, char **result);
+
+/* NoisyZipFree releases a string returned through result. */
+void NoisyZipFree(char *p);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
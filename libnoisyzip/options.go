@@ -0,0 +1,112 @@
+package main
+
+import "github.com/chekomaid/NoisyZip/noisyzip"
+
+// archiveOptions is the options JSON of BuildArchive. Its keys are part of
+// the C interface and keep their names whatever becomes of the Go fields
+// they map to.
+type archiveOptions struct {
+	Compression    string   `json:"compression"`
+	Level          int      `json:"level"`
+	Encoding       string   `json:"encoding"`
+	Workers        int      `json:"workers"`
+	NoiseFiles     int      `json:"noiseFiles"`
+	NoiseSize      int      `json:"noiseSize"`
+	NoiseGenerator string   `json:"noiseGenerator"`
+	Bait           bool     `json:"bait"`
+	Dedup          bool     `json:"dedup"`
+	PadNames       bool     `json:"padNames"`
+	PadBucket      int64    `json:"padBucket"`
+	CommentSize    int      `json:"commentSize"`
+	KeepCentralDir bool     `json:"keepCentralDir"`
+	FixedTime      bool     `json:"fixedTime"`
+	Seed           *int64   `json:"seed"`
+	IncludeHidden  bool     `json:"includeHidden"`
+	MaxDepth       int      `json:"maxDepth"`
+	Files          []string `json:"files"`
+	TmpDir         string   `json:"tmpDir"`
+	MemEntrySize   int64    `json:"memEntrySize"`
+	MemTotal       int64    `json:"memTotal"`
+	BufferSize     int      `json:"bufferSize"`
+	Resume         string   `json:"resume"`
+	Report         string   `json:"report"`
+	ReportPassword string   `json:"reportPassword"`
+}
+
+func (o archiveOptions) options() noisyzip.ArchiveOptions {
+	return noisyzip.ArchiveOptions{
+		Compression:    o.Compression,
+		Level:          o.Level,
+		Encoding:       o.Encoding,
+		Workers:        o.Workers,
+		NoiseFiles:     o.NoiseFiles,
+		NoiseSize:      o.NoiseSize,
+		NoiseGenerator: o.NoiseGenerator,
+		Bait:           o.Bait,
+		Dedup:          o.Dedup,
+		PadNames:       o.PadNames,
+		PadBucket:      o.PadBucket,
+		CommentSize:    o.CommentSize,
+		KeepCentralDir: o.KeepCentralDir,
+		FixedTime:      o.FixedTime,
+		Seed:           o.Seed,
+		IncludeHidden:  o.IncludeHidden,
+		MaxDepth:       o.MaxDepth,
+		Files:          o.Files,
+		TmpDir:         o.TmpDir,
+		MemEntrySize:   o.MemEntrySize,
+		MemTotal:       o.MemTotal,
+		BufferSize:     o.BufferSize,
+		Resume:         o.Resume,
+		Report:         o.Report,
+		ReportPassword: o.ReportPassword,
+	}
+}
+
+// recoverOptions is the options JSON of RecoverArchive and ExtractArchive,
+// as stable as archiveOptions.
+type recoverOptions struct {
+	Password     string   `json:"password"`
+	Encodings    []string `json:"encodings"`
+	Only         []string `json:"only"`
+	Skip         []string `json:"skip"`
+	Junk         []string `json:"junk"`
+	ForceScan    bool     `json:"forceScan"`
+	Strict       bool     `json:"strict"`
+	UnpadNames   bool     `json:"unpadNames"`
+	Recurse      int      `json:"recurse"`
+	Order        string   `json:"order"`
+	PathPolicy   string   `json:"pathPolicy"`
+	MaxMemory    int64    `json:"maxMemory"`
+	MaxEntrySize int64    `json:"maxEntrySize"`
+	MaxTotalSize int64    `json:"maxTotalSize"`
+	MaxRatio     float64  `json:"maxRatio"`
+	Compression  string   `json:"compression"`
+	Level        int      `json:"level"`
+	Workers      int      `json:"workers"`
+	TmpDir       string   `json:"tmpDir"`
+}
+
+func (o recoverOptions) options() noisyzip.RecoverOptions {
+	return noisyzip.RecoverOptions{
+		Password:     o.Password,
+		Encodings:    o.Encodings,
+		Only:         o.Only,
+		Skip:         o.Skip,
+		Junk:         o.Junk,
+		ForceScan:    o.ForceScan,
+		Strict:       o.Strict,
+		UnpadNames:   o.UnpadNames,
+		Recurse:      o.Recurse,
+		Order:        o.Order,
+		PathPolicy:   o.PathPolicy,
+		MaxMemory:    o.MaxMemory,
+		MaxEntrySize: o.MaxEntrySize,
+		MaxTotalSize: o.MaxTotalSize,
+		MaxRatio:     o.MaxRatio,
+		Compression:  o.Compression,
+		Level:        o.Level,
+		Workers:      o.Workers,
+		TmpDir:       o.TmpDir,
+	}
+}
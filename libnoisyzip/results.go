@@ -0,0 +1,117 @@
+package main
+
+import (
+	"time"
+
+	"github.com/chekomaid/NoisyZip/noisyzip"
+)
+
+// archiveResult is the result JSON of BuildArchive. Like the options, its
+// keys are part of the C interface and do not follow the Go fields.
+type archiveResult struct {
+	Entries        int                  `json:"entries"`
+	Files          int                  `json:"files"`
+	NoiseEntries   int                  `json:"noiseEntries"`
+	BaitEntries    int                  `json:"baitEntries"`
+	InputBytes     int64                `json:"inputBytes"`
+	DataBytes      int64                `json:"dataBytes"`
+	NoiseBytes     int64                `json:"noiseBytes"`
+	BaitBytes      int64                `json:"baitBytes"`
+	Duplicates     int                  `json:"duplicates"`
+	DuplicateBytes int64                `json:"duplicateBytes"`
+	OutputBytes    int64                `json:"outputBytes"`
+	DurationMs     int64                `json:"durationMs"`
+	Results        []archiveEntryResult `json:"results"`
+}
+
+type archiveEntryResult struct {
+	Path       string `json:"path,omitempty"`
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	Compressed int64  `json:"compressed"`
+	CRC        uint32 `json:"crc"`
+	Method     uint16 `json:"method"`
+	Offset     int64  `json:"offset"`
+	Noise      bool   `json:"noise,omitempty"`
+}
+
+func newArchiveResult(s noisyzip.ArchiveStats) archiveResult {
+	res := archiveResult{
+		Entries:        s.Entries,
+		Files:          s.Files,
+		NoiseEntries:   s.NoiseEntries,
+		BaitEntries:    s.BaitEntries,
+		InputBytes:     s.InputBytes,
+		DataBytes:      s.DataBytes,
+		NoiseBytes:     s.NoiseBytes,
+		BaitBytes:      s.BaitBytes,
+		Duplicates:     s.Duplicates,
+		DuplicateBytes: s.DuplicateBytes,
+		OutputBytes:    s.OutputBytes,
+		DurationMs:     s.Duration.Milliseconds(),
+		Results:        make([]archiveEntryResult, 0, len(s.Results)),
+	}
+	for _, r := range s.Results {
+		res.Results = append(res.Results, archiveEntryResult{
+			Path:       r.Path,
+			Name:       r.Name,
+			Size:       r.Size,
+			Compressed: r.Compressed,
+			CRC:        r.CRC,
+			Method:     r.Method,
+			Offset:     r.Offset,
+			Noise:      r.Noise,
+		})
+	}
+	return res
+}
+
+// recoverResult is the result JSON of RecoverArchive and ExtractArchive,
+// as stable as archiveResult.
+type recoverResult struct {
+	Source    string         `json:"source"`
+	Recovered int            `json:"recovered"`
+	Junk      int            `json:"junk"`
+	Skipped   int            `json:"skipped"`
+	Failed    int            `json:"failed"`
+	Truncated int            `json:"truncated"`
+	Entries   []recoverEntry `json:"entries"`
+}
+
+type recoverEntry struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Offset     int64     `json:"offset"`
+	Size       int64     `json:"size"`
+	CRC        uint32    `json:"crc"`
+	Modified   time.Time `json:"modified"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Confidence int       `json:"confidence"`
+}
+
+func newRecoverResult(r noisyzip.RecoverResult) recoverResult {
+	res := recoverResult{
+		Source:    r.Source,
+		Recovered: r.Recovered,
+		Junk:      r.Junk,
+		Skipped:   r.Skipped,
+		Failed:    r.Failed,
+		Truncated: r.Truncated,
+		Entries:   make([]recoverEntry, 0, len(r.Entries)),
+	}
+	for _, e := range r.Entries {
+		res.Entries = append(res.Entries, recoverEntry{
+			Name:       e.Name,
+			Path:       e.Path,
+			Offset:     e.Offset,
+			Size:       e.Size,
+			CRC:        e.CRC,
+			Modified:   e.Modified,
+			Status:     e.Status,
+			Error:      e.Error,
+			Confidence: e.Confidence,
+		})
+	}
+	return res
+}
@@ -6,7 +6,7 @@ package main
 import (
 	"os"
 
-	"noisyzip/internal/cli"
+	"github.com/chekomaid/NoisyZip/internal/cli"
 )
 
 func main() {
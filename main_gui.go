@@ -7,7 +7,7 @@ import (
 	"embed"
 	"log"
 
-	"noisyzip/internal/gui"
+	"github.com/chekomaid/NoisyZip/internal/gui"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -0,0 +1,170 @@
+// Package mobile is the NoisyZip API for Android and iOS apps, built with
+//
+//	gomobile bind -target=android ./mobile
+//	gomobile bind -target=ios ./mobile
+//
+// It sticks to the types gomobile can bind: string paths, plain option
+// structs made with NewArchiveOptions and NewRecoverOptions, and a Progress
+// interface the app implements. Set TmpDir to the app's cache directory,
+// as the OS temp directory is often not writable on a device.
+package mobile
+
+import (
+	"context"
+
+	"github.com/chekomaid/NoisyZip/noisyzip"
+)
+
+// Progress receives the progress of a run, which stops when OnProgress
+// returns false. Its methods are called one at a time, off the UI thread.
+type Progress interface {
+	OnProgress(done, total int, name string) bool
+	OnLog(msg string)
+}
+
+// ArchiveOptions tune Archive; zero fields mean the defaults of the
+// noisyzip command.
+type ArchiveOptions struct {
+	Compression    string
+	Level          int
+	Workers        int
+	NoiseFiles     int
+	NoiseSize      int
+	NoiseGenerator string
+	Bait           bool
+	PadNames       bool
+	FixedTime      bool
+	IncludeHidden  bool
+	TmpDir         string
+}
+
+// NewArchiveOptions returns options with every field at its default.
+func NewArchiveOptions() *ArchiveOptions {
+	return &ArchiveOptions{}
+}
+
+// RecoverOptions tune Recover and Extract; zero fields mean the defaults of
+// the noisyzip command.
+type RecoverOptions struct {
+	Password   string
+	Strict     bool
+	UnpadNames bool
+	Recurse    int
+	// MaxEntrySize and MaxTotalSize abandon archive bombs; 0 means no
+	// limit.
+	MaxEntrySize int64
+	MaxTotalSize int64
+	TmpDir       string
+}
+
+// NewRecoverOptions returns options with every field at its default.
+func NewRecoverOptions() *RecoverOptions {
+	return &RecoverOptions{}
+}
+
+// ArchiveResult totals an Archive run.
+type ArchiveResult struct {
+	Files        int
+	NoiseEntries int
+	InputBytes   int64
+	OutputBytes  int64
+	DurationMs   int64
+}
+
+// RecoverResult totals a Recover or Extract run.
+type RecoverResult struct {
+	Recovered int
+	Junk      int
+	Failed    int
+	Truncated int
+}
+
+// Archive writes the files under the directory src to the archive out.
+// opts and progress may be nil.
+func Archive(src, out string, opts *ArchiveOptions, progress Progress) (*ArchiveResult, error) {
+	if opts == nil {
+		opts = NewArchiveOptions()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progressFn, logFn := callbacks(progress, cancel)
+	stats, err := noisyzip.Archive(ctx, src, out, &noisyzip.ArchiveOptions{
+		Compression:    opts.Compression,
+		Level:          opts.Level,
+		Workers:        opts.Workers,
+		NoiseFiles:     opts.NoiseFiles,
+		NoiseSize:      opts.NoiseSize,
+		NoiseGenerator: opts.NoiseGenerator,
+		Bait:           opts.Bait,
+		PadNames:       opts.PadNames,
+		FixedTime:      opts.FixedTime,
+		IncludeHidden:  opts.IncludeHidden,
+		TmpDir:         opts.TmpDir,
+		Progress:       progressFn,
+		Log:            logFn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveResult{
+		Files:        stats.Files,
+		NoiseEntries: stats.NoiseEntries,
+		InputBytes:   stats.InputBytes,
+		OutputBytes:  stats.OutputBytes,
+		DurationMs:   stats.Duration.Milliseconds(),
+	}, nil
+}
+
+// Recover writes the real files of the noisy archive in as a clean ZIP,
+// out. opts and progress may be nil.
+func Recover(in, out string, opts *RecoverOptions, progress Progress) (*RecoverResult, error) {
+	return recoverRun(in, out, opts, progress, noisyzip.Recover)
+}
+
+// Extract writes the real files of the noisy archive in under the
+// directory dir. opts and progress may be nil.
+func Extract(in, dir string, opts *RecoverOptions, progress Progress) (*RecoverResult, error) {
+	return recoverRun(in, dir, opts, progress, noisyzip.Extract)
+}
+
+func recoverRun(in, out string, opts *RecoverOptions, progress Progress, run func(ctx context.Context, in, out string, opts *noisyzip.RecoverOptions) (noisyzip.RecoverResult, error)) (*RecoverResult, error) {
+	if opts == nil {
+		opts = NewRecoverOptions()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progressFn, logFn := callbacks(progress, cancel)
+	res, err := run(ctx, in, out, &noisyzip.RecoverOptions{
+		Password:     opts.Password,
+		Strict:       opts.Strict,
+		UnpadNames:   opts.UnpadNames,
+		Recurse:      opts.Recurse,
+		MaxEntrySize: opts.MaxEntrySize,
+		MaxTotalSize: opts.MaxTotalSize,
+		TmpDir:       opts.TmpDir,
+		Progress:     progressFn,
+		Log:          logFn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RecoverResult{
+		Recovered: res.Recovered,
+		Junk:      res.Junk,
+		Failed:    res.Failed,
+		Truncated: res.Truncated,
+	}, nil
+}
+
+// callbacks adapts progress to the noisyzip callbacks, cancelling the run
+// when OnProgress returns false.
+func callbacks(progress Progress, cancel context.CancelFunc) (func(done, total int, name string), func(msg string)) {
+	if progress == nil {
+		return nil, nil
+	}
+	return func(done, total int, name string) {
+		if !progress.OnProgress(done, total, name) {
+			cancel()
+		}
+	}, progress.OnLog
+}
@@ -0,0 +1,30 @@
+package noisyzip
+
+import "github.com/chekomaid/NoisyZip/internal/core"
+
+// Event is a typed progress event sent on ArchiveOptions.Events: one of
+// PhaseChanged, FileStarted, FileDone, NoiseDone and Warning.
+type Event = core.Event
+
+type (
+	// PhaseChanged starts a phase of the run, one of the Phase constants.
+	PhaseChanged = core.PhaseChanged
+	// FileStarted is sent when a worker picks up a source file.
+	FileStarted = core.FileStarted
+	// FileDone is sent when a source file is compressed, with its size
+	// before and after.
+	FileDone = core.FileDone
+	// NoiseDone is sent once the noise and bait entries are made.
+	NoiseDone = core.NoiseDone
+	// Warning is an option that could not be honored.
+	Warning = core.Warning
+)
+
+// Phases of an Archive run, in the order PhaseChanged reports them.
+const (
+	PhaseScan     = core.PhaseScan
+	PhaseCompress = core.PhaseCompress
+	PhaseNoise    = core.PhaseNoise
+	PhaseWrite    = core.PhaseWrite
+	PhaseDone     = core.PhaseDone
+)
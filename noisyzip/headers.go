@@ -0,0 +1,60 @@
+package noisyzip
+
+import (
+	"time"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+// NameEncoder turns a slash-separated entry name into the raw bytes Archive
+// writes to its headers.
+type NameEncoder = core.NameEncoder
+
+// NameEncodings returns the values ArchiveOptions.Encoding accepts.
+func NameEncodings() []string {
+	return core.NameEncodings()
+}
+
+// NewNameEncoder returns the encoder of one of NameEncodings and the general
+// purpose flag bits its entries get: 0x800, the UTF-8 flag, for utf-8 and
+// none for cp1251.
+func NewNameEncoder(encoding string) (NameEncoder, uint16, error) {
+	return core.NewNameEncoder(encoding)
+}
+
+// EncodeName returns name as Archive stores it with encoding, and whether
+// the entry gets the UTF-8 flag.
+func EncodeName(encoding, name string) ([]byte, bool, error) {
+	return core.EncodeName(encoding, name)
+}
+
+// EncodeCP1251 returns s in Windows-1251, failing on a rune it lacks.
+func EncodeCP1251(s string) ([]byte, error) {
+	return core.EncodeCP1251(s)
+}
+
+// DecodeCP1251 is the inverse of EncodeCP1251. ok is false when b holds
+// 0x98, the one byte Windows-1251 leaves undefined.
+func DecodeCP1251(b []byte) (s string, ok bool) {
+	return core.DecodeCP1251(b)
+}
+
+// CP1251Table returns the runes of the Windows-1251 bytes 0x80 to 0xFF, by
+// byte minus 0x80, with 0 for the undefined 0x98. Bytes below 0x80 are
+// ASCII.
+func CP1251Table() [128]rune {
+	return core.CP1251Table()
+}
+
+// DOSTimeDate returns t as the MS-DOS time and date fields Archive writes,
+// in local time at two-second precision. Times before 1980 and, when fixed
+// is set as by ArchiveOptions.FixedTime, every time become 1980-01-01 00:00.
+func DOSTimeDate(t time.Time, fixed bool) (dosTime, dosDate uint16) {
+	return core.DOSTimeDate(t, fixed)
+}
+
+// DOSToTime is the inverse of DOSTimeDate, in local time. ok is false for a
+// zero or out-of-range date.
+func DOSToTime(dosTime, dosDate uint16) (t time.Time, ok bool) {
+	return core.DOSToTime(dosTime, dosDate)
+}
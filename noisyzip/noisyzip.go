@@ -0,0 +1,369 @@
+// Package noisyzip writes directories as ZIP archives hidden among noise,
+// junk entries and a misleading layout, and gets the real files back out of
+// such archives.
+//
+// It is the library behind the noisyzip command, for Go programs that would
+// rather embed it than run the binary:
+//
+//	stats, err := noisyzip.Archive(ctx, "docs", "docs.zip", &noisyzip.ArchiveOptions{NoiseFiles: 20})
+//	...
+//	res, err := noisyzip.Recover(ctx, "docs.zip", "clean.zip", nil)
+//
+// The types and functions here are stable: fields may be added, but
+// existing ones keep their meaning. A nil options pointer, like a zero
+// field, means the default the command-line tool uses.
+//
+// Any number of runs may go at once in one process: each stages its files
+// in a directory of its own under TmpDir, and they share nothing but the
+// registries, which are safe for concurrent use. Runs must not write the
+// same output, and a Logger, Metrics or hook given to several of them must
+// be safe for concurrent use too.
+package noisyzip
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+// Kinds of failure errors from this package can be matched against with
+// errors.Is.
+var (
+	// ErrInvalidConfig marks an option that is out of range or malformed:
+	// nothing was read or written.
+	ErrInvalidConfig = core.ErrInvalidConfig
+	// ErrNoFiles is returned when the source directory holds no files.
+	ErrNoFiles = core.ErrNoFiles
+	// ErrVerification marks data that failed an integrity check.
+	ErrVerification = core.ErrVerification
+	// ErrNoSpace is returned before anything is written when the temp or
+	// output directory is too small for the estimated archive.
+	ErrNoSpace = core.ErrNoSpace
+)
+
+// ArchiveOptions tune Archive and ArchiveTo.
+type ArchiveOptions struct {
+	// Compression is "deflate" (the default), "store" or the name of a
+	// compressor added with RegisterCompressor.
+	Compression string
+	// Level is the deflate level, 1 to 9; 0 means 6 and LevelNone is
+	// level 0, which stores the data in uncompressed deflate blocks.
+	Level int
+	// Encoding is the charset entry names are written in: "utf-8" (the
+	// default) or "cp1251".
+	Encoding string
+	// Workers is the number of files compressed at once; 0 means one.
+	// runtime.NumCPU() gives the one per CPU of the noisyzip command's
+	// -workers auto.
+	Workers int
+
+	// NoiseFiles junk entries of NoiseSize bytes each are mixed in, made by
+	// NoiseGenerator ("random", the default, or "decoy").
+	NoiseFiles     int
+	NoiseSize      int
+	NoiseGenerator string
+	// Bait adds top-level decoy files with plausible content.
+	Bait bool
+	// Dedup stores the data of identical files once; the other copies get
+	// central directory records pointing at it. Info-ZIP unzip and
+	// Python's zipfile refuse such archives or copies.
+	Dedup bool
+	// PadNames pads every entry name to the same length.
+	PadNames bool
+	// PadBucket pads deflated streams to a multiple of this many bytes.
+	PadBucket int64
+	// CommentSize is the size of the junk archive comment, up to 65535.
+	CommentSize int
+	// KeepCentralDir leaves the central directory intact instead of
+	// overwriting it.
+	KeepCentralDir bool
+	// FixedTime gives every entry the same timestamp.
+	FixedTime bool
+	// Seed, when set, makes the noise reproducible.
+	Seed *int64
+	// Rand, when set, supplies the random bytes in place of crypto/rand
+	// and Seed, such as an HSM or a fixed stream for tests.
+	Rand RandSource
+
+	// IncludeHidden takes hidden files too.
+	IncludeHidden bool
+	// MaxDepth only takes files this many directories deep, 1 being the
+	// source directory itself; 0 means no limit.
+	MaxDepth int
+	// Files, when non-nil, are the files to archive, relative to the
+	// source directory and in this order, instead of walking it.
+	Files []string
+	// TmpDir is where entries are staged; "" means the OS temp directory.
+	TmpDir string
+	// MemEntrySize is the size up to which files are staged in memory
+	// rather than in TmpDir, MemTotal bytes at most between them. 0 means
+	// 4 MiB and 64 MiB; a negative MemEntrySize stages every file on disk.
+	MemEntrySize int64
+	MemTotal     int64
+	// BufferSize is the size of the chunks files are read in and of the
+	// buffer the archive is written through; 0 means 1 MiB. Larger buffers
+	// mean fewer, larger writes, which suits network filesystems.
+	BufferSize int
+	// Resume, when set, is a file where Archive saves its progress, with
+	// the staged entries next to it in Resume+".files". Archive again with
+	// the same source, output and compression settings after a crash or
+	// cancellation carries on from it; both are removed once the archive
+	// is written. ArchiveTo does not take it.
+	Resume string
+	// Report, when set, is where a JSON report of what was done is
+	// written, encrypted with ReportPassword if that is set.
+	Report         string
+	ReportPassword string
+
+	// BeforeEntry, when set, is called with each source file before it is
+	// compressed, one at a time in archive order. It may skip the file,
+	// rename its entry or change its timestamp; an error stops the run. A
+	// new name must be unique, relative, free of ".." and outside .junk/.
+	BeforeEntry func(info *EntryInfo) error
+	// AfterEntry, when set, is called as each source file is compressed,
+	// one at a time.
+	AfterEntry func(res EntryResult)
+
+	// Progress, when set, is called as each entry is written.
+	Progress func(done, total int, name string)
+	// Log, when set, receives a line per notable step.
+	Log func(msg string)
+	// Logger, when set, receives the same steps as leveled records with
+	// attributes, such as the entry, its bytes and the time it took, plus
+	// debug records per file; Log is then not called.
+	Logger *slog.Logger
+	// Metrics, when set, receives counters and timings: files compressed,
+	// bytes in and out, noise and temp bytes, run times.
+	Metrics Metrics
+	// Events, when set, receives typed progress events. Read it until
+	// PhaseChanged with PhaseDone, the last event of every run, failed ones
+	// included, as the run waits for each event to be taken. The channel is
+	// not closed and may be used for one run after another.
+	Events chan<- Event
+}
+
+// Compressor encodes entry data for one ZIP compression method: Method is
+// its ID in the headers and NewWriter compresses to w at a level of 1 to 9,
+// its Close ending the stream without closing w. Recover and Extract read
+// stored and deflated entries only.
+type Compressor = core.Compressor
+
+// RegisterCompressor makes c available as ArchiveOptions.Compression name,
+// replacing any compressor of that name. Call it before the runs that use
+// it, typically from an init function.
+func RegisterCompressor(name string, c Compressor) {
+	core.RegisterCompressor(name, c)
+}
+
+// LevelNone as ArchiveOptions.Level asks for deflate level 0, which a
+// zero Level cannot, as it means the default.
+const LevelNone = -1
+
+// Metrics receives the counters and timings of runs, named by the Metric
+// constants; ExpvarMetrics publishes them with expvar.
+type Metrics = core.Metrics
+
+// Names of the counters and timings reported to Metrics.
+const (
+	MetricFilesCompressed = core.MetricFilesCompressed
+	MetricBytesIn         = core.MetricBytesIn
+	MetricCompressTime    = core.MetricCompressTime
+	MetricBytesOut        = core.MetricBytesOut
+	MetricNoiseBytes      = core.MetricNoiseBytes
+	MetricTempBytes       = core.MetricTempBytes
+	MetricEncryptRuns     = core.MetricEncryptRuns
+	MetricEncryptTime     = core.MetricEncryptTime
+	MetricRecoverAttempts = core.MetricRecoverAttempts
+	MetricRecoverFailed   = core.MetricRecoverFailed
+	MetricRecoverBytes    = core.MetricRecoverBytes
+	MetricRecoverTime     = core.MetricRecoverTime
+)
+
+// ExpvarMetrics publishes the metrics as the expvar map name, timings as
+// name_count and name_ns. It panics when name is already published.
+func ExpvarMetrics(name string) Metrics {
+	return core.ExpvarMetrics(name)
+}
+
+// RandSource supplies the random bytes of a run: noise, names, bait and
+// padding. A source shared by concurrent runs must be safe for it.
+type RandSource = core.RandSource
+
+// SeededRandSource returns the deterministic ChaCha20 stream Seed uses, for
+// building on or comparing against.
+func SeededRandSource(seed int64) RandSource {
+	return core.SeededRandSource(seed)
+}
+
+// EntryInfo is a source file about to be compressed, as
+// ArchiveOptions.BeforeEntry sees it: Path relative to the source
+// directory, and the Name, Modified time and Skip flag the hook may change.
+type EntryInfo = core.EntryInfo
+
+// EntryResult is a source file compressed, as ArchiveOptions.AfterEntry
+// sees it, or an entry written, as ArchiveStats.Results lists it.
+type EntryResult = core.EntryResult
+
+// ArchiveStats totals an Archive or ArchiveTo run.
+type ArchiveStats struct {
+	// Entries is every entry written: Files plus noise and bait.
+	Entries      int
+	Files        int
+	NoiseEntries int
+	BaitEntries  int
+	// InputBytes is the source data read; DataBytes what it takes in the
+	// archive.
+	InputBytes int64
+	DataBytes  int64
+	NoiseBytes int64
+	BaitBytes  int64
+	// Duplicates counts the files Dedup stored as references to the data
+	// of an identical file, and DuplicateBytes the data that saved.
+	Duplicates     int
+	DuplicateBytes int64
+	OutputBytes    int64
+	Duration       time.Duration
+	// Results lists every entry in archive order with its method, sizes,
+	// CRC and offset, noise and bait marked as such.
+	Results []EntryResult
+}
+
+// Archive writes the files under src to the ZIP archive out.
+func Archive(ctx context.Context, src, out string, opts *ArchiveOptions) (ArchiveStats, error) {
+	cfg := archiveConfig(src, out, opts)
+	s, err := core.RunEncryptStats(ctx, cfg, opts.progress(), opts.log())
+	return archiveStats(s), err
+}
+
+// ArchiveTo writes the files under src as a ZIP archive to w, such as a
+// buffer, a socket or an upload. The archive is written front to back, so w
+// need not seek; it is not closed, and holds part of an archive after a
+// failure.
+func ArchiveTo(ctx context.Context, src string, w io.Writer, opts *ArchiveOptions) (ArchiveStats, error) {
+	cfg := archiveConfig(src, "", opts)
+	s, err := core.RunEncryptTo(ctx, w, cfg, opts.progress(), opts.log())
+	return archiveStats(s), err
+}
+
+// Writer builds an archive entry by entry, from files or readers, with
+// AddFile, AddReader and AddNoise; Close writes it. Close must always be
+// called, as it removes the staged entries.
+type Writer = core.Writer
+
+// NewWriter starts an archive written to w when the Writer is closed. Of
+// opts, the source options, Workers, NoiseFiles, the hooks, Progress, Log
+// and Events are not used; Logger is.
+func NewWriter(ctx context.Context, w io.Writer, opts *ArchiveOptions) (*Writer, error) {
+	return core.NewWriter(ctx, w, archiveConfig("", "", opts))
+}
+
+// ValidateOptions checks opts as Archive would before starting, and
+// reports every problem at once, joined; each matches ErrInvalidConfig.
+// Paths are not checked.
+func ValidateOptions(opts *ArchiveOptions) error {
+	return core.ValidateConfig(archiveConfig("", "", opts))
+}
+
+func archiveConfig(src, out string, opts *ArchiveOptions) core.Config {
+	if opts == nil {
+		opts = &ArchiveOptions{}
+	}
+	cfg := core.Config{
+		SrcDir:              src,
+		OutZip:              out,
+		Compression:         orDefault(opts.Compression, "deflate"),
+		Encoding:            orDefault(opts.Encoding, "utf-8"),
+		OverwriteCentralDir: !opts.KeepCentralDir,
+		CommentSize:         opts.CommentSize,
+		FixedTime:           opts.FixedTime,
+		NoiseFiles:          opts.NoiseFiles,
+		NoiseSize:           opts.NoiseSize,
+		NoiseGenerator:      orDefault(opts.NoiseGenerator, core.DefaultNoiseGenerator),
+		Level:               opts.Level,
+		Strategy:            "default",
+		DictSize:            32768,
+		Workers:             opts.Workers,
+		IncludeHidden:       opts.IncludeHidden,
+		MaxDepth:            opts.MaxDepth,
+		Files:               opts.Files,
+		Bait:                opts.Bait,
+		Dedup:               opts.Dedup,
+		PadNames:            opts.PadNames,
+		PadBucket:           opts.PadBucket,
+		ReportPath:          opts.Report,
+		ReportPassword:      opts.ReportPassword,
+		TmpDir:              opts.TmpDir,
+		MemEntrySize:        opts.MemEntrySize,
+		MemTotal:            opts.MemTotal,
+		BufferSize:          opts.BufferSize,
+		BeforeEntry:         opts.BeforeEntry,
+		AfterEntry:          opts.AfterEntry,
+		Events:              opts.Events,
+		Logger:              opts.Logger,
+		Metrics:             opts.Metrics,
+		Rand:                opts.Rand,
+		Resume:              opts.Resume,
+	}
+	switch cfg.Level {
+	case 0:
+		cfg.Level = 6
+	case LevelNone:
+		cfg.Level = 0
+	}
+	switch {
+	case cfg.MemEntrySize == 0:
+		cfg.MemEntrySize = core.DefaultMemEntrySize
+	case cfg.MemEntrySize < 0:
+		cfg.MemEntrySize = 0
+	}
+	if cfg.MemTotal == 0 {
+		cfg.MemTotal = core.DefaultMemTotal
+	}
+	if opts.Seed != nil {
+		cfg.Seed = *opts.Seed
+		cfg.HasSeed = true
+	}
+	return cfg
+}
+
+func archiveStats(s core.EncryptStats) ArchiveStats {
+	return ArchiveStats{
+		Entries:        s.Entries,
+		Files:          s.Files,
+		NoiseEntries:   s.NoiseEntries,
+		BaitEntries:    s.BaitEntries,
+		InputBytes:     s.InputBytes,
+		DataBytes:      s.DataBytes,
+		NoiseBytes:     s.NoiseBytes,
+		BaitBytes:      s.BaitBytes,
+		Duplicates:     s.Duplicates,
+		DuplicateBytes: s.DuplicateBytes,
+		OutputBytes:    s.OutputBytes,
+		Duration:       s.Duration,
+		Results:        s.Results,
+	}
+}
+
+func (opts *ArchiveOptions) progress() func(done, total int, name string) {
+	if opts == nil {
+		return nil
+	}
+	return opts.Progress
+}
+
+func (opts *ArchiveOptions) log() func(msg string) {
+	if opts == nil {
+		return nil
+	}
+	return opts.Log
+}
+
+func orDefault(val, def string) string {
+	if val == "" {
+		return def
+	}
+	return val
+}
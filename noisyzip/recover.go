@@ -0,0 +1,275 @@
+package noisyzip
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/chekomaid/NoisyZip/internal/core"
+)
+
+// Entry statuses reported in Entry.Status.
+const (
+	StatusOK        = core.EntryOK
+	StatusJunk      = core.EntryJunk
+	StatusSkipped   = core.EntrySkipped
+	StatusFailed    = core.EntryFailed
+	StatusInvalid   = core.EntryInvalid
+	StatusTruncated = core.EntryTruncated
+	StatusNested    = core.EntryNested
+	StatusDir       = core.EntryDir
+)
+
+// RecoverOptions tune Recover, Extract and List.
+type RecoverOptions struct {
+	// Password decrypts ZipCrypto and WinZip AES entries.
+	Password string
+	// Encodings are the charsets tried, in order, for names without the
+	// UTF-8 flag; nil means the defaults.
+	Encodings []string
+	// Only and Skip filter entries by path glob, ** matching directories:
+	// an entry is kept when it matches an Only pattern, or Only is empty,
+	// and no Skip pattern.
+	Only []string
+	Skip []string
+	// Junk are the globs of noise entries, which are never written; nil
+	// means the patterns of the noise Archive writes.
+	Junk []string
+	// ForceScan ignores the central directory and scans for local headers.
+	ForceScan bool
+	// Strict accepts only entries with a verified CRC and a name that
+	// needed no charset guess.
+	Strict bool
+	// UnpadNames strips the padding ArchiveOptions.PadNames adds.
+	UnpadNames bool
+	// Recurse recovers nested archives up to this depth.
+	Recurse int
+	// Order is "offset" (the default) or "name".
+	Order string
+	// PathPolicy handles .. components and absolute names: "strip" (the
+	// default), "replace" or "reject".
+	PathPolicy string
+	// MaxMemory caps an entry decoded in memory; bigger ones go to disk.
+	// MaxEntrySize, MaxTotalSize and MaxRatio abandon archive bombs. Zero
+	// means no limit.
+	MaxMemory    int64
+	MaxEntrySize int64
+	MaxTotalSize int64
+	MaxRatio     float64
+
+	// Compression, Level and Workers are used to rebuild the ZIP in
+	// Recover, as in ArchiveOptions.
+	Compression string
+	Level       int
+	Workers     int
+	// TmpDir holds the files Recover rebuilds the ZIP from; "" means the
+	// OS temp directory.
+	TmpDir string
+
+	// Progress, when set, is called as each entry is read.
+	Progress func(done, total int, name string)
+	// Log, when set, receives a line per notable step.
+	Log func(msg string)
+	// Logger, when set, receives the same steps as leveled records with
+	// attributes, plus debug records per entry; Log is then not called.
+	Logger *slog.Logger
+	// Metrics, when set, receives counters and timings: entries tried,
+	// failed and bytes decoded, run times.
+	Metrics Metrics
+}
+
+// Entry is one entry found in an archive.
+type Entry struct {
+	// Name is the name stored in the archive, Path where the file goes.
+	Name     string
+	Path     string
+	Offset   int64
+	Size     int64
+	CRC      uint32
+	Modified time.Time
+	// Status is one of the Status constants; Error says why for failed
+	// ones.
+	Status string
+	Error  string
+	// Confidence rates a decoded entry from 0 to 100.
+	Confidence int
+}
+
+// RecoverResult reports what Recover, Extract or List found.
+type RecoverResult struct {
+	// Source is "central-directory" or "scan".
+	Source    string
+	Recovered int
+	Junk      int
+	Skipped   int
+	Failed    int
+	Truncated int
+	Entries   []Entry
+}
+
+// List reads the archive in and reports its entries without writing
+// anything.
+func List(ctx context.Context, in string, opts *RecoverOptions) (RecoverResult, error) {
+	cfg := recoverConfig(in, opts)
+	cfg.ListOnly = true
+	rep, err := core.RecoverZipContext(ctx, cfg, opts.progress(), opts.log())
+	return recoverResult(rep), err
+}
+
+// Extract writes the real files of the archive in under dir.
+func Extract(ctx context.Context, in, dir string, opts *RecoverOptions) (RecoverResult, error) {
+	cfg := recoverConfig(in, opts)
+	cfg.OutDir = dir
+	rep, err := core.RecoverZipContext(ctx, cfg, opts.progress(), opts.log())
+	return recoverResult(rep), err
+}
+
+// MemoryFile is a file recovered into memory by RecoverFiles.
+type MemoryFile struct {
+	Entry
+	Data []byte
+	// Mode is the permission bits the archive recorded, 0 when none.
+	Mode fs.FileMode
+}
+
+// RecoverFiles returns the real files of the archive in, decoded into
+// memory, without writing anything to disk. Every file is held whole, so
+// MaxMemory does not apply; MaxEntrySize and MaxTotalSize bound it.
+func RecoverFiles(ctx context.Context, in string, opts *RecoverOptions) ([]MemoryFile, RecoverResult, error) {
+	entries, rep, err := core.RecoverEntries(ctx, recoverConfig(in, opts), opts.progress(), opts.log())
+	files := make([]MemoryFile, len(entries))
+	for i, ent := range entries {
+		files[i] = MemoryFile{Entry: publicEntry(ent.RecoveredEntry), Data: ent.Data, Mode: ent.Mode}
+	}
+	return files, recoverResult(rep), err
+}
+
+// RecoverFS is RecoverFiles with the files served as an fs.FS by their
+// recovered paths, for fs.WalkDir, http.FS, template.ParseFS and the like.
+func RecoverFS(ctx context.Context, in string, opts *RecoverOptions) (fs.FS, RecoverResult, error) {
+	fsys, rep, err := core.RecoverToFS(ctx, recoverConfig(in, opts), opts.progress(), opts.log())
+	return fsys, recoverResult(rep), err
+}
+
+// Recover writes the real files of the archive in as a clean ZIP, out.
+func Recover(ctx context.Context, in, out string, opts *RecoverOptions) (RecoverResult, error) {
+	if opts == nil {
+		opts = &RecoverOptions{}
+	}
+	dir, err := os.MkdirTemp(opts.TmpDir, "zip-recover-*")
+	if err != nil {
+		return RecoverResult{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := recoverConfig(in, opts)
+	cfg.OutDir = dir
+	rep, err := core.RecoverZipContext(ctx, cfg, opts.progress(), opts.log())
+	if err != nil {
+		return recoverResult(rep), err
+	}
+	rebuild := core.Config{
+		SrcDir:      dir,
+		OutZip:      out,
+		Compression: orDefault(opts.Compression, "deflate"),
+		Encoding:    "utf-8",
+		Level:       opts.Level,
+		Strategy:    "default",
+		DictSize:    32768,
+		Workers:     opts.Workers,
+		TmpDir:      opts.TmpDir,
+		FileOrder:   rep.RecoveredPaths(),
+		Logger:      opts.Logger,
+		Metrics:     opts.Metrics,
+	}
+	if rebuild.Level == 0 {
+		rebuild.Level = 6
+	}
+	_, err = core.RunEncryptContext(ctx, rebuild, nil, opts.log())
+	return recoverResult(rep), err
+}
+
+func recoverConfig(in string, opts *RecoverOptions) core.RecoverConfig {
+	if opts == nil {
+		opts = &RecoverOptions{}
+	}
+	return core.RecoverConfig{
+		InZip:        in,
+		Password:     opts.Password,
+		Encodings:    opts.Encodings,
+		Only:         opts.Only,
+		Skip:         opts.Skip,
+		Junk:         opts.Junk,
+		ForceScan:    opts.ForceScan,
+		Strict:       opts.Strict,
+		UnpadNames:   opts.UnpadNames,
+		Recurse:      opts.Recurse,
+		Order:        opts.Order,
+		PathPolicy:   opts.PathPolicy,
+		MaxMemory:    opts.MaxMemory,
+		MaxEntrySize: opts.MaxEntrySize,
+		MaxTotalSize: opts.MaxTotalSize,
+		MaxRatio:     opts.MaxRatio,
+		Logger:       opts.Logger,
+		Metrics:      opts.Metrics,
+	}
+}
+
+func (opts *RecoverOptions) progress() func(done, total int, name string) {
+	if opts == nil {
+		return nil
+	}
+	return opts.Progress
+}
+
+func (opts *RecoverOptions) log() func(msg string) {
+	if opts == nil {
+		return nil
+	}
+	return opts.Log
+}
+
+func recoverResult(rep core.RecoverReport) RecoverResult {
+	res := RecoverResult{
+		Source:    rep.Source,
+		Recovered: rep.Recovered,
+		Junk:      rep.Junk,
+		Skipped:   rep.Skipped,
+		Failed:    rep.Failed,
+		Truncated: rep.Truncated,
+	}
+	for _, ent := range rep.Entries {
+		res.Entries = append(res.Entries, publicEntry(ent))
+	}
+	return res
+}
+
+func publicEntry(ent core.RecoveredEntry) Entry {
+	return Entry{
+		Name:       ent.Name,
+		Path:       ent.Path,
+		Offset:     ent.Offset,
+		Size:       ent.Size,
+		CRC:        ent.CRC,
+		Modified:   ent.Modified,
+		Status:     ent.Status,
+		Error:      ent.Error,
+		Confidence: ent.Confidence,
+	}
+}
+
+// Reader reads single files out of a noisy archive without extracting the
+// rest: Entries lists the real files and Open decodes one of them. Set
+// Password for encrypted entries, and Close it when done.
+type Reader = core.NoisyReader
+
+// ReaderEntry is one real file of a Reader.
+type ReaderEntry = core.NoisyEntry
+
+// OpenReader opens the archive and indexes its real files, found as
+// Recover finds them. The archive is read by offset, never whole.
+func OpenReader(in string) (*Reader, error) {
+	return core.OpenNoisy(in)
+}
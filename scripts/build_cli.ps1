@@ -4,7 +4,7 @@ param(
 )
 
 $ErrorActionPreference = "Stop"
-$ldflags = "-X noisyzip/internal/cli.Version=2.1"
+$ldflags = "-X github.com/chekomaid/NoisyZip/internal/cli.Version=2.1"
 
 function Resolve-RepoRoot {
     $root = Join-Path $PSScriptRoot ".."
@@ -3,7 +3,7 @@ param(
 )
 
 $ErrorActionPreference = "Stop"
-$versionLdflags = "-X noisyzip/internal/cli.Version=2.1"
+$versionLdflags = "-X github.com/chekomaid/NoisyZip/internal/cli.Version=2.1"
 
 function Has-WailsOutputFlag {
     param([string[]]$Args)